@@ -1,20 +1,39 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
+	"log/slog"
+	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
+	"time"
 
 	"github.com/scanserver/scanner-service/internal/api"
+	"github.com/scanserver/scanner-service/internal/audit"
+	"github.com/scanserver/scanner-service/internal/auth"
+	"github.com/scanserver/scanner-service/internal/certs"
 	"github.com/scanserver/scanner-service/internal/config"
+	"github.com/scanserver/scanner-service/internal/cors"
 	"github.com/scanserver/scanner-service/internal/escl"
+	"github.com/scanserver/scanner-service/internal/export"
+	"github.com/scanserver/scanner-service/internal/hotfolder"
+	"github.com/scanserver/scanner-service/internal/logging"
 	"github.com/scanserver/scanner-service/internal/scanner"
+	"github.com/scanserver/scanner-service/internal/scheduler"
+	"github.com/scanserver/scanner-service/internal/storage"
+	"github.com/scanserver/scanner-service/internal/tracing"
 	"github.com/scanserver/scanner-service/pkg/models"
 )
 
+// version is reported in logs and traces
+const version = "1.0.0"
+
 var (
 	configFile = flag.String("config", "", "Path to configuration file")
 	host       = flag.String("host", "0.0.0.0", "Server host")
@@ -30,14 +49,41 @@ func main() {
 		log.Fatalf("Failed to load configuration: %v", err)
 	}
 
-	log.Println("Starting Scanner Service...")
-	log.Printf("Version: 1.0.0")
-	log.Printf("Platform: %s", getPlatform())
+	// Structured logger; the log package, as used by dependencies, writes through it too
+	logger, err := logging.New(&cfg.Log, os.Stderr)
+	if err != nil {
+		log.Fatalf("Invalid log configuration: %v", err)
+	}
+	slog.SetDefault(logger)
+
+	logger.Info("Starting Scanner Service", "version", version, "platform", getPlatform())
+
+	// Traces of requests and jobs, exported to an OTLP collector
+	shutdownTracing, err := tracing.Setup(context.Background(), &cfg.Tracing, version)
+	if err != nil {
+		fatal("Invalid tracing configuration", err)
+	}
+	if cfg.Tracing.Enabled {
+		logger.Info("Tracing enabled", "endpoint", cfg.Tracing.Endpoint, "sample_ratio", cfg.Tracing.SampleRatio)
+	}
+
+	// Create storage directory if it doesn't exist
+	if err := os.MkdirAll(cfg.Storage.OutputDir, 0755); err != nil {
+		fatal("Failed to create storage directory", err)
+	}
+	fileStore := storage.NewFileStore(cfg.Storage.OutputDir)
+
+	// Backend completed jobs are archived to
+	archive, err := storage.NewStorage(&cfg.Storage)
+	if err != nil {
+		fatal("Failed to initialize storage backend", err)
+	}
+	logger.Info("Storage backend ready", "backend", archive.Name())
 
 	// Initialize scanner manager
-	scannerManager, err := scanner.NewManager()
+	scannerManager, err := scanner.NewManager(fileStore, archive, logger)
 	if err != nil {
-		log.Fatalf("Failed to initialize scanner manager: %v", err)
+		fatal("Failed to initialize scanner manager", err)
 	}
 	defer scannerManager.Close()
 
@@ -45,15 +91,65 @@ func main() {
 	wsHub := api.NewWebSocketHub()
 	go wsHub.Run()
 
+	// Remote folders completed jobs can be exported to
+	exports, err := export.NewManager(&cfg.Export, logger)
+	if err != nil {
+		fatal("Failed to initialize export destinations", err)
+	}
+	if names := exports.Names(); len(names) > 0 {
+		logger.Info("Export destinations configured", "destinations", strings.Join(names, ", "))
+	}
+
+	// API keys and tokens accepted from clients
+	authenticator, err := auth.NewAuthenticator(&cfg.Auth, logger)
+	if err != nil {
+		fatal("Invalid auth configuration", err)
+	}
+	if authenticator.Enabled() {
+		logger.Info("Authentication enabled", "api_keys", len(cfg.Auth.APIKeys), "bearer_tokens", cfg.Auth.JWT.Secret != "")
+	} else {
+		logger.Warn("Authentication is disabled, the API is open to anyone who can reach it")
+	}
+
+	// HTTPS, with a self-signed certificate unless one is configured
+	tlsConfig, err := certs.ServerConfig(&cfg.Server.TLS, logger)
+	if err != nil {
+		fatal("Invalid server configuration", err)
+	}
+
+	// Browser origins allowed to call the API
+	corsPolicy, err := cors.NewPolicy(&cfg.Server.CORS)
+	if err != nil {
+		fatal("Invalid server configuration", err)
+	}
+
+	// Append-only record of who scanned, downloaded and deleted what
+	auditLog, err := audit.Open(&cfg.Audit)
+	if err != nil {
+		fatal("Failed to open audit log", err)
+	}
+	if auditLog != nil {
+		logger.Info("Audit log enabled", "file", cfg.Audit.File)
+	}
+
 	// Create API server
-	apiServer := api.NewServer(scannerManager, wsHub)
+	apiServer := api.NewServer(scannerManager, wsHub, exports, authenticator, corsPolicy, auditLog, logger, cfg)
 	apiServer.AddWebSocketRoute()
 
 	// Create eSCL server if enabled
 	if cfg.Server.ESCLEnabled {
-		esclServer := escl.NewESCLServer(scannerManager)
-		esclServer.RegisterRoutes(apiServer.Router())
-		log.Println("eSCL protocol support enabled")
+		esclServer := escl.NewESCLServer(scannerManager, scanner.ParseValidationMode(cfg.Scanner.ParamValidation), logger)
+		esclServer.RegisterRoutes(apiServer.Router(), authenticator.BasicMiddleware())
+		logger.Info("eSCL protocol support enabled")
+
+		if cfg.Server.ESCLAdvertise {
+			stopAdvertising, err := esclServer.Advertise(context.Background(), cfg.Server.Port, tlsConfig != nil)
+			if err != nil {
+				logger.Warn("Failed to advertise eSCL via mDNS", logging.Err(err))
+			} else {
+				defer stopAdvertising()
+			}
+		}
 	}
 
 	// Initialize auto-scan if enabled
@@ -67,54 +163,125 @@ func main() {
 				msg := models.WebSocketMessage{
 					Type:    "job_status",
 					Payload: job,
+					Job:     job,
 				}
 				wsHub.Broadcast(msg)
+
+				// Lid-close scans have no client, so they are audited as the system's
+				if job.CompletedAt != nil {
+					auditLog.Record(context.Background(), audit.Event{
+						Action:    audit.ActionScan,
+						JobID:     job.ID,
+						ScannerID: job.ScannerID,
+						Status:    job.Status,
+						Pages:     len(job.Results),
+						Detail:    "auto-scan",
+					})
+				}
 			},
+			logger,
 		)
 
 		err = autoScanManager.Start()
 		if err != nil {
-			log.Printf("Warning: Failed to start auto-scan: %v", err)
+			logger.Warn("Failed to start auto-scan", logging.Err(err))
 		} else {
-			log.Println("Auto-scan (lid close detection) enabled")
+			logger.Info("Auto-scan (lid close detection) enabled")
 		}
-		defer autoScanManager.Stop()
 	}
 
-	// Create storage directory if it doesn't exist
-	if err := os.MkdirAll(cfg.Storage.OutputDir, 0755); err != nil {
-		log.Fatalf("Failed to create storage directory: %v", err)
+	// Import files dropped into the hot folder
+	hotFolder := hotfolder.NewWatcher(&cfg.HotFolder, apiServer.ImportFile, logger)
+	if err := hotFolder.Start(); err != nil {
+		logger.Warn("Failed to start hot folder", logging.Err(err))
+	}
+
+	// Run scheduled scans
+	schedules, err := scheduler.NewScheduler(&cfg.Scheduler, apiServer.RunSchedule, logger)
+	if err != nil {
+		fatal("Invalid scheduler configuration", err)
 	}
+	apiServer.SetScheduler(schedules)
+	schedules.Start()
+
+	// Enforce retention period and storage quota
+	janitor := apiServer.Janitor()
+	janitor.Start()
+	defer janitor.Stop()
+
+	// Deliver job events to webhooks
+	webhooks := apiServer.Webhooks()
+	webhooks.Start()
+	defer webhooks.Stop()
 
 	// Start server
 	addr := fmt.Sprintf("%s:%d", cfg.Server.Host, cfg.Server.Port)
-	log.Printf("Server starting on http://%s", addr)
-	log.Printf("Web dashboard: http://%s/", addr)
-	log.Printf("API endpoint: http://%s/api/v1", addr)
-	log.Printf("WebSocket: ws://%s/ws", addr)
-
+	scheme, wsScheme := "http", "ws"
+	if tlsConfig != nil {
+		scheme, wsScheme = "https", "wss"
+	}
+	endpoints := []any{
+		"dashboard", fmt.Sprintf("%s://%s/", scheme, addr),
+		"api", fmt.Sprintf("%s://%s/api/v1", scheme, addr),
+		"websocket", fmt.Sprintf("%s://%s/ws", wsScheme, addr),
+	}
 	if cfg.Server.ESCLEnabled {
-		log.Printf("eSCL endpoint: http://%s/eSCL", addr)
+		endpoints = append(endpoints, "escl", fmt.Sprintf("%s://%s/eSCL", scheme, addr))
+	}
+	logger.Info("Server starting", endpoints...)
+	if tlsConfig != nil && cfg.Server.TLS.RedirectPort > 0 {
+		logger.Info("Redirecting HTTP to HTTPS", "from", fmt.Sprintf("http://%s:%d", cfg.Server.Host, cfg.Server.TLS.RedirectPort))
 	}
 
 	// Handle graceful shutdown
+	stopped := make(chan struct{})
 	go func() {
 		sigChan := make(chan os.Signal, 1)
 		signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
 		<-sigChan
 
-		log.Println("Shutting down server...")
+		logger.Info("Shutting down server")
+
+		// Stop starting jobs, then let the running ones finish. The hot
+		// folder and scheduler are only stopped here, before Shutdown waits
+		// for their jobs.
 		if autoScanManager != nil {
 			autoScanManager.Stop()
 		}
-		scannerManager.Close()
-		os.Exit(0)
+		hotFolder.Stop()
+		schedules.Stop()
+
+		timeout := time.Duration(cfg.Server.ShutdownTimeout) * time.Second
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		if err := apiServer.Shutdown(ctx); err != nil {
+			logger.Error("Error during shutdown", logging.Err(err))
+		}
+		if err := auditLog.Close(); err != nil {
+			logger.Error("Failed to close audit log", logging.Err(err))
+		}
+
+		// Export the spans of the last jobs
+		flushCtx, cancelFlush := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancelFlush()
+		if err := shutdownTracing(flushCtx); err != nil {
+			logger.Warn("Failed to export remaining traces", logging.Err(err))
+		}
+		close(stopped)
 	}()
 
 	// Run server
-	if err := apiServer.Run(addr); err != nil {
-		log.Fatalf("Server failed: %v", err)
+	if err := apiServer.Run(addr, tlsConfig); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		fatal("Server failed", err)
 	}
+	<-stopped
+	logger.Info("Server stopped")
+}
+
+// fatal logs err and exits, like log.Fatal for the structured logger
+func fatal(msg string, err error) {
+	slog.Error(msg, logging.Err(err))
+	os.Exit(1)
 }
 
 func loadConfig() (*config.Config, error) {
@@ -0,0 +1,49 @@
+package api
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/scanserver/scanner-service/internal/auth"
+	"github.com/scanserver/scanner-service/pkg/models"
+)
+
+// canSeeJob reports whether a client may read a job and its pages: admins
+// see every job, everyone else only the jobs they own
+func canSeeJob(p *auth.Principal, job *models.ScanJob) bool {
+	return p.IsAdmin() || (job.Owner != "" && job.Owner == p.Owner())
+}
+
+// canChangeJob reports whether a client may cancel, continue, edit, email or
+// export a job
+func canChangeJob(p *auth.Principal, job *models.ScanJob) bool {
+	return p.CanScan() && (p.IsAdmin() || (job.Owner != "" && job.Owner == p.Owner()))
+}
+
+// ownerOf returns the owner of jobs started by the request's client
+func ownerOf(c *gin.Context) string {
+	return auth.FromContext(c).Owner()
+}
+
+// allowScan checks that the client may scan on a scanner.
+// It writes the error response and returns false if the request must be rejected.
+func allowScan(c *gin.Context, scannerID string) bool {
+	p := auth.FromContext(c)
+	if !p.CanScan() {
+		auth.Forbid(c, "operator role required")
+		return false
+	}
+	if !p.CanUseScanner(scannerID) {
+		auth.Forbid(c, "scanner "+scannerID+" is not assigned to you")
+		return false
+	}
+	return true
+}
+
+// allowJobChange checks that the client may change a job it can see.
+// It writes the error response and returns false if the request must be rejected.
+func allowJobChange(c *gin.Context, job *models.ScanJob) bool {
+	if !canChangeJob(auth.FromContext(c), job) {
+		auth.Forbid(c, "only the job's owner or an admin can change it")
+		return false
+	}
+	return true
+}
@@ -0,0 +1,50 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/scanserver/scanner-service/internal/auth"
+	"github.com/scanserver/scanner-service/pkg/models"
+)
+
+func TestJobAccess(t *testing.T) {
+	admin := &auth.Principal{Name: "root", Method: auth.MethodAPIKey, Role: auth.RoleAdmin}
+	aliceKey := &auth.Principal{Name: "alice", Method: auth.MethodAPIKey, Role: auth.RoleOperator, Scanners: []string{"scanner-1"}}
+	aliceJWT := &auth.Principal{Name: "alice", Method: auth.MethodJWT, Role: auth.RoleOperator, Scanners: []string{"scanner-1"}}
+	viewer := &auth.Principal{Name: "bob", Method: auth.MethodJWT, Role: auth.RoleViewer, Scanners: []string{"scanner-1"}}
+
+	if got := aliceKey.Owner(); got != "key:alice" {
+		t.Errorf("API key owner = %s", got)
+	}
+	if got := aliceJWT.Owner(); got != "jwt:alice" {
+		t.Errorf("token owner = %s", got)
+	}
+
+	tests := []struct {
+		name      string
+		principal *auth.Principal
+		owner     string
+		see       bool
+		change    bool
+	}{
+		{"auth disabled", nil, "", true, true},
+		{"admin, unowned job", admin, "", true, true},
+		{"admin, other's job", admin, "key:alice", true, true},
+		{"owner", aliceKey, "key:alice", true, true},
+		{"token with the name of a key", aliceJWT, "key:alice", false, false},
+		{"operator, unowned job", aliceKey, "", false, false},
+		{"operator, other's job on an assigned scanner", aliceKey, "jwt:bob", false, false},
+		{"viewer, own job", viewer, "jwt:bob", true, false},
+		{"viewer, other's job on an assigned scanner", viewer, "key:alice", false, false},
+		{"viewer, unowned job on an assigned scanner", viewer, "", false, false},
+	}
+	for _, tt := range tests {
+		job := &models.ScanJob{ID: "job-1", ScannerID: "scanner-1", Owner: tt.owner}
+		if got := canSeeJob(tt.principal, job); got != tt.see {
+			t.Errorf("%s: canSeeJob = %v, want %v", tt.name, got, tt.see)
+		}
+		if got := canChangeJob(tt.principal, job); got != tt.change {
+			t.Errorf("%s: canChangeJob = %v, want %v", tt.name, got, tt.change)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/scanserver/scanner-service/internal/audit"
+	"github.com/scanserver/scanner-service/internal/auth"
+	"github.com/scanserver/scanner-service/internal/config"
+)
+
+func TestListAuditEvents(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Auth = config.AuthConfig{Enabled: true, APIKeys: []config.APIKey{
+		{Name: "admin", Key: "admin-key", Role: auth.RoleAdmin},
+		{Name: "portal", Key: "portal-key", Role: auth.RoleOperator},
+		{Name: "display", Key: "display-key", Role: auth.RoleViewer},
+	}}
+	s := newTestServer(t, cfg)
+	log, err := audit.Open(&config.AuditConfig{Enabled: true, File: filepath.Join(t.TempDir(), "audit.log")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer log.Close()
+	s.audit = log
+
+	get := func(key, query string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/api/v1/audit"+query, nil)
+		if key != "" {
+			r.Header.Set("X-API-Key", key)
+		}
+		w := httptest.NewRecorder()
+		s.Router().ServeHTTP(w, r)
+		return w
+	}
+
+	// Requests are audited with the client that made them
+	job := addCompletedJob(t, s, "job-1", 2)
+	for _, format := range []string{"zip", "pdf"} {
+		r := httptest.NewRequest(http.MethodGet, "/api/v1/jobs/"+job.ID+"/download?format="+format, nil)
+		r.Header.Set("X-API-Key", "admin-key")
+		w := httptest.NewRecorder()
+		s.Router().ServeHTTP(w, r)
+		if w.Code != http.StatusOK {
+			t.Fatalf("download %s: status %d", format, w.Code)
+		}
+	}
+	s.audit.Record(s.ctx, audit.Event{Action: audit.ActionDelete, Status: "deleted", Files: []string{"old.png"}})
+
+	w := get("admin-key", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	var body struct{ Events []audit.Event }
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if len(body.Events) != 3 {
+		t.Fatalf("%d events, want 3", len(body.Events))
+	}
+	if e := body.Events[0]; e.Action != audit.ActionDelete || e.Actor != audit.ActorSystem {
+		t.Errorf("newest event = %+v", e)
+	}
+	if e := body.Events[1]; e.Action != audit.ActionDownload || e.Actor != "admin" || e.AuthMethod != auth.MethodAPIKey ||
+		e.JobID != job.ID || e.Pages != 2 || e.Detail != "pdf" || e.ClientIP == "" {
+		t.Errorf("download event = %+v", e)
+	}
+
+	for _, tt := range []struct {
+		query string
+		count int
+	}{
+		{"?user=admin", 2},
+		{"?user=system", 1},
+		{"?action=download&job_id=job-1", 2},
+		{"?job_id=job-2", 0},
+		{"?scanner_id=scanner-001", 2},
+		{"?limit=1", 1},
+		{"?until=2000-01-01T00:00:00Z", 0},
+		{"?since=2000-01-01T00:00:00Z", 3},
+	} {
+		w := get("admin-key", tt.query)
+		var body struct{ Events []audit.Event }
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil || w.Code != http.StatusOK {
+			t.Errorf("%s: status %d, %v", tt.query, w.Code, err)
+			continue
+		}
+		if len(body.Events) != tt.count {
+			t.Errorf("%s: %d events, want %d", tt.query, len(body.Events), tt.count)
+		}
+	}
+
+	for _, query := range []string{"?since=yesterday", "?until=2024-01-31", "?limit=-1", "?limit=ten"} {
+		if w := get("admin-key", query); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", query, w.Code)
+		}
+	}
+
+	// The trail is for admins only
+	for key, status := range map[string]int{"portal-key": http.StatusForbidden, "display-key": http.StatusForbidden, "": http.StatusUnauthorized} {
+		if w := get(key, ""); w.Code != status {
+			t.Errorf("key %q: status %d, want %d", key, w.Code, status)
+		}
+	}
+
+	s.audit = nil
+	if w := get("admin-key", ""); w.Code != http.StatusNotFound {
+		t.Errorf("audit disabled: status %d, want 404", w.Code)
+	}
+}
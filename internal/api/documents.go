@@ -0,0 +1,316 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/scanserver/scanner-service/internal/audit"
+	"github.com/scanserver/scanner-service/internal/auth"
+	"github.com/scanserver/scanner-service/internal/document"
+	"github.com/scanserver/scanner-service/internal/logging"
+	"github.com/scanserver/scanner-service/internal/storage"
+	"github.com/scanserver/scanner-service/internal/webhook"
+	"github.com/scanserver/scanner-service/pkg/models"
+)
+
+// Page editing never modifies a job. Each edit creates a new job (a new
+// version of the document) with its own copies of the pages, so the
+// original scan stays available.
+
+// reorderPages creates a version of a job with its pages in a new order
+func (s *Server) reorderPages(c *gin.Context) {
+	var req struct {
+		Order []int `json:"order" binding:"required"` // 1-based page numbers in the new order
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	s.editPages(c, func(pages []document.PageEdit) ([]document.PageEdit, error) {
+		return document.Reorder(pages, req.Order)
+	})
+}
+
+// rotatePages creates a version of a job with some pages rotated
+func (s *Server) rotatePages(c *gin.Context) {
+	var req struct {
+		Pages   []int `json:"pages"`   // 1-based page numbers, empty = all pages
+		Degrees int   `json:"degrees"` // Clockwise, multiple of 90
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	s.editPages(c, func(pages []document.PageEdit) ([]document.PageEdit, error) {
+		numbers := req.Pages
+		if len(numbers) == 0 {
+			for i := range pages {
+				numbers = append(numbers, i+1)
+			}
+		}
+		return document.Rotate(pages, numbers, req.Degrees)
+	})
+}
+
+// deletePages creates a version of a job without some pages
+func (s *Server) deletePages(c *gin.Context) {
+	var req struct {
+		Pages []int `json:"pages" binding:"required"` // 1-based page numbers
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	s.editPages(c, func(pages []document.PageEdit) ([]document.PageEdit, error) {
+		return document.Delete(pages, req.Pages)
+	})
+}
+
+// interleaveDocuments merges two simplex feeder passes (fronts, then backs)
+// into one duplex document
+func (s *Server) interleaveDocuments(c *gin.Context) {
+	var req struct {
+		FrontJobID   string `json:"front_job_id" binding:"required"`
+		BackJobID    string `json:"back_job_id" binding:"required"`
+		ReverseBacks *bool  `json:"reverse_backs"` // Default true: backs were scanned last page first
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	fronts, ok := s.completedJob(c, req.FrontJobID)
+	if !ok {
+		return
+	}
+	backs, ok := s.completedJob(c, req.BackJobID)
+	if !ok {
+		return
+	}
+
+	reverse := req.ReverseBacks == nil || *req.ReverseBacks
+	pages, err := document.Interleave(document.Unchanged(fronts.Results), document.Unchanged(backs.Results), reverse)
+	if err != nil {
+		respondEditError(c, err)
+		return
+	}
+
+	job, err := s.createDocumentVersion(c.Request.Context(), fronts, []string{fronts.ID, backs.ID}, pages)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, job)
+}
+
+// editPages applies edit to the pages of the job in the URL and responds with the new version
+func (s *Server) editPages(c *gin.Context, edit func([]document.PageEdit) ([]document.PageEdit, error)) {
+	source, ok := s.completedJob(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	pages, err := edit(document.Unchanged(source.Results))
+	if err != nil {
+		respondEditError(c, err)
+		return
+	}
+
+	job, err := s.createDocumentVersion(c.Request.Context(), source, []string{source.ID}, pages)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, job)
+}
+
+// respondEditError rejects an edit: 422 for pages whose format cannot be
+// edited, 400 for invalid page numbers and orders
+func respondEditError(c *gin.Context, err error) {
+	if errors.Is(err, document.ErrUnsupportedFormat) {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+}
+
+// completedJob returns a snapshot of a completed job the client may change,
+// or writes an error response
+func (s *Server) completedJob(c *gin.Context, jobID string) (*models.ScanJob, bool) {
+	s.jobsMutex.RLock()
+	job, ok := s.jobs[jobID]
+	ok = ok && canSeeJob(auth.FromContext(c), job)
+	var snapshot models.ScanJob
+	if ok {
+		snapshot = *job
+		snapshot.Results = append([]models.ScanResult(nil), job.Results...)
+	}
+	s.jobsMutex.RUnlock()
+
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("job %s not found", jobID)})
+		return nil, false
+	}
+	if !allowJobChange(c, &snapshot) {
+		return nil, false
+	}
+	if snapshot.Status != "completed" || len(snapshot.Results) == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("job %s has no completed pages", jobID)})
+		return nil, false
+	}
+	return &snapshot, true
+}
+
+// createDocumentVersion writes the edited pages into a new job derived from
+// parent. The job is tracked like a scan, so shutdown waits for it.
+func (s *Server) createDocumentVersion(parentCtx context.Context, parent *models.ScanJob, sources []string, pages []document.PageEdit) (*models.ScanJob, error) {
+	// Refuse new versions when the disk is full
+	if err := s.janitor.CheckCapacity(); err != nil {
+		return nil, err
+	}
+
+	version := parent.Version
+	if version == 0 {
+		version = 1 // Original scan
+	}
+
+	job := &models.ScanJob{
+		ID:         models.GenerateUUID(),
+		ScannerID:  parent.ScannerID,
+		Status:     "processing",
+		Parameters: parent.Parameters,
+		Results:    []models.ScanResult{},
+		CreatedAt:  time.Now(),
+		ParentID:   parent.ID,
+		SourceJobs: sources,
+		Version:    version + 1,
+		Owner:      parent.Owner,
+	}
+
+	if err := s.addJob(job); err != nil {
+		return nil, err
+	}
+	defer s.running.Done()
+
+	store := s.scannerManager.Store()
+	ctx := s.jobContext(parentCtx, job)
+
+	results := make([]models.ScanResult, 0, len(pages))
+	for i, page := range pages {
+		result, err := writeEditedPage(ctx, store, page, parent.Parameters.JpegQuality)
+		if err != nil {
+			// Don't leave a half-written version behind
+			if dir, dirErr := store.JobDir(&storage.Job{ID: job.ID, CreatedAt: job.CreatedAt}); dirErr == nil {
+				os.RemoveAll(dir)
+			}
+			s.jobsMutex.Lock()
+			delete(s.jobs, job.ID)
+			s.jobsMutex.Unlock()
+			return nil, fmt.Errorf("failed to write page %d: %w", i+1, err)
+		}
+		result.PageNumber = i + 1
+		results = append(results, result)
+	}
+
+	s.archiveResults(ctx, job, results)
+
+	s.jobsMutex.Lock()
+	now := time.Now()
+	job.Status = "completed"
+	job.Progress = 100
+	job.CompletedAt = &now
+	job.Results = results
+	s.jobsMutex.Unlock()
+
+	logging.FromContext(ctx, s.logger).Info("Document version created",
+		"parent_job_id", parent.ID, "version", job.Version, "pages", len(results))
+	s.audit.Record(ctx, audit.Event{
+		Action:    audit.ActionEdit,
+		JobID:     job.ID,
+		ScannerID: job.ScannerID,
+		Status:    job.Status,
+		Pages:     len(results),
+		Detail:    fmt.Sprintf("version %d of job %s", job.Version, parent.ID),
+	})
+
+	s.broadcastJobUpdate(job)
+	snapshot := s.jobSnapshot(job)
+	s.webhooks.Send(webhook.EventJobCreated, snapshot, 0)
+	s.webhooks.Send(webhook.EventJobVersioned, snapshot, 0)
+	s.webhooks.Send(webhook.EventJobCompleted, snapshot, 0)
+	return job, nil
+}
+
+// writeEditedPage stores a copy of the source page, rotated if requested
+func writeEditedPage(ctx context.Context, store *storage.FileStore, page document.PageEdit, jpegQuality int) (models.ScanResult, error) {
+	src, err := store.Resolve(page.Source.FilePath)
+	if err != nil {
+		return models.ScanResult{}, err
+	}
+
+	dst, err := store.NextPagePath(ctx, page.Source.Format)
+	if err != nil {
+		return models.ScanResult{}, err
+	}
+
+	result := page.Source
+	result.FilePath = dst
+	result.StorageURI = ""
+
+	if page.Rotate != 0 {
+		if err := document.RotateImage(src, dst, page.Rotate, jpegQuality); err != nil {
+			return models.ScanResult{}, err
+		}
+		if page.Rotate != 180 {
+			result.Width, result.Height = result.Height, result.Width
+		}
+	} else if err := copyFile(src, dst); err != nil {
+		return models.ScanResult{}, err
+	}
+
+	info, err := os.Stat(dst)
+	if err != nil {
+		return models.ScanResult{}, err
+	}
+	result.FileSize = info.Size()
+
+	return result, nil
+}
+
+// copyFile copies src to dst. Pages are copied rather than hard-linked so
+// the new version gets its own modification time and is not evicted by the
+// janitor together with the original.
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	tmp := dst + ".tmp"
+	out, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, dst)
+}
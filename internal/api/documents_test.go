@@ -0,0 +1,51 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/scanserver/scanner-service/internal/config"
+	"github.com/scanserver/scanner-service/internal/storage"
+)
+
+// TestStorageFullRefusesNewJobs checks that document versions and hot
+// folder imports are refused, like scans, once the output directory is full
+func TestStorageFullRefusesNewJobs(t *testing.T) {
+	s := newTestServer(t, &config.Config{})
+	job := addCompletedJob(t, s, "job-1", 2)
+
+	reorder := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/api/v1/jobs/"+job.ID+"/pages/reorder", strings.NewReader(`{"order": [2, 1]}`))
+		s.Router().ServeHTTP(w, r)
+		return w
+	}
+	if w := reorder(); w.Code != http.StatusCreated {
+		t.Fatalf("reorder with space left: status %d: %s", w.Code, w.Body)
+	}
+
+	// The pages of that version fill the quota
+	s.config.Storage.MaxStorageSize = 1
+	jobs := len(s.jobs)
+
+	if w := reorder(); w.Code != http.StatusInsufficientStorage || !strings.Contains(w.Body.String(), codeStorageFull) {
+		t.Errorf("reorder on a full disk: status %d: %s", w.Code, w.Body)
+	}
+
+	file := filepath.Join(t.TempDir(), "scan.jpg")
+	if err := os.WriteFile(file, []byte("not imported"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.ImportFile(file); !errors.Is(err, storage.ErrStorageFull) {
+		t.Errorf("import on a full disk: %v, want ErrStorageFull", err)
+	}
+
+	if len(s.jobs) != jobs {
+		t.Errorf("%d jobs created on a full disk", len(s.jobs)-jobs)
+	}
+}
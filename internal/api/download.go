@@ -0,0 +1,213 @@
+package api
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/scanserver/scanner-service/internal/audit"
+	"github.com/scanserver/scanner-service/internal/auth"
+	"github.com/scanserver/scanner-service/internal/document"
+	"github.com/scanserver/scanner-service/internal/logging"
+	"github.com/scanserver/scanner-service/internal/storage"
+	"github.com/scanserver/scanner-service/pkg/models"
+)
+
+// downloadJob sends all pages of a completed job as a ZIP archive
+// (pages plus manifest.json) or as a merged PDF or multi-page TIFF
+func (s *Server) downloadJob(c *gin.Context) {
+	jobID := c.Param("id")
+	format := strings.ToLower(c.DefaultQuery("format", "zip"))
+
+	s.jobsMutex.RLock()
+	job, ok := s.jobs[jobID]
+	ok = ok && canSeeJob(auth.FromContext(c), job)
+	var snapshot models.ScanJob
+	if ok {
+		snapshot = *job
+		snapshot.Results = append([]models.ScanResult(nil), job.Results...)
+	}
+	s.jobsMutex.RUnlock()
+
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "job not found"})
+		return
+	}
+
+	if snapshot.Status != "completed" || len(snapshot.Results) == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "job has no completed pages"})
+		return
+	}
+
+	// Resolve every page up front so a missing file is a clean 404, not a truncated download
+	paths, err := s.resolvePages(snapshot.Results)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	var contentType, ext string
+	var write func(w io.Writer) error
+
+	switch format {
+	case "zip":
+		contentType, ext = "application/zip", "zip"
+		write = func(w io.Writer) error {
+			return writeJobZip(w, &snapshot, paths)
+		}
+
+	case "pdf":
+		contentType, ext = "application/pdf", "pdf"
+		write = func(w io.Writer) error {
+			return document.WritePDF(w, documentPages(&snapshot, paths))
+		}
+
+	case "tiff", "tif":
+		contentType, ext = "image/tiff", "tif"
+		write = func(w io.Writer) error {
+			return document.WriteTIFF(w, documentPages(&snapshot, paths))
+		}
+
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be zip, pdf or tiff"})
+		return
+	}
+
+	event := audit.Event{
+		Action:    audit.ActionDownload,
+		JobID:     snapshot.ID,
+		ScannerID: snapshot.ScannerID,
+		Status:    "sent",
+		Pages:     len(paths),
+		Detail:    ext,
+	}
+
+	// Encode into a temporary file first, so a failure is still a clean
+	// error response rather than a truncated download with status 200
+	f, err := os.CreateTemp("", "download-"+snapshot.ID+"-*."+ext)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	if err := write(f); err != nil {
+		logging.FromContext(c.Request.Context(), s.logger).Error("Download failed",
+			logging.KeyJobID, jobID, "format", format, logging.Err(err))
+		event.Status = "failed"
+		s.audit.Record(c.Request.Context(), event)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create the download"})
+		return
+	}
+	size, err := f.Seek(0, io.SeekCurrent)
+	if err == nil {
+		_, err = f.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.DataFromReader(http.StatusOK, size, contentType, f, map[string]string{
+		"Content-Disposition": fmt.Sprintf(`attachment; filename="scan-%s.%s"`, snapshot.ID, ext),
+	})
+	s.audit.Record(c.Request.Context(), event)
+}
+
+// resolvePages returns the real paths of a job's page files
+func (s *Server) resolvePages(results []models.ScanResult) ([]string, error) {
+	paths := make([]string, len(results))
+	for i, result := range results {
+		path, err := s.scannerManager.Store().Resolve(result.FilePath)
+		if err != nil {
+			return nil, fmt.Errorf("page %d not found", i+1)
+		}
+		paths[i] = path
+	}
+	return paths, nil
+}
+
+// documentPages returns the pages of a job for merging into a document
+func documentPages(job *models.ScanJob, paths []string) []document.Page {
+	pages := make([]document.Page, len(paths))
+	for i, path := range paths {
+		dpi := job.Results[i].Resolution
+		if dpi == 0 {
+			dpi = job.Parameters.Resolution
+		}
+		pages[i] = document.Page{Path: path, DPI: dpi}
+	}
+	return pages
+}
+
+// writeJobZip writes the page files of a job and a manifest.json to a ZIP archive
+func writeJobZip(w io.Writer, job *models.ScanJob, paths []string) error {
+	zw := zip.NewWriter(w)
+
+	completedAt := time.Now()
+	if job.CompletedAt != nil {
+		completedAt = *job.CompletedAt
+	}
+
+	manifest, err := json.MarshalIndent(storage.NewManifest(job, job.Results, completedAt), "", "  ")
+	if err != nil {
+		return err
+	}
+
+	for _, path := range paths {
+		if err := addZipFile(zw, path); err != nil {
+			return err
+		}
+	}
+
+	mw, err := zw.CreateHeader(&zip.FileHeader{
+		Name:     "manifest.json",
+		Method:   zip.Deflate,
+		Modified: completedAt,
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := mw.Write(manifest); err != nil {
+		return err
+	}
+
+	return zw.Close()
+}
+
+// addZipFile stores a page file in the archive. Images are already
+// compressed, so they are stored rather than deflated.
+func addZipFile(zw *zip.Writer, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	header, err := zip.FileInfoHeader(info)
+	if err != nil {
+		return err
+	}
+	header.Name = filepath.Base(path)
+	header.Method = zip.Store
+
+	fw, err := zw.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(fw, f)
+	return err
+}
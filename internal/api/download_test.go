@@ -0,0 +1,133 @@
+package api
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"image"
+	"image/png"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/scanserver/scanner-service/internal/config"
+	"github.com/scanserver/scanner-service/internal/storage"
+	"github.com/scanserver/scanner-service/pkg/models"
+)
+
+// addCompletedJob adds a completed job whose pages are PNG files in the store
+func addCompletedJob(t *testing.T, s *Server, id string, pages int) *models.ScanJob {
+	t.Helper()
+	dir := filepath.Join(s.config.Storage.OutputDir, id)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	completedAt := time.Now()
+	job := &models.ScanJob{ID: id, ScannerID: "scanner-001", Status: "completed", CompletedAt: &completedAt}
+	for i := 1; i <= pages; i++ {
+		var buf bytes.Buffer
+		png.Encode(&buf, image.NewGray(image.Rect(0, 0, 10*i, 10)))
+		path := filepath.Join(dir, "page_"+strconv.Itoa(i)+".png")
+		if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+			t.Fatal(err)
+		}
+		job.Results = append(job.Results, models.ScanResult{
+			PageNumber: i, FilePath: path, Format: "PNG", FileSize: int64(buf.Len()), Width: 10 * i, Height: 10, Resolution: 150,
+		})
+	}
+
+	s.jobsMutex.Lock()
+	s.jobs[id] = job
+	s.jobsMutex.Unlock()
+	return job
+}
+
+func download(s *Server, id, format string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	s.Router().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/jobs/"+id+"/download?format="+format, nil))
+	return w
+}
+
+func TestDownloadZip(t *testing.T) {
+	s := newTestServer(t, &config.Config{})
+	job := addCompletedJob(t, s, "job-zip", 3)
+
+	w := download(s, job.ID, "zip")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	if got := w.Header().Get("Content-Disposition"); got != `attachment; filename="scan-job-zip.zip"` {
+		t.Errorf("Content-Disposition %q", got)
+	}
+	if got := w.Header().Get("Content-Length"); got != strconv.Itoa(w.Body.Len()) {
+		t.Errorf("Content-Length %q for %d bytes", got, w.Body.Len())
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"page_1.png", "page_2.png", "page_3.png", "manifest.json"}
+	if len(zr.File) != len(want) {
+		t.Fatalf("%d files in the archive, want %d", len(zr.File), len(want))
+	}
+	for i, f := range zr.File {
+		if f.Name != want[i] {
+			t.Errorf("file %d is %s, want %s", i, f.Name, want[i])
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if f.Name == "manifest.json" {
+			var manifest storage.Manifest
+			if err := json.Unmarshal(data, &manifest); err != nil {
+				t.Fatal(err)
+			}
+			if manifest.JobID != job.ID || len(manifest.Pages) != 3 || manifest.Pages[2].File != "page_3.png" || manifest.Pages[2].Width != 30 {
+				t.Errorf("manifest = %+v", manifest)
+			}
+			continue
+		}
+		page, err := os.ReadFile(job.Results[i].FilePath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(data, page) {
+			t.Errorf("%s differs from the page file", f.Name)
+		}
+	}
+}
+
+// TestDownloadFailure checks that a page that cannot be encoded fails the
+// download with an error instead of a truncated 200 response
+func TestDownloadFailure(t *testing.T) {
+	s := newTestServer(t, &config.Config{})
+	job := addCompletedJob(t, s, "job-broken", 2)
+	if err := os.WriteFile(job.Results[1].FilePath, []byte("not an image"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, format := range []string{"pdf", "tiff"} {
+		w := download(s, job.ID, format)
+		if w.Code != http.StatusInternalServerError || w.Header().Get("Content-Disposition") != "" {
+			t.Errorf("%s: status %d, Content-Disposition %q", format, w.Code, w.Header().Get("Content-Disposition"))
+		}
+	}
+	if w := download(s, job.ID, "zip"); w.Code != http.StatusOK {
+		t.Errorf("zip: status %d", w.Code)
+	}
+}
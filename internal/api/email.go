@@ -0,0 +1,136 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/scanserver/scanner-service/internal/audit"
+	"github.com/scanserver/scanner-service/internal/email"
+	"github.com/scanserver/scanner-service/internal/logging"
+	"github.com/scanserver/scanner-service/internal/tracing"
+	"github.com/scanserver/scanner-service/pkg/models"
+)
+
+// checkOutputs validates the destinations requested for a new job.
+// It writes the error response and returns false if the request must be rejected.
+func (s *Server) checkOutputs(c *gin.Context, emailReq *models.EmailRequest, exportReq *models.ExportRequest) bool {
+	if err := s.validateOutputs(emailReq, exportReq); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+	return true
+}
+
+// validateOutputs checks that the requested destinations can be delivered to
+func (s *Server) validateOutputs(emailReq *models.EmailRequest, exportReq *models.ExportRequest) error {
+	if emailReq != nil {
+		if err := s.email.Check(*emailReq); err != nil {
+			return err
+		}
+	}
+	if exportReq != nil {
+		if err := s.exports.Check(*exportReq); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deliverOutputs sends a job that just completed with results to the
+// destinations it requested. Failures are reported in the returned outputs
+// rather than failing the job.
+func (s *Server) deliverOutputs(ctx context.Context, job *models.ScanJob, results []models.ScanResult) []models.OutputResult {
+	if job.Email == nil && job.Export == nil {
+		return nil
+	}
+
+	done := *job
+	done.Results = results
+
+	var outputs []models.OutputResult
+	if job.Email != nil {
+		outputs = append(outputs, s.emailJob(ctx, &done, *job.Email))
+	}
+	if job.Export != nil {
+		outputs = append(outputs, s.exportJob(ctx, &done, *job.Export)...)
+	}
+	return outputs
+}
+
+// emailJob emails the pages of a completed job
+func (s *Server) emailJob(ctx context.Context, job *models.ScanJob, req models.EmailRequest) models.OutputResult {
+	output := models.OutputResult{Destination: "email"}
+
+	paths, err := s.resolvePages(job.Results)
+	messages := 0
+	if err == nil {
+		sendCtx, span := tracing.Start(ctx, "email.send")
+		messages, err = s.email.Send(sendCtx, job, req, documentPages(job, paths))
+		tracing.End(span, err)
+	}
+
+	output.Time = time.Now()
+	if err != nil {
+		output.Status = "failed"
+		output.Error = err.Error()
+		if messages > 0 {
+			output.Detail = fmt.Sprintf("%d message(s) sent before the failure", messages)
+		}
+		logging.FromContext(ctx, s.logger).Error("Failed to email job", logging.Err(err))
+		return output
+	}
+
+	output.Status = "sent"
+	output.Detail = fmt.Sprintf("%d message(s)", messages)
+	return output
+}
+
+// emailCompletedJob emails a completed job on request
+func (s *Server) emailCompletedJob(c *gin.Context) {
+	var req models.EmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := s.email.Check(req); err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, email.ErrNotConfigured) {
+			status = http.StatusServiceUnavailable
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	snapshot, ok := s.completedJob(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	output := s.emailJob(c.Request.Context(), snapshot, req)
+	s.audit.Record(c.Request.Context(), audit.Event{
+		Action:    audit.ActionEmail,
+		JobID:     snapshot.ID,
+		ScannerID: snapshot.ScannerID,
+		Profile:   req.Profile,
+		Status:    output.Status,
+		Pages:     len(snapshot.Results),
+		Outputs:   []models.OutputResult{output},
+	})
+
+	s.jobsMutex.Lock()
+	if job, ok := s.jobs[snapshot.ID]; ok {
+		job.Outputs = append(job.Outputs, output)
+	}
+	s.jobsMutex.Unlock()
+
+	if output.Status != "sent" {
+		c.JSON(http.StatusBadGateway, output)
+		return
+	}
+	c.JSON(http.StatusOK, output)
+}
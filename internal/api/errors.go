@@ -0,0 +1,88 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/scanserver/scanner-service/internal/audit"
+	"github.com/scanserver/scanner-service/internal/document"
+	"github.com/scanserver/scanner-service/internal/scanner"
+	"github.com/scanserver/scanner-service/internal/storage"
+	"github.com/scanserver/scanner-service/internal/webhook"
+)
+
+// Error codes for failures outside the scanner layer
+const (
+	codeStorageFull       = "storage_full"
+	codeShuttingDown      = "shutting_down"
+	codeAuditDisabled     = "audit_disabled"
+	codeUnsupportedFormat = "unsupported_format"
+	codeInvalidParameter  = "invalid_parameter"
+	codeNotFound          = "not_found"
+)
+
+// ErrShuttingDown is returned for new jobs once the server has begun shutting down
+var ErrShuttingDown = errors.New("server is shutting down")
+
+// ErrInvalidParameter is returned for a malformed query parameter
+var ErrInvalidParameter = errors.New("invalid parameter")
+
+// errorStatuses maps scanner error codes to HTTP status codes
+var errorStatuses = map[string]int{
+	scanner.CodeScannerNotFound:    http.StatusNotFound,
+	scanner.CodeFeederEmpty:        http.StatusConflict,
+	scanner.CodePaperJam:           http.StatusConflict,
+	scanner.CodeCoverOpen:          http.StatusConflict,
+	scanner.CodeBusy:               http.StatusConflict,
+	scanner.CodeOffline:            http.StatusServiceUnavailable,
+	scanner.CodeCancelled:          http.StatusConflict,
+	scanner.CodeUnsupportedSetting: http.StatusUnprocessableEntity,
+}
+
+// respondError writes err as {"error", "code"} with the HTTP status for its type.
+// Validation errors additionally list the offending fields.
+func respondError(c *gin.Context, err error) {
+	code := scanner.ErrorCode(err)
+	status, ok := errorStatuses[code]
+	if !ok {
+		status = http.StatusInternalServerError
+	}
+
+	if errors.Is(err, storage.ErrStorageFull) {
+		code = codeStorageFull
+		status = http.StatusInsufficientStorage
+	}
+	if errors.Is(err, ErrShuttingDown) {
+		code = codeShuttingDown
+		status = http.StatusServiceUnavailable
+	}
+	if errors.Is(err, audit.ErrDisabled) {
+		code = codeAuditDisabled
+		status = http.StatusNotFound
+	}
+	if errors.Is(err, ErrInvalidParameter) {
+		code = codeInvalidParameter
+		status = http.StatusBadRequest
+	}
+	if errors.Is(err, webhook.ErrDeliveryNotFound) {
+		code = codeNotFound
+		status = http.StatusNotFound
+	}
+	if errors.Is(err, document.ErrUnsupportedFormat) {
+		code = codeUnsupportedFormat
+		status = http.StatusUnprocessableEntity
+	}
+
+	body := gin.H{
+		"error": err.Error(),
+		"code":  code,
+	}
+
+	var validationErr *scanner.ValidationError
+	if errors.As(err, &validationErr) {
+		body["fields"] = validationErr.Fields
+	}
+
+	c.JSON(status, body)
+}
@@ -0,0 +1,73 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/scanserver/scanner-service/internal/audit"
+	"github.com/scanserver/scanner-service/internal/document"
+	"github.com/scanserver/scanner-service/internal/scanner"
+	"github.com/scanserver/scanner-service/internal/storage"
+	"github.com/scanserver/scanner-service/internal/webhook"
+)
+
+func TestRespondError(t *testing.T) {
+	validation := &scanner.ValidationError{ScannerID: "scanner-1", Fields: []scanner.FieldError{
+		{Field: "resolution", Value: 400, Allowed: []int{150, 300}, Message: "unsupported resolution"},
+	}}
+
+	tests := []struct {
+		err    error
+		code   string
+		status int
+	}{
+		{scanner.ErrScannerNotFound, scanner.CodeScannerNotFound, http.StatusNotFound},
+		{scanner.ErrFeederEmpty, scanner.CodeFeederEmpty, http.StatusConflict},
+		{scanner.ErrPaperJam, scanner.CodePaperJam, http.StatusConflict},
+		{scanner.ErrCoverOpen, scanner.CodeCoverOpen, http.StatusConflict},
+		{scanner.ErrBusy, scanner.CodeBusy, http.StatusConflict},
+		{scanner.ErrOffline, scanner.CodeOffline, http.StatusServiceUnavailable},
+		{scanner.ErrCancelled, scanner.CodeCancelled, http.StatusConflict},
+		{scanner.ErrUnsupportedSetting, scanner.CodeUnsupportedSetting, http.StatusUnprocessableEntity},
+		{validation, scanner.CodeUnsupportedSetting, http.StatusUnprocessableEntity},
+		{storage.ErrStorageFull, codeStorageFull, http.StatusInsufficientStorage},
+		{ErrShuttingDown, codeShuttingDown, http.StatusServiceUnavailable},
+		{audit.ErrDisabled, codeAuditDisabled, http.StatusNotFound},
+		{ErrInvalidParameter, codeInvalidParameter, http.StatusBadRequest},
+		{webhook.ErrDeliveryNotFound, codeNotFound, http.StatusNotFound},
+		{document.ErrUnsupportedFormat, codeUnsupportedFormat, http.StatusUnprocessableEntity},
+		{errors.New("disk on fire"), scanner.CodeInternal, http.StatusInternalServerError},
+	}
+
+	gin.SetMode(gin.TestMode)
+	for _, tt := range tests {
+		for _, err := range []error{tt.err, fmt.Errorf("batch scan failed: %w", tt.err)} {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			respondError(c, err)
+
+			var body struct {
+				Error  string
+				Code   string
+				Fields []scanner.FieldError
+			}
+			if jsonErr := json.Unmarshal(w.Body.Bytes(), &body); jsonErr != nil {
+				t.Fatal(jsonErr)
+			}
+			if w.Code != tt.status || body.Code != tt.code || body.Error != err.Error() {
+				t.Errorf("%v: status %d, code %s, error %q, want %d %s", err, w.Code, body.Code, body.Error, tt.status, tt.code)
+			}
+			if wantFields := errors.As(err, new(*scanner.ValidationError)); wantFields != (len(body.Fields) == 1) {
+				t.Errorf("%v: fields %+v", err, body.Fields)
+			} else if wantFields && body.Fields[0].Field != "resolution" {
+				t.Errorf("%v: fields %+v", err, body.Fields)
+			}
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/scanserver/scanner-service/internal/audit"
+	"github.com/scanserver/scanner-service/pkg/models"
+)
+
+// exportJob copies the pages of a completed job to remote folders
+func (s *Server) exportJob(ctx context.Context, job *models.ScanJob, req models.ExportRequest) []models.OutputResult {
+	paths, err := s.resolvePages(job.Results)
+	if err != nil {
+		return []models.OutputResult{{Destination: "export", Status: "failed", Error: err.Error(), Time: time.Now()}}
+	}
+	return s.exports.Export(ctx, job, req, documentPages(job, paths))
+}
+
+// exportCompletedJob exports a completed job on request
+func (s *Server) exportCompletedJob(c *gin.Context) {
+	var req models.ExportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := s.exports.Check(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	snapshot, ok := s.completedJob(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	outputs := s.exportJob(c.Request.Context(), snapshot, req)
+
+	s.jobsMutex.Lock()
+	if job, ok := s.jobs[snapshot.ID]; ok {
+		job.Outputs = append(job.Outputs, outputs...)
+	}
+	s.jobsMutex.Unlock()
+
+	status, outcome := http.StatusOK, "sent"
+	for _, output := range outputs {
+		if output.Status != "sent" {
+			status, outcome = http.StatusBadGateway, "failed"
+		}
+	}
+	s.audit.Record(c.Request.Context(), audit.Event{
+		Action:    audit.ActionExport,
+		JobID:     snapshot.ID,
+		ScannerID: snapshot.ScannerID,
+		Profile:   req.Profile,
+		Status:    outcome,
+		Pages:     len(snapshot.Results),
+		Outputs:   outputs,
+	})
+	c.JSON(status, gin.H{"outputs": outputs})
+}
@@ -0,0 +1,165 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/scanserver/scanner-service/internal/health"
+	"github.com/scanserver/scanner-service/internal/storage"
+)
+
+// setupHealth registers the liveness (/healthz) and readiness (/readyz)
+// probes. Both are public, like /api/v1/health, and answer 503 when the
+// server is down.
+func (s *Server) setupHealth() {
+	timeout := time.Duration(s.config.Health.Timeout) * time.Second
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+
+	// Liveness only fails when the server is stuck and should be restarted
+	liveness := health.NewChecker(timeout)
+	liveness.Add("job_store", s.checkJobsLock)
+
+	readiness := health.NewChecker(timeout)
+	readiness.Add("driver", s.checkDriver)
+	readiness.Add("scanners", s.checkScanners)
+	readiness.Add("storage", s.checkStorage)
+	readiness.Add("job_store", s.checkJobStore)
+	if len(s.exports.Names()) > 0 {
+		interval := time.Duration(s.config.Health.ExportInterval) * time.Second
+		readiness.AddOptional("exports", health.Cached(interval, s.checkExports))
+	}
+
+	s.router.GET("/healthz", healthHandler(liveness))
+	s.router.GET("/readyz", healthHandler(readiness))
+}
+
+// healthHandler responds with the report of checker
+func healthHandler(checker *health.Checker) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		report := checker.Run(c.Request.Context())
+
+		status := http.StatusOK
+		if report.Status == health.StatusDown {
+			status = http.StatusServiceUnavailable
+		}
+		c.Header("Cache-Control", "no-store")
+		c.JSON(status, report)
+	}
+}
+
+// checkJobsLock verifies that the job records can be locked, which fails
+// only if the server is deadlocked
+func (s *Server) checkJobsLock(ctx context.Context) health.Result {
+	s.jobsMutex.RLock()
+	n := len(s.jobs)
+	s.jobsMutex.RUnlock()
+
+	return health.OK(fmt.Sprintf("%d job(s)", n))
+}
+
+// checkDriver reports whether all backends of the scanner driver initialized
+func (s *Server) checkDriver(ctx context.Context) health.Result {
+	if err := s.scannerManager.DriverError(); err != nil {
+		result := health.Degraded(err)
+		result.Detail = runtime.GOOS
+		return result
+	}
+	return health.OK(runtime.GOOS)
+}
+
+// checkScanners enumerates the scanners. A server without scanners can still
+// serve stored jobs, so that is only degraded.
+func (s *Server) checkScanners(ctx context.Context) health.Result {
+	scanners, err := s.scannerManager.ListScanners(ctx)
+	if err != nil {
+		return health.Down(err)
+	}
+	if len(scanners) == 0 {
+		return health.Degraded(errors.New("no scanners found"))
+	}
+	return health.OK(fmt.Sprintf("%d scanner(s)", len(scanners)))
+}
+
+// checkStorage verifies that pages can be written to the output directory
+// and that it has the configured minimum of free space
+func (s *Server) checkStorage(ctx context.Context) health.Result {
+	if err := storage.CheckWritable(s.scannerManager.Store().Root()); err != nil {
+		return health.Down(fmt.Errorf("output directory is not writable: %w", err))
+	}
+
+	free, min, err := s.janitor.FreeSpace()
+	if err != nil {
+		return health.Degraded(fmt.Errorf("could not determine free space: %w", err))
+	}
+	if min > 0 && free < uint64(min) {
+		return health.Down(fmt.Errorf("%w: %d bytes free, at least %d required", storage.ErrStorageFull, free, min))
+	}
+	return health.OK(fmt.Sprintf("%d bytes free", free))
+}
+
+// checkJobStore verifies that new jobs are accepted and that the job records
+// can be saved at shutdown
+func (s *Server) checkJobStore(ctx context.Context) health.Result {
+	s.jobsMutex.RLock()
+	draining := s.draining
+	n := len(s.jobs)
+	s.jobsMutex.RUnlock()
+
+	if draining {
+		return health.Down(ErrShuttingDown)
+	}
+
+	file := s.config.Storage.JobsFile
+	if file == "" {
+		return health.OK(fmt.Sprintf("%d job(s), not persisted", n))
+	}
+	// saveJobs creates the directory too
+	dir := filepath.Dir(file)
+	err := os.MkdirAll(dir, 0755)
+	if err == nil {
+		err = storage.CheckWritable(dir)
+	}
+	if err != nil {
+		return health.Down(fmt.Errorf("jobs file directory is not writable: %w", err))
+	}
+	return health.OK(fmt.Sprintf("%d job(s)", n))
+}
+
+// checkExports connects to every export destination. Exports are degraded
+// while some destinations are unreachable and down when none is reachable.
+func (s *Server) checkExports(ctx context.Context) health.Result {
+	errs := s.exports.Ping(ctx)
+
+	var reachable, failures []string
+	for name, err := range errs {
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", name, err))
+		} else {
+			reachable = append(reachable, name)
+		}
+	}
+	sort.Strings(reachable)
+	sort.Strings(failures)
+
+	if len(failures) == 0 {
+		return health.OK(strings.Join(reachable, ", ") + " reachable")
+	}
+	err := errors.New(strings.Join(failures, "; "))
+	if len(reachable) == 0 {
+		return health.Down(err)
+	}
+	result := health.Degraded(err)
+	result.Detail = strings.Join(reachable, ", ") + " reachable"
+	return result
+}
@@ -0,0 +1,121 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/scanserver/scanner-service/internal/config"
+	"github.com/scanserver/scanner-service/internal/document"
+	"github.com/scanserver/scanner-service/internal/scanner"
+	"github.com/scanserver/scanner-service/internal/storage"
+	"github.com/scanserver/scanner-service/internal/webhook"
+	"github.com/scanserver/scanner-service/pkg/models"
+)
+
+// HotFolderScannerID is the scanner_id of jobs imported from the hot folder
+const HotFolderScannerID = "hotfolder"
+
+// ImportFile turns a file dropped into the hot folder into a completed job.
+// The job goes through the same steps as a scan: the post-processing,
+// email and export destinations configured for the hot folder, archiving
+// and job events.
+func (s *Server) ImportFile(path string) error {
+	cfg := s.config.HotFolder
+
+	// Refuse new jobs when the disk is full
+	if err := s.janitor.CheckCapacity(); err != nil {
+		return err
+	}
+
+	// Invalid parameters fail the job, so the file ends up in failed/
+	params, paramsErr := hotFolderParams(cfg)
+
+	job := &models.ScanJob{
+		ID:           models.GenerateUUID(),
+		ScannerID:    HotFolderScannerID,
+		Status:       "processing",
+		Parameters:   params,
+		Results:      []models.ScanResult{},
+		CreatedAt:    time.Now(),
+		ImportedFrom: filepath.Base(path),
+		Owner:        cfg.Owner,
+	}
+	if cfg.Email != "" {
+		job.Email = &models.EmailRequest{Profile: cfg.Email}
+	}
+	if cfg.Export != "" {
+		job.Export = &models.ExportRequest{Profile: cfg.Export}
+	}
+
+	if err := s.addJob(job); err != nil {
+		return err
+	}
+	defer s.running.Done()
+
+	s.broadcastJobUpdate(job)
+	snapshot := s.jobSnapshot(job)
+	s.webhooks.Send(webhook.EventJobCreated, snapshot, 0)
+	s.webhooks.Send(webhook.EventJobStarted, snapshot, 0)
+
+	store := s.scannerManager.Store()
+	ctx := startJobSpan(s.jobContext(s.ctx, job), "job.import", job)
+
+	var results []models.ScanResult
+	err := paramsErr
+	if err == nil {
+		results, err = document.Import(path, func(format string) (string, error) {
+			return store.NextPagePath(ctx, format)
+		})
+	}
+
+	var outputs []models.OutputResult
+	if err == nil {
+		for i := range results {
+			results[i].Resolution = cfg.Resolution
+		}
+		results = scanner.PostProcess(ctx, params, results)
+		outputs = s.finishPages(ctx, job, results)
+	} else if dir, dirErr := store.JobDir(&storage.Job{ID: job.ID, CreatedAt: job.CreatedAt}); dirErr == nil {
+		// Don't leave the pages of a half-imported file behind
+		os.RemoveAll(dir)
+	}
+
+	s.jobsMutex.Lock()
+	now := time.Now()
+	job.CompletedAt = &now
+	if err != nil {
+		job.Status = "failed"
+		job.Error = err.Error()
+	} else {
+		job.Status = "completed"
+		job.Progress = 100
+		job.Results = results
+		job.Outputs = outputs
+	}
+	s.jobsMutex.Unlock()
+
+	s.broadcastJobUpdate(job)
+	s.notifyJobFinished(ctx, job)
+	return err
+}
+
+// hotFolderParams returns the parameters imported pages are post-processed with
+func hotFolderParams(cfg config.HotFolderConfig) (models.ScanParams, error) {
+	params := models.ScanParams{}
+
+	// Parameters use the field names of the scan API
+	if len(cfg.Parameters) > 0 {
+		data, err := json.Marshal(cfg.Parameters)
+		if err != nil {
+			return params, err
+		}
+		if err := json.Unmarshal(data, &params); err != nil {
+			return params, fmt.Errorf("invalid hot folder parameters: %w", err)
+		}
+	}
+	params.Resolution = cfg.Resolution
+	return params, nil
+}
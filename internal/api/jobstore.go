@@ -0,0 +1,84 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/scanserver/scanner-service/pkg/models"
+)
+
+// loadJobs restores the job records saved to storage.jobs_file by the last shutdown.
+// Jobs that had not finished are marked as failed.
+func (s *Server) loadJobs() error {
+	file := s.config.Storage.JobsFile
+	if file == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(file)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read jobs: %w", err)
+	}
+
+	var jobs []*models.ScanJob
+	if err := json.Unmarshal(data, &jobs); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", file, err)
+	}
+
+	s.jobsMutex.Lock()
+	defer s.jobsMutex.Unlock()
+
+	for _, job := range jobs {
+		if !isFinished(job) {
+			now := time.Now()
+			job.Status = "failed"
+			job.Error = "interrupted by server restart"
+			job.Prompt = ""
+			job.CompletedAt = &now
+		}
+		s.jobs[job.ID] = job
+	}
+	return nil
+}
+
+// saveJobs writes the job records to storage.jobs_file
+func (s *Server) saveJobs() error {
+	file := s.config.Storage.JobsFile
+	if file == "" {
+		return nil
+	}
+
+	s.jobsMutex.RLock()
+	jobs := make([]*models.ScanJob, 0, len(s.jobs))
+	for _, job := range s.jobs {
+		jobs = append(jobs, job)
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].CreatedAt.Before(jobs[j].CreatedAt) })
+	data, err := json.MarshalIndent(jobs, "", "  ")
+	s.jobsMutex.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	if dir := filepath.Dir(file); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+	tmp := file + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to save jobs: %w", err)
+	}
+	if err := os.Rename(tmp, file); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to save jobs: %w", err)
+	}
+	return nil
+}
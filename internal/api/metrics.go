@@ -0,0 +1,87 @@
+package api
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/scanserver/scanner-service/internal/logging"
+	"github.com/scanserver/scanner-service/internal/metrics"
+)
+
+// serverCollector reports the job queue, WebSocket clients and storage usage
+// of a server when metrics are scraped
+type serverCollector struct {
+	server *Server
+
+	queueDepth       *prometheus.Desc
+	websocketClients *prometheus.Desc
+	storageUsed      *prometheus.Desc
+	storageFree      *prometheus.Desc
+	storageFiles     *prometheus.Desc
+}
+
+func newServerCollector(s *Server) *serverCollector {
+	name := func(n string) string { return prometheus.BuildFQName(metrics.Namespace, "", n) }
+	return &serverCollector{
+		server:           s,
+		queueDepth:       prometheus.NewDesc(name("job_queue_depth"), "Jobs that have not finished, by status (pending, processing, waiting).", []string{"status"}, nil),
+		websocketClients: prometheus.NewDesc(name("websocket_clients"), "Connected WebSocket clients.", nil, nil),
+		storageUsed:      prometheus.NewDesc(name("storage_used_bytes"), "Bytes used by scanned files in the output directory.", nil, nil),
+		storageFree:      prometheus.NewDesc(name("storage_free_bytes"), "Free bytes on the volume holding the output directory.", nil, nil),
+		storageFiles:     prometheus.NewDesc(name("storage_files"), "Files in the output directory.", nil, nil),
+	}
+}
+
+// Describe implements prometheus.Collector
+func (c *serverCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.queueDepth
+	ch <- c.websocketClients
+	ch <- c.storageUsed
+	ch <- c.storageFree
+	ch <- c.storageFiles
+}
+
+// Collect implements prometheus.Collector
+func (c *serverCollector) Collect(ch chan<- prometheus.Metric) {
+	s := c.server
+
+	depth := map[string]int{"pending": 0, "processing": 0, "waiting": 0}
+	s.jobsMutex.RLock()
+	for _, job := range s.jobs {
+		if _, ok := depth[job.Status]; ok {
+			depth[job.Status]++
+		}
+	}
+	s.jobsMutex.RUnlock()
+	for status, n := range depth {
+		ch <- prometheus.MustNewConstMetric(c.queueDepth, prometheus.GaugeValue, float64(n), status)
+	}
+
+	ch <- prometheus.MustNewConstMetric(c.websocketClients, prometheus.GaugeValue, float64(s.wsHub.ClientCount()))
+
+	usage, err := s.janitor.Usage()
+	if err != nil {
+		s.logger.Warn("Failed to read storage usage for metrics", logging.Err(err))
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(c.storageUsed, prometheus.GaugeValue, float64(usage.UsedBytes))
+	ch <- prometheus.MustNewConstMetric(c.storageFree, prometheus.GaugeValue, float64(usage.FreeBytes))
+	ch <- prometheus.MustNewConstMetric(c.storageFiles, prometheus.GaugeValue, float64(usage.FileCount))
+}
+
+// setupMetrics registers the server's metrics and serves them on /metrics
+func (s *Server) setupMetrics() {
+	cfg := s.config.Metrics
+	if !cfg.Enabled {
+		return
+	}
+
+	if err := metrics.Register(newServerCollector(s)); err != nil {
+		s.logger.Warn("Failed to register server metrics", logging.Err(err))
+	}
+
+	handlers := []gin.HandlerFunc{gin.WrapH(metrics.Handler())}
+	if cfg.RequireAuth {
+		handlers = append([]gin.HandlerFunc{s.auth.Middleware()}, handlers...)
+	}
+	s.router.GET("/metrics", handlers...)
+}
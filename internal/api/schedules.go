@@ -0,0 +1,182 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/scanserver/scanner-service/internal/scheduler"
+	"github.com/scanserver/scanner-service/internal/webhook"
+	"github.com/scanserver/scanner-service/pkg/models"
+)
+
+// scheduleRequest is the body of POST and PUT /schedules
+type scheduleRequest struct {
+	ID         string                `json:"id"` // Generated if empty; ignored by PUT
+	Name       string                `json:"name"`
+	Cron       string                `json:"cron" binding:"required"`
+	ScannerID  string                `json:"scanner_id" binding:"required"`
+	Parameters models.ScanParams     `json:"parameters"`
+	Email      *models.EmailRequest  `json:"email"`
+	Export     *models.ExportRequest `json:"export"`
+	Enabled    *bool                 `json:"enabled"` // Default true
+}
+
+// SetScheduler sets the scheduler served by the /schedules endpoints
+func (s *Server) SetScheduler(sched *scheduler.Scheduler) {
+	s.scheduler = sched
+}
+
+// RunSchedule runs a schedule's scan as a normal job and returns the
+// finished job. A feeder that turns out to be empty ends the job as skipped.
+func (s *Server) RunSchedule(ctx context.Context, schedule models.Schedule) (*models.ScanJob, error) {
+	params := schedule.Parameters
+	if err := s.scannerManager.ValidateParams(ctx, schedule.ScannerID, &params, s.validationMode); err != nil {
+		return nil, err
+	}
+	if err := s.validateOutputs(schedule.Email, schedule.Export); err != nil {
+		return nil, err
+	}
+
+	// Refuse new jobs when the disk is full
+	if err := s.janitor.CheckCapacity(); err != nil {
+		return nil, err
+	}
+
+	job := &models.ScanJob{
+		ID:         models.GenerateUUID(),
+		ScannerID:  schedule.ScannerID,
+		Status:     "pending",
+		Parameters: params,
+		Results:    []models.ScanResult{},
+		CreatedAt:  time.Now(),
+		Email:      schedule.Email,
+		Export:     schedule.Export,
+		ScheduleID: schedule.ID,
+		Owner:      schedule.Owner,
+	}
+
+	if err := s.addJob(job); err != nil {
+		return nil, err
+	}
+
+	s.webhooks.Send(webhook.EventJobCreated, s.jobSnapshot(job), 0)
+	s.executeScanJob(s.jobContext(ctx, job), job)
+
+	snapshot := s.jobSnapshot(job)
+	return &snapshot, nil
+}
+
+// listSchedules returns all schedules with their next run and recent runs
+func (s *Server) listSchedules(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"schedules": s.scheduler.List()})
+}
+
+// getSchedule returns a schedule
+func (s *Server) getSchedule(c *gin.Context) {
+	schedule, err := s.scheduler.Get(c.Param("id"))
+	if err != nil {
+		respondScheduleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, schedule)
+}
+
+// createSchedule adds a schedule
+func (s *Server) createSchedule(c *gin.Context) {
+	schedule, ok := s.bindSchedule(c)
+	if !ok {
+		return
+	}
+
+	created, err := s.scheduler.Create(schedule)
+	if err != nil {
+		respondScheduleError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, created)
+}
+
+// updateSchedule replaces a schedule created through the API
+func (s *Server) updateSchedule(c *gin.Context) {
+	schedule, ok := s.bindSchedule(c)
+	if !ok {
+		return
+	}
+
+	updated, err := s.scheduler.Update(c.Param("id"), schedule)
+	if err != nil {
+		respondScheduleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, updated)
+}
+
+// deleteSchedule removes a schedule created through the API
+func (s *Server) deleteSchedule(c *gin.Context) {
+	if err := s.scheduler.Delete(c.Param("id")); err != nil {
+		respondScheduleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "schedule deleted"})
+}
+
+// runScheduleNow starts a run of a schedule immediately
+func (s *Server) runScheduleNow(c *gin.Context) {
+	if err := s.scheduler.RunNow(c.Param("id")); err != nil {
+		respondScheduleError(c, err)
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{"message": "schedule started"})
+}
+
+// bindSchedule reads and validates a schedule from the request body.
+// It writes the error response and returns false if the request must be rejected.
+func (s *Server) bindSchedule(c *gin.Context) (models.Schedule, bool) {
+	var req scheduleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return models.Schedule{}, false
+	}
+
+	if err := scheduler.Validate(req.Cron); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return models.Schedule{}, false
+	}
+
+	// Check parameters against scanner capabilities
+	if !s.validateScanParams(c, req.ScannerID, &req.Parameters) {
+		return models.Schedule{}, false
+	}
+
+	if !s.checkOutputs(c, req.Email, req.Export) {
+		return models.Schedule{}, false
+	}
+
+	return models.Schedule{
+		ID:         req.ID,
+		Name:       req.Name,
+		Cron:       req.Cron,
+		ScannerID:  req.ScannerID,
+		Parameters: req.Parameters,
+		Email:      req.Email,
+		Export:     req.Export,
+		Enabled:    req.Enabled == nil || *req.Enabled,
+		Owner:      ownerOf(c),
+	}, true
+}
+
+// respondScheduleError writes a scheduler error with its HTTP status
+func respondScheduleError(c *gin.Context, err error) {
+	status := http.StatusBadRequest
+	switch {
+	case errors.Is(err, scheduler.ErrNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, scheduler.ErrReadOnly), errors.Is(err, scheduler.ErrExists),
+		errors.Is(err, scheduler.ErrRunning):
+		status = http.StatusConflict
+	}
+	c.JSON(status, gin.H{"error": err.Error()})
+}
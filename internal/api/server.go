@@ -2,32 +2,99 @@ package api
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
 	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/scanserver/scanner-service/internal/audit"
+	"github.com/scanserver/scanner-service/internal/auth"
+	"github.com/scanserver/scanner-service/internal/config"
+	"github.com/scanserver/scanner-service/internal/cors"
+	"github.com/scanserver/scanner-service/internal/email"
+	"github.com/scanserver/scanner-service/internal/export"
+	"github.com/scanserver/scanner-service/internal/logging"
+	"github.com/scanserver/scanner-service/internal/metrics"
 	"github.com/scanserver/scanner-service/internal/scanner"
+	"github.com/scanserver/scanner-service/internal/scheduler"
+	"github.com/scanserver/scanner-service/internal/storage"
+	"github.com/scanserver/scanner-service/internal/tracing"
+	"github.com/scanserver/scanner-service/internal/webhook"
 	"github.com/scanserver/scanner-service/pkg/models"
 )
 
 // Server represents the API server
 type Server struct {
 	router         *gin.Engine
+	config         *config.Config
 	scannerManager *scanner.Manager
 	jobs           map[string]*models.ScanJob
 	jobsMutex      sync.RWMutex
+	prompts        map[string]chan bool // Jobs waiting for the user; true = continue
 	wsHub          *WebSocketHub
+	validationMode scanner.ValidationMode
+	janitor        *storage.Janitor
+	webhooks       *webhook.Dispatcher
+	email          *email.Sender
+	exports        *export.Manager
+	scheduler      *scheduler.Scheduler
+	auth           *auth.Authenticator
+	cors           *cors.Policy
+	audit          *audit.Log
+	logger         *slog.Logger
+
+	// Shutdown state, see Shutdown
+	ctx         context.Context    // parent of every job, cancelled when shutdown stops waiting
+	cancelJobs  context.CancelFunc
+	running     sync.WaitGroup // jobs started by addJob that have not finished
+	draining    bool           // no new jobs once set, guarded by jobsMutex
+	httpServers []*http.Server // started by Run
+	httpMutex   sync.Mutex
 }
 
 // NewServer creates a new API server
-func NewServer(scannerManager *scanner.Manager, wsHub *WebSocketHub) *Server {
+func NewServer(scannerManager *scanner.Manager, wsHub *WebSocketHub, exports *export.Manager, authenticator *auth.Authenticator, corsPolicy *cors.Policy, auditLog *audit.Log, logger *slog.Logger, cfg *config.Config) *Server {
 	s := &Server{
-		router:         gin.Default(),
+		router:         gin.New(),
+		config:         cfg,
 		scannerManager: scannerManager,
 		jobs:           make(map[string]*models.ScanJob),
+		prompts:        make(map[string]chan bool),
 		wsHub:          wsHub,
+		validationMode: scanner.ParseValidationMode(cfg.Scanner.ParamValidation),
+		janitor:        storage.NewJanitor(&cfg.Storage, logger),
+		webhooks:       webhook.NewDispatcher(&cfg.Webhooks, logger),
+		email:          email.NewSender(&cfg.Email),
+		exports:        exports,
+		auth:           authenticator,
+		cors:           corsPolicy,
+		audit:          auditLog,
+		logger:         logger,
 	}
+	s.ctx, s.cancelJobs = context.WithCancel(context.Background())
+
+	// Jobs recorded by the last shutdown
+	if err := s.loadJobs(); err != nil {
+		logger.Warn("Failed to load job records", logging.Err(err))
+	}
+
+	// Audit files removed by retention or quota cleanup and drop their job records
+	s.janitor.OnRemove(func(paths []string) {
+		s.auditFilesRemoved(paths)
+		s.removeJobsForFiles(paths)
+	})
 
 	s.setupRoutes()
 	return s
@@ -35,23 +102,24 @@ func NewServer(scannerManager *scanner.Manager, wsHub *WebSocketHub) *Server {
 
 // setupRoutes configures API routes
 func (s *Server) setupRoutes() {
-	// CORS middleware - 允许跨域访问
-	s.router.Use(func(c *gin.Context) {
-		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
-		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE")
-
-		if c.Request.Method == "OPTIONS" {
-			c.AbortWithStatus(204)
-			return
-		}
+	// Tracing, request IDs and request logging come first so every response
+	// carries an ID. Health checks, scrapes and WebSocket connections are not traced.
+	s.router.Use(tracing.Middleware("/api/v1/health", "/healthz", "/readyz", "/metrics", "/ws"), logging.Middleware(s.logger), gin.Recovery())
 
-		c.Next()
-	})
+	// CORS middleware, origins from server.cors
+	s.router.Use(s.cors.Middleware())
+
+	// Health check, public for load balancers and monitoring
+	s.router.GET("/api/v1/health", s.healthCheck)
+
+	// Liveness and readiness probes with per-component status
+	s.setupHealth()
+
+	// Prometheus metrics, public unless metrics.require_auth is set
+	s.setupMetrics()
 
 	// API v1 routes
-	v1 := s.router.Group("/api/v1")
+	v1 := s.router.Group("/api/v1", s.auth.Middleware(), auditOrigin())
 	{
 		// Scanner endpoints
 		v1.GET("/scanners", s.listScanners)
@@ -62,20 +130,51 @@ func (s *Server) setupRoutes() {
 		v1.GET("/jobs", s.listJobs)
 		v1.GET("/jobs/:id", s.getJob)
 		v1.DELETE("/jobs/:id", s.cancelJob)
+		v1.POST("/jobs/:id/continue", s.continueJob)
 
 		// Batch scan endpoint
 		v1.POST("/scan/batch", s.createBatchScan)
 
-		// Scanned files endpoint
-		v1.GET("/files/*filepath", s.serveScannedFile)
-
-		// Health check
-		v1.GET("/health", s.healthCheck)
+		// Scanned pages, addressed by job and 1-based page number
+		v1.GET("/jobs/:id/pages/:n", s.servePage)
+		v1.GET("/jobs/:id/download", s.downloadJob)
+		v1.POST("/jobs/:id/email", s.emailCompletedJob)
+		v1.POST("/jobs/:id/export", s.exportCompletedJob)
+
+		// Page editing, each edit creates a new version of the document
+		v1.POST("/jobs/:id/pages/reorder", s.reorderPages)
+		v1.POST("/jobs/:id/pages/rotate", s.rotatePages)
+		v1.POST("/jobs/:id/pages/delete", s.deletePages)
+		v1.POST("/documents/interleave", s.interleaveDocuments)
+
+		// Webhook delivery log, admins only since it holds every job
+		webhooks := v1.Group("/webhooks", auth.RequireAdmin())
+		webhooks.GET("/deliveries", s.listWebhookDeliveries)
+		webhooks.GET("/deliveries/:id", s.getWebhookDelivery)
+
+		// Scheduled scans, managed by admins
+		schedules := v1.Group("/schedules", auth.RequireAdmin())
+		schedules.GET("", s.listSchedules)
+		schedules.POST("", s.createSchedule)
+		schedules.GET("/:id", s.getSchedule)
+		schedules.PUT("/:id", s.updateSchedule)
+		schedules.DELETE("/:id", s.deleteSchedule)
+		schedules.POST("/:id/run", s.runScheduleNow)
+
+		// Storage usage
+		v1.GET("/storage", s.getStorageUsage)
+
+		// Audit trail, admins only
+		v1.GET("/audit", auth.RequireAdmin(), s.listAuditEvents)
 	}
 
 	// Serve static files for web UI
-	s.router.Static("/static", "./web/static")
-	s.router.LoadHTMLGlob("./web/templates/*")
+	webDir := s.config.Server.WebDir
+	if webDir == "" {
+		webDir = "./web"
+	}
+	s.router.Static("/static", filepath.Join(webDir, "static"))
+	s.router.LoadHTMLGlob(filepath.Join(webDir, "templates", "*"))
 	s.router.GET("/", s.serveDashboard)
 }
 
@@ -83,20 +182,33 @@ func (s *Server) setupRoutes() {
 func (s *Server) listScanners(c *gin.Context) {
 	scanners, err := s.scannerManager.ListScanners(c.Request.Context())
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"scanners": scanners})
+	// Only the scanners assigned to the client
+	principal := auth.FromContext(c)
+	visible := make([]models.Scanner, 0, len(scanners))
+	for _, sc := range scanners {
+		if principal.CanUseScanner(sc.ID) {
+			visible = append(visible, sc)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"scanners": visible})
 }
 
 // getScanner returns a specific scanner
 func (s *Server) getScanner(c *gin.Context) {
 	scannerID := c.Param("id")
+	if !auth.FromContext(c).CanUseScanner(scannerID) {
+		auth.Forbid(c, "scanner "+scannerID+" is not assigned to you")
+		return
+	}
 
 	scanner, err := s.scannerManager.GetScanner(c.Request.Context(), scannerID)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		respondError(c, err)
 		return
 	}
 
@@ -106,8 +218,10 @@ func (s *Server) getScanner(c *gin.Context) {
 // createScanJob creates a new scan job
 func (s *Server) createScanJob(c *gin.Context) {
 	var req struct {
-		ScannerID  string             `json:"scanner_id" binding:"required"`
-		Parameters models.ScanParams  `json:"parameters" binding:"required"`
+		ScannerID  string                `json:"scanner_id" binding:"required"`
+		Parameters models.ScanParams     `json:"parameters" binding:"required"`
+		Email      *models.EmailRequest  `json:"email"`  // Send the job by email once it completes
+		Export     *models.ExportRequest `json:"export"` // Copy the job to remote folders once it completes
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -115,6 +229,25 @@ func (s *Server) createScanJob(c *gin.Context) {
 		return
 	}
 
+	if !allowScan(c, req.ScannerID) {
+		return
+	}
+
+	// Check parameters against scanner capabilities
+	if !s.validateScanParams(c, req.ScannerID, &req.Parameters) {
+		return
+	}
+
+	if !s.checkOutputs(c, req.Email, req.Export) {
+		return
+	}
+
+	// Refuse new jobs when the disk is full
+	if err := s.janitor.CheckCapacity(); err != nil {
+		respondError(c, err)
+		return
+	}
+
 	// Create job
 	job := &models.ScanJob{
 		ID:         models.GenerateUUID(),
@@ -124,52 +257,181 @@ func (s *Server) createScanJob(c *gin.Context) {
 		Parameters: req.Parameters,
 		Results:    []models.ScanResult{},
 		CreatedAt:  time.Now(),
+		Email:      req.Email,
+		Export:     req.Export,
+		Owner:      ownerOf(c),
 	}
 
-	s.jobsMutex.Lock()
-	s.jobs[job.ID] = job
-	s.jobsMutex.Unlock()
+	if err := s.addJob(job); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	s.webhooks.Send(webhook.EventJobCreated, s.jobSnapshot(job), 0)
 
 	// Start scan in background
-	go s.executeScanJob(job)
+	go s.executeScanJob(s.jobContext(c.Request.Context(), job), job)
 
 	c.JSON(http.StatusCreated, job)
 }
 
-// executeScanJob executes a scan job
-func (s *Server) executeScanJob(job *models.ScanJob) {
-	ctx := context.Background()
+// validateScanParams validates (or coerces) params for the given scanner.
+// It writes the error response and returns false if the request must be rejected.
+func (s *Server) validateScanParams(c *gin.Context, scannerID string, params *models.ScanParams) bool {
+	err := s.scannerManager.ValidateParams(c.Request.Context(), scannerID, params, s.validationMode)
+	if err != nil {
+		respondError(c, err)
+		return false
+	}
+	return true
+}
+
+// jobContext returns the context a job runs in. It is cancelled at shutdown
+// rather than with the request that created the job, and carries the job's
+// storage and a logger tagged with the job, plus the request or schedule
+// found in parent. The job is traced as part of parent's trace and audited
+// with parent's origin.
+func (s *Server) jobContext(parent context.Context, job *models.ScanJob) context.Context {
+	logger := logging.FromContext(parent, s.logger).With(
+		logging.KeyJobID, job.ID, logging.KeyScannerID, job.ScannerID)
+	ctx := logging.WithLogger(tracing.Link(s.ctx, parent), logger)
+	ctx = audit.WithOrigin(ctx, audit.OriginFrom(parent))
+	return storage.WithJob(ctx, job.ID, job.CreatedAt)
+}
+
+// startJobSpan starts the span covering a job from its creation until
+// notifyJobFinished ends it
+func startJobSpan(ctx context.Context, name string, job *models.ScanJob) context.Context {
+	ctx, _ = tracing.StartJob(ctx, name, job.CreatedAt,
+		tracing.KeyJobID.String(job.ID), tracing.KeyScannerID.String(job.ScannerID))
+	return ctx
+}
+
+// executeScanJob executes a scan job added with addJob, in a context from jobContext
+func (s *Server) executeScanJob(ctx context.Context, job *models.ScanJob) {
+	defer s.running.Done()
+	ctx = startJobSpan(ctx, "job.scan", job)
 
 	// Update job status
 	s.updateJobStatus(job.ID, "processing", 0)
 	s.broadcastJobUpdate(job)
+	s.webhooks.Send(webhook.EventJobStarted, s.jobSnapshot(job), 0)
 
 	// Progress callback
+	notifyPages := s.pageNotifier(ctx, job)
 	progressCallback := func(progress int) {
 		s.updateJobStatus(job.ID, "processing", progress)
 		s.broadcastJobUpdate(job)
+		notifyPages()
 	}
 
 	// Execute scan
 	results, err := s.scannerManager.Scan(ctx, job.ScannerID, job.Parameters, progressCallback)
+	var outputs []models.OutputResult
+	if err == nil {
+		outputs = s.finishPages(ctx, job, results)
+	}
 
 	s.jobsMutex.Lock()
-	defer s.jobsMutex.Unlock()
-
-	if err != nil {
+	switch {
+	case errors.Is(err, scanner.ErrCancelled):
+		job.Status = "cancelled"
+		job.ErrorCode = scanner.CodeCancelled
+		job.Error = s.shutdownReason()
+	case errors.Is(err, scanner.ErrFeederEmpty) && job.ScheduleID != "":
+		// An empty feeder is expected at unattended stations
+		job.Status = "skipped"
+		job.ErrorCode = scanner.CodeFeederEmpty
+	case err != nil:
 		job.Status = "failed"
 		job.Error = err.Error()
-	} else {
+		job.ErrorCode = scanner.ErrorCode(err)
+	default:
 		job.Status = "completed"
 		job.Results = results
+		job.Outputs = outputs
 		job.Progress = 100
 	}
 
 	now := time.Now()
 	job.CompletedAt = &now
+	s.jobsMutex.Unlock()
 
 	// Broadcast final status
 	s.broadcastJobUpdate(job)
+	s.notifyJobFinished(ctx, job)
+}
+
+// pageNotifier returns a function that sends job.page_scanned for every
+// page written into the job in ctx since it was last called
+func (s *Server) pageNotifier(ctx context.Context, job *models.ScanJob) func() {
+	storeJob, ok := storage.JobFromContext(ctx)
+	if !ok {
+		return func() {}
+	}
+
+	var mutex sync.Mutex
+	sent := 0
+	return func() {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		for pages := storeJob.Pages(); sent < pages; {
+			sent++
+			s.webhooks.Send(webhook.EventJobPageScanned, s.jobSnapshot(job), sent)
+		}
+	}
+}
+
+// notifyJobFinished logs and audits a job's final status, ends the job's
+// span and sends its webhook event
+func (s *Server) notifyJobFinished(ctx context.Context, job *models.ScanJob) {
+	metrics.JobsFinished.WithLabelValues(job.ScannerID, job.Status).Inc()
+	s.auditJobFinished(ctx, job)
+
+	span := trace.SpanFromContext(ctx)
+	span.SetAttributes(attribute.String("scan.job.status", job.Status), tracing.KeyPages.Int(len(job.Results)))
+	var jobErr error
+	if job.Status == "failed" {
+		jobErr = errors.New(job.Error)
+	}
+	tracing.End(span, jobErr)
+
+	logger := logging.FromContext(ctx, s.logger)
+	attrs := []any{"status", job.Status, "pages", len(job.Results)}
+	switch job.Status {
+	case "completed", "skipped":
+		logger.Info("Job finished", attrs...)
+	default:
+		logger.Warn("Job finished", append(attrs, "error_code", job.ErrorCode, "error", job.Error)...)
+	}
+
+	switch job.Status {
+	case "completed":
+		s.webhooks.Send(webhook.EventJobCompleted, s.jobSnapshot(job), 0)
+	case "cancelled":
+		s.webhooks.Send(webhook.EventJobCancelled, s.jobSnapshot(job), 0)
+	case "skipped":
+		s.webhooks.Send(webhook.EventJobSkipped, s.jobSnapshot(job), 0)
+	default:
+		s.webhooks.Send(webhook.EventJobFailed, s.jobSnapshot(job), 0)
+	}
+}
+
+// finishPages runs the pipeline every job's pages go through once they are
+// stored, whether scanned or imported: archiving and the job's email and
+// export destinations. It returns the outcome of the deliveries.
+func (s *Server) finishPages(ctx context.Context, job *models.ScanJob, results []models.ScanResult) []models.OutputResult {
+	s.archiveResults(ctx, job, results)
+	return s.deliverOutputs(ctx, job, results)
+}
+
+// archiveResults uploads a completed job's pages to the storage backend.
+// Failures are logged; the scan itself still succeeded and its local files remain.
+func (s *Server) archiveResults(ctx context.Context, job *models.ScanJob, results []models.ScanResult) {
+	if err := s.scannerManager.ArchiveResults(ctx, job, results); err != nil {
+		logging.FromContext(ctx, s.logger).Error("Failed to archive job", logging.Err(err))
+	}
 }
 
 // createBatchScan creates a NAPS2-style batch scan
@@ -178,6 +440,8 @@ func (s *Server) createBatchScan(c *gin.Context) {
 		ScannerID     string                `json:"scanner_id" binding:"required"`
 		Parameters    models.ScanParams     `json:"parameters" binding:"required"`
 		BatchSettings models.BatchSettings  `json:"batch_settings" binding:"required"`
+		Email         *models.EmailRequest  `json:"email"`  // Send the job by email once it completes
+		Export        *models.ExportRequest `json:"export"` // Copy the job to remote folders once it completes
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -185,15 +449,118 @@ func (s *Server) createBatchScan(c *gin.Context) {
 		return
 	}
 
+	if !allowScan(c, req.ScannerID) {
+		return
+	}
+
+	// save_path writes anywhere the server may write, so only admins can set it
+	if req.BatchSettings.SavePath != "" && !auth.FromContext(c).IsAdmin() {
+		auth.Forbid(c, "only admins can set batch_settings.save_path")
+		return
+	}
+
+	// Manual duplex feeds both passes one side at a time, so validate the
+	// parameters it will actually scan with
+	manualDuplex := req.BatchSettings.ScanType == models.BatchScanManualDuplex
+	if manualDuplex {
+		req.Parameters.UseFeeder = true
+		req.Parameters.UseDuplex = false
+	}
+
+	// Check parameters against scanner capabilities
+	if !s.validateScanParams(c, req.ScannerID, &req.Parameters) {
+		return
+	}
+	if manualDuplex && !req.Parameters.UseFeeder {
+		// Coercion fell back to the flatbed
+		respondError(c, &scanner.ValidationError{ScannerID: req.ScannerID, Fields: []scanner.FieldError{{
+			Field: "use_feeder", Value: true, Allowed: []bool{false},
+			Message: "manual duplex needs a document feeder",
+		}}})
+		return
+	}
+
+	if !s.checkOutputs(c, req.Email, req.Export) {
+		return
+	}
+
+	// Refuse new jobs when the disk is full
+	if err := s.janitor.CheckCapacity(); err != nil {
+		respondError(c, err)
+		return
+	}
+
 	// Fill in scan params in batch settings
 	req.BatchSettings.ScanParams = req.Parameters
 
-	ctx := context.Background()
+	// Record the batch as a job so its pages can be served
+	job := &models.ScanJob{
+		ID:         models.GenerateUUID(),
+		ScannerID:  req.ScannerID,
+		Status:     "processing",
+		Parameters: req.Parameters,
+		Results:    []models.ScanResult{},
+		CreatedAt:  time.Now(),
+		Email:      req.Email,
+		Export:     req.Export,
+		Owner:      ownerOf(c),
+		Profile:    req.BatchSettings.ProfileDisplayName,
+	}
+
+	if err := s.addJob(job); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	snapshot := s.jobSnapshot(job)
+	s.webhooks.Send(webhook.EventJobCreated, snapshot, 0)
+	s.webhooks.Send(webhook.EventJobStarted, snapshot, 0)
+
+	ctx := s.jobContext(c.Request.Context(), job)
 
 	// Create batch scan performer
-	performer := scanner.NewBatchScanPerformer(s.scannerManager.GetDriver())
+	performer := scanner.NewBatchScanPerformer(s.scannerManager.GetDriver(), s.logger)
+	performer.OnPrompt(func(ctx context.Context, message string) error {
+		return s.waitForUser(ctx, job, message)
+	})
+
+	// Manual duplex waits for the user between passes, so it runs in the
+	// background and is followed through the job status
+	if manualDuplex {
+		snapshot := *job
+		go s.runBatchScan(ctx, job, performer, req.BatchSettings)
+		c.JSON(http.StatusAccepted, snapshot)
+		return
+	}
+
+	// Execute batch scan
+	scans, err := s.runBatchScan(ctx, job, performer, req.BatchSettings)
+	if err != nil {
+		respondError(c, fmt.Errorf("batch scan failed: %w", err))
+		return
+	}
+
+	// Calculate totals
+	totalScans := len(scans)
+	totalPages := len(job.Results)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":     "Batch scan completed successfully",
+		"job_id":      job.ID,
+		"total_scans": totalScans,
+		"total_pages": totalPages,
+		"scans":       scans,
+	})
+}
+
+// runBatchScan performs a batch scan for job, archives its pages and records the outcome in the job.
+// The job must have been added with addJob.
+func (s *Server) runBatchScan(ctx context.Context, job *models.ScanJob, performer *scanner.BatchScanPerformer, settings models.BatchSettings) ([][]models.ScanResult, error) {
+	defer s.running.Done()
+	ctx = startJobSpan(ctx, "job.batch_scan", job)
 
 	// Progress callback
+	notifyPages := s.pageNotifier(ctx, job)
 	progressCallback := func(progress models.BatchScanProgress) {
 		// Broadcast progress via WebSocket
 		if s.wsHub != nil {
@@ -201,33 +568,122 @@ func (s *Server) createBatchScan(c *gin.Context) {
 				Type:    "batch_scan_progress",
 				Payload: progress,
 				Time:    time.Now(),
+				Job:     job,
 			}
 			s.wsHub.Broadcast(msg)
 		}
+		notifyPages()
 	}
 
-	// Execute batch scan
-	scans, err := performer.PerformBatchScan(ctx, req.ScannerID, req.BatchSettings, progressCallback)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Batch scan failed: " + err.Error(),
-		})
+	scans, err := performer.PerformBatchScan(ctx, job.ScannerID, settings, progressCallback)
+
+	var results []models.ScanResult
+	var outputs []models.OutputResult
+	if err == nil {
+		for _, scan := range scans {
+			results = append(results, scan...)
+		}
+		outputs = s.finishPages(ctx, job, results)
+
+		// Report the storage URIs in the per-scan results too
+		offset := 0
+		for _, scan := range scans {
+			offset += copy(scan, results[offset:])
+		}
+	}
+
+	s.jobsMutex.Lock()
+	now := time.Now()
+	job.CompletedAt = &now
+	switch {
+	case errors.Is(err, scanner.ErrCancelled), errors.Is(err, context.Canceled):
+		// A prompt left unanswered at shutdown ends with context.Canceled
+		job.Status = "cancelled"
+		job.ErrorCode = scanner.CodeCancelled
+		job.Error = s.shutdownReason()
+	case err != nil:
+		job.Status = "failed"
+		job.Error = err.Error()
+		job.ErrorCode = scanner.ErrorCode(err)
+	default:
+		job.Status = "completed"
+		job.Progress = 100
+		job.Results = results
+		job.Outputs = outputs
+	}
+	s.jobsMutex.Unlock()
+
+	s.broadcastJobUpdate(job)
+	s.notifyJobFinished(ctx, job)
+	return scans, err
+}
+
+// waitForUser marks a job as waiting for the user and blocks until the
+// user continues (POST /jobs/:id/continue) or cancels it
+func (s *Server) waitForUser(ctx context.Context, job *models.ScanJob, message string) error {
+	reply := make(chan bool, 1)
+
+	s.jobsMutex.Lock()
+	s.prompts[job.ID] = reply
+	job.Status = "waiting"
+	job.Prompt = message
+	s.jobsMutex.Unlock()
+	s.broadcastJobUpdate(job)
+
+	_, span := tracing.Start(ctx, "job.wait_for_user")
+	proceed := false
+	select {
+	case proceed = <-reply:
+	case <-ctx.Done():
+	}
+	span.SetAttributes(attribute.Bool("scan.continued", proceed))
+	span.End()
+
+	s.jobsMutex.Lock()
+	delete(s.prompts, job.ID)
+	job.Status = "processing"
+	job.Prompt = ""
+	s.jobsMutex.Unlock()
+	s.broadcastJobUpdate(job)
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if !proceed {
+		return scanner.ErrCancelled
+	}
+	return nil
+}
+
+// continueJob resumes a job that is waiting for the user
+func (s *Server) continueJob(c *gin.Context) {
+	jobID := c.Param("id")
+
+	s.jobsMutex.RLock()
+	job, ok := s.jobs[jobID]
+	s.jobsMutex.RUnlock()
+
+	if !ok || !canSeeJob(auth.FromContext(c), job) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "job not found"})
+		return
+	}
+	if !allowJobChange(c, job) {
 		return
 	}
 
-	// Calculate totals
-	totalScans := len(scans)
-	totalPages := 0
-	for _, scan := range scans {
-		totalPages += len(scan)
+	s.jobsMutex.Lock()
+	reply, waiting := s.prompts[jobID]
+	delete(s.prompts, jobID)
+	s.jobsMutex.Unlock()
+
+	if !waiting {
+		c.JSON(http.StatusConflict, gin.H{"error": "job is not waiting for the user"})
+		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"message":     "Batch scan completed successfully",
-		"total_scans": totalScans,
-		"total_pages": totalPages,
-		"scans":       scans,
-	})
+	reply <- true
+
+	c.JSON(http.StatusOK, gin.H{"message": "job continued"})
 }
 
 // listJobs returns all jobs
@@ -235,9 +691,12 @@ func (s *Server) listJobs(c *gin.Context) {
 	s.jobsMutex.RLock()
 	defer s.jobsMutex.RUnlock()
 
+	principal := auth.FromContext(c)
 	jobs := make([]*models.ScanJob, 0, len(s.jobs))
 	for _, job := range s.jobs {
-		jobs = append(jobs, job)
+		if canSeeJob(principal, job) {
+			jobs = append(jobs, job)
+		}
 	}
 
 	c.JSON(http.StatusOK, gin.H{"jobs": jobs})
@@ -249,6 +708,7 @@ func (s *Server) getJob(c *gin.Context) {
 
 	s.jobsMutex.RLock()
 	job, ok := s.jobs[jobID]
+	ok = ok && canSeeJob(auth.FromContext(c), job)
 	s.jobsMutex.RUnlock()
 
 	if !ok {
@@ -263,16 +723,33 @@ func (s *Server) getJob(c *gin.Context) {
 func (s *Server) cancelJob(c *gin.Context) {
 	jobID := c.Param("id")
 
-	s.jobsMutex.Lock()
+	s.jobsMutex.RLock()
 	job, ok := s.jobs[jobID]
-	s.jobsMutex.Unlock()
+	s.jobsMutex.RUnlock()
 
-	if !ok {
+	if !ok || !canSeeJob(auth.FromContext(c), job) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "job not found"})
 		return
 	}
+	if !allowJobChange(c, job) {
+		return
+	}
 
-	if job.Status != "processing" {
+	s.jobsMutex.Lock()
+	reply, waiting := s.prompts[jobID]
+	delete(s.prompts, jobID)
+	running := job.Status == "processing"
+	s.jobsMutex.Unlock()
+
+	// A job waiting for the user is cancelled by declining its prompt
+	if waiting {
+		reply <- false
+		s.auditCancel(c, job)
+		c.JSON(http.StatusOK, gin.H{"message": "job cancelled"})
+		return
+	}
+
+	if !running {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "job is not running"})
 		return
 	}
@@ -280,15 +757,25 @@ func (s *Server) cancelJob(c *gin.Context) {
 	// Cancel scan
 	err := s.scannerManager.CancelScan(c.Request.Context(), job.ScannerID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, err)
 		return
 	}
 
+	// The job may have finished while the scan was being cancelled
+	s.jobsMutex.Lock()
+	if job.Status != "processing" {
+		s.jobsMutex.Unlock()
+		c.JSON(http.StatusBadRequest, gin.H{"error": "job is not running"})
+		return
+	}
 	job.Status = "cancelled"
+	job.ErrorCode = scanner.CodeCancelled
 	now := time.Now()
 	job.CompletedAt = &now
+	s.jobsMutex.Unlock()
 
 	s.broadcastJobUpdate(job)
+	s.auditCancel(c, job)
 
 	c.JSON(http.StatusOK, gin.H{"message": "job cancelled"})
 }
@@ -301,21 +788,110 @@ func (s *Server) healthCheck(c *gin.Context) {
 	})
 }
 
-// serveScannedFile serves scanned image files
-func (s *Server) serveScannedFile(c *gin.Context) {
-	filepath := c.Param("filepath")
+// getStorageUsage returns storage usage of the output directory
+func (s *Server) getStorageUsage(c *gin.Context) {
+	usage, err := s.janitor.Usage()
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, usage)
+}
+
+// removeJobsForFiles deletes finished jobs that reference any of the removed files,
+// as well as finished jobs older than the retention period
+func (s *Server) removeJobsForFiles(paths []string) {
+	removed := make(map[string]bool, len(paths))
+	for _, path := range paths {
+		removed[path] = true
+	}
+
+	var cutoff time.Time
+	if s.config.Storage.RetentionDays > 0 {
+		cutoff = time.Now().AddDate(0, 0, -s.config.Storage.RetentionDays)
+	}
+
+	s.jobsMutex.Lock()
+	defer s.jobsMutex.Unlock()
+
+	for id, job := range s.jobs {
+		if job.CompletedAt == nil {
+			continue // Still running
+		}
 
-	// Security: prevent directory traversal
-	if filepath == "" || filepath[0] != '/' {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid file path"})
+		evict := !cutoff.IsZero() && job.CompletedAt.Before(cutoff)
+		for _, result := range job.Results {
+			if abs, err := filepath.Abs(result.FilePath); err == nil && removed[abs] {
+				evict = true
+				break
+			}
+		}
+
+		if evict {
+			delete(s.jobs, id)
+		}
+	}
+}
+
+// servePage serves a scanned page of a job from the storage directory.
+// Range requests and conditional requests (ETag/If-None-Match) are supported.
+func (s *Server) servePage(c *gin.Context) {
+	jobID := c.Param("id")
+
+	n, err := strconv.Atoi(c.Param("n"))
+	if err != nil || n < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid page number"})
 		return
 	}
 
-	// Remove leading slash
-	filepath = filepath[1:]
+	s.jobsMutex.RLock()
+	job, ok := s.jobs[jobID]
+	ok = ok && canSeeJob(auth.FromContext(c), job)
+	var filePath string
+	if ok && n <= len(job.Results) {
+		filePath = job.Results[n-1].FilePath
+	}
+	s.jobsMutex.RUnlock()
 
-	// Serve the file from the scans directory
-	c.File(filepath)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "job not found"})
+		return
+	}
+	if filePath == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "page not found"})
+		return
+	}
+
+	file, info, err := s.scannerManager.Store().Open(filePath)
+	if err != nil {
+		if !errors.Is(err, storage.ErrNotInStore) && !os.IsNotExist(err) {
+			logging.FromContext(c.Request.Context(), s.logger).Error("Failed to open page",
+				logging.KeyJobID, jobID, "page", n, logging.Err(err))
+		}
+		c.JSON(http.StatusNotFound, gin.H{"error": "page not found"})
+		return
+	}
+	defer file.Close()
+
+	c.Header("ETag", fmt.Sprintf(`"%x-%x"`, info.ModTime().UnixNano(), info.Size()))
+	c.Header("Cache-Control", "private, no-cache")
+
+	// ServeContent picks the content type from the extension or by sniffing
+	// and handles Range, If-None-Match and If-Modified-Since
+	http.ServeContent(c.Writer, c.Request, filepath.Base(filePath), info.ModTime(), file)
+
+	// Partial and not-modified responses are part of a download already audited
+	if c.Writer.Status() == http.StatusOK {
+		s.audit.Record(c.Request.Context(), audit.Event{
+			Action:    audit.ActionDownload,
+			JobID:     jobID,
+			ScannerID: job.ScannerID,
+			Status:    "sent",
+			Pages:     1,
+			Detail:    fmt.Sprintf("page %d", n),
+		})
+	}
 }
 
 // serveDashboard serves the web dashboard
@@ -325,32 +901,108 @@ func (s *Server) serveDashboard(c *gin.Context) {
 	})
 }
 
-// updateJobStatus updates job status and progress
+// updateJobStatus updates job status and progress. A job that has already
+// finished, e.g. cancelled while its scan was still reporting pages, keeps
+// its final status.
 func (s *Server) updateJobStatus(jobID string, status string, progress int) {
 	s.jobsMutex.Lock()
 	defer s.jobsMutex.Unlock()
 
-	if job, ok := s.jobs[jobID]; ok {
+	if job, ok := s.jobs[jobID]; ok && !isFinished(job) {
 		job.Status = status
 		job.Progress = progress
 	}
 }
 
+// jobSnapshot returns a copy of job taken under jobsMutex, which can be
+// read while the job keeps running
+func (s *Server) jobSnapshot(job *models.ScanJob) models.ScanJob {
+	s.jobsMutex.RLock()
+	defer s.jobsMutex.RUnlock()
+
+	snapshot := *job
+	snapshot.Results = append(job.Results[:0:0], job.Results...)
+	snapshot.Outputs = append(job.Outputs[:0:0], job.Outputs...)
+	return snapshot
+}
+
 // broadcastJobUpdate broadcasts job update via WebSocket
 func (s *Server) broadcastJobUpdate(job *models.ScanJob) {
 	if s.wsHub != nil {
+		snapshot := s.jobSnapshot(job)
 		msg := models.WebSocketMessage{
 			Type:    "job_status",
-			Payload: job,
+			Payload: &snapshot,
 			Time:    time.Now(),
+			Job:     &snapshot,
 		}
 		s.wsHub.Broadcast(msg)
 	}
 }
 
-// Run starts the HTTP server
-func (s *Server) Run(addr string) error {
-	return s.router.Run(addr)
+// Run starts the HTTP server, or the HTTPS server if tlsConfig is set.
+// With server.tls.redirect_port, plain HTTP requests on that port are
+// redirected to HTTPS.
+//
+// Run returns http.ErrServerClosed once Shutdown has been called.
+func (s *Server) Run(addr string, tlsConfig *tls.Config) error {
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           s.router,
+		TLSConfig:         tlsConfig,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	s.trackHTTPServer(srv)
+	if tlsConfig == nil {
+		return srv.ListenAndServe()
+	}
+
+	if port := s.config.Server.TLS.RedirectPort; port > 0 {
+		redirectAddr := net.JoinHostPort(s.config.Server.Host, strconv.Itoa(port))
+		go func() {
+			redirect := &http.Server{
+				Addr:              redirectAddr,
+				Handler:           httpsRedirect(s.config.Server.Port),
+				ReadHeaderTimeout: 10 * time.Second,
+			}
+			s.trackHTTPServer(redirect)
+			if err := redirect.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				s.logger.Error("HTTP redirect listener failed", logging.Err(err))
+			}
+		}()
+	}
+
+	// Certificates are in tlsConfig
+	return srv.ListenAndServeTLS("", "")
+}
+
+// httpsRedirect redirects requests to the same host and path on the HTTPS port
+func httpsRedirect(httpsPort int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if httpsPort != 443 {
+			host = net.JoinHostPort(host, strconv.Itoa(httpsPort))
+		} else if strings.Contains(host, ":") {
+			host = "[" + host + "]" // IPv6 literal
+		}
+
+		target := "https://" + host + r.URL.RequestURI()
+		// 308 keeps the method and body of API calls
+		http.Redirect(w, r, target, http.StatusPermanentRedirect)
+	})
+}
+
+// Janitor returns the storage janitor enforcing retention and quota
+func (s *Server) Janitor() *storage.Janitor {
+	return s.janitor
+}
+
+// Webhooks returns the dispatcher sending job events to webhooks
+func (s *Server) Webhooks() *webhook.Dispatcher {
+	return s.webhooks
 }
 
 // Router returns the Gin router (useful for testing)
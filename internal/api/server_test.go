@@ -0,0 +1,200 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/scanserver/scanner-service/internal/auth"
+	"github.com/scanserver/scanner-service/internal/config"
+	"github.com/scanserver/scanner-service/internal/cors"
+	"github.com/scanserver/scanner-service/internal/export"
+	"github.com/scanserver/scanner-service/internal/scanner"
+	"github.com/scanserver/scanner-service/internal/storage"
+	"github.com/scanserver/scanner-service/internal/webhook"
+	"github.com/scanserver/scanner-service/pkg/models"
+)
+
+var testLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+// newTestServer creates a server with the settings of cfg, scanning with the
+// platform's driver into a temporary directory and exporting to a Paperless
+// consume directory
+func newTestServer(t *testing.T, cfg *config.Config) *Server {
+	t.Helper()
+	dir := t.TempDir()
+
+	gin.SetMode(gin.TestMode)
+
+	cfg.Server.WebDir = filepath.Join("..", "..", "web")
+	cfg.Storage.OutputDir = filepath.Join(dir, "scans")
+	cfg.Export.MaxAttempts = 1
+	cfg.Export.Destinations = map[string]config.ExportDestination{
+		"paperless": {Type: "paperless", ConsumeDir: filepath.Join(dir, "consume"), Format: "images"},
+	}
+
+	store := storage.NewFileStore(cfg.Storage.OutputDir)
+	manager, err := scanner.NewManager(store, storage.NewLocalStorage(cfg.Storage.OutputDir), testLogger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := manager.ListScanners(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	exports, err := export.NewManager(&cfg.Export, testLogger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	authenticator, err := auth.NewAuthenticator(&cfg.Auth, testLogger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	corsPolicy, err := cors.NewPolicy(&cfg.Server.CORS)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return NewServer(manager, NewWebSocketHub(), exports, authenticator, corsPolicy, nil, testLogger, cfg)
+}
+
+// TestWebhookPayloadWhileJobRuns sends webhook events while the job they
+// describe is being updated. Run with -race.
+func TestWebhookPayloadWhileJobRuns(t *testing.T) {
+	var mutex sync.Mutex
+	var payloads []webhook.Payload
+	endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload webhook.Payload
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Error(err)
+		}
+		mutex.Lock()
+		payloads = append(payloads, payload)
+		mutex.Unlock()
+	}))
+	defer endpoint.Close()
+
+	cfg := &config.Config{}
+	cfg.Webhooks.MaxAttempts = 1
+	cfg.Webhooks.Endpoints = []config.WebhookEndpoint{{URL: endpoint.URL}}
+	s := newTestServer(t, cfg)
+	s.Webhooks().Start()
+
+	job := &models.ScanJob{ID: "job-1", ScannerID: "scanner-001", Status: "pending", Results: []models.ScanResult{}}
+	if err := s.addJob(job); err != nil {
+		t.Fatal(err)
+	}
+	defer s.running.Done()
+
+	// Pages are added the way executeScanJob and the page edits do
+	const pages = 50
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for page := 1; page <= pages; page++ {
+			s.updateJobStatus(job.ID, "processing", page)
+			s.jobsMutex.Lock()
+			job.Results = append(job.Results, models.ScanResult{PageNumber: page})
+			job.Progress = page
+			s.jobsMutex.Unlock()
+		}
+	}()
+	for page := 1; page <= pages; page++ {
+		s.webhooks.Send(webhook.EventJobPageScanned, s.jobSnapshot(job), page)
+	}
+	<-done
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !delivered(s.Webhooks()) {
+		if time.Now().After(deadline) {
+			t.Fatal("deliveries did not finish")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	s.Webhooks().Stop()
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	if len(payloads) != pages {
+		t.Fatalf("%d payloads delivered, want %d", len(payloads), pages)
+	}
+	for _, payload := range payloads {
+		if got := payload.Job; got.Progress != len(got.Results) {
+			t.Errorf("payload %d: progress %d with %d pages", payload.Page, got.Progress, len(got.Results))
+		}
+	}
+}
+
+// delivered reports whether every queued delivery has finished
+func delivered(d *webhook.Dispatcher) bool {
+	for _, delivery := range d.Deliveries(webhook.DeliveryFilter{}) {
+		if delivery.Status == webhook.StatusPending {
+			return false
+		}
+	}
+	return true
+}
+
+func TestPublicRoutes(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Auth = config.AuthConfig{Enabled: true, APIKeys: []config.APIKey{{Name: "portal", Key: "portal-key"}}}
+	s := newTestServer(t, cfg)
+	s.AddWebSocketRoute()
+
+	for path, public := range map[string]bool{
+		"/api/v1/health":   true,
+		"/healthz":         true,
+		"/readyz":          true,
+		"/metrics":         true,
+		"/api/v1/scanners": false,
+		"/api/v1/jobs":     false,
+		"/ws":              false,
+	} {
+		w := httptest.NewRecorder()
+		s.Router().ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if got := w.Code != http.StatusUnauthorized; got != public {
+			t.Errorf("%s: status %d without credentials", path, w.Code)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/api/v1/scanners", nil)
+	r.Header.Set("X-API-Key", "portal-key")
+	s.Router().ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Errorf("/api/v1/scanners: status %d with an API key", w.Code)
+	}
+}
+
+func TestUpdateJobStatusKeepsFinalStatus(t *testing.T) {
+	s := newTestServer(t, &config.Config{})
+
+	for _, status := range []string{"pending", "processing", "waiting"} {
+		job := &models.ScanJob{ID: "job-" + status, Status: status}
+		s.jobs[job.ID] = job
+		s.updateJobStatus(job.ID, "processing", 3)
+		if job.Status != "processing" || job.Progress != 3 {
+			t.Errorf("%s job: status %s, progress %d", status, job.Status, job.Progress)
+		}
+	}
+
+	// A page reported after the job was cancelled must not revive it
+	for _, status := range []string{"completed", "failed", "cancelled", "skipped"} {
+		job := &models.ScanJob{ID: "job-" + status, Status: status, Progress: 1}
+		s.jobs[job.ID] = job
+		s.updateJobStatus(job.ID, "processing", 3)
+		if job.Status != status || job.Progress != 1 {
+			t.Errorf("%s job: status %s, progress %d", status, job.Status, job.Progress)
+		}
+	}
+
+	s.updateJobStatus("missing", "processing", 3)
+}
@@ -0,0 +1,118 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/scanserver/scanner-service/pkg/models"
+)
+
+// cancelGrace is how long cancelled jobs get to record their outcome
+const cancelGrace = 10 * time.Second
+
+// addJob records a new job and counts it as running until the code executing
+// it calls s.running.Done(). It fails with ErrShuttingDown once Shutdown has begun.
+func (s *Server) addJob(job *models.ScanJob) error {
+	s.jobsMutex.Lock()
+	defer s.jobsMutex.Unlock()
+
+	if s.draining {
+		return ErrShuttingDown
+	}
+	s.jobs[job.ID] = job
+	s.running.Add(1)
+	return nil
+}
+
+// shutdownReason explains a cancellation caused by Shutdown, and is empty otherwise
+func (s *Server) shutdownReason() string {
+	if s.ctx.Err() != nil {
+		return "cancelled by server shutdown"
+	}
+	return ""
+}
+
+// trackHTTPServer registers a server started by Run so Shutdown can stop it
+func (s *Server) trackHTTPServer(srv *http.Server) {
+	s.httpMutex.Lock()
+	s.httpServers = append(s.httpServers, srv)
+	s.httpMutex.Unlock()
+}
+
+// Shutdown stops the server gracefully. New jobs are refused with
+// ErrShuttingDown while running scans, batch scans, imports and their email
+// and export deliveries finish. Jobs still running when ctx is done are
+// cancelled. The job records are then saved to storage.jobs_file, WebSocket
+// clients are sent a close frame and the HTTP server is stopped.
+//
+// The API keeps serving while jobs drain, so clients can follow them and
+// answer manual duplex prompts.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.jobsMutex.Lock()
+	s.draining = true
+	waiting := 0
+	for _, job := range s.jobs {
+		if !isFinished(job) {
+			waiting++
+		}
+	}
+	s.jobsMutex.Unlock()
+
+	drained := make(chan struct{})
+	go func() {
+		s.running.Wait()
+		close(drained)
+	}()
+
+	if waiting > 0 {
+		s.logger.Info("Waiting for running jobs to finish", "jobs", waiting)
+	}
+	select {
+	case <-drained:
+	case <-ctx.Done():
+		s.logger.Warn("Shutdown timeout reached, cancelling running jobs")
+		s.cancelJobs()
+		select {
+		case <-drained:
+		case <-time.After(cancelGrace):
+			s.logger.Warn("Some jobs did not stop after being cancelled")
+		}
+	}
+	s.cancelJobs()
+
+	var errs []error
+	if err := s.saveJobs(); err != nil {
+		errs = append(errs, err)
+	}
+
+	s.wsHub.Close()
+
+	// Requests still in flight get until ctx is done, or a moment if it already is
+	httpCtx := ctx
+	if ctx.Err() != nil {
+		var cancel context.CancelFunc
+		httpCtx, cancel = context.WithTimeout(context.Background(), cancelGrace)
+		defer cancel()
+	}
+	s.httpMutex.Lock()
+	servers := s.httpServers
+	s.httpMutex.Unlock()
+	for _, srv := range servers {
+		if err := srv.Shutdown(httpCtx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// isFinished reports whether a job has reached a final status
+func isFinished(job *models.ScanJob) bool {
+	switch job.Status {
+	case "completed", "failed", "cancelled", "skipped":
+		return true
+	}
+	return false
+}
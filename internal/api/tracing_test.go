@@ -0,0 +1,110 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/scanserver/scanner-service/internal/config"
+)
+
+// recordSpans installs a tracer provider that keeps finished spans in memory
+// until the test ends
+func recordSpans(t *testing.T) *tracetest.InMemoryExporter {
+	t.Helper()
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() {
+		otel.SetTracerProvider(previous)
+		provider.Shutdown(context.Background())
+	})
+	return exporter
+}
+
+func TestScanJobTrace(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the Windows driver needs a scanner")
+	}
+	if testing.Short() {
+		t.Skip("the simulated scanner takes seconds per page")
+	}
+	spans := recordSpans(t)
+	s := newTestServer(t, &config.Config{})
+
+	body := `{
+		"scanner_id": "scanner-001",
+		"parameters": {"resolution": 150, "format": "JPEG", "scale_ratio": 2, "jpeg_quality": 80},
+		"export": {"destinations": ["paperless"]}
+	}`
+	w := httptest.NewRecorder()
+	s.Router().ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/scan", strings.NewReader(body)))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+
+	// The job span ends once the job has finished
+	byName := make(map[string]tracetest.SpanStub)
+	for deadline := time.Now().Add(10 * time.Second); byName["job.scan"].Name == ""; time.Sleep(50 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("the job did not finish")
+		}
+		for _, span := range spans.GetSpans() {
+			byName[span.Name] = span
+		}
+	}
+	if job := byName["job.scan"]; !hasAttribute(job, "scan.job.status", "completed") {
+		t.Fatalf("job span attributes %v", job.Attributes)
+	}
+
+	// Each span is the child of the step it is part of
+	for _, tt := range []struct{ name, parent string }{
+		{"job.scan", "POST /api/v1/scan"},
+		{"job.queue_wait", "job.scan"},
+		{"scanner.scan", "job.scan"},
+		{"scanner.page", "scanner.scan"},
+		{"postprocess.scale", "scanner.scan"},
+		{"postprocess.quality", "scanner.scan"},
+		{"storage.archive", "job.scan"},
+		{"export.upload", "job.scan"},
+	} {
+		span, ok := byName[tt.name]
+		if !ok {
+			t.Errorf("no %s span", tt.name)
+			continue
+		}
+		parent := byName[tt.parent]
+		if span.Parent.SpanID() != parent.SpanContext.SpanID() || span.SpanContext.TraceID() != parent.SpanContext.TraceID() {
+			t.Errorf("%s is not a child of %s", tt.name, tt.parent)
+		}
+	}
+
+	// The steps follow one another
+	order := []string{"job.queue_wait", "scanner.page", "postprocess.scale", "postprocess.quality", "export.upload"}
+	for i := 1; i < len(order); i++ {
+		before, after := byName[order[i-1]], byName[order[i]]
+		if after.StartTime.Before(before.EndTime) {
+			t.Errorf("%s started before %s ended", order[i], order[i-1])
+		}
+	}
+}
+
+// hasAttribute reports whether span has the string attribute key set to value
+func hasAttribute(span tracetest.SpanStub, key, value string) bool {
+	for _, attr := range span.Attributes {
+		if string(attr.Key) == key {
+			return attr.Value.AsString() == value
+		}
+	}
+	return false
+}
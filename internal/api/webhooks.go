@@ -0,0 +1,42 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/scanserver/scanner-service/internal/webhook"
+)
+
+// listWebhookDeliveries returns the webhook delivery log, newest first.
+// Query parameters job_id, event and status filter it; limit caps its length.
+func (s *Server) listWebhookDeliveries(c *gin.Context) {
+	filter := webhook.DeliveryFilter{
+		JobID:  c.Query("job_id"),
+		Event:  webhook.Event(c.Query("event")),
+		Status: c.Query("status"),
+	}
+
+	if limit := c.Query("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 0 {
+			respondError(c, fmt.Errorf("%w: limit must be a non-negative number", ErrInvalidParameter))
+			return
+		}
+		filter.Limit = n
+	}
+
+	c.JSON(http.StatusOK, gin.H{"deliveries": s.webhooks.Deliveries(filter)})
+}
+
+// getWebhookDelivery returns one delivery with all its attempts
+func (s *Server) getWebhookDelivery(c *gin.Context) {
+	delivery, err := s.webhooks.Delivery(c.Param("id"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, delivery)
+}
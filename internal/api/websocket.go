@@ -2,29 +2,24 @@ package api
 
 import (
 	"encoding/json"
-	"log"
+	"log/slog"
 	"net/http"
 	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
+	"github.com/scanserver/scanner-service/internal/auth"
+	"github.com/scanserver/scanner-service/internal/logging"
 	"github.com/scanserver/scanner-service/pkg/models"
 )
 
-var upgrader = websocket.Upgrader{
-	ReadBufferSize:  1024,
-	WriteBufferSize: 1024,
-	CheckOrigin: func(r *http.Request) bool {
-		return true // Allow all origins in development
-	},
-}
-
 // Client represents a WebSocket client
 type Client struct {
-	hub  *WebSocketHub
-	conn *websocket.Conn
-	send chan []byte
+	hub       *WebSocketHub
+	conn      *websocket.Conn
+	send      chan []byte
+	principal *auth.Principal // nil when auth is disabled
 }
 
 // WebSocketHub manages WebSocket clients
@@ -34,6 +29,7 @@ type WebSocketHub struct {
 	register   chan *Client
 	unregister chan *Client
 	mutex      sync.RWMutex
+	closed     bool // set by Close, new clients are turned away
 }
 
 // NewWebSocketHub creates a new WebSocket hub
@@ -52,9 +48,14 @@ func (h *WebSocketHub) Run() {
 		select {
 		case client := <-h.register:
 			h.mutex.Lock()
+			if h.closed {
+				h.mutex.Unlock()
+				close(client.send) // writePump sends a close frame
+				continue
+			}
 			h.clients[client] = true
 			h.mutex.Unlock()
-			log.Printf("WebSocket client connected, total: %d", len(h.clients))
+			slog.Debug("WebSocket client connected", "clients", len(h.clients))
 
 		case client := <-h.unregister:
 			h.mutex.Lock()
@@ -63,17 +64,20 @@ func (h *WebSocketHub) Run() {
 				close(client.send)
 			}
 			h.mutex.Unlock()
-			log.Printf("WebSocket client disconnected, total: %d", len(h.clients))
+			slog.Debug("WebSocket client disconnected", "clients", len(h.clients))
 
 		case message := <-h.broadcast:
 			data, err := json.Marshal(message)
 			if err != nil {
-				log.Printf("Error marshaling message: %v", err)
+				slog.Error("Failed to marshal WebSocket message", logging.Err(err))
 				continue
 			}
 
 			h.mutex.RLock()
 			for client := range h.clients {
+				if message.Job != nil && !canSeeJob(client.principal, message.Job) {
+					continue
+				}
 				select {
 				case client.send <- data:
 				default:
@@ -86,6 +90,29 @@ func (h *WebSocketHub) Run() {
 	}
 }
 
+// Close sends every client a "going away" close frame and disconnects it
+func (h *WebSocketHub) Close() {
+	message := websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down")
+	deadline := time.Now().Add(5 * time.Second)
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.closed = true
+	for client := range h.clients {
+		client.conn.WriteControl(websocket.CloseMessage, message, deadline)
+		close(client.send)
+		delete(h.clients, client)
+	}
+}
+
+// ClientCount returns the number of connected clients
+func (h *WebSocketHub) ClientCount() int {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	return len(h.clients)
+}
+
 // Broadcast sends a message to all connected clients
 func (h *WebSocketHub) Broadcast(message models.WebSocketMessage) {
 	h.broadcast <- message
@@ -108,7 +135,7 @@ func (c *Client) readPump() {
 		_, _, err := c.conn.ReadMessage()
 		if err != nil {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
-				log.Printf("WebSocket error: %v", err)
+				slog.Warn("WebSocket connection closed unexpectedly", logging.Err(err))
 			}
 			break
 		}
@@ -158,19 +185,27 @@ func (c *Client) writePump() {
 	}
 }
 
-// HandleWebSocket handles WebSocket upgrade requests
-func HandleWebSocket(hub *WebSocketHub) gin.HandlerFunc {
+// HandleWebSocket handles WebSocket upgrade requests from origins accepted by checkOrigin
+func HandleWebSocket(hub *WebSocketHub, checkOrigin func(r *http.Request) bool) gin.HandlerFunc {
+	upgrader := websocket.Upgrader{
+		ReadBufferSize:  1024,
+		WriteBufferSize: 1024,
+		Subprotocols:    auth.WebSocketProtocols(),
+		CheckOrigin:     checkOrigin,
+	}
+
 	return func(c *gin.Context) {
 		conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
 		if err != nil {
-			log.Printf("WebSocket upgrade error: %v", err)
+			logging.FromContext(c.Request.Context(), nil).Warn("WebSocket upgrade failed", logging.Err(err))
 			return
 		}
 
 		client := &Client{
-			hub:  hub,
-			conn: conn,
-			send: make(chan []byte, 256),
+			hub:       hub,
+			conn:      conn,
+			send:      make(chan []byte, 256),
+			principal: auth.FromContext(c),
 		}
 
 		client.hub.register <- client
@@ -183,5 +218,5 @@ func HandleWebSocket(hub *WebSocketHub) gin.HandlerFunc {
 
 // AddWebSocketRoute adds WebSocket route to the server
 func (s *Server) AddWebSocketRoute() {
-	s.router.GET("/ws", HandleWebSocket(s.wsHub))
+	s.router.GET("/ws", s.auth.Middleware(), HandleWebSocket(s.wsHub, s.cors.CheckOrigin))
 }
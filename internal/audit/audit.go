@@ -0,0 +1,225 @@
+// Package audit keeps an append-only trail of who scanned, downloaded, sent
+// and deleted what, as JSON lines in a file
+package audit
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/scanserver/scanner-service/internal/config"
+	"github.com/scanserver/scanner-service/internal/logging"
+	"github.com/scanserver/scanner-service/pkg/models"
+)
+
+// Actions recorded in the trail
+const (
+	ActionScan     = "scan"     // A scan job finished
+	ActionImport   = "import"   // A hot folder file was imported as a job
+	ActionEdit     = "edit"     // Pages were edited into a new document version
+	ActionDownload = "download" // Pages of a job were downloaded
+	ActionEmail    = "email"    // A completed job was emailed on request
+	ActionExport   = "export"   // A completed job was exported on request
+	ActionCancel   = "cancel"   // A running job was cancelled
+	ActionDelete   = "delete"   // Scanned files were deleted
+)
+
+// Actors that are not API clients
+const (
+	ActorSystem    = "system"    // The server itself: schedules, hot folder, storage cleanup
+	ActorAnonymous = "anonymous" // A client of a server without authentication
+)
+
+// defaultQueryLimit caps the events returned when the filter sets no limit
+const defaultQueryLimit = 1000
+
+// ErrDisabled is returned when querying a trail that is not kept
+var ErrDisabled = errors.New("audit log is disabled")
+
+// Origin identifies who caused an event and from where
+type Origin struct {
+	Actor      string `json:"actor"`                 // API key name or token subject, or one of the Actor constants
+	AuthMethod string `json:"auth_method,omitempty"` // api_key or jwt
+	ClientIP   string `json:"client_ip,omitempty"`
+	RequestID  string `json:"request_id,omitempty"`
+}
+
+// Event is one entry of the trail
+type Event struct {
+	Time   time.Time `json:"time"`
+	Action string    `json:"action"`
+	Origin
+
+	JobID      string                `json:"job_id,omitempty"`
+	ScannerID  string                `json:"scanner_id,omitempty"`
+	ScheduleID string                `json:"schedule_id,omitempty"`
+	Profile    string                `json:"profile,omitempty"` // Batch scan profile, or the email/export profile requested
+	Status     string                `json:"status,omitempty"`  // Final job status, or the outcome of the action
+	Pages      int                   `json:"pages,omitempty"`
+	Outputs    []models.OutputResult `json:"outputs,omitempty"` // Where the pages were sent
+	Files      []string              `json:"files,omitempty"`   // Deleted files
+	Detail     string                `json:"detail,omitempty"`
+}
+
+// Filter selects events from the trail. Empty fields match everything.
+type Filter struct {
+	Since     time.Time
+	Until     time.Time
+	Actor     string
+	Action    string
+	JobID     string
+	ScannerID string
+	Limit     int // 0 = defaultQueryLimit
+}
+
+// matches reports whether an event passes the filter
+func (f Filter) matches(e *Event) bool {
+	return (f.Since.IsZero() || !e.Time.Before(f.Since)) &&
+		(f.Until.IsZero() || e.Time.Before(f.Until)) &&
+		(f.Actor == "" || e.Actor == f.Actor) &&
+		(f.Action == "" || e.Action == f.Action) &&
+		(f.JobID == "" || e.JobID == f.JobID) &&
+		(f.ScannerID == "" || e.ScannerID == f.ScannerID)
+}
+
+// Log appends events to the audit file. A nil *Log records nothing.
+type Log struct {
+	mutex sync.Mutex
+	path  string
+	file  *os.File // nil once closed
+}
+
+// Open opens the configured audit file for appending, creating it if needed.
+// It returns nil if the trail is disabled.
+func Open(cfg *config.AuditConfig) (*Log, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	if cfg.File == "" {
+		return nil, errors.New("audit.file is required")
+	}
+
+	if dir := filepath.Dir(cfg.File); dir != "." {
+		if err := os.MkdirAll(dir, 0o750); err != nil {
+			return nil, fmt.Errorf("failed to create audit log directory: %w", err)
+		}
+	}
+	file, err := os.OpenFile(cfg.File, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	return &Log{path: cfg.File, file: file}, nil
+}
+
+// Record appends an event. The time and, unless set, the origin are filled
+// in from ctx. Events that can't be written are logged instead.
+func (l *Log) Record(ctx context.Context, e Event) {
+	if l == nil {
+		return
+	}
+	e.Time = time.Now().UTC()
+	if e.Origin == (Origin{}) {
+		e.Origin = OriginFrom(ctx)
+	}
+
+	line, err := json.Marshal(e)
+	if err == nil {
+		line = append(line, '\n')
+
+		l.mutex.Lock()
+		if l.file == nil {
+			err = os.ErrClosed
+		} else {
+			_, err = l.file.Write(line)
+		}
+		l.mutex.Unlock()
+	}
+	if err != nil {
+		logging.FromContext(ctx, nil).Error("Failed to write audit event",
+			"action", e.Action, "actor", e.Actor, logging.KeyJobID, e.JobID, logging.Err(err))
+	}
+}
+
+// Query returns the matching events, newest first
+func (l *Log) Query(filter Filter) ([]Event, error) {
+	if l == nil {
+		return nil, ErrDisabled
+	}
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = defaultQueryLimit
+	}
+
+	file, err := os.Open(l.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read audit log: %w", err)
+	}
+	defer file.Close()
+
+	// Keep the last limit matches of the file, which is oldest first
+	matches := make([]Event, 0, limit)
+	next := 0
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		var e Event
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			continue // A line still being written
+		}
+		if !filter.matches(&e) {
+			continue
+		}
+		if len(matches) < limit {
+			matches = append(matches, e)
+		} else {
+			matches[next] = e
+			next = (next + 1) % limit
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read audit log: %w", err)
+	}
+
+	events := make([]Event, 0, len(matches))
+	for i := len(matches) - 1; i >= 0; i-- {
+		events = append(events, matches[(next+i)%len(matches)])
+	}
+	return events, nil
+}
+
+// Close closes the audit file; later events are logged instead of recorded
+func (l *Log) Close() error {
+	if l == nil {
+		return nil
+	}
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
+
+type originKey struct{}
+
+// WithOrigin returns a copy of ctx carrying the origin of the events it causes
+func WithOrigin(ctx context.Context, origin Origin) context.Context {
+	return context.WithValue(ctx, originKey{}, origin)
+}
+
+// OriginFrom returns the origin carried by ctx, or the system if it has none
+func OriginFrom(ctx context.Context) Origin {
+	if origin, ok := ctx.Value(originKey{}).(Origin); ok {
+		return origin
+	}
+	return Origin{Actor: ActorSystem}
+}
@@ -0,0 +1,197 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/scanserver/scanner-service/internal/config"
+)
+
+// openTestLog opens a trail in a temporary directory that already holds events
+func openTestLog(t *testing.T, events ...Event) *Log {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), "audit", "audit.log")
+	l, err := Open(&config.AuditConfig{Enabled: true, File: file})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	for _, e := range events {
+		line, err := json.Marshal(e)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := l.file.Write(append(line, '\n')); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return l
+}
+
+// jobIDs returns the job IDs of events, in order
+func jobIDs(events []Event) []string {
+	ids := make([]string, len(events))
+	for i, e := range events {
+		ids[i] = e.JobID
+	}
+	return ids
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestRecord(t *testing.T) {
+	l := openTestLog(t)
+	before := time.Now().UTC()
+
+	ctx := WithOrigin(context.Background(), Origin{Actor: "portal", AuthMethod: "api_key", ClientIP: "192.0.2.1", RequestID: "req-1"})
+	l.Record(ctx, Event{Action: ActionDownload, JobID: "job-1", Pages: 2})
+	l.Record(context.Background(), Event{Action: ActionDelete, Files: []string{"a.png"}})
+	l.Record(ctx, Event{Action: ActionScan, JobID: "job-2", Origin: Origin{Actor: ActorSystem}})
+
+	events, err := l.Query(Filter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 3 {
+		t.Fatalf("%d events, want 3", len(events))
+	}
+	if e := events[2]; e.Actor != "portal" || e.ClientIP != "192.0.2.1" || e.RequestID != "req-1" || e.Pages != 2 || e.Time.Before(before) {
+		t.Errorf("event from a request = %+v", e)
+	}
+	if e := events[1]; e.Actor != ActorSystem || len(e.Files) != 1 {
+		t.Errorf("event without an origin = %+v", e)
+	}
+	if e := events[0]; e.Actor != ActorSystem || e.ClientIP != "" {
+		t.Errorf("event with its own origin = %+v", e)
+	}
+
+	// Events after Close are dropped, not written to a closed file
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+	l.Record(ctx, Event{Action: ActionScan})
+	if events, _ := l.Query(Filter{}); len(events) != 3 {
+		t.Errorf("%d events after Close, want 3", len(events))
+	}
+	if err := l.Close(); err != nil {
+		t.Errorf("second Close: %v", err)
+	}
+}
+
+func TestNilLog(t *testing.T) {
+	l, err := Open(&config.AuditConfig{File: filepath.Join(t.TempDir(), "audit.log")})
+	if err != nil || l != nil {
+		t.Fatalf("disabled trail: %v, %v", l, err)
+	}
+	l.Record(context.Background(), Event{Action: ActionScan})
+	if _, err := l.Query(Filter{}); !errors.Is(err, ErrDisabled) {
+		t.Errorf("Query: %v, want ErrDisabled", err)
+	}
+	if err := l.Close(); err != nil {
+		t.Errorf("Close: %v", err)
+	}
+
+	if _, err := Open(&config.AuditConfig{Enabled: true}); err == nil {
+		t.Error("opened a trail without a file")
+	}
+}
+
+func TestQuery(t *testing.T) {
+	start := time.Date(2024, 1, 31, 8, 0, 0, 0, time.UTC)
+	var events []Event
+	for i := 0; i < 10; i++ {
+		e := Event{
+			Time:      start.Add(time.Duration(i) * time.Hour),
+			Action:    ActionScan,
+			Origin:    Origin{Actor: "alice"},
+			JobID:     "job-" + strconv.Itoa(i),
+			ScannerID: "scanner-1",
+		}
+		if i%2 == 1 {
+			e.Actor = "bob"
+			e.Action = ActionDownload
+			e.ScannerID = "scanner-2"
+		}
+		events = append(events, e)
+	}
+	l := openTestLog(t, events...)
+
+	// A line being written is skipped
+	if _, err := l.file.WriteString(`{"time":"2024-01-31T20:00:00Z","act`); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		filter Filter
+		want   []string
+	}{
+		{"all", Filter{}, []string{"job-9", "job-8", "job-7", "job-6", "job-5", "job-4", "job-3", "job-2", "job-1", "job-0"}},
+		{"user", Filter{Actor: "bob"}, []string{"job-9", "job-7", "job-5", "job-3", "job-1"}},
+		{"action", Filter{Action: ActionScan}, []string{"job-8", "job-6", "job-4", "job-2", "job-0"}},
+		{"job", Filter{JobID: "job-4"}, []string{"job-4"}},
+		{"scanner", Filter{ScannerID: "scanner-2", Limit: 2}, []string{"job-9", "job-7"}},
+		{"since is inclusive", Filter{Since: start.Add(7 * time.Hour)}, []string{"job-9", "job-8", "job-7"}},
+		{"until is exclusive", Filter{Until: start.Add(2 * time.Hour)}, []string{"job-1", "job-0"}},
+		{"since and until", Filter{Since: start.Add(3 * time.Hour), Until: start.Add(5*time.Hour + time.Second)}, []string{"job-5", "job-4", "job-3"}},
+		{"no match", Filter{Actor: "alice", Action: ActionDownload}, []string{}},
+		{"unknown user", Filter{Actor: "carol"}, []string{}},
+
+		// The limit keeps the newest matches, even when they wrap around the ring
+		{"limit", Filter{Limit: 3}, []string{"job-9", "job-8", "job-7"}},
+		{"limit of one", Filter{Limit: 1}, []string{"job-9"}},
+		{"limit of all", Filter{Limit: 10}, []string{"job-9", "job-8", "job-7", "job-6", "job-5", "job-4", "job-3", "job-2", "job-1", "job-0"}},
+		{"limit above the matches", Filter{Actor: "alice", Limit: 50}, []string{"job-8", "job-6", "job-4", "job-2", "job-0"}},
+		{"limit with a filter", Filter{Actor: "alice", Limit: 4}, []string{"job-8", "job-6", "job-4", "job-2"}},
+	}
+	for _, tt := range tests {
+		got, err := l.Query(tt.filter)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if ids := jobIDs(got); !equal(ids, tt.want) {
+			t.Errorf("%s: %v, want %v", tt.name, ids, tt.want)
+		}
+	}
+}
+
+func TestQueryDefaultLimit(t *testing.T) {
+	events := make([]Event, defaultQueryLimit+5)
+	for i := range events {
+		events[i] = Event{Action: ActionScan, JobID: strconv.Itoa(i)}
+	}
+	l := openTestLog(t, events...)
+
+	got, err := l.Query(Filter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != defaultQueryLimit || got[0].JobID != strconv.Itoa(len(events)-1) || got[len(got)-1].JobID != "5" {
+		t.Errorf("%d events from %s to %s", len(got), got[0].JobID, got[len(got)-1].JobID)
+	}
+
+	// Queries read the file, so they fail once it is gone
+	if err := os.Remove(l.path); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.Query(Filter{}); err == nil {
+		t.Error("no error for a missing audit file")
+	}
+}
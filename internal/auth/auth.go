@@ -0,0 +1,323 @@
+// Package auth authenticates API clients with static API keys and
+// HMAC-signed bearer tokens (JWT)
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/scanserver/scanner-service/internal/config"
+	"github.com/scanserver/scanner-service/internal/logging"
+)
+
+// Authentication methods
+const (
+	MethodAPIKey = "api_key"
+	MethodJWT    = "jwt"
+)
+
+// Roles, from most to least privileged
+const (
+	RoleAdmin    = "admin"    // Everything, including schedules and the webhook log
+	RoleOperator = "operator" // Scans on assigned scanners and manages own jobs
+	RoleViewer   = "viewer"   // Lists assigned scanners and reads own jobs
+)
+
+// Error codes of rejected requests
+const (
+	codeUnauthorized = "unauthorized"
+	codeForbidden    = "forbidden"
+)
+
+// principalKey stores the authenticated client in the gin context
+const principalKey = "auth.principal"
+
+// wsProtocol is the WebSocket subprotocol announcing a token as the next
+// subprotocol, since browsers can't set headers on WebSocket requests
+const wsProtocol = "bearer"
+
+var (
+	// ErrNoCredentials is returned when a request carries no API key or token
+	ErrNoCredentials = errors.New("authentication required")
+
+	// ErrInvalidCredentials is returned for an unknown API key or a bad token
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+// Principal is an authenticated client. A nil *Principal stands for
+// requests on a server without authentication and is allowed everything.
+type Principal struct {
+	Name     string   `json:"name"`               // API key name or token subject
+	Method   string   `json:"method"`             // api_key or jwt
+	Role     string   `json:"role"`               // admin, operator or viewer
+	Scanners []string `json:"scanners,omitempty"` // Scanner IDs the client may use, empty = all
+}
+
+// IsAdmin reports whether the client has the admin role
+func (p *Principal) IsAdmin() bool {
+	return p == nil || p.Role == RoleAdmin
+}
+
+// Owner returns the owner of jobs the client starts. The name is prefixed
+// with the authentication method, e.g. key:frontdesk or jwt:alice, so that
+// an API key and a token subject with the same name are different owners.
+func (p *Principal) Owner() string {
+	if p == nil {
+		return ""
+	}
+	if p.Method == MethodJWT {
+		return "jwt:" + p.Name
+	}
+	return "key:" + p.Name
+}
+
+// CanScan reports whether the client may start scans and change jobs
+func (p *Principal) CanScan() bool {
+	return p == nil || p.Role == RoleAdmin || p.Role == RoleOperator
+}
+
+// CanUseScanner reports whether a scanner is assigned to the client.
+// Admins may use every scanner.
+func (p *Principal) CanUseScanner(scannerID string) bool {
+	if p.IsAdmin() || len(p.Scanners) == 0 {
+		return true
+	}
+	for _, id := range p.Scanners {
+		if id == scannerID || id == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidRole reports whether role is admin, operator or viewer
+func ValidRole(role string) bool {
+	switch role {
+	case RoleAdmin, RoleOperator, RoleViewer:
+		return true
+	}
+	return false
+}
+
+// Authenticator checks the credentials of API requests
+type Authenticator struct {
+	config *config.AuthConfig
+	keys   []apiKey
+	logger *slog.Logger
+}
+
+// apiKey is a configured API key, hashed so that comparisons take the same
+// time whatever the length of the presented key
+type apiKey struct {
+	name     string
+	role     string
+	scanners []string
+	hash     [sha256.Size]byte
+}
+
+// NewAuthenticator validates the auth configuration
+func NewAuthenticator(cfg *config.AuthConfig, logger *slog.Logger) (*Authenticator, error) {
+	a := &Authenticator{config: cfg, logger: logger}
+	if !cfg.Enabled {
+		return a, nil
+	}
+
+	seen := make(map[string]bool)
+	for i, key := range cfg.APIKeys {
+		if key.Key == "" {
+			return nil, fmt.Errorf("api key %d: key is required", i+1)
+		}
+		if seen[key.Key] {
+			return nil, fmt.Errorf("api key %d: duplicate key", i+1)
+		}
+		seen[key.Key] = true
+
+		name := key.Name
+		if name == "" {
+			name = fmt.Sprintf("key-%d", i+1)
+		}
+
+		// Keys without a role predate roles and keep full access
+		role := key.Role
+		if role == "" {
+			role = RoleAdmin
+		}
+		if !ValidRole(role) {
+			return nil, fmt.Errorf("api key %s: unknown role %q", name, key.Role)
+		}
+
+		a.keys = append(a.keys, apiKey{
+			name:     name,
+			role:     role,
+			scanners: key.Scanners,
+			hash:     sha256.Sum256([]byte(key.Key)),
+		})
+	}
+
+	if role := cfg.JWT.DefaultRole; role != "" && !ValidRole(role) {
+		return nil, fmt.Errorf("jwt.default_role: unknown role %q", role)
+	}
+
+	if cfg.JWT.Secret != "" && len(cfg.JWT.Secret) < minSecretLength {
+		return nil, fmt.Errorf("jwt.secret must be at least %d bytes", minSecretLength)
+	}
+	if len(a.keys) == 0 && cfg.JWT.Secret == "" {
+		return nil, fmt.Errorf("auth is enabled but neither api_keys nor jwt.secret is configured")
+	}
+	return a, nil
+}
+
+// Enabled reports whether requests must be authenticated
+func (a *Authenticator) Enabled() bool {
+	return a.config.Enabled
+}
+
+// Authenticate checks an API key or bearer token
+func (a *Authenticator) Authenticate(credential string) (*Principal, error) {
+	if credential == "" {
+		return nil, ErrNoCredentials
+	}
+
+	// Compare against every key so the time taken doesn't tell which matched
+	hash := sha256.Sum256([]byte(credential))
+	var match *apiKey
+	for i := range a.keys {
+		if subtle.ConstantTimeCompare(hash[:], a.keys[i].hash[:]) == 1 {
+			match = &a.keys[i]
+		}
+	}
+	if match != nil {
+		return &Principal{Name: match.name, Method: MethodAPIKey, Role: match.role, Scanners: match.scanners}, nil
+	}
+
+	if a.config.JWT.Secret != "" && strings.Count(credential, ".") == 2 {
+		return a.verifyToken(credential)
+	}
+	return nil, ErrInvalidCredentials
+}
+
+// Middleware rejects requests without a valid API key or token with
+// 401 Unauthorized. Credentials are read from the Authorization header
+// (Bearer), the X-API-Key header, the WebSocket subprotocols ("bearer",
+// <token>) or the access_token query parameter, in that order.
+func (a *Authenticator) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !a.config.Enabled {
+			c.Next()
+			return
+		}
+
+		principal, err := a.Authenticate(credential(c.Request))
+		if err != nil {
+			if !errors.Is(err, ErrNoCredentials) {
+				logging.FromContext(c.Request.Context(), a.logger).Warn("Rejected request",
+					"path", c.Request.URL.Path, "client_ip", c.ClientIP(), logging.Err(err))
+			}
+			c.Header("WWW-Authenticate", `Bearer realm="scanserver"`)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"error": err.Error(),
+				"code":  codeUnauthorized,
+			})
+			return
+		}
+
+		c.Set(principalKey, principal)
+		c.Next()
+	}
+}
+
+// BasicMiddleware rejects requests without HTTP Basic credentials whose
+// password is a valid API key or token. The user name is not checked.
+// It is used for eSCL clients, which only support Basic auth, and does
+// nothing unless auth.escl is set.
+func (a *Authenticator) BasicMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !a.config.Enabled || !a.config.ESCL {
+			c.Next()
+			return
+		}
+
+		_, password, _ := c.Request.BasicAuth()
+		principal, err := a.Authenticate(password)
+		if err != nil {
+			if !errors.Is(err, ErrNoCredentials) {
+				logging.FromContext(c.Request.Context(), a.logger).Warn("Rejected eSCL request",
+					"path", c.Request.URL.Path, "client_ip", c.ClientIP(), logging.Err(err))
+			}
+			c.Header("WWW-Authenticate", `Basic realm="scanserver"`)
+			c.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+
+		c.Set(principalKey, principal)
+		c.Next()
+	}
+}
+
+// RequireAdmin rejects requests from clients without the admin role with
+// 403 Forbidden
+func RequireAdmin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !FromContext(c).IsAdmin() {
+			Forbid(c, "admin role required")
+			return
+		}
+		c.Next()
+	}
+}
+
+// Forbid rejects a request with 403 Forbidden
+func Forbid(c *gin.Context, message string) {
+	c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+		"error": message,
+		"code":  codeForbidden,
+	})
+}
+
+// WebSocketProtocols returns the subprotocols the WebSocket upgrader must
+// accept for clients passing their token as a subprotocol
+func WebSocketProtocols() []string {
+	return []string{wsProtocol}
+}
+
+// FromContext returns the client authenticated for a request, or nil if
+// auth is disabled
+func FromContext(c *gin.Context) *Principal {
+	if p, ok := c.Get(principalKey); ok {
+		return p.(*Principal)
+	}
+	return nil
+}
+
+// credential returns the API key or token presented with a request
+func credential(r *http.Request) string {
+	if header := r.Header.Get("Authorization"); header != "" {
+		if scheme, token, ok := strings.Cut(header, " "); ok && strings.EqualFold(scheme, "Bearer") {
+			return strings.TrimSpace(token)
+		}
+	}
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		return key
+	}
+
+	var protocols []string
+	for _, header := range r.Header.Values("Sec-WebSocket-Protocol") {
+		for _, p := range strings.Split(header, ",") {
+			protocols = append(protocols, strings.TrimSpace(p))
+		}
+	}
+	for i := 0; i+1 < len(protocols); i++ {
+		if protocols[i] == wsProtocol {
+			return protocols[i+1]
+		}
+	}
+
+	return r.URL.Query().Get("access_token")
+}
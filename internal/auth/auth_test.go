@@ -0,0 +1,180 @@
+package auth
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/scanserver/scanner-service/internal/config"
+)
+
+var testLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+func newTestAuthenticator(t *testing.T, cfg *config.AuthConfig) *Authenticator {
+	t.Helper()
+	a, err := NewAuthenticator(cfg, testLogger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+// testRouter serves the client authenticated by middleware on /whoami
+func testRouter(middleware gin.HandlerFunc) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/whoami", middleware, func(c *gin.Context) {
+		p := FromContext(c)
+		if p == nil {
+			c.String(http.StatusOK, "anonymous")
+			return
+		}
+		c.String(http.StatusOK, p.Owner()+" "+p.Role)
+	})
+	return router
+}
+
+// serve sends a GET /whoami request set up by prepare
+func serve(router *gin.Engine, prepare func(r *http.Request)) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, "/whoami", nil)
+	if prepare != nil {
+		prepare(r)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	return w
+}
+
+func TestNewAuthenticator(t *testing.T) {
+	a := newTestAuthenticator(t, &config.AuthConfig{Enabled: true, APIKeys: []config.APIKey{
+		{Key: "legacy-key"},
+		{Name: "portal", Key: "portal-key", Role: RoleOperator, Scanners: []string{"scanner-1"}},
+	}})
+
+	// Keys without a role keep the full access they had before roles
+	p, err := a.Authenticate("legacy-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Name != "key-1" || p.Role != RoleAdmin || p.Method != MethodAPIKey {
+		t.Errorf("key without a role = %+v", p)
+	}
+	if p, err := a.Authenticate("portal-key"); err != nil || p.Role != RoleOperator || p.Scanners[0] != "scanner-1" {
+		t.Errorf("portal key = %+v, %v", p, err)
+	}
+	if _, err := a.Authenticate("portal-key "); err != ErrInvalidCredentials {
+		t.Errorf("unknown key: %v", err)
+	}
+	if _, err := a.Authenticate(""); err != ErrNoCredentials {
+		t.Errorf("no key: %v", err)
+	}
+
+	for _, cfg := range []config.AuthConfig{
+		{Enabled: true},
+		{Enabled: true, APIKeys: []config.APIKey{{Name: "empty"}}},
+		{Enabled: true, APIKeys: []config.APIKey{{Key: "k"}, {Key: "k"}}},
+		{Enabled: true, APIKeys: []config.APIKey{{Key: "k", Role: "root"}}},
+		{Enabled: true, JWT: config.JWTConfig{Secret: "short"}},
+		{Enabled: true, JWT: config.JWTConfig{Secret: testSecret, DefaultRole: "root"}},
+	} {
+		if _, err := NewAuthenticator(&cfg, testLogger); err == nil {
+			t.Errorf("%+v: no error", cfg)
+		}
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	a := newTestAuthenticator(t, &config.AuthConfig{
+		Enabled: true,
+		APIKeys: []config.APIKey{{Name: "portal", Key: "portal-key", Role: RoleOperator}},
+		JWT:     config.JWTConfig{Secret: testSecret},
+	})
+	router := testRouter(a.Middleware())
+	token := sign(t, "HS256", testSecret, map[string]interface{}{
+		"sub": "alice", "role": RoleViewer, "exp": time.Now().Add(time.Hour).Unix(),
+	})
+
+	tests := []struct {
+		name    string
+		prepare func(r *http.Request)
+		want    string // response body, empty = 401
+	}{
+		{"bearer key", func(r *http.Request) { r.Header.Set("Authorization", "Bearer portal-key") }, "key:portal operator"},
+		{"bearer token", func(r *http.Request) { r.Header.Set("Authorization", "bearer "+token) }, "jwt:alice viewer"},
+		{"key header", func(r *http.Request) { r.Header.Set("X-API-Key", "portal-key") }, "key:portal operator"},
+		{"query", func(r *http.Request) { r.URL.RawQuery = "access_token=" + token }, "jwt:alice viewer"},
+		{"header before query", func(r *http.Request) {
+			r.Header.Set("X-API-Key", "wrong")
+			r.URL.RawQuery = "access_token=portal-key"
+		}, ""},
+		{"authorization before key header", func(r *http.Request) {
+			r.Header.Set("Authorization", "Bearer "+token)
+			r.Header.Set("X-API-Key", "portal-key")
+		}, "jwt:alice viewer"},
+		{"basic is not bearer", func(r *http.Request) { r.SetBasicAuth("portal", "portal-key") }, ""},
+		{"websocket subprotocol", func(r *http.Request) { r.Header.Set("Sec-WebSocket-Protocol", "bearer, "+token) }, "jwt:alice viewer"},
+		{"websocket subprotocol headers", func(r *http.Request) {
+			r.Header.Add("Sec-WebSocket-Protocol", "json")
+			r.Header.Add("Sec-WebSocket-Protocol", "bearer")
+			r.Header.Add("Sec-WebSocket-Protocol", "portal-key")
+		}, "key:portal operator"},
+		{"websocket subprotocol without a token", func(r *http.Request) { r.Header.Set("Sec-WebSocket-Protocol", "bearer") }, ""},
+		{"wrong key", func(r *http.Request) { r.Header.Set("Authorization", "Bearer portal-kex") }, ""},
+		{"no credentials", nil, ""},
+	}
+	for _, tt := range tests {
+		w := serve(router, tt.prepare)
+		if tt.want == "" {
+			if w.Code != http.StatusUnauthorized || w.Header().Get("WWW-Authenticate") != `Bearer realm="scanserver"` {
+				t.Errorf("%s: status %d, body %s", tt.name, w.Code, w.Body)
+			}
+			continue
+		}
+		if w.Code != http.StatusOK || w.Body.String() != tt.want {
+			t.Errorf("%s: status %d, body %s, want %s", tt.name, w.Code, w.Body, tt.want)
+		}
+	}
+
+	// Without auth every request passes, anonymously
+	open := newTestAuthenticator(t, &config.AuthConfig{})
+	if w := serve(testRouter(open.Middleware()), nil); w.Code != http.StatusOK || w.Body.String() != "anonymous" {
+		t.Errorf("auth disabled: status %d, body %s", w.Code, w.Body)
+	}
+}
+
+func TestBasicMiddleware(t *testing.T) {
+	cfg := &config.AuthConfig{
+		Enabled: true,
+		APIKeys: []config.APIKey{{Name: "printer", Key: "printer-key", Role: RoleOperator}},
+		ESCL:    true,
+	}
+	router := testRouter(newTestAuthenticator(t, cfg).BasicMiddleware())
+
+	w := serve(router, func(r *http.Request) { r.SetBasicAuth("anyone", "printer-key") })
+	if w.Code != http.StatusOK || w.Body.String() != "key:printer operator" {
+		t.Errorf("valid password: status %d, body %s", w.Code, w.Body)
+	}
+	for name, prepare := range map[string]func(r *http.Request){
+		"wrong password": func(r *http.Request) { r.SetBasicAuth("printer", "printer") },
+		"key as user":    func(r *http.Request) { r.SetBasicAuth("printer-key", "") },
+		"bearer":         func(r *http.Request) { r.Header.Set("Authorization", "Bearer printer-key") },
+		"none":           nil,
+	} {
+		w := serve(router, prepare)
+		if w.Code != http.StatusUnauthorized || !strings.HasPrefix(w.Header().Get("WWW-Authenticate"), "Basic ") {
+			t.Errorf("%s: status %d, WWW-Authenticate %q", name, w.Code, w.Header().Get("WWW-Authenticate"))
+		}
+	}
+
+	// eSCL stays open unless auth.escl is set
+	cfg.ESCL = false
+	if w := serve(testRouter(newTestAuthenticator(t, cfg).BasicMiddleware()), nil); w.Code != http.StatusOK {
+		t.Errorf("escl disabled: status %d", w.Code)
+	}
+}
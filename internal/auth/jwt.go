@@ -0,0 +1,142 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"strings"
+	"time"
+)
+
+// minSecretLength is the shortest accepted HMAC secret, the output size of HS256
+const minSecretLength = 32
+
+// clockSkew is tolerated when checking exp and nbf
+const clockSkew = time.Minute
+
+// algorithms are the accepted token signing algorithms
+var algorithms = map[string]func() hash.Hash{
+	"HS256": sha256.New,
+	"HS384": sha512.New384,
+	"HS512": sha512.New,
+}
+
+// claims are the registered JWT claims checked by the server
+type claims struct {
+	Subject   string   `json:"sub"`
+	Issuer    string   `json:"iss"`
+	Audience  audience `json:"aud"`
+	ExpiresAt *float64 `json:"exp"`
+	NotBefore *float64 `json:"nbf"`
+
+	Role     string   `json:"role"`     // admin, operator or viewer, default jwt.default_role
+	Scanners []string `json:"scanners"` // Assigned scanner IDs, empty = all
+}
+
+// audience is the aud claim, a string or an array of strings
+type audience []string
+
+// UnmarshalJSON accepts both forms of the aud claim
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = audience{single}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*a = list
+	return nil
+}
+
+// verifyToken checks the signature and claims of a JWT. Tokens must expire.
+func (a *Authenticator) verifyToken(token string) (*Principal, error) {
+	parts := strings.Split(token, ".")
+
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("%w: malformed token header", ErrInvalidCredentials)
+	}
+	newHash, ok := algorithms[header.Alg]
+	if !ok {
+		return nil, fmt.Errorf("%w: unsupported token algorithm %q", ErrInvalidCredentials, header.Alg)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed token signature", ErrInvalidCredentials)
+	}
+	mac := hmac.New(newHash, []byte(a.config.JWT.Secret))
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+	if !hmac.Equal(signature, mac.Sum(nil)) {
+		return nil, fmt.Errorf("%w: bad token signature", ErrInvalidCredentials)
+	}
+
+	var c claims
+	if err := decodeSegment(parts[1], &c); err != nil {
+		return nil, fmt.Errorf("%w: malformed token claims", ErrInvalidCredentials)
+	}
+
+	now := time.Now()
+	if c.ExpiresAt == nil {
+		return nil, fmt.Errorf("%w: token has no expiry", ErrInvalidCredentials)
+	}
+	if now.After(unixTime(*c.ExpiresAt).Add(clockSkew)) {
+		return nil, fmt.Errorf("%w: token expired", ErrInvalidCredentials)
+	}
+	if c.NotBefore != nil && now.Add(clockSkew).Before(unixTime(*c.NotBefore)) {
+		return nil, fmt.Errorf("%w: token not yet valid", ErrInvalidCredentials)
+	}
+	if iss := a.config.JWT.Issuer; iss != "" && c.Issuer != iss {
+		return nil, fmt.Errorf("%w: wrong token issuer", ErrInvalidCredentials)
+	}
+	if aud := a.config.JWT.Audience; aud != "" && !c.Audience.contains(aud) {
+		return nil, fmt.Errorf("%w: wrong token audience", ErrInvalidCredentials)
+	}
+
+	role := c.Role
+	if role == "" {
+		role = a.config.JWT.DefaultRole
+	}
+	if !ValidRole(role) {
+		return nil, fmt.Errorf("%w: unknown role %q", ErrInvalidCredentials, role)
+	}
+
+	name := c.Subject
+	if name == "" {
+		name = "token"
+	}
+	return &Principal{Name: name, Method: MethodJWT, Role: role, Scanners: c.Scanners}, nil
+}
+
+// contains reports whether the audience includes name
+func (a audience) contains(name string) bool {
+	for _, aud := range a {
+		if aud == name {
+			return true
+		}
+	}
+	return false
+}
+
+// decodeSegment decodes a base64url JSON token segment
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// unixTime converts a NumericDate claim
+func unixTime(seconds float64) time.Time {
+	return time.Unix(0, int64(seconds*float64(time.Second)))
+}
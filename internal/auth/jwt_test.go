@@ -0,0 +1,152 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"hash"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/scanserver/scanner-service/internal/config"
+)
+
+const testSecret = "0123456789abcdef0123456789abcdef"
+
+// sign returns a token of claims signed with secret, or with an empty
+// signature for algorithms other than HS256, HS384 and HS512
+func sign(t *testing.T, alg, secret string, claims map[string]interface{}) string {
+	t.Helper()
+	segment := func(v interface{}) string {
+		data, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+	unsigned := segment(map[string]string{"alg": alg, "typ": "JWT"}) + "." + segment(claims)
+
+	var newHash func() hash.Hash
+	switch alg {
+	case "HS256":
+		newHash = sha256.New
+	case "HS384":
+		newHash = sha512.New384
+	case "HS512":
+		newHash = sha512.New
+	default:
+		return unsigned + "."
+	}
+	mac := hmac.New(newHash, []byte(secret))
+	mac.Write([]byte(unsigned))
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func newTokenAuthenticator(t *testing.T, jwt config.JWTConfig) *Authenticator {
+	t.Helper()
+	if jwt.Secret == "" {
+		jwt.Secret = testSecret
+	}
+	a, err := NewAuthenticator(&config.AuthConfig{Enabled: true, JWT: jwt}, testLogger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+func TestVerifyToken(t *testing.T) {
+	a := newTokenAuthenticator(t, config.JWTConfig{Issuer: "https://sso.example.com", Audience: "scanserver", DefaultRole: RoleViewer})
+
+	now := time.Now()
+	valid := func(changes map[string]interface{}) map[string]interface{} {
+		claims := map[string]interface{}{
+			"sub":      "alice",
+			"iss":      "https://sso.example.com",
+			"aud":      "scanserver",
+			"exp":      now.Add(time.Hour).Unix(),
+			"role":     RoleOperator,
+			"scanners": []string{"scanner-1"},
+		}
+		for k, v := range changes {
+			if v == nil {
+				delete(claims, k)
+			} else {
+				claims[k] = v
+			}
+		}
+		return claims
+	}
+
+	for _, alg := range []string{"HS256", "HS384", "HS512"} {
+		p, err := a.Authenticate(sign(t, alg, testSecret, valid(nil)))
+		if err != nil {
+			t.Errorf("%s: %v", alg, err)
+			continue
+		}
+		if p.Name != "alice" || p.Method != MethodJWT || p.Role != RoleOperator || len(p.Scanners) != 1 || p.Scanners[0] != "scanner-1" {
+			t.Errorf("%s: principal = %+v", alg, p)
+		}
+	}
+
+	accepted := []struct {
+		name   string
+		claims map[string]interface{}
+		role   string
+	}{
+		{"expired within the clock skew", valid(map[string]interface{}{"exp": now.Add(-30 * time.Second).Unix()}), RoleOperator},
+		{"valid within the clock skew", valid(map[string]interface{}{"nbf": now.Add(30 * time.Second).Unix()}), RoleOperator},
+		{"audience list", valid(map[string]interface{}{"aud": []string{"portal", "scanserver"}}), RoleOperator},
+		{"default role", valid(map[string]interface{}{"role": nil}), RoleViewer},
+	}
+	for _, tt := range accepted {
+		p, err := a.Authenticate(sign(t, "HS256", testSecret, tt.claims))
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+		} else if p.Role != tt.role {
+			t.Errorf("%s: role %s, want %s", tt.name, p.Role, tt.role)
+		}
+	}
+
+	good := sign(t, "HS256", testSecret, valid(nil))
+	parts := strings.Split(good, ".")
+	admin := strings.Split(sign(t, "HS256", testSecret, valid(map[string]interface{}{"role": RoleAdmin})), ".")
+	tampered := parts[0] + "." + admin[1] + "." + parts[2]
+
+	rejected := []struct {
+		name  string
+		token string
+		want  string
+	}{
+		{"alg none", sign(t, "none", "", valid(nil)), "unsupported token algorithm"},
+		{"alg none with a signature", sign(t, "none", "", valid(nil)) + parts[2], "unsupported token algorithm"},
+		{"RS256", sign(t, "RS256", "", valid(nil)) + parts[2], "unsupported token algorithm"},
+		{"other secret", sign(t, "HS256", strings.Repeat("x", 32), valid(nil)), "bad token signature"},
+		{"changed claims", tampered, "bad token signature"},
+		{"no signature", parts[0] + "." + parts[1] + ".", "bad token signature"},
+		{"malformed header", "e30x." + parts[1] + "." + parts[2], "malformed token header"},
+		{"malformed signature", parts[0] + "." + parts[1] + ".!!", "malformed token signature"},
+		{"no exp", sign(t, "HS256", testSecret, valid(map[string]interface{}{"exp": nil})), "token has no expiry"},
+		{"expired", sign(t, "HS256", testSecret, valid(map[string]interface{}{"exp": now.Add(-2 * time.Minute).Unix()})), "token expired"},
+		{"not yet valid", sign(t, "HS256", testSecret, valid(map[string]interface{}{"nbf": now.Add(5 * time.Minute).Unix()})), "token not yet valid"},
+		{"wrong issuer", sign(t, "HS256", testSecret, valid(map[string]interface{}{"iss": "https://evil.example.com"})), "wrong token issuer"},
+		{"no issuer", sign(t, "HS256", testSecret, valid(map[string]interface{}{"iss": nil})), "wrong token issuer"},
+		{"wrong audience", sign(t, "HS256", testSecret, valid(map[string]interface{}{"aud": []string{"portal"}})), "wrong token audience"},
+		{"unknown role", sign(t, "HS256", testSecret, valid(map[string]interface{}{"role": "root"})), `unknown role "root"`},
+	}
+	for _, tt := range rejected {
+		p, err := a.Authenticate(tt.token)
+		if !errors.Is(err, ErrInvalidCredentials) || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: principal %+v, error %v, want %q", tt.name, p, err, tt.want)
+		}
+	}
+
+	// Without a default role, tokens must name their role
+	strict := newTokenAuthenticator(t, config.JWTConfig{})
+	if _, err := strict.Authenticate(sign(t, "HS256", testSecret, valid(map[string]interface{}{"role": nil}))); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("token without a role: %v", err)
+	}
+}
@@ -0,0 +1,286 @@
+// Package certs builds the server's TLS configuration, generating a
+// self-signed certificate when none is configured
+package certs
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/scanserver/scanner-service/internal/config"
+)
+
+// Self-signed certificate files in tls.cert_dir
+const (
+	selfSignedCert = "selfsigned.crt"
+	selfSignedKey  = "selfsigned.key"
+)
+
+const (
+	// selfSignedValidity is the lifetime of a generated certificate
+	selfSignedValidity = 365 * 24 * time.Hour
+
+	// renewBefore regenerates a self-signed certificate this long before it expires
+	renewBefore = 30 * 24 * time.Hour
+)
+
+// ServerConfig returns the TLS configuration for the HTTPS listener, or nil
+// if TLS is disabled
+func ServerConfig(cfg *config.TLSConfig, logger *slog.Logger) (*tls.Config, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+
+	certFile, keyFile := cfg.CertFile, cfg.KeyFile
+	if (certFile == "") != (keyFile == "") {
+		return nil, fmt.Errorf("tls: cert_file and key_file must be set together")
+	}
+	if certFile == "" {
+		var err error
+		certFile, keyFile, err = selfSigned(cfg.CertDir, cfg.Hosts, logger)
+		if err != nil {
+			return nil, fmt.Errorf("tls: self-signed certificate: %w", err)
+		}
+	}
+
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("tls: %w", err)
+	}
+
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+	}
+
+	switch cfg.MinVersion {
+	case "", "1.2":
+		tlsConfig.MinVersion = tls.VersionTLS12
+	case "1.3":
+		tlsConfig.MinVersion = tls.VersionTLS13
+	default:
+		return nil, fmt.Errorf("tls: unsupported min_version %q, use 1.2 or 1.3", cfg.MinVersion)
+	}
+
+	switch cfg.ClientAuth {
+	case "", "none":
+		if cfg.ClientCA != "" {
+			return nil, fmt.Errorf("tls: client_ca needs client_auth optional or require")
+		}
+		return tlsConfig, nil
+	case "optional":
+		tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	case "require":
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	default:
+		return nil, fmt.Errorf("tls: unsupported client_auth %q, use none, optional or require", cfg.ClientAuth)
+	}
+
+	if cfg.ClientCA == "" {
+		return nil, fmt.Errorf("tls: client_auth %s needs client_ca", cfg.ClientAuth)
+	}
+	pemData, err := os.ReadFile(cfg.ClientCA)
+	if err != nil {
+		return nil, fmt.Errorf("tls: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pemData) {
+		return nil, fmt.Errorf("tls: no certificates found in %s", cfg.ClientCA)
+	}
+	tlsConfig.ClientCAs = pool
+	return tlsConfig, nil
+}
+
+// selfSigned returns the self-signed certificate in dir, generating it on
+// first run or when it is about to expire or doesn't cover hosts. Changed
+// interface addresses alone don't replace a certificate clients may trust.
+func selfSigned(dir string, hosts []string, logger *slog.Logger) (string, string, error) {
+	certFile := filepath.Join(dir, selfSignedCert)
+	keyFile := filepath.Join(dir, selfSignedKey)
+
+	requiredNames, requiredIPs := splitHosts(hosts)
+	if cert, err := readCertificate(certFile); err == nil && covers(cert, requiredNames, requiredIPs) &&
+		time.Until(cert.NotAfter) > renewBefore {
+		if _, err := os.Stat(keyFile); err == nil {
+			return certFile, keyFile, nil
+		}
+	}
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", "", err
+	}
+
+	names, ips := subjectNames(hosts)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return "", "", err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return "", "", err
+	}
+
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: names[0], Organization: []string{"Scanner Service"}},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(selfSignedValidity),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		DNSNames:              names,
+		IPAddresses:           ips,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return "", "", err
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return "", "", err
+	}
+
+	if err := writePEM(keyFile, "EC PRIVATE KEY", keyDER, 0600); err != nil {
+		return "", "", err
+	}
+	if err := writePEM(certFile, "CERTIFICATE", der, 0644); err != nil {
+		return "", "", err
+	}
+
+	logger.Info("Generated self-signed TLS certificate", "file", certFile, "sha256", fmt.Sprintf("%X", sha256.Sum256(der)))
+	return certFile, keyFile, nil
+}
+
+// subjectNames returns the DNS names and IP addresses a self-signed
+// certificate is issued for: the host name, localhost, the loopback and
+// local interface addresses, and the configured hosts
+func subjectNames(hosts []string) ([]string, []net.IP) {
+	var names []string
+	var ips []net.IP
+	add := func(host string) {
+		host = strings.TrimSpace(host)
+		if host == "" {
+			return
+		}
+		if ip := net.ParseIP(host); ip != nil {
+			for _, existing := range ips {
+				if existing.Equal(ip) {
+					return
+				}
+			}
+			ips = append(ips, ip)
+			return
+		}
+		for _, existing := range names {
+			if strings.EqualFold(existing, host) {
+				return
+			}
+		}
+		names = append(names, host)
+	}
+
+	if hostname, err := os.Hostname(); err == nil {
+		add(hostname)
+		if !strings.Contains(hostname, ".") {
+			add(hostname + ".local") // mDNS name, used by eSCL clients
+		}
+	}
+	add("localhost")
+	add("127.0.0.1")
+	add("::1")
+	if addrs, err := net.InterfaceAddrs(); err == nil {
+		for _, addr := range addrs {
+			if ipNet, ok := addr.(*net.IPNet); ok && !ipNet.IP.IsLinkLocalUnicast() {
+				add(ipNet.IP.String())
+			}
+		}
+	}
+	for _, host := range hosts {
+		add(host)
+	}
+	return names, ips
+}
+
+// splitHosts separates IP addresses from DNS names
+func splitHosts(hosts []string) ([]string, []net.IP) {
+	var names []string
+	var ips []net.IP
+	for _, host := range hosts {
+		if ip := net.ParseIP(strings.TrimSpace(host)); ip != nil {
+			ips = append(ips, ip)
+		} else if host = strings.TrimSpace(host); host != "" {
+			names = append(names, host)
+		}
+	}
+	return names, ips
+}
+
+// covers reports whether a certificate lists all names and addresses
+func covers(cert *x509.Certificate, names []string, ips []net.IP) bool {
+	for _, name := range names {
+		found := false
+		for _, existing := range cert.DNSNames {
+			if strings.EqualFold(existing, name) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	for _, ip := range ips {
+		found := false
+		for _, existing := range cert.IPAddresses {
+			if existing.Equal(ip) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// readCertificate parses the first certificate of a PEM file
+func readCertificate(path string) (*x509.Certificate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, fmt.Errorf("no certificate in %s", path)
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
+
+// writePEM writes a PEM file atomically
+func writePEM(path, blockType string, der []byte, perm os.FileMode) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), perm); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
@@ -11,15 +11,113 @@ type Config struct {
 	Server   ServerConfig   `mapstructure:"server"`
 	Scanner  ScannerConfig  `mapstructure:"scanner"`
 	Storage  StorageConfig  `mapstructure:"storage"`
-	AutoScan AutoScanConfig `mapstructure:"autoscan"`
+	AutoScan  AutoScanConfig  `mapstructure:"autoscan"`
+	HotFolder HotFolderConfig `mapstructure:"hotfolder"`
+	Scheduler SchedulerConfig `mapstructure:"scheduler"`
+	Webhooks  WebhooksConfig  `mapstructure:"webhooks"`
+	Email     EmailConfig     `mapstructure:"email"`
+	Export    ExportConfig    `mapstructure:"export"`
+	Auth      AuthConfig      `mapstructure:"auth"`
+	Metrics   MetricsConfig   `mapstructure:"metrics"`
+	Log       LogConfig       `mapstructure:"log"`
+	Tracing   TracingConfig   `mapstructure:"tracing"`
+	Audit     AuditConfig     `mapstructure:"audit"`
+	Health    HealthConfig    `mapstructure:"health"`
+}
+
+// HealthConfig represents the /healthz and /readyz checks
+type HealthConfig struct {
+	Timeout        int `mapstructure:"timeout"`         // seconds each check may take before it counts as down
+	ExportInterval int `mapstructure:"export_interval"` // seconds between connection checks of export destinations, 0 = every probe
+}
+
+// AuditConfig represents the audit trail of scans, downloads, deliveries and deletions
+type AuditConfig struct {
+	Enabled bool   `mapstructure:"enabled"`
+	File    string `mapstructure:"file"` // JSON lines, only ever appended to
+}
+
+// TracingConfig represents OpenTelemetry trace export over OTLP/HTTP
+type TracingConfig struct {
+	Enabled     bool              `mapstructure:"enabled"`
+	Endpoint    string            `mapstructure:"endpoint"`     // collector host:port
+	URLPath     string            `mapstructure:"url_path"`     // default /v1/traces
+	Insecure    bool              `mapstructure:"insecure"`     // plain HTTP instead of HTTPS
+	Headers     map[string]string `mapstructure:"headers"`      // sent with every export, e.g. an API key
+	ServiceName string            `mapstructure:"service_name"` // service.name resource attribute
+	SampleRatio float64           `mapstructure:"sample_ratio"` // fraction of traces started here that are kept, 0 to 1
+}
+
+// LogConfig represents logging configuration
+type LogConfig struct {
+	Level  string `mapstructure:"level"`  // debug, info, warn or error
+	Format string `mapstructure:"format"` // text or json
+}
+
+// MetricsConfig represents the Prometheus /metrics endpoint
+type MetricsConfig struct {
+	Enabled     bool `mapstructure:"enabled"`
+	RequireAuth bool `mapstructure:"require_auth"` // ask scrapers for an API key or token when auth is enabled
 }
 
 // ServerConfig represents server configuration
 type ServerConfig struct {
-	Host         string `mapstructure:"host"`
-	Port         int    `mapstructure:"port"`
-	ESCLEnabled  bool   `mapstructure:"escl_enabled"`
-	ESCLPort     int    `mapstructure:"escl_port"`
+	Host            string     `mapstructure:"host"`
+	Port            int        `mapstructure:"port"`
+	ESCLEnabled     bool       `mapstructure:"escl_enabled"`
+	ESCLPort        int        `mapstructure:"escl_port"`
+	ESCLAdvertise   bool       `mapstructure:"escl_advertise"`   // announce eSCL via mDNS as _uscan._tcp, or _uscans._tcp with TLS
+	ShutdownTimeout int        `mapstructure:"shutdown_timeout"` // seconds running jobs may take to finish on shutdown before they are cancelled
+	WebDir          string     `mapstructure:"web_dir"`          // static files and templates of the web UI
+	CORS            CORSConfig `mapstructure:"cors"`
+	TLS             TLSConfig  `mapstructure:"tls"`
+}
+
+// TLSConfig represents HTTPS configuration
+type TLSConfig struct {
+	Enabled      bool     `mapstructure:"enabled"`
+	CertFile     string   `mapstructure:"cert_file"`     // PEM certificate (chain), empty = self-signed
+	KeyFile      string   `mapstructure:"key_file"`      // PEM private key
+	CertDir      string   `mapstructure:"cert_dir"`      // where the self-signed certificate is kept
+	Hosts        []string `mapstructure:"hosts"`         // extra names and addresses for the self-signed certificate
+	MinVersion   string   `mapstructure:"min_version"`   // 1.2 or 1.3
+	ClientAuth   string   `mapstructure:"client_auth"`   // none, optional or require
+	ClientCA     string   `mapstructure:"client_ca"`     // PEM CA bundle client certificates are verified against
+	RedirectPort int      `mapstructure:"redirect_port"` // plain HTTP port redirecting to HTTPS, 0 = off
+}
+
+// CORSConfig represents the browser origins allowed to call the API
+type CORSConfig struct {
+	AllowedOrigins   []string `mapstructure:"allowed_origins"`   // e.g. https://app.example.com or https://*.example.com, "*" = any, empty = same origin only
+	AllowedMethods   []string `mapstructure:"allowed_methods"`
+	AllowedHeaders   []string `mapstructure:"allowed_headers"`   // "*" = whatever the browser asks for
+	ExposedHeaders   []string `mapstructure:"exposed_headers"`   // response headers readable by scripts
+	AllowCredentials bool     `mapstructure:"allow_credentials"` // cookies and HTTP auth; not with "*"
+	MaxAge           int      `mapstructure:"max_age"`           // seconds browsers may cache a preflight
+}
+
+// AuthConfig represents API authentication configuration
+type AuthConfig struct {
+	Enabled bool      `mapstructure:"enabled"`
+	APIKeys []APIKey  `mapstructure:"api_keys"`
+	JWT     JWTConfig `mapstructure:"jwt"`
+	ESCL    bool      `mapstructure:"escl"` // require HTTP Basic auth on eSCL routes
+}
+
+// APIKey represents a static API key
+type APIKey struct {
+	Name     string   `mapstructure:"name"`     // identifies the client in logs and owns its jobs
+	Key      string   `mapstructure:"key"`
+	Role     string   `mapstructure:"role"`     // admin, operator or viewer, empty = admin
+	Scanners []string `mapstructure:"scanners"` // scanner IDs the key may use, empty = all
+}
+
+// JWTConfig represents verification of HMAC-signed bearer tokens
+type JWTConfig struct {
+	Secret      string `mapstructure:"secret"`       // HS256/HS384/HS512 key, empty = tokens not accepted
+	Issuer      string `mapstructure:"issuer"`       // required iss claim, empty = any
+	Audience    string `mapstructure:"audience"`     // required aud claim, empty = any
+	DefaultRole string `mapstructure:"default_role"` // role of tokens without a role claim
 }
 
 // ScannerConfig represents scanner configuration
@@ -28,14 +126,48 @@ type ScannerConfig struct {
 	DefaultColorMode  string `mapstructure:"default_color_mode"`
 	DefaultFormat     string `mapstructure:"default_format"`
 	ScanTimeout       int    `mapstructure:"scan_timeout"` // seconds
+	ParamValidation   string `mapstructure:"param_validation"` // strict (reject) or coerce
 }
 
 // StorageConfig represents storage configuration
 type StorageConfig struct {
-	OutputDir      string `mapstructure:"output_dir"`
-	MaxStorageSize int64  `mapstructure:"max_storage_size"` // bytes
-	CleanupEnabled bool   `mapstructure:"cleanup_enabled"`
-	RetentionDays  int    `mapstructure:"retention_days"`
+	OutputDir       string `mapstructure:"output_dir"`
+	MaxStorageSize  int64  `mapstructure:"max_storage_size"` // bytes
+	CleanupEnabled  bool   `mapstructure:"cleanup_enabled"`
+	RetentionDays   int    `mapstructure:"retention_days"`
+	CleanupInterval int    `mapstructure:"cleanup_interval"` // minutes
+	MinFreeSpace    int64  `mapstructure:"min_free_space"`   // bytes, new jobs are refused below this
+	JobsFile        string `mapstructure:"jobs_file"`        // job records are saved here on shutdown and reloaded at startup, empty = not saved
+
+	Backend string             `mapstructure:"backend"` // local, s3 or webdav
+	Local   LocalStorageConfig `mapstructure:"local"`
+	S3      S3Config           `mapstructure:"s3"`
+	WebDAV  WebDAVConfig       `mapstructure:"webdav"`
+}
+
+// LocalStorageConfig represents the local filesystem storage backend
+type LocalStorageConfig struct {
+	Path string `mapstructure:"path"` // archive directory, empty keeps files in output_dir
+}
+
+// S3Config represents an S3-compatible storage backend (AWS S3, MinIO)
+type S3Config struct {
+	Endpoint  string `mapstructure:"endpoint"` // e.g. http://minio:9000
+	Region    string `mapstructure:"region"`
+	Bucket    string `mapstructure:"bucket"`
+	Prefix    string `mapstructure:"prefix"`
+	AccessKey string `mapstructure:"access_key"`
+	SecretKey string `mapstructure:"secret_key"`
+	PathStyle bool   `mapstructure:"path_style"` // bucket in the path instead of the host name
+	Timeout   int    `mapstructure:"timeout"`    // seconds per upload, 0 = no limit
+}
+
+// WebDAVConfig represents a WebDAV storage backend
+type WebDAVConfig struct {
+	URL      string `mapstructure:"url"` // base collection, e.g. https://cloud.example.com/remote.php/dav/files/scan
+	Username string `mapstructure:"username"`
+	Password string `mapstructure:"password"`
+	Timeout  int    `mapstructure:"timeout"` // seconds per request, 0 = no limit
 }
 
 // AutoScanConfig represents auto-scan configuration
@@ -55,6 +187,117 @@ type DefaultScanParams struct {
 	UseFeeder  bool   `mapstructure:"use_feeder"`
 }
 
+// HotFolderConfig represents the watched directory files are imported from
+type HotFolderConfig struct {
+	Enabled     bool   `mapstructure:"enabled"`
+	Path        string `mapstructure:"path"`
+	DoneDir     string `mapstructure:"done_dir"`     // imported files are moved here, default <path>/done
+	FailedDir   string `mapstructure:"failed_dir"`   // files that could not be imported, default <path>/failed
+	SettleDelay int    `mapstructure:"settle_delay"` // seconds a file must stay unchanged before it is imported
+	Resolution  int    `mapstructure:"resolution"`   // DPI assumed for imported images
+	Email       string `mapstructure:"email"`        // email profile imported jobs are sent to, empty = none
+	Export      string `mapstructure:"export"`       // export profile imported jobs are copied to, empty = none
+	Owner       string `mapstructure:"owner"`        // owner of imported jobs, e.g. key:frontdesk, empty = admins only
+	// Post-processing applied to imported pages, same fields as the scan API,
+	// e.g. exclude_blank_pages or scale_ratio
+	Parameters map[string]interface{} `mapstructure:"parameters"`
+}
+
+// SchedulerConfig represents scans run on cron schedules
+type SchedulerConfig struct {
+	File      string           `mapstructure:"file"` // schedules created through the API are saved here, empty = not saved
+	Schedules []ScheduleConfig `mapstructure:"schedules"`
+}
+
+// ScheduleConfig represents a schedule defined in the config file
+type ScheduleConfig struct {
+	ID         string                 `mapstructure:"id"`
+	Name       string                 `mapstructure:"name"`
+	Cron       string                 `mapstructure:"cron"`
+	ScannerID  string                 `mapstructure:"scanner_id"`
+	Parameters map[string]interface{} `mapstructure:"parameters"` // same fields as the scan API
+	Email      string                 `mapstructure:"email"`      // email profile, empty = none
+	Export     string                 `mapstructure:"export"`     // export profile, empty = none
+	Disabled   bool                   `mapstructure:"disabled"`
+	Owner      string                 `mapstructure:"owner"` // owner of the jobs, e.g. key:frontdesk, empty = admins only
+}
+
+// WebhooksConfig represents job lifecycle webhook configuration
+type WebhooksConfig struct {
+	Endpoints      []WebhookEndpoint `mapstructure:"endpoints"`
+	MaxAttempts    int               `mapstructure:"max_attempts"`    // including the first try
+	InitialBackoff int               `mapstructure:"initial_backoff"` // seconds, doubled after each failed attempt
+	Timeout        int               `mapstructure:"timeout"`         // seconds per attempt
+	LogSize        int               `mapstructure:"log_size"`        // deliveries kept in the delivery log
+}
+
+// WebhookEndpoint represents a URL that receives job events
+type WebhookEndpoint struct {
+	URL    string   `mapstructure:"url"`
+	Secret string   `mapstructure:"secret"` // HMAC-SHA256 key for the X-Scanner-Signature header
+	Events []string `mapstructure:"events"` // e.g. job.completed, empty = all events
+}
+
+// EmailConfig represents the SMTP server completed scans are emailed through
+type EmailConfig struct {
+	Host           string `mapstructure:"host"` // empty disables email
+	Port           int    `mapstructure:"port"`
+	TLS            string `mapstructure:"tls"` // starttls, tls (implicit, port 465) or none
+	Username       string `mapstructure:"username"`
+	Password       string `mapstructure:"password"`
+	From           string `mapstructure:"from"`
+	MaxMessageSize int64  `mapstructure:"max_message_size"` // bytes, larger scans are split across messages
+	Subject        string `mapstructure:"subject"`          // text/template
+	Body           string `mapstructure:"body"`             // text/template
+
+	Profiles map[string]EmailProfile `mapstructure:"profiles"`
+}
+
+// EmailProfile represents named recipients and message settings
+type EmailProfile struct {
+	To      []string `mapstructure:"to"`
+	Cc      []string `mapstructure:"cc"`
+	Subject string   `mapstructure:"subject"` // overrides email.subject
+	Body    string   `mapstructure:"body"`    // overrides email.body
+	Attach  string   `mapstructure:"attach"`  // pdf or images
+}
+
+// ExportConfig represents the remote folders completed jobs can be exported to
+type ExportConfig struct {
+	MaxAttempts  int                          `mapstructure:"max_attempts"` // including the first try
+	RetryDelay   int                          `mapstructure:"retry_delay"`  // seconds, doubled after each failed attempt
+	Destinations map[string]ExportDestination `mapstructure:"destinations"`
+	Profiles     map[string][]string          `mapstructure:"profiles"` // profile name -> destination names
+}
+
+// ExportDestination represents one remote folder
+type ExportDestination struct {
+	Type     string `mapstructure:"type"` // sftp, ftp, ftps or paperless
+	Host     string `mapstructure:"host"`
+	Port     int    `mapstructure:"port"` // 0 = default for the type
+	Username string `mapstructure:"username"`
+	Password string `mapstructure:"password"`
+	Timeout  int    `mapstructure:"timeout"` // seconds
+
+	// Remote file path without extension, with placeholders such as $(yyyy) and $(n)
+	Path   string `mapstructure:"path"`
+	Format string `mapstructure:"format"` // pdf (merged document) or images (page files)
+
+	PrivateKey         string `mapstructure:"private_key"`          // sftp: path to a PEM private key
+	HostKey            string `mapstructure:"host_key"`             // sftp: server key in authorized_keys format
+	ImplicitTLS        bool   `mapstructure:"implicit_tls"`         // ftps: TLS from connect (port 990) instead of AUTH TLS
+	InsecureSkipVerify bool   `mapstructure:"insecure_skip_verify"` // skip host key or certificate verification
+
+	// Paperless-ngx: post documents to the API at URL, or drop them into ConsumeDir
+	URL           string   `mapstructure:"url"`           // e.g. http://paperless:8000
+	Token         string   `mapstructure:"token"`         // API token; Username/Password are used if empty
+	ConsumeDir    string   `mapstructure:"consume_dir"`   // local consume directory, instead of URL
+	Title         string   `mapstructure:"title"`         // Go template, e.g. "Scan {{.Date}}"
+	Tags          []string `mapstructure:"tags"`          // names or IDs
+	Correspondent string   `mapstructure:"correspondent"` // name or ID
+	DocumentType  string   `mapstructure:"document_type"` // name or ID
+}
+
 // Load loads configuration from file or environment variables
 func Load(configPath string) (*Config, error) {
 	v := viper.New()
@@ -89,18 +332,55 @@ func setDefaults(v *viper.Viper) {
 	v.SetDefault("server.port", 8080)
 	v.SetDefault("server.escl_enabled", true)
 	v.SetDefault("server.escl_port", 8080)
+	v.SetDefault("server.escl_advertise", false)
+	v.SetDefault("server.shutdown_timeout", 60)
+	v.SetDefault("server.web_dir", "./web")
+	v.SetDefault("server.tls.enabled", false)
+	v.SetDefault("server.tls.cert_dir", "./certs")
+	v.SetDefault("server.tls.min_version", "1.2")
+	v.SetDefault("server.tls.client_auth", "none")
+	v.SetDefault("server.cors.allowed_methods", []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"})
+	v.SetDefault("server.cors.allowed_headers", []string{"Content-Type", "Authorization", "X-API-Key", "Accept", "Cache-Control", "X-Requested-With", "X-Request-ID"})
+	v.SetDefault("server.cors.exposed_headers", []string{"Content-Disposition", "Content-Length", "ETag", "X-Request-ID"})
+	v.SetDefault("server.cors.max_age", 600)
 
 	// Scanner defaults
 	v.SetDefault("scanner.default_resolution", 300)
 	v.SetDefault("scanner.default_color_mode", "Color")
 	v.SetDefault("scanner.default_format", "PDF")
 	v.SetDefault("scanner.scan_timeout", 300)
+	v.SetDefault("scanner.param_validation", "strict")
 
 	// Storage defaults
 	v.SetDefault("storage.output_dir", "./scans")
 	v.SetDefault("storage.max_storage_size", int64(10*1024*1024*1024)) // 10GB
 	v.SetDefault("storage.cleanup_enabled", true)
 	v.SetDefault("storage.retention_days", 30)
+	v.SetDefault("storage.cleanup_interval", 60)
+	v.SetDefault("storage.min_free_space", int64(500*1024*1024)) // 500MB
+	v.SetDefault("storage.jobs_file", "./jobs.json")
+	v.SetDefault("storage.backend", "local")
+	v.SetDefault("storage.s3.region", "us-east-1")
+	v.SetDefault("storage.s3.path_style", true)
+	v.SetDefault("storage.s3.timeout", 120)
+	v.SetDefault("storage.webdav.timeout", 120)
+
+	// Webhook defaults
+	v.SetDefault("webhooks.max_attempts", 5)
+	v.SetDefault("webhooks.initial_backoff", 2)
+	v.SetDefault("webhooks.timeout", 10)
+	v.SetDefault("webhooks.log_size", 1000)
+
+	// Email defaults
+	v.SetDefault("email.port", 587)
+	v.SetDefault("email.tls", "starttls")
+	v.SetDefault("email.max_message_size", int64(10*1024*1024)) // 10MB
+	v.SetDefault("email.subject", "Scan {{.Date}}{{if gt .Parts 1}} ({{.Part}}/{{.Parts}}){{end}}")
+	v.SetDefault("email.body", "Attached: {{.Pages}} scanned page(s) from job {{.Job.ID}}.")
+
+	// Export defaults
+	v.SetDefault("export.max_attempts", 3)
+	v.SetDefault("export.retry_delay", 5)
 
 	// Auto-scan defaults
 	v.SetDefault("autoscan.enabled", false)
@@ -111,4 +391,40 @@ func setDefaults(v *viper.Viper) {
 	v.SetDefault("autoscan.default_params.format", "PDF")
 	v.SetDefault("autoscan.default_params.use_duplex", false)
 	v.SetDefault("autoscan.default_params.use_feeder", false)
+
+	// Hot folder defaults
+	v.SetDefault("hotfolder.enabled", false)
+	v.SetDefault("hotfolder.path", "./hotfolder")
+	v.SetDefault("hotfolder.settle_delay", 2)
+	v.SetDefault("hotfolder.resolution", 300)
+
+	// Scheduler defaults
+	v.SetDefault("scheduler.file", "./schedules.json")
+
+	// Auth defaults
+	v.SetDefault("auth.enabled", false)
+	v.SetDefault("auth.jwt.default_role", "viewer")
+
+	// Metrics defaults
+	v.SetDefault("metrics.enabled", true)
+	v.SetDefault("metrics.require_auth", false)
+
+	// Logging defaults
+	v.SetDefault("log.level", "info")
+	v.SetDefault("log.format", "text")
+
+	// Tracing defaults
+	v.SetDefault("tracing.enabled", false)
+	v.SetDefault("tracing.endpoint", "localhost:4318")
+	v.SetDefault("tracing.insecure", true)
+	v.SetDefault("tracing.service_name", "scanserver")
+	v.SetDefault("tracing.sample_ratio", 1.0)
+
+	// Audit defaults
+	v.SetDefault("audit.enabled", true)
+	v.SetDefault("audit.file", "./audit.jsonl")
+
+	// Health check defaults
+	v.SetDefault("health.timeout", 5)
+	v.SetDefault("health.export_interval", 60)
 }
@@ -0,0 +1,208 @@
+// Package cors decides which browser origins may call the API
+package cors
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/scanserver/scanner-service/internal/config"
+)
+
+// Policy applies the configured origin policy to REST requests and WebSocket upgrades
+type Policy struct {
+	config   *config.CORSConfig
+	any      bool     // allowed_origins contains "*"
+	origins  []string // Exact origins, lower case
+	suffixes []string // "https://*.example.com" as scheme "https://" and suffix ".example.com"
+	schemes  []string
+
+	methods   string
+	headers   string
+	exposed   string
+	maxAge    string
+	anyHeader bool // allowed_headers contains "*": echo the requested headers
+}
+
+// NewPolicy validates the CORS configuration
+func NewPolicy(cfg *config.CORSConfig) (*Policy, error) {
+	p := &Policy{
+		config:  cfg,
+		methods: strings.Join(cfg.AllowedMethods, ", "),
+		exposed: strings.Join(cfg.ExposedHeaders, ", "),
+	}
+	if cfg.MaxAge > 0 {
+		p.maxAge = strconv.Itoa(cfg.MaxAge)
+	}
+
+	for _, header := range cfg.AllowedHeaders {
+		if header == "*" {
+			p.anyHeader = true
+		}
+	}
+	p.headers = strings.Join(cfg.AllowedHeaders, ", ")
+
+	for _, origin := range cfg.AllowedOrigins {
+		origin = strings.ToLower(strings.TrimRight(origin, "/"))
+		if origin == "*" {
+			if cfg.AllowCredentials {
+				return nil, fmt.Errorf("server.cors: allowed_origins \"*\" can't be combined with allow_credentials")
+			}
+			p.any = true
+			continue
+		}
+
+		scheme, host, ok := strings.Cut(origin, "://")
+		if !ok || (scheme != "http" && scheme != "https") || host == "" || strings.ContainsAny(host, "/?#") {
+			return nil, fmt.Errorf("server.cors: invalid origin %q, expected e.g. https://app.example.com", origin)
+		}
+		if strings.HasPrefix(host, "*.") {
+			if strings.Contains(host[2:], "*") {
+				return nil, fmt.Errorf("server.cors: invalid origin %q, only the leftmost label can be *", origin)
+			}
+			p.schemes = append(p.schemes, scheme+"://")
+			p.suffixes = append(p.suffixes, host[1:])
+			continue
+		}
+		if strings.Contains(host, "*") {
+			return nil, fmt.Errorf("server.cors: invalid origin %q, only the leftmost label can be *", origin)
+		}
+		p.origins = append(p.origins, origin)
+	}
+	return p, nil
+}
+
+// Allowed reports whether a cross-origin request from origin is allowed
+func (p *Policy) Allowed(origin string) bool {
+	if origin == "" {
+		return false
+	}
+	if p.any {
+		return true
+	}
+
+	origin = strings.ToLower(origin)
+	for _, allowed := range p.origins {
+		if origin == allowed {
+			return true
+		}
+	}
+	for i, suffix := range p.suffixes {
+		// The wildcard stands for one or more labels, not for the bare domain
+		host, ok := strings.CutPrefix(origin, p.schemes[i])
+		if ok && strings.HasSuffix(host, suffix) && len(host) > len(suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Middleware sets the CORS headers for allowed origins and answers preflight
+// requests. Preflights from other origins are rejected with 403 Forbidden;
+// other requests from them get no CORS headers, so browsers hide the response.
+func (p *Policy) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		origin := c.Request.Header.Get("Origin")
+		preflight := c.Request.Method == http.MethodOptions && c.Request.Header.Get("Access-Control-Request-Method") != ""
+
+		if origin == "" || sameOrigin(c.Request) {
+			if c.Request.Method == http.MethodOptions {
+				c.AbortWithStatus(http.StatusNoContent)
+				return
+			}
+			c.Next()
+			return
+		}
+
+		c.Writer.Header().Add("Vary", "Origin")
+		if !p.Allowed(origin) {
+			if preflight {
+				c.AbortWithStatus(http.StatusForbidden)
+				return
+			}
+			c.Next()
+			return
+		}
+
+		header := c.Writer.Header()
+		if p.any {
+			header.Set("Access-Control-Allow-Origin", "*")
+		} else {
+			header.Set("Access-Control-Allow-Origin", origin)
+		}
+		if p.config.AllowCredentials {
+			header.Set("Access-Control-Allow-Credentials", "true")
+		}
+
+		if preflight {
+			header.Set("Access-Control-Allow-Methods", p.methods)
+			if p.anyHeader {
+				header.Add("Vary", "Access-Control-Request-Headers")
+				header.Set("Access-Control-Allow-Headers", c.Request.Header.Get("Access-Control-Request-Headers"))
+			} else {
+				header.Set("Access-Control-Allow-Headers", p.headers)
+			}
+			if p.maxAge != "" {
+				header.Set("Access-Control-Max-Age", p.maxAge)
+			}
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+
+		if p.exposed != "" {
+			header.Set("Access-Control-Expose-Headers", p.exposed)
+		}
+		c.Next()
+	}
+}
+
+// CheckOrigin is the WebSocket upgrader's origin check. Clients that send no
+// Origin (non-browser clients), same-origin pages and allowed origins may connect.
+func (p *Policy) CheckOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	return origin == "" || sameOrigin(r) || p.Allowed(origin)
+}
+
+// sameOrigin reports whether the request's Origin names the scheme and host
+// it was sent to. Behind a TLS-terminating proxy, the scheme is taken from
+// X-Forwarded-Proto.
+func sameOrigin(r *http.Request) bool {
+	u, err := url.Parse(r.Header.Get("Origin"))
+	if err != nil || u.Host == "" {
+		return false
+	}
+	scheme := requestScheme(r)
+	if !strings.EqualFold(u.Scheme, scheme) {
+		return false
+	}
+	return strings.EqualFold(withoutDefaultPort(u.Host, scheme), withoutDefaultPort(r.Host, scheme))
+}
+
+// requestScheme returns the scheme the client used for a request
+func requestScheme(r *http.Request) string {
+	if r.TLS != nil {
+		return "https"
+	}
+	if proto := r.Header.Get("X-Forwarded-Proto"); proto != "" {
+		// The first proxy's value is the client's
+		proto, _, _ = strings.Cut(proto, ",")
+		return strings.ToLower(strings.TrimSpace(proto))
+	}
+	return "http"
+}
+
+// withoutDefaultPort strips :80 from http and :443 from https hosts, which
+// browsers leave out of Origin
+func withoutDefaultPort(host, scheme string) string {
+	if scheme == "http" {
+		return strings.TrimSuffix(host, ":80")
+	}
+	if scheme == "https" {
+		return strings.TrimSuffix(host, ":443")
+	}
+	return host
+}
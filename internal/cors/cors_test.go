@@ -0,0 +1,113 @@
+package cors
+
+import (
+	"crypto/tls"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/scanserver/scanner-service/internal/config"
+)
+
+func newTestPolicy(t *testing.T, origins ...string) *Policy {
+	t.Helper()
+	p, err := NewPolicy(&config.CORSConfig{AllowedOrigins: origins})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestAllowed(t *testing.T) {
+	p := newTestPolicy(t, "https://portal.example.com/", "HTTPS://*.Intranet.Example.com", "http://localhost:3000")
+
+	tests := []struct {
+		origin string
+		want   bool
+	}{
+		{"https://portal.example.com", true},
+		{"https://PORTAL.example.com", true},
+		{"http://portal.example.com", false},                // Other scheme
+		{"https://portal.example.com:8443", false},          // Other port
+		{"https://evilportal.example.com", false},           // Not a subdomain
+		{"https://hr.intranet.example.com", true},           // Wildcard
+		{"https://a.b.intranet.example.com", true},          // Several labels
+		{"https://intranet.example.com", false},             // Not the bare domain
+		{"https://evil-intranet.example.com", false},        // Suffix without the dot
+		{"http://hr.intranet.example.com", false},           // Wildcard keeps the scheme
+		{"https://hr.intranet.example.com.evil.com", false}, // Suffix must end the host
+		{"http://localhost:3000", true},
+		{"http://localhost", false},
+		{"null", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := p.Allowed(tt.origin); got != tt.want {
+			t.Errorf("Allowed(%q) = %v, want %v", tt.origin, got, tt.want)
+		}
+	}
+
+	wildcard := newTestPolicy(t, "*")
+	if !wildcard.Allowed("https://anything.example.org") || wildcard.Allowed("") {
+		t.Error(`"*" must allow every origin`)
+	}
+}
+
+func TestNewPolicyValidation(t *testing.T) {
+	for _, cfg := range []config.CORSConfig{
+		{AllowedOrigins: []string{"*"}, AllowCredentials: true},
+		{AllowedOrigins: []string{"portal.example.com"}},
+		{AllowedOrigins: []string{"ftp://portal.example.com"}},
+		{AllowedOrigins: []string{"https://portal.example.com/app"}},
+		{AllowedOrigins: []string{"https://*.*.example.com"}},
+		{AllowedOrigins: []string{"https://portal.*.com"}},
+	} {
+		if _, err := NewPolicy(&cfg); err == nil {
+			t.Errorf("%+v: no error", cfg)
+		}
+	}
+}
+
+func TestCheckOrigin(t *testing.T) {
+	p := newTestPolicy(t, "https://portal.example.com")
+
+	tests := []struct {
+		name      string
+		host      string
+		origin    string
+		tls       bool
+		forwarded string // X-Forwarded-Proto
+		want      bool
+	}{
+		{name: "no origin", host: "scan.example.com", want: true},
+		{name: "same origin", host: "scan.example.com:8080", origin: "http://scan.example.com:8080", want: true},
+		{name: "same origin, default port", host: "scan.example.com:80", origin: "http://scan.example.com", want: true},
+		{name: "same host over TLS", host: "scan.example.com", origin: "https://scan.example.com", tls: true, want: true},
+		{name: "same host behind a TLS proxy", host: "scan.example.com", origin: "https://scan.example.com", forwarded: "https", want: true},
+		{name: "first forwarded proto", host: "scan.example.com", origin: "https://scan.example.com", forwarded: "HTTPS, http", want: true},
+		{name: "https page, plain request", host: "scan.example.com", origin: "https://scan.example.com", want: false},
+		{name: "http page, TLS request", host: "scan.example.com", origin: "http://scan.example.com", tls: true, want: false},
+		{name: "http page behind a TLS proxy", host: "scan.example.com", origin: "http://scan.example.com", forwarded: "https", want: false},
+		{name: "other port", host: "scan.example.com:8080", origin: "http://scan.example.com:9090", want: false},
+		{name: "other host", host: "scan.example.com", origin: "http://evil.example.com", want: false},
+		{name: "allowed origin", host: "scan.example.com", origin: "https://portal.example.com", want: true},
+		{name: "opaque origin", host: "scan.example.com", origin: "null", want: false},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/ws", nil)
+		r.Host = tt.host
+		if tt.origin != "" {
+			r.Header.Set("Origin", tt.origin)
+		}
+		if tt.tls {
+			r.TLS = &tls.ConnectionState{}
+		} else {
+			r.TLS = nil
+		}
+		if tt.forwarded != "" {
+			r.Header.Set("X-Forwarded-Proto", tt.forwarded)
+		}
+		if got := p.CheckOrigin(r); got != tt.want {
+			t.Errorf("%s: CheckOrigin = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+// Package document merges scanned page images into multi-page documents
+package document
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"os"
+
+	// Decoders for page images
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+
+	_ "golang.org/x/image/bmp"
+	_ "golang.org/x/image/tiff"
+)
+
+// ErrNoPages is returned when a document would have no pages
+var ErrNoPages = errors.New("document has no pages")
+
+// DefaultDPI is assumed for pages whose resolution is unknown
+const DefaultDPI = 300
+
+// Page is a scanned page image
+type Page struct {
+	Path string
+	DPI  int // Resolution of the image, 0 = DefaultDPI
+}
+
+// dpi returns the page resolution
+func (p Page) dpi() int {
+	if p.DPI > 0 {
+		return p.DPI
+	}
+	return DefaultDPI
+}
+
+// decode reads the page image
+func (p Page) decode() (image.Image, error) {
+	f, err := os.Open(p.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %w", p.Path, err)
+	}
+	return img, nil
+}
+
+// pixels returns the image as 8-bit samples, one byte per pixel for
+// grayscale images and three (RGB) otherwise
+func pixels(img image.Image) (data []byte, gray bool) {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+
+	switch m := img.(type) {
+	case *image.Gray:
+		if m.Stride == w {
+			return m.Pix[:w*h], true
+		}
+		data = make([]byte, 0, w*h)
+		for y := 0; y < h; y++ {
+			data = append(data, m.Pix[y*m.Stride:y*m.Stride+w]...)
+		}
+		return data, true
+	}
+
+	if img.ColorModel() == color.GrayModel || img.ColorModel() == color.Gray16Model {
+		data = make([]byte, 0, w*h)
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			for x := b.Min.X; x < b.Max.X; x++ {
+				data = append(data, color.GrayModel.Convert(img.At(x, y)).(color.Gray).Y)
+			}
+		}
+		return data, true
+	}
+
+	data = make([]byte, 0, w*h*3)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			r, g, bl, _ := img.At(x, y).RGBA()
+			data = append(data, byte(r>>8), byte(g>>8), byte(bl>>8))
+		}
+	}
+	return data, false
+}
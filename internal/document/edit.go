@@ -0,0 +1,179 @@
+package document
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/disintegration/imaging"
+	"github.com/scanserver/scanner-service/pkg/models"
+)
+
+// ErrUnsupportedFormat is returned for edits that cannot be applied to a
+// page's format, such as rotating or interleaving PDF pages
+var ErrUnsupportedFormat = errors.New("page format cannot be edited")
+
+// PageEdit is a page of an edited document: the source page and the rotation to apply
+type PageEdit struct {
+	Source models.ScanResult
+	Rotate int // Clockwise degrees: 0, 90, 180 or 270
+}
+
+// Unchanged returns the pages as edits without rotation
+func Unchanged(pages []models.ScanResult) []PageEdit {
+	edits := make([]PageEdit, len(pages))
+	for i, page := range pages {
+		edits[i] = PageEdit{Source: page}
+	}
+	return edits
+}
+
+// Reorder returns the pages in the given order. order lists every
+// 1-based page number exactly once.
+func Reorder(pages []PageEdit, order []int) ([]PageEdit, error) {
+	if len(order) != len(pages) {
+		return nil, fmt.Errorf("order must list all %d pages", len(pages))
+	}
+
+	seen := make(map[int]bool, len(order))
+	result := make([]PageEdit, 0, len(pages))
+	for _, n := range order {
+		if n < 1 || n > len(pages) {
+			return nil, fmt.Errorf("page %d does not exist", n)
+		}
+		if seen[n] {
+			return nil, fmt.Errorf("page %d is listed twice", n)
+		}
+		seen[n] = true
+		result = append(result, pages[n-1])
+	}
+	return result, nil
+}
+
+// Delete returns the pages without the given 1-based page numbers
+func Delete(pages []PageEdit, numbers []int) ([]PageEdit, error) {
+	remove, err := pageSet(pages, numbers)
+	if err != nil {
+		return nil, err
+	}
+	if len(remove) == len(pages) {
+		return nil, fmt.Errorf("cannot delete all pages")
+	}
+
+	result := make([]PageEdit, 0, len(pages)-len(remove))
+	for i, page := range pages {
+		if !remove[i+1] {
+			result = append(result, page)
+		}
+	}
+	return result, nil
+}
+
+// Rotate rotates the given 1-based pages clockwise by degrees (a multiple of 90)
+func Rotate(pages []PageEdit, numbers []int, degrees int) ([]PageEdit, error) {
+	if degrees%90 != 0 {
+		return nil, fmt.Errorf("rotation must be a multiple of 90 degrees")
+	}
+
+	rotate, err := pageSet(pages, numbers)
+	if err != nil {
+		return nil, err
+	}
+	for n := range rotate {
+		if isPDF(pages[n-1].Source.Format) {
+			return nil, fmt.Errorf("%w: page %d is a PDF and cannot be rotated", ErrUnsupportedFormat, n)
+		}
+	}
+
+	result := make([]PageEdit, len(pages))
+	for i, page := range pages {
+		if rotate[i+1] {
+			page.Rotate = ((page.Rotate+degrees)%360 + 360) % 360
+		}
+		result[i] = page
+	}
+	return result, nil
+}
+
+// Interleave merges the fronts and backs of two simplex feeder passes into
+// duplex order. The backs pass is usually scanned with the stack flipped,
+// so it comes out last page first; reverseBacks restores its order.
+func Interleave(fronts, backs []PageEdit, reverseBacks bool) ([]PageEdit, error) {
+	if len(backs) > len(fronts) {
+		return nil, fmt.Errorf("more backs (%d) than fronts (%d)", len(backs), len(fronts))
+	}
+	for _, pass := range [][]PageEdit{fronts, backs} {
+		for i, page := range pass {
+			if isPDF(page.Source.Format) {
+				return nil, fmt.Errorf("%w: page %d is a PDF and cannot be interleaved", ErrUnsupportedFormat, i+1)
+			}
+		}
+	}
+
+	result := make([]PageEdit, 0, len(fronts)+len(backs))
+	for i, front := range fronts {
+		result = append(result, front)
+
+		j := i
+		if reverseBacks {
+			j = len(backs) - 1 - i
+		}
+		if j >= 0 && j < len(backs) {
+			result = append(result, backs[j])
+		}
+	}
+	return result, nil
+}
+
+// pageSet validates 1-based page numbers and returns them as a set
+func pageSet(pages []PageEdit, numbers []int) (map[int]bool, error) {
+	if len(numbers) == 0 {
+		return nil, fmt.Errorf("no pages given")
+	}
+
+	set := make(map[int]bool, len(numbers))
+	for _, n := range numbers {
+		if n < 1 || n > len(pages) {
+			return nil, fmt.Errorf("page %d does not exist", n)
+		}
+		set[n] = true
+	}
+	return set, nil
+}
+
+// isPDF reports whether a page format is PDF, which the image functions cannot read
+func isPDF(format string) bool {
+	return strings.EqualFold(format, "PDF")
+}
+
+// RotateImage writes src rotated clockwise by degrees to dst, keeping the image format
+func RotateImage(src, dst string, degrees, jpegQuality int) error {
+	if strings.EqualFold(filepath.Ext(src), ".pdf") {
+		return fmt.Errorf("%w: %s is a PDF and cannot be rotated", ErrUnsupportedFormat, filepath.Base(src))
+	}
+
+	img, err := imaging.Open(src)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", src, err)
+	}
+
+	// imaging rotates counter-clockwise
+	switch degrees {
+	case 90:
+		img = imaging.Rotate270(img)
+	case 180:
+		img = imaging.Rotate180(img)
+	case 270:
+		img = imaging.Rotate90(img)
+	}
+
+	if jpegQuality <= 0 {
+		jpegQuality = models.DefaultJpegQuality
+	}
+
+	if err := imaging.Save(img, dst, imaging.JPEGQuality(jpegQuality)); err != nil {
+		return fmt.Errorf("failed to save %s: %w", dst, err)
+	}
+	return nil
+}
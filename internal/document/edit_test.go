@@ -0,0 +1,89 @@
+package document
+
+import (
+	"errors"
+	"image"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/disintegration/imaging"
+	"github.com/scanserver/scanner-service/pkg/models"
+)
+
+func testPages(formats ...string) []PageEdit {
+	pages := make([]models.ScanResult, len(formats))
+	for i, format := range formats {
+		pages[i] = models.ScanResult{PageNumber: i + 1, Format: format}
+	}
+	return Unchanged(pages)
+}
+
+func TestRotateRejectsPDF(t *testing.T) {
+	pages := testPages("JPEG", "PDF")
+
+	if _, err := Rotate(pages, []int{2}, 90); !errors.Is(err, ErrUnsupportedFormat) {
+		t.Errorf("rotating a PDF page: got %v, want ErrUnsupportedFormat", err)
+	}
+
+	// Other pages of the job can still be rotated
+	rotated, err := Rotate(pages, []int{1}, -90)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rotated[0].Rotate != 270 || rotated[1].Rotate != 0 {
+		t.Errorf("rotations = %d, %d", rotated[0].Rotate, rotated[1].Rotate)
+	}
+}
+
+func TestInterleave(t *testing.T) {
+	fronts := testPages("JPEG", "JPEG", "JPEG")
+	backs := testPages("PNG", "PNG", "PNG")
+
+	pages, err := Interleave(fronts, backs, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Backs were scanned last page first
+	want := []struct {
+		format string
+		page   int
+	}{{"JPEG", 1}, {"PNG", 3}, {"JPEG", 2}, {"PNG", 2}, {"JPEG", 3}, {"PNG", 1}}
+	for i, w := range want {
+		if got := pages[i].Source; got.Format != w.format || got.PageNumber != w.page {
+			t.Errorf("page %d = %s %d, want %s %d", i+1, got.Format, got.PageNumber, w.format, w.page)
+		}
+	}
+
+	if _, err := Interleave(fronts, testPages("JPEG", "PDF"), true); !errors.Is(err, ErrUnsupportedFormat) {
+		t.Errorf("interleaving PDF backs: got %v, want ErrUnsupportedFormat", err)
+	}
+}
+
+func TestRotateImage(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "page.png")
+	if err := imaging.Save(image.NewGray(image.Rect(0, 0, 20, 10)), src); err != nil {
+		t.Fatal(err)
+	}
+
+	dst := filepath.Join(dir, "rotated.png")
+	if err := RotateImage(src, dst, 90, 0); err != nil {
+		t.Fatal(err)
+	}
+	img, err := imaging.Open(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := img.Bounds(); b.Dx() != 10 || b.Dy() != 20 {
+		t.Errorf("rotated size = %dx%d, want 10x20", b.Dx(), b.Dy())
+	}
+
+	pdf := filepath.Join(dir, "page.pdf")
+	if err := os.WriteFile(pdf, []byte("%PDF-1.4"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := RotateImage(pdf, filepath.Join(dir, "out.pdf"), 90, 0); !errors.Is(err, ErrUnsupportedFormat) {
+		t.Errorf("rotating a PDF file: got %v, want ErrUnsupportedFormat", err)
+	}
+}
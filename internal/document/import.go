@@ -0,0 +1,377 @@
+package document
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/image/tiff"
+
+	"github.com/scanserver/scanner-service/pkg/models"
+)
+
+// ErrUnsupportedFile is returned for files that cannot be imported
+var ErrUnsupportedFile = errors.New("unsupported file type")
+
+// Bounds on imported files, which come from untrusted sources: pages taken
+// from one TIFF or PDF file, and the width, height and pixels of a page
+const (
+	maxImportPages     = 1000
+	maxImportDimension = 1 << 16
+	maxImportPixels    = 1 << 28
+)
+
+// checkImageSize rejects page images too large to decode
+func checkImageSize(width, height int) error {
+	if width <= 0 || height <= 0 || width > maxImportDimension || height > maxImportDimension || width*height > maxImportPixels {
+		return fmt.Errorf("invalid image size %dx%d", width, height)
+	}
+	return nil
+}
+
+// CanImport reports whether Import accepts a file, judging by its extension
+func CanImport(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".jpg", ".jpeg", ".png", ".tif", ".tiff", ".pdf":
+		return true
+	}
+	return false
+}
+
+// Import splits a JPEG, PNG, TIFF or PDF file into page images. next
+// allocates the path of each page for a document format such as "JPEG".
+// JPEG and PNG files are copied as-is; TIFF pages are converted to PNG.
+// PDFs must be scans, i.e. contain one JPEG or Flate image per page.
+func Import(src string, next func(format string) (string, error)) ([]models.ScanResult, error) {
+	var results []models.ScanResult
+	add := func(format string, data []byte) error {
+		cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+		if err == nil {
+			err = checkImageSize(cfg.Width, cfg.Height)
+		}
+		if err != nil {
+			return fmt.Errorf("page %d: %w", len(results)+1, err)
+		}
+
+		dst, err := next(format)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(dst, data, 0644); err != nil {
+			return err
+		}
+
+		results = append(results, models.ScanResult{
+			PageNumber: len(results) + 1,
+			FilePath:   dst,
+			FileSize:   int64(len(data)),
+			Format:     format,
+			Width:      cfg.Width,
+			Height:     cfg.Height,
+		})
+		return nil
+	}
+
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return nil, err
+	}
+
+	switch strings.ToLower(filepath.Ext(src)) {
+	case ".jpg", ".jpeg":
+		err = add("JPEG", data)
+	case ".png":
+		err = add("PNG", data)
+	case ".tif", ".tiff":
+		err = importTIFF(data, add)
+	case ".pdf":
+		err = importPDF(data, add)
+	default:
+		err = fmt.Errorf("%w: %s", ErrUnsupportedFile, filepath.Ext(src))
+	}
+	if err != nil {
+		return results, err
+	}
+
+	if len(results) == 0 {
+		return nil, ErrNoPages
+	}
+	return results, nil
+}
+
+// importTIFF converts every page of a (multi-page) TIFF to PNG
+func importTIFF(data []byte, add func(format string, data []byte) error) error {
+	if len(data) < 8 {
+		return fmt.Errorf("not a TIFF file")
+	}
+
+	var order binary.ByteOrder
+	switch string(data[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return fmt.Errorf("not a TIFF file")
+	}
+
+	// Collect the chain of image file directories, one per page
+	var ifds []uint32
+	seen := make(map[uint32]bool)
+	for offset := order.Uint32(data[4:8]); offset != 0; {
+		if seen[offset] || int(offset)+2 > len(data) || len(ifds) >= maxImportPages {
+			return fmt.Errorf("invalid TIFF directory chain")
+		}
+		seen[offset] = true
+		ifds = append(ifds, offset)
+
+		entries := int(order.Uint16(data[offset:]))
+		end := int(offset) + 2 + entries*12
+		if end+4 > len(data) {
+			return fmt.Errorf("invalid TIFF directory")
+		}
+		offset = order.Uint32(data[end:])
+	}
+
+	// The decoder reads only the first directory, so point the header at
+	// each page in turn. Offsets in TIFF files are absolute.
+	page := make([]byte, len(data))
+	copy(page, data)
+	for i, ifd := range ifds {
+		order.PutUint32(page[4:8], ifd)
+		cfg, err := tiff.DecodeConfig(bytes.NewReader(page))
+		if err == nil {
+			err = checkImageSize(cfg.Width, cfg.Height)
+		}
+		if err != nil {
+			return fmt.Errorf("page %d: %w", i+1, err)
+		}
+		img, err := tiff.Decode(bytes.NewReader(page))
+		if err != nil {
+			return fmt.Errorf("page %d: %w", i+1, err)
+		}
+
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, img); err != nil {
+			return err
+		}
+		if err := add("PNG", buf.Bytes()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var (
+	pdfObject      = regexp.MustCompile(`(\d+)\s+(\d+)\s+obj\b`)
+	pdfImage       = regexp.MustCompile(`/Subtype\s*/Image\b`)
+	pdfPage        = regexp.MustCompile(`/Type\s*/Page\b`)
+	pdfSMask       = regexp.MustCompile(`/SMask\s+(\d+)\s+\d+\s+R`)
+	pdfLength      = regexp.MustCompile(`/Length\s+(\d+)(\s+(\d+)\s+R)?`)
+	pdfDimension   = regexp.MustCompile(`/(Width|Height|BitsPerComponent)\s+(\d+)`)
+	pdfFilter      = regexp.MustCompile(`/Filter\s*\[?\s*/(\w+)\s*\]?`)
+	pdfDecodeParms = regexp.MustCompile(`/DecodeParms\b`)
+)
+
+// pdfStreamImage is an image XObject found in a PDF
+type pdfStreamImage struct {
+	num  string
+	dict string
+	data []byte
+}
+
+// importPDF extracts the page images of a scanned PDF, in file order.
+// PDFs with other content (text, vector graphics) are rejected rather than
+// imported with pages missing.
+func importPDF(data []byte, add func(format string, data []byte) error) error {
+	if !bytes.HasPrefix(data, []byte("%PDF-")) {
+		return fmt.Errorf("not a PDF file")
+	}
+
+	var images []pdfStreamImage
+	pages := 0
+	masks := make(map[string]bool)
+
+	for pos := 0; pos < len(data); {
+		loc := pdfObject.FindSubmatchIndex(data[pos:])
+		if loc == nil {
+			break
+		}
+		num := string(data[pos+loc[2] : pos+loc[3]])
+		start := pos + loc[1]
+
+		// The dictionary runs up to the stream keyword or the end of the object
+		end := bytes.Index(data[start:], []byte("endobj"))
+		if end < 0 {
+			break
+		}
+		end += start
+		streamAt := bytes.Index(data[start:end], []byte("stream"))
+		if streamAt < 0 {
+			dict := string(data[start:end])
+			if pdfPage.MatchString(dict) {
+				pages++
+			}
+			pos = end
+			continue
+		}
+
+		dict := string(data[start : start+streamAt])
+		body := start + streamAt + len("stream")
+		if bytes.HasPrefix(data[body:], []byte("\r\n")) {
+			body += 2
+		} else if bytes.HasPrefix(data[body:], []byte("\n")) {
+			body++
+		}
+
+		length := pdfStreamLength(data, dict)
+		if length < 0 || length > len(data)-body {
+			// Fall back to the endstream keyword
+			length = bytes.Index(data[body:], []byte("endstream"))
+			if length < 0 {
+				return fmt.Errorf("unterminated stream in object %s", num)
+			}
+		}
+
+		for _, m := range pdfSMask.FindAllStringSubmatch(dict, -1) {
+			masks[m[1]] = true
+		}
+		if pdfImage.MatchString(dict) {
+			images = append(images, pdfStreamImage{num: num, dict: dict, data: data[body : body+length]})
+		}
+
+		pos = body + length
+		if next := bytes.Index(data[pos:], []byte("endobj")); next >= 0 {
+			pos += next
+		}
+	}
+
+	// Soft masks (transparency) are images too, but not pages
+	var pageImages []pdfStreamImage
+	for _, img := range images {
+		if !masks[img.num] {
+			pageImages = append(pageImages, img)
+		}
+	}
+
+	// Page objects inside compressed object streams can't be counted
+	if pages > 0 && pages != len(pageImages) {
+		return fmt.Errorf("PDF has %d pages but %d page images; only scanned documents can be imported", pages, len(pageImages))
+	}
+	if len(pageImages) > maxImportPages {
+		return fmt.Errorf("PDF has more than %d pages", maxImportPages)
+	}
+
+	for i, img := range pageImages {
+		format, page, err := pdfPageImage(img)
+		if err != nil {
+			return fmt.Errorf("page %d: %w", i+1, err)
+		}
+		if err := add(format, page); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pdfStreamLength returns the /Length of a stream, following an indirect
+// reference, or -1 if it can't be determined
+func pdfStreamLength(data []byte, dict string) int {
+	m := pdfLength.FindStringSubmatch(dict)
+	if m == nil {
+		return -1
+	}
+	if m[2] == "" {
+		n, _ := strconv.Atoi(m[1])
+		return n
+	}
+
+	ref := regexp.MustCompile(`(?:^|\s)` + m[1] + `\s+` + m[3] + `\s+obj\s+(\d+)`)
+	if r := ref.FindSubmatch(data); r != nil {
+		n, _ := strconv.Atoi(string(r[1]))
+		return n
+	}
+	return -1
+}
+
+// pdfPageImage returns a page image as a JPEG (embedded as-is) or PNG file
+func pdfPageImage(img pdfStreamImage) (string, []byte, error) {
+	filter := ""
+	if m := pdfFilter.FindStringSubmatch(img.dict); m != nil {
+		filter = m[1]
+	}
+
+	switch filter {
+	case "DCTDecode":
+		return "JPEG", img.data, nil
+	case "FlateDecode":
+	default:
+		return "", nil, fmt.Errorf("unsupported image encoding %q", filter)
+	}
+
+	if pdfDecodeParms.MatchString(img.dict) {
+		return "", nil, fmt.Errorf("unsupported image predictor")
+	}
+
+	dims := make(map[string]int)
+	for _, m := range pdfDimension.FindAllStringSubmatch(img.dict, -1) {
+		dims[m[1]], _ = strconv.Atoi(m[2])
+	}
+	width, height, bpc := dims["Width"], dims["Height"], dims["BitsPerComponent"]
+	if err := checkImageSize(width, height); err != nil {
+		return "", nil, err
+	}
+
+	zr, err := zlib.NewReader(bytes.NewReader(img.data))
+	if err != nil {
+		return "", nil, err
+	}
+	samples, err := io.ReadAll(io.LimitReader(zr, int64(width*height*3)+1))
+	if err != nil {
+		return "", nil, err
+	}
+
+	// The sample layout follows from the data size, which also covers
+	// color spaces given by reference (e.g. ICC profiles)
+	var page image.Image
+	switch {
+	case bpc == 8 && len(samples) == width*height:
+		page = &image.Gray{Pix: samples, Stride: width, Rect: image.Rect(0, 0, width, height)}
+	case bpc == 8 && len(samples) == width*height*3:
+		rgba := image.NewRGBA(image.Rect(0, 0, width, height))
+		for i := 0; i < width*height; i++ {
+			copy(rgba.Pix[i*4:], samples[i*3:i*3+3])
+			rgba.Pix[i*4+3] = 0xff
+		}
+		page = rgba
+	case bpc == 1 && len(samples) == (width+7)/8*height:
+		gray := image.NewGray(image.Rect(0, 0, width, height))
+		stride := (width + 7) / 8
+		for y := 0; y < height; y++ {
+			for x := 0; x < width; x++ {
+				if samples[y*stride+x/8]&(0x80>>(x%8)) != 0 {
+					gray.Pix[y*width+x] = 0xff
+				}
+			}
+		}
+		page = gray
+	default:
+		return "", nil, fmt.Errorf("unsupported image layout (%d bits, %d bytes for %dx%d)", bpc, len(samples), width, height)
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, page); err != nil {
+		return "", nil, err
+	}
+	return "PNG", buf.Bytes(), nil
+}
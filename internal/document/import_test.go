@@ -0,0 +1,255 @@
+package document
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/scanserver/scanner-service/pkg/models"
+)
+
+// importBytes imports data saved as a file called name into a temporary directory
+func importBytes(t *testing.T, name string, data []byte) ([]models.ScanResult, error) {
+	t.Helper()
+	dir := t.TempDir()
+	src := filepath.Join(dir, name)
+	if err := os.WriteFile(src, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	n := 0
+	return Import(src, func(format string) (string, error) {
+		n++
+		return filepath.Join(dir, fmt.Sprintf("page-%04d.%s", n, strings.ToLower(format))), nil
+	})
+}
+
+// testTIFF builds a little-endian TIFF of 8-bit grayscale pages, each
+// declaring the given size but holding width*height bytes at most 4096
+func testTIFF(width, height uint32, pages int) []byte {
+	const entries = 9
+	pixels := int(width * height)
+	if pixels > 4096 || pixels < 0 {
+		pixels = 4096
+	}
+	ifdSize := 2 + entries*12 + 4
+
+	var buf bytes.Buffer
+	buf.WriteString("II*\x00")
+	binary.Write(&buf, binary.LittleEndian, uint32(8))
+	for i := 0; i < pages; i++ {
+		ifd := 8 + i*(ifdSize+pixels)
+		strip := ifd + ifdSize
+		next := uint32(strip + pixels)
+		if i == pages-1 {
+			next = 0
+		}
+
+		binary.Write(&buf, binary.LittleEndian, uint16(entries))
+		for _, e := range [][3]uint32{
+			{256, 4, width},          // ImageWidth
+			{257, 4, height},         // ImageLength
+			{258, 3, 8},              // BitsPerSample
+			{259, 3, 1},              // Compression: none
+			{262, 3, 1},              // PhotometricInterpretation: black is zero
+			{273, 4, uint32(strip)},  // StripOffsets
+			{277, 3, 1},              // SamplesPerPixel
+			{278, 4, height},         // RowsPerStrip
+			{279, 4, uint32(pixels)}, // StripByteCounts
+		} {
+			binary.Write(&buf, binary.LittleEndian, uint16(e[0]))
+			binary.Write(&buf, binary.LittleEndian, uint16(e[1]))
+			binary.Write(&buf, binary.LittleEndian, uint32(1))
+			binary.Write(&buf, binary.LittleEndian, e[2])
+		}
+		binary.Write(&buf, binary.LittleEndian, next)
+		buf.Write(bytes.Repeat([]byte{0x80}, pixels))
+	}
+	return buf.Bytes()
+}
+
+// testPDF builds a PDF of the given objects, numbered from 1
+func testPDF(objects ...string) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n")
+	for i, obj := range objects {
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	buf.WriteString("trailer\n<< /Root 1 0 R >>\n%%EOF\n")
+	return buf.Bytes()
+}
+
+// pdfImageObject returns an image XObject with a stream of data
+func pdfImageObject(dict string, data []byte) string {
+	return fmt.Sprintf("<< /Type /XObject /Subtype /Image %s /Length %d >>\nstream\n%s\nendstream", dict, len(data), data)
+}
+
+// flate compresses data for a FlateDecode stream
+func flate(data []byte) []byte {
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	zw.Write(data)
+	zw.Close()
+	return buf.Bytes()
+}
+
+func testJPEG(t *testing.T, width, height int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, image.NewGray(image.Rect(0, 0, width, height)), nil); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestImportTIFF(t *testing.T) {
+	results, err := importBytes(t, "scan.tif", testTIFF(16, 8, 3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("%d pages, want 3", len(results))
+	}
+	for i, result := range results {
+		if result.PageNumber != i+1 || result.Format != "PNG" || result.Width != 16 || result.Height != 8 {
+			t.Errorf("page %d = %+v", i+1, result)
+		}
+	}
+}
+
+func TestImportPDF(t *testing.T) {
+	gray := bytes.Repeat([]byte{0x40}, 4*2)
+	data := testPDF(
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R 4 0 R] /Count 2 >>",
+		"<< /Type /Page /Parent 2 0 R /Resources << /XObject << /Im1 5 0 R >> >> >>",
+		"<< /Type /Page /Parent 2 0 R /Resources << /XObject << /Im2 6 0 R >> >> >>",
+		pdfImageObject("/Width 4 /Height 2 /BitsPerComponent 8 /ColorSpace /DeviceGray /Filter /FlateDecode", flate(gray)),
+		pdfImageObject("/Width 8 /Height 4 /BitsPerComponent 8 /ColorSpace /DeviceGray /Filter /DCTDecode", testJPEG(t, 8, 4)),
+	)
+
+	results, err := importBytes(t, "scan.pdf", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("%d pages, want 2", len(results))
+	}
+	if r := results[0]; r.Format != "PNG" || r.Width != 4 || r.Height != 2 {
+		t.Errorf("page 1 = %+v", r)
+	}
+	if r := results[1]; r.Format != "JPEG" || r.Width != 8 || r.Height != 4 {
+		t.Errorf("page 2 = %+v", r)
+	}
+}
+
+// TestImportRejectsBadFiles imports damaged and hostile files. Each must
+// fail with an error, without panicking or allocating for the sizes the
+// file claims.
+func TestImportRejectsBadFiles(t *testing.T) {
+	tiff := testTIFF(16, 8, 2)
+	selfLoop := testTIFF(16, 8, 1)
+	binary.LittleEndian.PutUint32(selfLoop[8+2+9*12:], 8)
+	badStrip := testTIFF(16, 8, 1)
+	binary.LittleEndian.PutUint32(badStrip[8+2+5*12+8:], 1<<30)
+
+	validPDF := testPDF(
+		"<< /Type /Page >>",
+		pdfImageObject("/Width 4 /Height 2 /BitsPerComponent 8 /Filter /FlateDecode", flate(make([]byte, 8))),
+	)
+	manyImages := make([]string, maxImportPages+1)
+	for i := range manyImages {
+		manyImages[i] = pdfImageObject("/Width 1 /Height 1 /BitsPerComponent 8 /Filter /DCTDecode", []byte{0xff, 0xd8})
+	}
+
+	tests := []struct {
+		name string
+		file string
+		data []byte
+		want string // in the error
+	}{
+		{"empty TIFF", "a.tif", nil, "not a TIFF file"},
+		{"TIFF header only", "a.tif", []byte("II*\x00"), "not a TIFF file"},
+		{"truncated TIFF directory", "a.tif", tiff[:20], "invalid TIFF directory"},
+		{"truncated TIFF pixels", "a.tif", tiff[:len(tiff)-100], "page 2"},
+		{"TIFF directory beyond the end", "a.tif", append([]byte("II*\x00"), 0xff, 0xff, 0xff, 0x7f), "invalid TIFF directory chain"},
+		{"TIFF directory loop", "a.tif", selfLoop, "invalid TIFF directory chain"},
+		{"TIFF strip beyond the end", "a.tif", badStrip, "page 1"},
+		{"huge TIFF width", "a.tif", testTIFF(1<<20, 1, 1), "invalid image size"},
+		{"huge TIFF area", "a.tif", testTIFF(1<<15, 1<<15, 1), "invalid image size"},
+		{"too many TIFF pages", "a.tif", testTIFF(1, 1, maxImportPages+1), "invalid TIFF directory chain"},
+
+		{"not a PDF", "a.pdf", []byte("%!PS-Adobe-3.0"), "not a PDF file"},
+		{"truncated PDF stream", "a.pdf", validPDF[:len(validPDF)-40], "1 pages but 0 page images"},
+		{"PDF stream without endstream", "a.pdf", testPDF("<< /Type /Page >>", "<< /Subtype /Image /Length 99 >>\nstream\nxx"), "unterminated stream"},
+		{"PDF stream length beyond the end", "a.pdf", testPDF(
+			"<< /Type /Page >>",
+			"<< /Subtype /Image /Width 1 /Height 1 /BitsPerComponent 8 /Filter /DCTDecode /Length 9223372036854775807 >>\nstream\nxx\nendstream",
+		), "page 1"},
+		{"huge PDF image", "a.pdf", testPDF(
+			"<< /Type /Page >>",
+			pdfImageObject("/Width 100000 /Height 100000 /BitsPerComponent 8 /Filter /FlateDecode", flate(nil)),
+		), "invalid image size"},
+		{"PDF image size overflowing int", "a.pdf", testPDF(
+			"<< /Type /Page >>",
+			pdfImageObject("/Width 4294967296 /Height 4294967296 /BitsPerComponent 8 /Filter /FlateDecode", flate(nil)),
+		), "invalid image size"},
+		{"PDF image larger than declared", "a.pdf", testPDF(
+			"<< /Type /Page >>",
+			pdfImageObject("/Width 2 /Height 2 /BitsPerComponent 8 /Filter /FlateDecode", flate(make([]byte, 1<<20))),
+		), "unsupported image layout"},
+		{"huge JPEG in a PDF", "a.pdf", testPDF(
+			"<< /Type /Page >>",
+			pdfImageObject("/Width 1 /Height 1 /BitsPerComponent 8 /Filter /DCTDecode", hugeJPEG(t)),
+		), "invalid image size"},
+		{"text PDF", "a.pdf", testPDF(
+			"<< /Type /Page /Contents 2 0 R >>",
+			"<< /Length 44 >>\nstream\nBT /F1 12 Tf 72 712 Td (Hello) Tj ET\nendstream",
+		), "only scanned documents"},
+		{"PDF with more pages than images", "a.pdf", append(validPDF, testPDF("<< /Type /Page >>")...), "2 pages but 1 page images"},
+		{"too many PDF pages", "a.pdf", testPDF(manyImages...), "more than 1000 pages"},
+		{"unsupported PDF image encoding", "a.pdf", testPDF(
+			"<< /Type /Page >>",
+			pdfImageObject("/Width 1 /Height 1 /BitsPerComponent 1 /Filter /CCITTFaxDecode", []byte{0}),
+		), "unsupported image encoding"},
+
+		{"truncated JPEG", "a.jpg", testJPEG(t, 8, 8)[:10], "page 1"},
+		{"huge JPEG", "a.jpg", hugeJPEG(t), "invalid image size"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, err := importBytes(t, tt.file, tt.data)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error %v, want %q (%d pages)", err, tt.want, len(results))
+			}
+		})
+	}
+
+	for _, data := range [][]byte{testPDF("<< /Type /Catalog >>"), []byte("%PDF-1.4\n")} {
+		if _, err := importBytes(t, "a.pdf", data); !errors.Is(err, ErrNoPages) {
+			t.Errorf("PDF without pages: %v, want ErrNoPages", err)
+		}
+	}
+}
+
+// hugeJPEG returns the start of a JPEG that declares 65500x65500 pixels
+func hugeJPEG(t *testing.T) []byte {
+	t.Helper()
+	data := testJPEG(t, 8, 8)
+	// The frame header (SOF0) holds the height and width after the precision
+	sof := bytes.Index(data, []byte{0xff, 0xc0})
+	if sof < 0 {
+		t.Fatal("no SOF0 marker")
+	}
+	binary.BigEndian.PutUint16(data[sof+5:], 65500)
+	binary.BigEndian.PutUint16(data[sof+7:], 65500)
+	return data
+}
@@ -0,0 +1,178 @@
+package document
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"os"
+)
+
+// WritePDF writes pages as a PDF with one image per page. JPEG pages are
+// embedded as-is; other formats are stored losslessly (Flate).
+// Page sizes follow from the image size and resolution.
+func WritePDF(w io.Writer, pages []Page) error {
+	if len(pages) == 0 {
+		return ErrNoPages
+	}
+
+	pw := &pdfWriter{w: w}
+
+	pw.printf("%%PDF-1.4\n%%\xe2\xe3\xcf\xd3\n")
+
+	// Object 1: catalog, 2: page tree, then per page: page, image, content
+	pw.object(1, "<< /Type /Catalog /Pages 2 0 R >>")
+
+	var kids bytes.Buffer
+	for i := range pages {
+		fmt.Fprintf(&kids, "%d 0 R ", 3+3*i)
+	}
+	pw.object(2, fmt.Sprintf("<< /Type /Pages /Kids [ %s] /Count %d >>", kids.String(), len(pages)))
+
+	for i, page := range pages {
+		if err := pw.page(3+3*i, page); err != nil {
+			return err
+		}
+	}
+
+	// Cross-reference table
+	xref := pw.offset
+	count := 3 + 3*len(pages)
+	pw.printf("xref\n0 %d\n0000000000 65535 f \n", count)
+	for i := 1; i < count; i++ {
+		pw.printf("%010d 00000 n \n", pw.offsets[i])
+	}
+	pw.printf("trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", count, xref)
+
+	return pw.err
+}
+
+// pdfWriter tracks object offsets while writing a PDF
+type pdfWriter struct {
+	w       io.Writer
+	offset  int64
+	offsets map[int]int64
+	err     error
+}
+
+func (pw *pdfWriter) write(b []byte) {
+	if pw.err != nil {
+		return
+	}
+	n, err := pw.w.Write(b)
+	pw.offset += int64(n)
+	pw.err = err
+}
+
+func (pw *pdfWriter) printf(format string, args ...interface{}) {
+	pw.write([]byte(fmt.Sprintf(format, args...)))
+}
+
+func (pw *pdfWriter) begin(num int) {
+	if pw.offsets == nil {
+		pw.offsets = make(map[int]int64)
+	}
+	pw.offsets[num] = pw.offset
+	pw.printf("%d 0 obj\n", num)
+}
+
+func (pw *pdfWriter) object(num int, body string) {
+	pw.begin(num)
+	pw.printf("%s\nendobj\n", body)
+}
+
+// stream writes a stream object whose data comes from r
+func (pw *pdfWriter) stream(num int, dict string, length int64, r io.Reader) {
+	pw.begin(num)
+	pw.printf("<< %s /Length %d >>\nstream\n", dict, length)
+	if pw.err == nil {
+		n, err := io.Copy(pw.w, r)
+		pw.offset += n
+		pw.err = err
+	}
+	pw.printf("\nendstream\nendobj\n")
+}
+
+// page writes the page, image and content stream objects of a page
+func (pw *pdfWriter) page(num int, page Page) error {
+	imageNum, contentNum := num+1, num+2
+
+	width, height, err := pw.image(imageNum, page)
+	if err != nil {
+		return err
+	}
+
+	// Points (1/72 inch)
+	dpi := float64(page.dpi())
+	ptWidth := float64(width) * 72 / dpi
+	ptHeight := float64(height) * 72 / dpi
+
+	pw.object(num, fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.2f %.2f] "+
+		"/Resources << /XObject << /Im0 %d 0 R >> >> /Contents %d 0 R >>", ptWidth, ptHeight, imageNum, contentNum))
+
+	content := fmt.Sprintf("q %.2f 0 0 %.2f 0 0 cm /Im0 Do Q", ptWidth, ptHeight)
+	pw.stream(contentNum, "", int64(len(content)), bytes.NewReader([]byte(content)))
+
+	return pw.err
+}
+
+// image writes the image XObject of a page and returns its pixel size
+func (pw *pdfWriter) image(num int, page Page) (int, int, error) {
+	f, err := os.Open(page.Path)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+
+	cfg, format, err := image.DecodeConfig(f)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to read %s: %w", page.Path, err)
+	}
+
+	// Baseline JPEGs can be embedded without re-encoding
+	if format == "jpeg" && (cfg.ColorModel == color.GrayModel || cfg.ColorModel == color.YCbCrModel) {
+		colorSpace := "/DeviceRGB"
+		if cfg.ColorModel == color.GrayModel {
+			colorSpace = "/DeviceGray"
+		}
+
+		info, err := f.Stat()
+		if err != nil {
+			return 0, 0, err
+		}
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return 0, 0, err
+		}
+
+		dict := fmt.Sprintf("/Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace %s "+
+			"/BitsPerComponent 8 /Filter /DCTDecode", cfg.Width, cfg.Height, colorSpace)
+		pw.stream(num, dict, info.Size(), f)
+		return cfg.Width, cfg.Height, pw.err
+	}
+
+	img, err := page.decode()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	data, gray := pixels(img)
+	colorSpace := "/DeviceRGB"
+	if gray {
+		colorSpace = "/DeviceGray"
+	}
+
+	var compressed bytes.Buffer
+	zw := zlib.NewWriter(&compressed)
+	zw.Write(data)
+	if err := zw.Close(); err != nil {
+		return 0, 0, err
+	}
+
+	b := img.Bounds()
+	dict := fmt.Sprintf("/Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace %s "+
+		"/BitsPerComponent 8 /Filter /FlateDecode", b.Dx(), b.Dy(), colorSpace)
+	pw.stream(num, dict, int64(compressed.Len()), &compressed)
+	return b.Dx(), b.Dy(), pw.err
+}
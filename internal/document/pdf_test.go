@@ -0,0 +1,162 @@
+package document
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"testing"
+)
+
+// writePage saves img as a page file in dir, encoded as format (PNG or JPEG)
+func writePage(t *testing.T, dir, name string, img image.Image, dpi int) Page {
+	t.Helper()
+	var buf bytes.Buffer
+	var err error
+	if filepath.Ext(name) == ".jpg" {
+		err = jpeg.Encode(&buf, img, nil)
+	} else {
+		err = png.Encode(&buf, img)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return Page{Path: path, DPI: dpi}
+}
+
+// testImages returns a gray and an RGB image with a gradient, so that
+// mixed-up rows or channels change the pixels
+func testImages() (*image.Gray, *image.RGBA) {
+	gray := image.NewGray(image.Rect(0, 0, 30, 20))
+	rgb := image.NewRGBA(image.Rect(0, 0, 21, 13))
+	for y := 0; y < 20; y++ {
+		for x := 0; x < 30; x++ {
+			gray.SetGray(x, y, color.Gray{Y: uint8(x*8 + y)})
+		}
+	}
+	for y := 0; y < 13; y++ {
+		for x := 0; x < 21; x++ {
+			rgb.Set(x, y, color.RGBA{R: uint8(x * 12), G: uint8(y * 19), B: uint8(x + y), A: 255})
+		}
+	}
+	return gray, rgb
+}
+
+func TestWritePDF(t *testing.T) {
+	dir := t.TempDir()
+	gray, rgb := testImages()
+	pages := []Page{
+		writePage(t, dir, "1.png", gray, 150),
+		writePage(t, dir, "2.jpg", rgb, 0),
+		writePage(t, dir, "3.png", rgb, 72),
+	}
+
+	var buf bytes.Buffer
+	if err := WritePDF(&buf, pages); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+
+	// startxref points at the cross-reference table, whose entries point at their objects
+	m := regexp.MustCompile(`startxref\n(\d+)\n%%EOF\n$`).FindSubmatch(data)
+	if m == nil {
+		t.Fatal("no startxref at the end")
+	}
+	xref, _ := strconv.Atoi(string(m[1]))
+	table := regexp.MustCompile(`^xref\n0 (\d+)\n0000000000 65535 f \n`).FindSubmatch(data[xref:])
+	if table == nil {
+		t.Fatalf("no xref table at offset %d", xref)
+	}
+	count, _ := strconv.Atoi(string(table[1]))
+	if count != 3+3*len(pages) {
+		t.Errorf("xref has %d entries, want %d", count, 3+3*len(pages))
+	}
+	entries := data[xref+len(table[0]):]
+	for i := 1; i < count; i++ {
+		entry := string(entries[(i-1)*20 : i*20])
+		offset, err := strconv.Atoi(entry[:10])
+		if err != nil || entry[10:] != " 00000 n \n" {
+			t.Fatalf("xref entry %d = %q", i, entry)
+		}
+		if want := fmt.Sprintf("%d 0 obj\n", i); !bytes.HasPrefix(data[offset:], []byte(want)) {
+			t.Errorf("xref entry %d points at %q", i, data[offset:offset+10])
+		}
+	}
+	if !bytes.Contains(data, []byte(fmt.Sprintf("trailer\n<< /Size %d /Root 1 0 R >>", count))) {
+		t.Error("trailer does not match the xref table")
+	}
+
+	if !bytes.Contains(data, []byte("/Count 3 >>")) {
+		t.Error("page tree does not count 3 pages")
+	}
+	// Page size in points follows from the resolution
+	for _, box := range []string{"/MediaBox [0 0 14.40 9.60]", "/MediaBox [0 0 5.04 3.12]", "/MediaBox [0 0 21.00 13.00]"} {
+		if !bytes.Contains(data, []byte(box)) {
+			t.Errorf("no page with %s", box)
+		}
+	}
+
+	// Reading the PDF back gives the pages, losslessly where they were not JPEG
+	src := filepath.Join(dir, "scan.pdf")
+	if err := os.WriteFile(src, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	n := 0
+	results, err := Import(src, func(format string) (string, error) {
+		n++
+		return filepath.Join(dir, fmt.Sprintf("import-%d.%s", n, format)), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		format        string
+		width, height int
+		img           image.Image
+	}{{"PNG", 30, 20, gray}, {"JPEG", 21, 13, nil}, {"PNG", 21, 13, rgb}}
+	if len(results) != len(want) {
+		t.Fatalf("%d pages read back, want %d", len(results), len(want))
+	}
+	for i, w := range want {
+		r := results[i]
+		if r.Format != w.format || r.Width != w.width || r.Height != w.height {
+			t.Errorf("page %d = %+v", i+1, r)
+			continue
+		}
+		if w.img != nil {
+			assertSamePixels(t, fmt.Sprintf("page %d", i+1), r.FilePath, w.img)
+		}
+	}
+
+	if err := WritePDF(&buf, nil); err != ErrNoPages {
+		t.Errorf("no pages: %v", err)
+	}
+}
+
+// assertSamePixels checks that the image file at path has the pixels of want
+func assertSamePixels(t *testing.T, name, path string, want image.Image) {
+	t.Helper()
+	got, err := Page{Path: path}.decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Bounds().Size() != want.Bounds().Size() {
+		t.Errorf("%s: size %v, want %v", name, got.Bounds().Size(), want.Bounds().Size())
+		return
+	}
+	gotPix, gotGray := pixels(got)
+	wantPix, wantGray := pixels(want)
+	if gotGray != wantGray || !bytes.Equal(gotPix, wantPix) {
+		t.Errorf("%s: pixels differ", name)
+	}
+}
@@ -0,0 +1,195 @@
+package document
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"io"
+)
+
+// TIFF tag IDs and field types used by WriteTIFF
+const (
+	tagImageWidth       = 256
+	tagImageLength      = 257
+	tagBitsPerSample    = 258
+	tagCompression      = 259
+	tagPhotometric      = 262
+	tagStripOffsets     = 273
+	tagSamplesPerPixel  = 277
+	tagRowsPerStrip     = 278
+	tagStripByteCounts  = 279
+	tagXResolution      = 282
+	tagYResolution      = 283
+	tagResolutionUnit   = 296
+	tiffShort           = 3
+	tiffLong            = 4
+	tiffRational        = 5
+	tiffCompressDeflate = 8
+	tiffIFDEntries      = 12
+	tiffIFDSize         = 2 + tiffIFDEntries*12 + 4
+)
+
+// tiffPage is an encoded page waiting to be written
+type tiffPage struct {
+	width, height int
+	gray          bool
+	dpi           int
+	strip         []byte // Deflate-compressed samples
+}
+
+// extraSize is the size of out-of-line tag values: BitsPerSample for RGB and two resolutions
+func (p *tiffPage) extraSize() int {
+	if p.gray {
+		return 16
+	}
+	return 6 + 16
+}
+
+// size is the number of bytes the page occupies before its IFD
+func (p *tiffPage) size() int {
+	return align2(len(p.strip)) + p.extraSize()
+}
+
+// WriteTIFF writes pages as a multi-page TIFF (Deflate compressed, little-endian).
+// Only two pages are held in memory at a time.
+func WriteTIFF(w io.Writer, pages []Page) error {
+	if len(pages) == 0 {
+		return ErrNoPages
+	}
+
+	current, err := encodeTIFFPage(pages[0])
+	if err != nil {
+		return err
+	}
+
+	// Header: byte order, magic, offset of the first IFD
+	offset := 8
+	header := []byte{'I', 'I', 42, 0, 0, 0, 0, 0}
+	binary.LittleEndian.PutUint32(header[4:], uint32(offset+current.size()))
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+
+	for i := range pages {
+		// The IFD points at the next page's IFD, so encode the next page first
+		var next *tiffPage
+		if i+1 < len(pages) {
+			if next, err = encodeTIFFPage(pages[i+1]); err != nil {
+				return err
+			}
+		}
+
+		ifdOffset := offset + current.size()
+		nextIFD := 0
+		if next != nil {
+			nextIFD = ifdOffset + tiffIFDSize + next.size()
+		}
+
+		if _, err := w.Write(current.block(offset, nextIFD)); err != nil {
+			return err
+		}
+
+		offset = ifdOffset + tiffIFDSize
+		current = next
+	}
+
+	return nil
+}
+
+// encodeTIFFPage decodes a page and compresses its samples
+func encodeTIFFPage(page Page) (*tiffPage, error) {
+	img, err := page.decode()
+	if err != nil {
+		return nil, err
+	}
+
+	data, gray := pixels(img)
+
+	var strip bytes.Buffer
+	zw := zlib.NewWriter(&strip)
+	zw.Write(data)
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+
+	return &tiffPage{
+		width:  img.Bounds().Dx(),
+		height: img.Bounds().Dy(),
+		gray:   gray,
+		dpi:    page.dpi(),
+		strip:  strip.Bytes(),
+	}, nil
+}
+
+// block returns the page data, out-of-line values and IFD, starting at offset
+func (p *tiffPage) block(offset, nextIFD int) []byte {
+	le := binary.LittleEndian
+	buf := make([]byte, p.size()+tiffIFDSize)
+
+	copy(buf, p.strip)
+	extra := align2(len(p.strip))
+
+	samples, photometric := 3, 2 // RGB
+	bitsPerSample := uint32(8)
+	if p.gray {
+		samples, photometric = 1, 1 // BlackIsZero
+	} else {
+		// Three SHORTs don't fit in the entry, store them out of line
+		le.PutUint16(buf[extra:], 8)
+		le.PutUint16(buf[extra+2:], 8)
+		le.PutUint16(buf[extra+4:], 8)
+		bitsPerSample = uint32(offset + extra)
+		extra += 6
+	}
+
+	// Resolution as dpi/1
+	xres := offset + extra
+	le.PutUint32(buf[extra:], uint32(p.dpi))
+	le.PutUint32(buf[extra+4:], 1)
+	le.PutUint32(buf[extra+8:], uint32(p.dpi))
+	le.PutUint32(buf[extra+12:], 1)
+	yres := xres + 8
+
+	ifd := buf[p.size():]
+	le.PutUint16(ifd, tiffIFDEntries)
+
+	entries := []struct {
+		tag, typ uint16
+		count    uint32
+		value    uint32
+	}{
+		{tagImageWidth, tiffLong, 1, uint32(p.width)},
+		{tagImageLength, tiffLong, 1, uint32(p.height)},
+		{tagBitsPerSample, tiffShort, uint32(samples), bitsPerSample},
+		{tagCompression, tiffShort, 1, tiffCompressDeflate},
+		{tagPhotometric, tiffShort, 1, uint32(photometric)},
+		{tagStripOffsets, tiffLong, 1, uint32(offset)},
+		{tagSamplesPerPixel, tiffShort, 1, uint32(samples)},
+		{tagRowsPerStrip, tiffLong, 1, uint32(p.height)},
+		{tagStripByteCounts, tiffLong, 1, uint32(len(p.strip))},
+		{tagXResolution, tiffRational, 1, uint32(xres)},
+		{tagYResolution, tiffRational, 1, uint32(yres)},
+		{tagResolutionUnit, tiffShort, 1, 2}, // Inch
+	}
+
+	for i, e := range entries {
+		entry := ifd[2+i*12:]
+		le.PutUint16(entry, e.tag)
+		le.PutUint16(entry[2:], e.typ)
+		le.PutUint32(entry[4:], e.count)
+		// SHORT values are left-justified in the value field
+		if e.typ == tiffShort && e.count == 1 {
+			le.PutUint16(entry[8:], uint16(e.value))
+		} else {
+			le.PutUint32(entry[8:], e.value)
+		}
+	}
+	le.PutUint32(ifd[2+tiffIFDEntries*12:], uint32(nextIFD))
+
+	return buf
+}
+
+// align2 rounds n up to a word boundary, as TIFF requires for offsets
+func align2(n int) int {
+	return n + n&1
+}
@@ -0,0 +1,60 @@
+package document
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/image/tiff"
+)
+
+func TestWriteTIFF(t *testing.T) {
+	dir := t.TempDir()
+	gray, rgb := testImages()
+	pages := []Page{
+		writePage(t, dir, "1.png", gray, 200),
+		writePage(t, dir, "2.png", rgb, 0),
+		writePage(t, dir, "3.png", gray, 0),
+	}
+
+	var buf bytes.Buffer
+	if err := WriteTIFF(&buf, pages); err != nil {
+		t.Fatal(err)
+	}
+
+	// x/image/tiff reads the first page
+	img, err := tiff.Decode(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantPix, _ := pixels(gray)
+	if gotPix, isGray := pixels(img); !isGray || !bytes.Equal(gotPix, wantPix) {
+		t.Error("first page differs from the page written")
+	}
+
+	// Import follows the IFD chain through all pages
+	src := filepath.Join(dir, "scan.tif")
+	if err := os.WriteFile(src, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	n := 0
+	results, err := Import(src, func(format string) (string, error) {
+		n++
+		return filepath.Join(dir, fmt.Sprintf("import-%d.%s", n, format)), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != len(pages) {
+		t.Fatalf("%d pages read back, want %d", len(results), len(pages))
+	}
+	assertSamePixels(t, "page 1", results[0].FilePath, gray)
+	assertSamePixels(t, "page 2", results[1].FilePath, rgb)
+	assertSamePixels(t, "page 3", results[2].FilePath, gray)
+
+	if err := WriteTIFF(&buf, nil); err != ErrNoPages {
+		t.Errorf("no pages: %v", err)
+	}
+}
@@ -0,0 +1,281 @@
+// Package email sends completed scans by email through an SMTP server
+package email
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/scanserver/scanner-service/internal/config"
+	"github.com/scanserver/scanner-service/internal/document"
+	"github.com/scanserver/scanner-service/pkg/models"
+)
+
+// ErrNotConfigured is returned when email is requested but no SMTP server is configured
+var ErrNotConfigured = errors.New("email is not configured")
+
+// Attachment kinds
+const (
+	AttachPDF    = "pdf"    // All pages merged into one PDF
+	AttachImages = "images" // The page image files
+)
+
+// sendTimeout bounds the SMTP conversation for one message
+const sendTimeout = 2 * time.Minute
+
+// Sender emails completed jobs
+type Sender struct {
+	config  *config.EmailConfig
+	rootCAs *x509.CertPool // nil = system roots
+}
+
+// NewSender creates a sender for the configured SMTP server
+func NewSender(cfg *config.EmailConfig) *Sender {
+	return &Sender{config: cfg}
+}
+
+// Enabled reports whether an SMTP server is configured
+func (s *Sender) Enabled() bool {
+	return s.config.Host != ""
+}
+
+// settings is a request merged with its profile
+type settings struct {
+	to, cc  []string
+	subject string
+	body    string
+	attach  string
+}
+
+// Check validates a request, so that a job is not started only to fail sending
+func (s *Sender) Check(req models.EmailRequest) error {
+	_, err := s.settings(req)
+	return err
+}
+
+// settings merges a request with its profile and the configured defaults
+func (s *Sender) settings(req models.EmailRequest) (*settings, error) {
+	if !s.Enabled() {
+		return nil, ErrNotConfigured
+	}
+
+	result := &settings{
+		subject: s.config.Subject,
+		body:    s.config.Body,
+		attach:  AttachPDF,
+	}
+
+	if req.Profile != "" {
+		// Viper lower-cases map keys
+		profile, ok := s.config.Profiles[strings.ToLower(req.Profile)]
+		if !ok {
+			return nil, fmt.Errorf("unknown email profile %q", req.Profile)
+		}
+		result.to, result.cc = profile.To, profile.Cc
+		if profile.Subject != "" {
+			result.subject = profile.Subject
+		}
+		if profile.Body != "" {
+			result.body = profile.Body
+		}
+		if profile.Attach != "" {
+			result.attach = profile.Attach
+		}
+	}
+
+	if len(req.To) > 0 {
+		result.to = req.To
+	}
+	if len(req.Cc) > 0 {
+		result.cc = req.Cc
+	}
+	if req.Attach != "" {
+		result.attach = req.Attach
+	}
+
+	if len(result.to) == 0 {
+		return nil, fmt.Errorf("no email recipients")
+	}
+	for _, addr := range append(append([]string{}, result.to...), result.cc...) {
+		if _, err := mail.ParseAddress(addr); err != nil {
+			return nil, fmt.Errorf("invalid email address %q", addr)
+		}
+	}
+
+	result.attach = strings.ToLower(result.attach)
+	if result.attach != AttachPDF && result.attach != AttachImages {
+		return nil, fmt.Errorf("attach must be %s or %s", AttachPDF, AttachImages)
+	}
+
+	return result, nil
+}
+
+// templateData is available to subject and body templates
+type templateData struct {
+	Job   *models.ScanJob
+	Pages int    // Pages in the job
+	Part  int    // Message number when the job is split, starting at 1
+	Parts int    // Number of messages
+	Date  string // Job creation time, e.g. 2025-11-10 08:00
+}
+
+// Send emails the pages of a completed job and returns the number of messages sent.
+// Attachments that exceed email.max_message_size are split across several messages.
+func (s *Sender) Send(ctx context.Context, job *models.ScanJob, req models.EmailRequest, pages []document.Page) (int, error) {
+	settings, err := s.settings(req)
+	if err != nil {
+		return 0, err
+	}
+
+	from, err := mail.ParseAddress(s.config.From)
+	if err != nil {
+		return 0, fmt.Errorf("invalid email.from %q", s.config.From)
+	}
+
+	subjectTmpl, err := template.New("subject").Parse(settings.subject)
+	if err != nil {
+		return 0, fmt.Errorf("invalid subject template: %w", err)
+	}
+	bodyTmpl, err := template.New("body").Parse(settings.body)
+	if err != nil {
+		return 0, fmt.Errorf("invalid body template: %w", err)
+	}
+
+	var parts [][]attachment
+	if settings.attach == AttachImages {
+		parts, err = imageParts(pages, s.attachmentBudget())
+	} else {
+		parts, err = pdfParts(job.ID, pages, s.attachmentBudget())
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	recipients := append(append([]string{}, settings.to...), settings.cc...)
+
+	for i, attachments := range parts {
+		data := templateData{
+			Job:   job,
+			Pages: len(pages),
+			Part:  i + 1,
+			Parts: len(parts),
+			Date:  job.CreatedAt.Format("2006-01-02 15:04"),
+		}
+
+		var subject, body bytes.Buffer
+		if err := subjectTmpl.Execute(&subject, data); err != nil {
+			return i, fmt.Errorf("failed to render subject: %w", err)
+		}
+		if err := bodyTmpl.Execute(&body, data); err != nil {
+			return i, fmt.Errorf("failed to render body: %w", err)
+		}
+
+		msg, err := buildMessage(from, settings.to, settings.cc, subject.String(), body.String(), attachments)
+		if err != nil {
+			return i, err
+		}
+
+		if err := s.deliver(ctx, from.Address, recipients, msg); err != nil {
+			return i, fmt.Errorf("failed to send message %d of %d: %w", i+1, len(parts), err)
+		}
+	}
+
+	return len(parts), nil
+}
+
+// attachmentBudget returns the size attachments of one message may have before encoding
+func (s *Sender) attachmentBudget() int64 {
+	limit := s.config.MaxMessageSize
+	if limit <= 0 {
+		return 0 // No limit
+	}
+
+	// Base64 grows attachments by a third; leave room for headers and body
+	budget := (limit - 16*1024) * 3 / 4
+	if budget < 1 {
+		budget = 1
+	}
+	return budget
+}
+
+// deliver sends one message through the SMTP server
+func (s *Sender) deliver(ctx context.Context, from string, recipients []string, msg []byte) error {
+	port := s.config.Port
+	if port == 0 {
+		port = 587
+	}
+	addr := net.JoinHostPort(s.config.Host, strconv.Itoa(port))
+	tlsConfig := &tls.Config{ServerName: s.config.Host, RootCAs: s.rootCAs}
+
+	dialer := &net.Dialer{Timeout: 30 * time.Second}
+	var conn net.Conn
+	var err error
+	if strings.EqualFold(s.config.TLS, "tls") {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: tlsConfig}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return err
+	}
+	conn.SetDeadline(time.Now().Add(sendTimeout))
+
+	client, err := smtp.NewClient(conn, s.config.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if strings.EqualFold(s.config.TLS, "starttls") || s.config.TLS == "" {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			return fmt.Errorf("server %s does not support STARTTLS", addr)
+		}
+		if err := client.StartTLS(tlsConfig); err != nil {
+			return err
+		}
+	}
+
+	if s.config.Username != "" {
+		// PlainAuth refuses to send credentials without TLS, except to localhost
+		if err := client.Auth(smtp.PlainAuth("", s.config.Username, s.config.Password, s.config.Host)); err != nil {
+			return err
+		}
+	}
+
+	if err := client.Mail(from); err != nil {
+		return err
+	}
+	for _, rcpt := range recipients {
+		address, err := mail.ParseAddress(rcpt)
+		if err != nil {
+			return err
+		}
+		if err := client.Rcpt(address.Address); err != nil {
+			return fmt.Errorf("recipient %s rejected: %w", address.Address, err)
+		}
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	return client.Quit()
+}
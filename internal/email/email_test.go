@@ -0,0 +1,474 @@
+package email
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/mail"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/scanserver/scanner-service/internal/config"
+	"github.com/scanserver/scanner-service/internal/document"
+	"github.com/scanserver/scanner-service/pkg/models"
+)
+
+// smtpMessage is a message the fake server accepted
+type smtpMessage struct {
+	from       string
+	recipients []string
+	data       []byte
+	tls        bool   // Sent after STARTTLS
+	auth       string // user:password from AUTH PLAIN
+}
+
+// fakeSMTP is a minimal SMTP server with STARTTLS and AUTH PLAIN
+type fakeSMTP struct {
+	listener net.Listener
+	tls      *tls.Config
+	startTLS bool // Advertise STARTTLS
+
+	mutex    sync.Mutex
+	messages []smtpMessage
+}
+
+// newFakeSMTP starts a server and returns it with a pool trusting its certificate
+func newFakeSMTP(t *testing.T, startTLS bool) (*fakeSMTP, *x509.CertPool) {
+	t.Helper()
+
+	// Borrow the test certificate of httptest, issued for 127.0.0.1
+	certServer := httptest.NewTLSServer(http.NotFoundHandler())
+	cert := certServer.TLS.Certificates[0]
+	roots := x509.NewCertPool()
+	roots.AddCert(certServer.Certificate())
+	certServer.Close()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &fakeSMTP{
+		listener: listener,
+		tls:      &tls.Config{Certificates: []tls.Certificate{cert}},
+		startTLS: startTLS,
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s, roots
+}
+
+func (s *fakeSMTP) port() int {
+	return s.listener.Addr().(*net.TCPAddr).Port
+}
+
+func (s *fakeSMTP) received() []smtpMessage {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]smtpMessage(nil), s.messages...)
+}
+
+func (s *fakeSMTP) serve(conn net.Conn) {
+	defer func() { conn.Close() }()
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+
+	text := textproto.NewConn(conn)
+	text.PrintfLine("220 fake ESMTP")
+
+	var msg smtpMessage
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+
+		switch strings.ToUpper(verb) {
+		case "EHLO":
+			text.PrintfLine("250-fake")
+			if s.startTLS && !msg.tls {
+				text.PrintfLine("250-STARTTLS")
+			}
+			text.PrintfLine("250 AUTH PLAIN")
+		case "STARTTLS":
+			text.PrintfLine("220 ready")
+			tlsConn := tls.Server(conn, s.tls)
+			if err := tlsConn.Handshake(); err != nil {
+				return
+			}
+			conn = tlsConn
+			text = textproto.NewConn(conn)
+			msg.tls = true
+		case "AUTH":
+			mechanism, initial, _ := strings.Cut(arg, " ")
+			decoded, err := base64.StdEncoding.DecodeString(initial)
+			fields := strings.Split(string(decoded), "\x00")
+			if mechanism != "PLAIN" || err != nil || len(fields) != 3 {
+				text.PrintfLine("535 authentication failed")
+				continue
+			}
+			msg.auth = fields[1] + ":" + fields[2]
+			text.PrintfLine("235 authenticated")
+		case "MAIL":
+			msg.from = strings.Trim(strings.TrimPrefix(arg, "FROM:"), "<>")
+			text.PrintfLine("250 ok")
+		case "RCPT":
+			msg.recipients = append(msg.recipients, strings.Trim(strings.TrimPrefix(arg, "TO:"), "<>"))
+			text.PrintfLine("250 ok")
+		case "DATA":
+			text.PrintfLine("354 go ahead")
+			data, err := text.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			msg.data = data
+			s.mutex.Lock()
+			s.messages = append(s.messages, msg)
+			s.mutex.Unlock()
+			text.PrintfLine("250 queued")
+		case "QUIT":
+			text.PrintfLine("221 bye")
+			return
+		default:
+			text.PrintfLine("250 ok")
+		}
+	}
+}
+
+// parsedMessage is a received message decoded for assertions
+type parsedMessage struct {
+	header      mail.Header
+	subject     string
+	body        string
+	attachments map[string][]byte // filename -> content
+}
+
+func parseMessage(t *testing.T, data []byte) parsedMessage {
+	t.Helper()
+	m, err := mail.ReadMessage(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(m.Header.Get("Subject"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := parsedMessage{header: m.Header, subject: subject, attachments: map[string][]byte{}}
+
+	_, params, err := mime.ParseMediaType(m.Header.Get("Content-Type"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mr := multipart.NewReader(m.Body, params["boundary"])
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		// multipart decodes quoted-printable itself
+		content, err := io.ReadAll(part)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if name := part.FileName(); name != "" {
+			decoded, err := base64.StdEncoding.DecodeString(strings.ReplaceAll(string(content), "\r\n", ""))
+			if err != nil {
+				t.Fatal(err)
+			}
+			result.attachments[name] = decoded
+		} else {
+			result.body = string(content)
+		}
+	}
+	return result
+}
+
+// writePages writes n page files. Image attachments are not decoded, so
+// with raw set the pages are just size bytes each; otherwise they are
+// PNG images that can be merged into a PDF.
+func writePages(t *testing.T, n, size int, raw bool) []document.Page {
+	t.Helper()
+	dir := t.TempDir()
+	pages := make([]document.Page, n)
+	for i := range pages {
+		path := filepath.Join(dir, fmt.Sprintf("page-%04d.png", i+1))
+		var data []byte
+		if raw {
+			data = bytes.Repeat([]byte{byte('a' + i)}, size)
+		} else {
+			// Noise does not compress, so every page adds about the same size
+			img := image.NewGray(image.Rect(0, 0, 64, 64))
+			for p := range img.Pix {
+				img.Pix[p] = byte(p*7919 + i*104729)
+			}
+			img.SetGray(0, 0, color.Gray{Y: byte(i)})
+			var buf bytes.Buffer
+			if err := png.Encode(&buf, img); err != nil {
+				t.Fatal(err)
+			}
+			data = buf.Bytes()
+		}
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		pages[i] = document.Page{Path: path}
+	}
+	return pages
+}
+
+func testJob() *models.ScanJob {
+	return &models.ScanJob{
+		ID:        "job-1",
+		ScannerID: "scanner-1",
+		CreatedAt: time.Date(2025, 11, 10, 8, 0, 0, 0, time.UTC),
+	}
+}
+
+func TestSendStartTLSAuth(t *testing.T) {
+	server, roots := newFakeSMTP(t, true)
+	sender := NewSender(&config.EmailConfig{
+		Host:     "127.0.0.1",
+		Port:     server.port(),
+		TLS:      "starttls",
+		Username: "scanner",
+		Password: "hunter2",
+		From:     "Scanner <scanner@example.com>",
+		Subject:  "Scan {{.Job.ID}}",
+		Body:     "{{.Pages}} page(s) from {{.Job.ScannerID}}, scanned {{.Date}}",
+		Profiles: map[string]config.EmailProfile{
+			"accounting": {To: []string{"Invoices <invoices@example.com>"}, Cc: []string{"audit@example.com"}},
+		},
+	})
+	sender.rootCAs = roots
+
+	n, err := sender.Send(context.Background(), testJob(), models.EmailRequest{Profile: "Accounting", Attach: AttachImages}, writePages(t, 2, 100, true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Fatalf("sent %d messages, want 1", n)
+	}
+
+	messages := server.received()
+	if len(messages) != 1 {
+		t.Fatalf("server received %d messages, want 1", len(messages))
+	}
+	msg := messages[0]
+	if !msg.tls {
+		t.Error("message was sent without STARTTLS")
+	}
+	if msg.auth != "scanner:hunter2" {
+		t.Errorf("auth = %q", msg.auth)
+	}
+	if msg.from != "scanner@example.com" {
+		t.Errorf("MAIL FROM %s", msg.from)
+	}
+	if strings.Join(msg.recipients, ",") != "invoices@example.com,audit@example.com" {
+		t.Errorf("RCPT TO %v", msg.recipients)
+	}
+
+	parsed := parseMessage(t, msg.data)
+	if parsed.subject != "Scan job-1" {
+		t.Errorf("subject = %q", parsed.subject)
+	}
+	if parsed.body != "2 page(s) from scanner-1, scanned 2025-11-10 08:00" {
+		t.Errorf("body = %q", parsed.body)
+	}
+	if cc := parsed.header.Get("Cc"); cc != "audit@example.com" {
+		t.Errorf("Cc = %q", cc)
+	}
+	if len(parsed.attachments) != 2 || len(parsed.attachments["page-0002.png"]) != 100 {
+		t.Errorf("attachments = %d", len(parsed.attachments))
+	}
+}
+
+func TestSendRequiresStartTLS(t *testing.T) {
+	server, roots := newFakeSMTP(t, false)
+	sender := NewSender(&config.EmailConfig{
+		Host: "127.0.0.1",
+		Port: server.port(),
+		From: "scanner@example.com",
+	})
+	sender.rootCAs = roots
+
+	_, err := sender.Send(context.Background(), testJob(), models.EmailRequest{To: []string{"a@example.com"}}, writePages(t, 1, 0, false))
+	if err == nil || !strings.Contains(err.Error(), "STARTTLS") {
+		t.Fatalf("err = %v, want a STARTTLS error", err)
+	}
+	if n := len(server.received()); n != 0 {
+		t.Errorf("%d message(s) sent in plain text", n)
+	}
+}
+
+func TestSendSplitsImages(t *testing.T) {
+	server, _ := newFakeSMTP(t, false)
+	sender := NewSender(&config.EmailConfig{
+		Host:           "127.0.0.1",
+		Port:           server.port(),
+		TLS:            "none",
+		From:           "scanner@example.com",
+		MaxMessageSize: 16*1024 + 4000, // 3000 bytes of attachments
+		Subject:        "Scan {{.Job.ID}} ({{.Part}}/{{.Parts}})",
+		Body:           "Part {{.Part}} of {{.Parts}}",
+	})
+
+	n, err := sender.Send(context.Background(), testJob(), models.EmailRequest{To: []string{"a@example.com"}, Attach: "IMAGES"}, writePages(t, 3, 1200, true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Fatalf("sent %d messages, want 2", n)
+	}
+
+	messages := server.received()
+	if len(messages) != 2 {
+		t.Fatalf("server received %d messages, want 2", len(messages))
+	}
+	wantPages := [][]string{{"page-0001.png", "page-0002.png"}, {"page-0003.png"}}
+	for i, msg := range messages {
+		parsed := parseMessage(t, msg.data)
+		if want := fmt.Sprintf("Scan job-1 (%d/2)", i+1); parsed.subject != want {
+			t.Errorf("message %d: subject %q, want %q", i+1, parsed.subject, want)
+		}
+		if want := fmt.Sprintf("Part %d of 2", i+1); parsed.body != want {
+			t.Errorf("message %d: body %q, want %q", i+1, parsed.body, want)
+		}
+		if len(parsed.attachments) != len(wantPages[i]) {
+			t.Errorf("message %d: %d attachments, want %v", i+1, len(parsed.attachments), wantPages[i])
+		}
+		for _, name := range wantPages[i] {
+			if len(parsed.attachments[name]) != 1200 {
+				t.Errorf("message %d: %s missing", i+1, name)
+			}
+		}
+		if len(msg.data) > int(sender.config.MaxMessageSize) {
+			t.Errorf("message %d is %d bytes, over the limit", i+1, len(msg.data))
+		}
+	}
+}
+
+func TestSendSplitsPDF(t *testing.T) {
+	server, _ := newFakeSMTP(t, false)
+	pages := writePages(t, 4, 0, false)
+
+	// Allow two pages per message
+	var two bytes.Buffer
+	if err := document.WritePDF(&two, pages[:2]); err != nil {
+		t.Fatal(err)
+	}
+	budget := int64(two.Len()) + 100
+	sender := NewSender(&config.EmailConfig{
+		Host:           "127.0.0.1",
+		Port:           server.port(),
+		TLS:            "none",
+		From:           "scanner@example.com",
+		MaxMessageSize: 16*1024 + (budget*4+2)/3,
+		Subject:        "{{.Job.ID}} {{.Part}}/{{.Parts}}",
+	})
+
+	n, err := sender.Send(context.Background(), testJob(), models.EmailRequest{To: []string{"a@example.com"}}, pages)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Fatalf("sent %d messages, want 2", n)
+	}
+
+	for i, msg := range server.received() {
+		parsed := parseMessage(t, msg.data)
+		name := "scan-job-1-" + strconv.Itoa(i+1) + ".pdf"
+		pdf, ok := parsed.attachments[name]
+		if !ok || !bytes.HasPrefix(pdf, []byte("%PDF-")) {
+			t.Errorf("message %d: no %s", i+1, name)
+		}
+		if count := bytes.Count(pdf, []byte("/Type /Page ")); count != 2 {
+			t.Errorf("message %d: %d pages, want 2", i+1, count)
+		}
+		if want := fmt.Sprintf("job-1 %d/2", i+1); parsed.subject != want {
+			t.Errorf("message %d: subject %q", i+1, parsed.subject)
+		}
+	}
+
+	// A single page over the limit cannot be split
+	sender.config.MaxMessageSize = 16*1024 + 10
+	if _, err := sender.Send(context.Background(), testJob(), models.EmailRequest{To: []string{"a@example.com"}}, pages); err == nil {
+		t.Error("expected an error for a page over the size limit")
+	}
+}
+
+func TestSettings(t *testing.T) {
+	sender := NewSender(&config.EmailConfig{
+		Host:    "smtp.example.com",
+		Subject: "default subject",
+		Profiles: map[string]config.EmailProfile{
+			"team": {To: []string{"team@example.com"}, Subject: "team subject", Attach: "images"},
+		},
+	})
+
+	tests := []struct {
+		name    string
+		req     models.EmailRequest
+		wantErr string
+		check   func(s *settings) bool
+	}{
+		{"profile", models.EmailRequest{Profile: "team"}, "", func(s *settings) bool {
+			return s.to[0] == "team@example.com" && s.subject == "team subject" && s.attach == AttachImages
+		}},
+		{"request overrides profile", models.EmailRequest{Profile: "team", To: []string{"me@example.com"}, Attach: "pdf"}, "", func(s *settings) bool {
+			return s.to[0] == "me@example.com" && s.attach == AttachPDF
+		}},
+		{"defaults", models.EmailRequest{To: []string{"me@example.com"}}, "", func(s *settings) bool {
+			return s.subject == "default subject" && s.attach == AttachPDF
+		}},
+		{"unknown profile", models.EmailRequest{Profile: "nobody"}, "unknown email profile", nil},
+		{"no recipients", models.EmailRequest{}, "no email recipients", nil},
+		{"bad address", models.EmailRequest{To: []string{"not an address"}}, "invalid email address", nil},
+		{"bad attach", models.EmailRequest{To: []string{"me@example.com"}, Attach: "zip"}, "attach must be", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := sender.settings(tt.req)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !tt.check(s) {
+				t.Errorf("settings = %+v", s)
+			}
+		})
+	}
+}
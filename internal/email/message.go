@@ -0,0 +1,179 @@
+package email
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/scanserver/scanner-service/internal/document"
+	"github.com/scanserver/scanner-service/pkg/models"
+)
+
+// attachment is a file attached to a message
+type attachment struct {
+	name        string
+	contentType string
+	data        []byte
+}
+
+// pdfParts merges the pages into PDFs. If the document exceeds budget
+// bytes it is split in halves until every part fits.
+func pdfParts(jobID string, pages []document.Page, budget int64) ([][]attachment, error) {
+	var chunks [][]byte
+	var split func(pages []document.Page, first int) error
+	split = func(pages []document.Page, first int) error {
+		var buf bytes.Buffer
+		if err := document.WritePDF(&buf, pages); err != nil {
+			return err
+		}
+
+		if budget > 0 && int64(buf.Len()) > budget {
+			if len(pages) == 1 {
+				return fmt.Errorf("page %d is larger than the message size limit", first)
+			}
+			half := len(pages) / 2
+			if err := split(pages[:half], first); err != nil {
+				return err
+			}
+			return split(pages[half:], first+half)
+		}
+
+		chunks = append(chunks, buf.Bytes())
+		return nil
+	}
+
+	if err := split(pages, 1); err != nil {
+		return nil, err
+	}
+
+	parts := make([][]attachment, len(chunks))
+	for i, data := range chunks {
+		name := fmt.Sprintf("scan-%s.pdf", jobID)
+		if len(chunks) > 1 {
+			name = fmt.Sprintf("scan-%s-%d.pdf", jobID, i+1)
+		}
+		parts[i] = []attachment{{name: name, contentType: "application/pdf", data: data}}
+	}
+	return parts, nil
+}
+
+// imageParts attaches the page files, filling each message up to budget bytes
+func imageParts(pages []document.Page, budget int64) ([][]attachment, error) {
+	var parts [][]attachment
+	var current []attachment
+	var size int64
+
+	for i, page := range pages {
+		data, err := os.ReadFile(page.Path)
+		if err != nil {
+			return nil, err
+		}
+		if budget > 0 && int64(len(data)) > budget {
+			return nil, fmt.Errorf("page %d is larger than the message size limit", i+1)
+		}
+
+		if budget > 0 && size+int64(len(data)) > budget && len(current) > 0 {
+			parts = append(parts, current)
+			current, size = nil, 0
+		}
+
+		contentType := mime.TypeByExtension(filepath.Ext(page.Path))
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		current = append(current, attachment{name: filepath.Base(page.Path), contentType: contentType, data: data})
+		size += int64(len(data))
+	}
+
+	if len(current) > 0 {
+		parts = append(parts, current)
+	}
+	return parts, nil
+}
+
+// buildMessage composes a multipart/mixed message with a text body and attachments
+func buildMessage(from *mail.Address, to, cc []string, subject, body string, attachments []attachment) ([]byte, error) {
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+
+	domain := "localhost"
+	if at := strings.LastIndex(from.Address, "@"); at >= 0 {
+		domain = from.Address[at+1:]
+	}
+
+	header := []string{
+		"From: " + from.String(),
+		"To: " + strings.Join(to, ", "),
+	}
+	if len(cc) > 0 {
+		header = append(header, "Cc: "+strings.Join(cc, ", "))
+	}
+	header = append(header,
+		"Subject: "+mime.QEncoding.Encode("utf-8", subject),
+		"Date: "+time.Now().Format(time.RFC1123Z),
+		fmt.Sprintf("Message-ID: <%s@%s>", models.GenerateUUID(), domain),
+		"MIME-Version: 1.0",
+		fmt.Sprintf("Content-Type: multipart/mixed; boundary=%q", mw.Boundary()),
+	)
+	buf.WriteString(strings.Join(header, "\r\n") + "\r\n\r\n")
+
+	text, err := mw.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {"text/plain; charset=utf-8"},
+		"Content-Transfer-Encoding": {"quoted-printable"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	qp := quotedprintable.NewWriter(text)
+	if _, err := qp.Write([]byte(body)); err != nil {
+		return nil, err
+	}
+	if err := qp.Close(); err != nil {
+		return nil, err
+	}
+
+	for _, a := range attachments {
+		part, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {mime.FormatMediaType(a.contentType, map[string]string{"name": a.name})},
+			"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": a.name})},
+			"Content-Transfer-Encoding": {"base64"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if err := writeBase64(part, a.data); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// writeBase64 writes data base64-encoded in lines of 76 characters
+func writeBase64(w io.Writer, data []byte) error {
+	encoded := base64.StdEncoding.EncodeToString(data)
+	for len(encoded) > 0 {
+		n := 76
+		if len(encoded) < n {
+			n = len(encoded)
+		}
+		if _, err := w.Write([]byte(encoded[:n] + "\r\n")); err != nil {
+			return err
+		}
+		encoded = encoded[n:]
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package escl
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/grandcat/zeroconf"
+)
+
+// DNS-SD service types of eSCL over HTTP and HTTPS
+const (
+	serviceHTTP  = "_uscan._tcp"
+	serviceHTTPS = "_uscans._tcp"
+)
+
+// Advertise announces the first scanner via mDNS so that eSCL clients on the
+// local network find it: as _uscans._tcp when served over TLS, else as
+// _uscan._tcp. The returned function withdraws the announcement.
+func (s *ESCLServer) Advertise(ctx context.Context, port int, secure bool) (func(), error) {
+	scanners, err := s.scannerManager.ListScanners(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(scanners) == 0 {
+		return nil, fmt.Errorf("no scanner available")
+	}
+	sc := scanners[0]
+
+	// TXT keys from the Mopria eSCL specification
+	colorSpaces := make([]string, 0, len(sc.Capabilities.ColorModes))
+	for _, mode := range sc.Capabilities.ColorModes {
+		switch strings.ToLower(mode) {
+		case "color", "rgb24":
+			colorSpaces = append(colorSpaces, "color")
+		case "gray", "grayscale", "grayscale8":
+			colorSpaces = append(colorSpaces, "grayscale")
+		case "blackandwhite", "blackandwhite1", "lineart":
+			colorSpaces = append(colorSpaces, "binary")
+		}
+	}
+	sources := "platen"
+	if sc.Capabilities.FeederEnabled {
+		sources = "platen,adf"
+	}
+	duplex := "F"
+	if sc.Capabilities.DuplexEnabled {
+		duplex = "T"
+	}
+
+	makeAndModel := strings.TrimSpace(sc.Manufacturer + " " + sc.Model)
+	txt := []string{
+		"txtvers=1",
+		"vers=2.6",
+		"rs=eSCL",
+		"ty=" + makeAndModel,
+		"uuid=" + sc.ID,
+		"pdl=application/pdf,image/jpeg",
+		"cs=" + strings.Join(colorSpaces, ","),
+		"is=" + sources,
+		"duplex=" + duplex,
+		"representation=/static/icon.png",
+	}
+
+	service := serviceHTTP
+	if secure {
+		service = serviceHTTPS
+	}
+
+	server, err := zeroconf.Register(makeAndModel, service, "local.", port, txt, nil)
+	if err != nil {
+		return nil, err
+	}
+	s.logger.Info("Advertising eSCL via mDNS", "name", makeAndModel, "service", service)
+	return server.Shutdown, nil
+}
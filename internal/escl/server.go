@@ -3,11 +3,14 @@ package escl
 import (
 	"encoding/xml"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/scanserver/scanner-service/internal/auth"
 	"github.com/scanserver/scanner-service/internal/scanner"
+	"github.com/scanserver/scanner-service/pkg/models"
 )
 
 // eSCL (eScan over HTTP) protocol implementation
@@ -109,27 +112,121 @@ type ScannerStatus struct {
 	Version string   `xml:"scan:Version"`
 	State   string   `xml:"pwg:State"`
 	StateReasons StateReasons `xml:"pwg:StateReasons"`
+	AdfState string  `xml:"scan:AdfState,omitempty"`
 }
 
 type StateReasons struct {
 	StateReason []string `xml:"pwg:StateReason"`
 }
 
+// ScanSettings represents the eSCL scan job request XML
+// Elements are matched by local name so both scan: and pwg: prefixes are accepted
+type ScanSettings struct {
+	XMLName           xml.Name     `xml:"ScanSettings"`
+	Intent            string       `xml:"Intent"`
+	ScanRegions       []ScanRegion `xml:"ScanRegions>ScanRegion"`
+	InputSource       string       `xml:"InputSource"`
+	Duplex            bool         `xml:"Duplex"`
+	ColorMode         string       `xml:"ColorMode"`
+	XResolution       int          `xml:"XResolution"`
+	YResolution       int          `xml:"YResolution"`
+	DocumentFormat    string       `xml:"DocumentFormat"`
+	DocumentFormatExt string       `xml:"DocumentFormatExt"`
+}
+
+type ScanRegion struct {
+	Width              int    `xml:"Width"`
+	Height             int    `xml:"Height"`
+	XOffset            int    `xml:"XOffset"`
+	YOffset            int    `xml:"YOffset"`
+	ContentRegionUnits string `xml:"ContentRegionUnits"`
+}
+
+// stateReasons maps scanner error codes to eSCL (PWG) state reasons
+var stateReasons = map[string]string{
+	scanner.CodeFeederEmpty: "MediaEmpty",
+	scanner.CodePaperJam:    "MediaJam",
+	scanner.CodeCoverOpen:   "CoverOpen",
+	scanner.CodeOffline:     "Offline",
+}
+
+// adfStates maps scanner error codes to eSCL ADF states
+var adfStates = map[string]string{
+	scanner.CodeFeederEmpty: "ScannerAdfEmpty",
+	scanner.CodePaperJam:    "ScannerAdfJam",
+	scanner.CodeCoverOpen:   "ScannerAdfHatchOpen",
+}
+
+// eSCL color modes mapped to ScanParams color modes
+var esclColorModes = map[string]string{
+	"RGB24":          "Color",
+	"RGB48":          "Color",
+	"Grayscale8":     "Grayscale",
+	"Grayscale16":    "Grayscale",
+	"BlackAndWhite1": "BlackAndWhite",
+}
+
+// eSCL MIME types mapped to ScanParams formats
+var esclFormats = map[string]string{
+	"image/jpeg":      "JPEG",
+	"image/png":       "PNG",
+	"image/tiff":      "TIFF",
+	"application/pdf": "PDF",
+}
+
+// toScanParams converts eSCL scan settings to ScanParams
+func (ss *ScanSettings) toScanParams() models.ScanParams {
+	params := models.ScanParams{
+		Resolution: ss.XResolution,
+		ColorMode:  ss.ColorMode,
+		UseFeeder:  ss.InputSource == "Feeder",
+		UseDuplex:  ss.Duplex,
+	}
+
+	if mode, ok := esclColorModes[ss.ColorMode]; ok {
+		params.ColorMode = mode
+	}
+
+	// DocumentFormatExt takes precedence per the eSCL spec
+	mime := ss.DocumentFormatExt
+	if mime == "" {
+		mime = ss.DocumentFormat
+	}
+	if format, ok := esclFormats[mime]; ok {
+		params.Format = format
+	} else {
+		params.Format = mime
+	}
+
+	// Regions are in 1/300 inch; convert to mm
+	if len(ss.ScanRegions) > 0 {
+		region := ss.ScanRegions[0]
+		params.PageWidth = region.Width * 254 / 3000
+		params.PageHeight = region.Height * 254 / 3000
+	}
+
+	return params
+}
+
 // ESCLServer handles eSCL protocol requests
 type ESCLServer struct {
 	scannerManager *scanner.Manager
+	validationMode scanner.ValidationMode
+	logger         *slog.Logger
 }
 
 // NewESCLServer creates a new eSCL server
-func NewESCLServer(scannerManager *scanner.Manager) *ESCLServer {
+func NewESCLServer(scannerManager *scanner.Manager, validationMode scanner.ValidationMode, logger *slog.Logger) *ESCLServer {
 	return &ESCLServer{
 		scannerManager: scannerManager,
+		logger:         logger,
+		validationMode: validationMode,
 	}
 }
 
-// RegisterRoutes registers eSCL routes
-func (s *ESCLServer) RegisterRoutes(router *gin.Engine) {
-	escl := router.Group("/eSCL")
+// RegisterRoutes registers eSCL routes behind the given middleware, e.g. authentication
+func (s *ESCLServer) RegisterRoutes(router *gin.Engine, middleware ...gin.HandlerFunc) {
+	escl := router.Group("/eSCL", middleware...)
 	{
 		escl.GET("/ScannerCapabilities", s.getScannerCapabilities)
 		escl.GET("/ScannerStatus", s.getScannerStatus)
@@ -139,9 +236,36 @@ func (s *ESCLServer) RegisterRoutes(router *gin.Engine) {
 	}
 }
 
+// listScanners returns the scanners assigned to the client. eSCL exposes the first one.
+func (s *ESCLServer) listScanners(c *gin.Context) ([]models.Scanner, error) {
+	scanners, err := s.scannerManager.ListScanners(c.Request.Context())
+	if err != nil {
+		return nil, err
+	}
+
+	principal := auth.FromContext(c)
+	assigned := make([]models.Scanner, 0, len(scanners))
+	for _, sc := range scanners {
+		if principal.CanUseScanner(sc.ID) {
+			assigned = append(assigned, sc)
+		}
+	}
+	return assigned, nil
+}
+
+// baseURL returns the scheme and host a request was sent to, so that
+// links work over HTTPS and from other machines
+func baseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
+
 // getScannerCapabilities returns scanner capabilities in eSCL format
 func (s *ESCLServer) getScannerCapabilities(c *gin.Context) {
-	scanners, err := s.scannerManager.ListScanners(c.Request.Context())
+	scanners, err := s.listScanners(c)
 	if err != nil || len(scanners) == 0 {
 		c.XML(http.StatusNotFound, gin.H{"error": "no scanner available"})
 		return
@@ -157,14 +281,14 @@ func (s *ESCLServer) getScannerCapabilities(c *gin.Context) {
 		Manufacturer: scanner.Manufacturer,
 		SerialNumber: scanner.ID,
 		UUID:         fmt.Sprintf("urn:uuid:%s", scanner.ID),
-		AdminURI:     "http://localhost:8080/",
-		IconURI:      "http://localhost:8080/static/icon.png",
+		AdminURI:     baseURL(c.Request) + "/",
+		IconURI:      baseURL(c.Request) + "/static/icon.png",
 		Platen: &Platen{
 			PlatenInputCaps: PlatenInputCaps{
 				MinWidth:  1,
-				MaxWidth:  scanner.Capabilities.MaxWidth,
+				MaxWidth:  toESCLUnits(scanner.Capabilities.MaxWidth),
 				MinHeight: 1,
-				MaxHeight: scanner.Capabilities.MaxHeight,
+				MaxHeight: toESCLUnits(scanner.Capabilities.MaxHeight),
 				MaxScanRegions: 1,
 				SettingProfiles: SettingProfiles{
 					SettingProfile: []SettingProfile{
@@ -196,9 +320,9 @@ func (s *ESCLServer) getScannerCapabilities(c *gin.Context) {
 		caps.Adf = &Adf{
 			AdfSimplexInputCaps: &AdfInputCaps{
 				MinWidth:  1,
-				MaxWidth:  scanner.Capabilities.MaxWidth,
+				MaxWidth:  toESCLUnits(scanner.Capabilities.MaxWidth),
 				MinHeight: 1,
-				MaxHeight: scanner.Capabilities.MaxHeight,
+				MaxHeight: toESCLUnits(scanner.Capabilities.MaxHeight),
 				MaxScanRegions: 1,
 				SettingProfiles: SettingProfiles{
 					SettingProfile: []SettingProfile{
@@ -230,6 +354,7 @@ func (s *ESCLServer) getScannerCapabilities(c *gin.Context) {
 }
 
 // getScannerStatus returns scanner status in eSCL format
+// State and reasons are derived from the scanner status and its most recent scan error
 func (s *ESCLServer) getScannerStatus(c *gin.Context) {
 	status := ScannerStatus{
 		Xmlns:    "http://schemas.hp.com/imaging/escl/2011/05/03",
@@ -241,13 +366,61 @@ func (s *ESCLServer) getScannerStatus(c *gin.Context) {
 		},
 	}
 
+	scanners, err := s.listScanners(c)
+	if err != nil || len(scanners) == 0 {
+		status.State = "Down"
+		status.StateReasons.StateReason = []string{stateReasons[scanner.CodeOffline]}
+		c.XML(http.StatusOK, status)
+		return
+	}
+
+	target := scanners[0]
+	if target.Status == "scanning" {
+		status.State = "Processing"
+	}
+
+	code := scanner.ErrorCode(s.scannerManager.LastError(target.ID))
+	if reason, ok := stateReasons[code]; ok {
+		status.StateReasons.StateReason = []string{reason}
+		if code == scanner.CodeOffline {
+			status.State = "Down"
+		} else if status.State == "Idle" && code != scanner.CodeFeederEmpty {
+			status.State = "Stopped"
+		}
+	}
+	if target.Capabilities.FeederEnabled {
+		status.AdfState = adfStates[code]
+	}
+
 	c.XML(http.StatusOK, status)
 }
 
 // createScanJob creates a new scan job via eSCL
 func (s *ESCLServer) createScanJob(c *gin.Context) {
+	if !auth.FromContext(c).CanScan() {
+		c.Status(http.StatusForbidden)
+		return
+	}
+
 	// Parse eSCL scan settings XML from request body
-	// For simplicity, using default settings
+	var settings ScanSettings
+	if err := xml.NewDecoder(c.Request.Body).Decode(&settings); err != nil {
+		c.XML(http.StatusBadRequest, gin.H{"error": "invalid scan settings: " + err.Error()})
+		return
+	}
+
+	scanners, err := s.listScanners(c)
+	if err != nil || len(scanners) == 0 {
+		c.XML(http.StatusServiceUnavailable, gin.H{"error": "no scanner available"})
+		return
+	}
+
+	// eSCL reports 409 Conflict for settings the scanner cannot honour
+	params := settings.toScanParams()
+	if err := scanner.ValidateParams(&scanners[0], &params, s.validationMode); err != nil {
+		c.XML(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
 
 	jobID := fmt.Sprintf("escl-job-%d", time.Now().Unix())
 
@@ -256,6 +429,11 @@ func (s *ESCLServer) createScanJob(c *gin.Context) {
 	c.Status(http.StatusCreated)
 }
 
+// toESCLUnits converts a capability length in 0.1mm to the 1/300 inch eSCL uses
+func toESCLUnits(tenthsMM int) int {
+	return tenthsMM * 300 / 254
+}
+
 // getNextDocument retrieves the next scanned document
 func (s *ESCLServer) getNextDocument(c *gin.Context) {
 	jobID := c.Param("jobId")
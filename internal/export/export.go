@@ -0,0 +1,337 @@
+// Package export copies completed jobs to remote folders (SFTP, FTP, ...)
+package export
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/scanserver/scanner-service/internal/config"
+	"github.com/scanserver/scanner-service/internal/document"
+	"github.com/scanserver/scanner-service/internal/logging"
+	"github.com/scanserver/scanner-service/internal/scanner"
+	"github.com/scanserver/scanner-service/internal/tracing"
+	"github.com/scanserver/scanner-service/pkg/models"
+)
+
+// Export formats
+const (
+	FormatPDF    = "pdf"    // All pages merged into one PDF
+	FormatImages = "images" // The page image files
+)
+
+// File is a local file and the remote path it is exported to
+type File struct {
+	Local  string
+	Remote string // Slash-separated
+}
+
+// Receipt describes where an upload went
+type Receipt struct {
+	Location string   // e.g. a URL
+	TaskIDs  []string // Processing tasks started by the destination, if any
+}
+
+// Exporter uploads the files of a job to a destination
+type Exporter interface {
+	// Upload writes the files, creating remote directories as needed
+	Upload(ctx context.Context, job *models.ScanJob, files []File) (Receipt, error)
+
+	// Ping connects and logs in to the destination without writing anything
+	Ping(ctx context.Context) error
+}
+
+// Factory creates an exporter for a configured destination
+type Factory func(cfg config.ExportDestination) (Exporter, error)
+
+// factories holds the exporter types by name
+var factories = map[string]Factory{}
+
+// Register makes an exporter type available to export.destinations
+func Register(typ string, factory Factory) {
+	factories[typ] = factory
+}
+
+// destination is a configured exporter
+type destination struct {
+	name     string
+	config   config.ExportDestination
+	exporter Exporter
+}
+
+// Manager exports completed jobs to the configured destinations
+type Manager struct {
+	config       *config.ExportConfig
+	destinations map[string]*destination
+	retryDelay   time.Duration // Wait before the first retry, doubled after each
+	logger       *slog.Logger
+}
+
+// NewManager creates the exporters of all configured destinations
+func NewManager(cfg *config.ExportConfig, logger *slog.Logger) (*Manager, error) {
+	retryDelay := time.Duration(cfg.RetryDelay) * time.Second
+	if retryDelay <= 0 {
+		retryDelay = time.Second
+	}
+
+	m := &Manager{
+		config:       cfg,
+		destinations: make(map[string]*destination),
+		retryDelay:   retryDelay,
+		logger:       logger,
+	}
+
+	for name, dest := range cfg.Destinations {
+		factory, ok := factories[strings.ToLower(dest.Type)]
+		if !ok {
+			return nil, fmt.Errorf("export destination %s: unknown type %q", name, dest.Type)
+		}
+
+		format := strings.ToLower(dest.Format)
+		if format == "" {
+			format = FormatPDF
+		}
+		if format != FormatPDF && format != FormatImages {
+			return nil, fmt.Errorf("export destination %s: format must be %s or %s", name, FormatPDF, FormatImages)
+		}
+		dest.Format = format
+
+		exporter, err := factory(dest)
+		if err != nil {
+			return nil, fmt.Errorf("export destination %s: %w", name, err)
+		}
+		m.destinations[name] = &destination{name: name, config: dest, exporter: exporter}
+	}
+
+	for profile, names := range cfg.Profiles {
+		for _, name := range names {
+			if _, ok := m.destinations[strings.ToLower(name)]; !ok {
+				return nil, fmt.Errorf("export profile %s: unknown destination %q", profile, name)
+			}
+		}
+	}
+
+	return m, nil
+}
+
+// Names returns the configured destination names
+func (m *Manager) Names() []string {
+	names := make([]string, 0, len(m.destinations))
+	for name := range m.destinations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Check validates a request, so that a job is not started only to fail exporting
+func (m *Manager) Check(req models.ExportRequest) error {
+	_, err := m.resolve(req)
+	return err
+}
+
+// Ping checks that every destination can be reached and logged in to, and
+// returns the error of each destination by name. Destinations are checked
+// concurrently, each within its connection timeout.
+func (m *Manager) Ping(ctx context.Context) map[string]error {
+	results := make(map[string]error, len(m.destinations))
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	for name, dest := range m.destinations {
+		wg.Add(1)
+		go func(name string, dest *destination) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(ctx, timeout(dest.config))
+			defer cancel()
+			err := dest.exporter.Ping(ctx)
+
+			mutex.Lock()
+			results[name] = err
+			mutex.Unlock()
+		}(name, dest)
+	}
+	wg.Wait()
+	return results
+}
+
+// resolve returns the destinations selected by a request
+func (m *Manager) resolve(req models.ExportRequest) ([]*destination, error) {
+	// Viper lower-cases map keys
+	var names []string
+	if req.Profile != "" {
+		profile, ok := m.config.Profiles[strings.ToLower(req.Profile)]
+		if !ok {
+			return nil, fmt.Errorf("unknown export profile %q", req.Profile)
+		}
+		names = append(names, profile...)
+	}
+	names = append(names, req.Destinations...)
+
+	if len(names) == 0 {
+		return nil, fmt.Errorf("no export destinations")
+	}
+
+	seen := make(map[string]bool)
+	var result []*destination
+	for _, name := range names {
+		name = strings.ToLower(name)
+		dest, ok := m.destinations[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown export destination %q", name)
+		}
+		if !seen[name] {
+			seen[name] = true
+			result = append(result, dest)
+		}
+	}
+	return result, nil
+}
+
+// Export uploads the pages of a completed job to the destinations selected
+// by req, retrying failed uploads, and returns the outcome per destination
+func (m *Manager) Export(ctx context.Context, job *models.ScanJob, req models.ExportRequest, pages []document.Page) []models.OutputResult {
+	dests, err := m.resolve(req)
+	if err != nil {
+		return []models.OutputResult{{Destination: "export", Status: "failed", Error: err.Error(), Time: time.Now()}}
+	}
+
+	// The merged PDF is written once for all destinations that want it
+	var pdfPath string
+	defer func() {
+		if pdfPath != "" {
+			os.Remove(pdfPath)
+		}
+	}()
+
+	results := make([]models.OutputResult, 0, len(dests))
+	for _, dest := range dests {
+		output := models.OutputResult{Destination: dest.name}
+
+		var locals []string
+		if dest.config.Format == FormatImages {
+			for _, page := range pages {
+				locals = append(locals, page.Path)
+			}
+		} else {
+			if pdfPath == "" {
+				pdfPath, err = writeTempPDF(job.ID, pages)
+			}
+			if err != nil {
+				output.Status, output.Error, output.Time = "failed", err.Error(), time.Now()
+				results = append(results, output)
+				continue
+			}
+			locals = []string{pdfPath}
+		}
+
+		uploadCtx, span := tracing.Start(ctx, "export.upload", attribute.String("export.destination", dest.name))
+		receipt, err := m.upload(uploadCtx, dest, job, remoteFiles(dest.config.Path, job.ID, locals))
+		tracing.End(span, err)
+		output.Time = time.Now()
+		if err != nil {
+			output.Status = "failed"
+			output.Error = err.Error()
+			logging.FromContext(ctx, m.logger).Error("Failed to export job", "destination", dest.name, logging.Err(err))
+		} else {
+			output.Status = "sent"
+			output.Detail = receipt.Location
+			output.TaskIDs = receipt.TaskIDs
+		}
+		results = append(results, output)
+	}
+
+	return results
+}
+
+// upload uploads files to a destination, retrying with exponential backoff
+func (m *Manager) upload(ctx context.Context, dest *destination, job *models.ScanJob, files []File) (Receipt, error) {
+	attempts := m.config.MaxAttempts
+	if attempts <= 0 {
+		attempts = 1
+	}
+	delay := m.retryDelay
+
+	for attempt := 1; ; attempt++ {
+		receipt, err := dest.exporter.Upload(ctx, job, files)
+		if err == nil {
+			return receipt, nil
+		}
+		if attempt >= attempts {
+			return Receipt{}, fmt.Errorf("%w (after %d attempts)", err, attempt)
+		}
+
+		logging.FromContext(ctx, m.logger).Warn("Export failed, retrying",
+			"destination", dest.name, "attempt", attempt, "max_attempts", attempts, "retry_in", delay, logging.Err(err))
+		select {
+		case <-time.After(delay):
+			delay *= 2
+		case <-ctx.Done():
+			return Receipt{}, ctx.Err()
+		}
+	}
+}
+
+// remoteFiles maps local files to remote paths. The path template gets the
+// file's extension; without $(n) in it, several files are told apart by a _1, _2, ... suffix.
+func remoteFiles(template, jobID string, locals []string) []File {
+	if template == "" {
+		template = "$(yyyy)-$(MM)-$(dd)/scan-" + jobID
+	}
+
+	files := make([]File, len(locals))
+	for i, local := range locals {
+		remote := scanner.SubstitutePlaceholders(template, i)
+		if len(locals) > 1 && !strings.Contains(template, "$(n)") {
+			remote = fmt.Sprintf("%s_%d", remote, i+1)
+		}
+		files[i] = File{Local: local, Remote: path.Clean(remote + strings.ToLower(filepath.Ext(local)))}
+	}
+	return files
+}
+
+// writeTempPDF merges the pages into a temporary PDF file
+func writeTempPDF(jobID string, pages []document.Page) (string, error) {
+	f, err := os.CreateTemp("", "scan-"+jobID+"-*.pdf")
+	if err != nil {
+		return "", err
+	}
+
+	if err := document.WritePDF(f, pages); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+// timeout returns the connection timeout of a destination
+func timeout(cfg config.ExportDestination) time.Duration {
+	if cfg.Timeout > 0 {
+		return time.Duration(cfg.Timeout) * time.Second
+	}
+	return 30 * time.Second
+}
+
+// dirOf returns the remote directory of the uploaded files, for reporting
+func dirOf(files []File) string {
+	if len(files) == 0 {
+		return ""
+	}
+	if len(files) == 1 {
+		return files[0].Remote
+	}
+	return path.Dir(files[0].Remote) + "/"
+}
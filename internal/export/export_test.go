@@ -0,0 +1,587 @@
+package export
+
+import (
+	"bufio"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+
+	"github.com/scanserver/scanner-service/internal/config"
+	"github.com/scanserver/scanner-service/internal/document"
+	"github.com/scanserver/scanner-service/pkg/models"
+)
+
+// testLogger discards the log output of the code under test
+var testLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+// writePages writes n page files of distinct content
+func writePages(t *testing.T, n int) []document.Page {
+	t.Helper()
+	dir := t.TempDir()
+	pages := make([]document.Page, n)
+	for i := range pages {
+		p := filepath.Join(dir, fmt.Sprintf("page-%04d.PNG", i+1))
+		if err := os.WriteFile(p, []byte(fmt.Sprintf("page %d", i+1)), 0644); err != nil {
+			t.Fatal(err)
+		}
+		pages[i] = document.Page{Path: p}
+	}
+	return pages
+}
+
+// today returns the $(yyyy)/$(MM) expansion for now
+func today() string {
+	return time.Now().Format("2006/01")
+}
+
+// startSFTPServer serves SFTP over SSH from root, accepting user scanner
+// with password secret. It returns the address and the host key in
+// authorized_keys format.
+func startSFTPServer(t *testing.T, root string) (string, int, string) {
+	t.Helper()
+
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(private)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serverConfig := &ssh.ServerConfig{
+		PasswordCallback: func(conn ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			if conn.User() == "scanner" && string(password) == "secret" {
+				return nil, nil
+			}
+			return nil, errors.New("access denied")
+		},
+	}
+	serverConfig.AddHostKey(signer)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveSSH(conn, serverConfig, root)
+		}
+	}()
+
+	addr := listener.Addr().(*net.TCPAddr)
+	return addr.IP.String(), addr.Port, string(ssh.MarshalAuthorizedKey(signer.PublicKey()))
+}
+
+func serveSSH(conn net.Conn, serverConfig *ssh.ServerConfig, root string) {
+	_, chans, reqs, err := ssh.NewServerConn(conn, serverConfig)
+	if err != nil {
+		conn.Close()
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+
+	for newChannel := range chans {
+		if newChannel.ChannelType() != "session" {
+			newChannel.Reject(ssh.UnknownChannelType, "session only")
+			continue
+		}
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			for req := range requests {
+				// Payload is the subsystem name as an SSH string
+				ok := req.Type == "subsystem" && len(req.Payload) > 4 && string(req.Payload[4:]) == "sftp"
+				req.Reply(ok, nil)
+				if !ok {
+					continue
+				}
+				server, err := sftp.NewServer(channel, sftp.WithServerWorkingDirectory(root))
+				if err != nil {
+					channel.Close()
+					return
+				}
+				go func() {
+					server.Serve()
+					channel.Close()
+				}()
+			}
+		}()
+	}
+}
+
+func TestSFTPExport(t *testing.T) {
+	root := t.TempDir()
+	host, port, hostKey := startSFTPServer(t, root)
+
+	m, err := NewManager(&config.ExportConfig{
+		MaxAttempts: 1,
+		Destinations: map[string]config.ExportDestination{
+			"nas": {
+				Type:     "sftp",
+				Host:     host,
+				Port:     port,
+				Username: "scanner",
+				Password: "secret",
+				HostKey:  hostKey,
+				Path:     "inbox/$(yyyy)/$(MM)/scan-$(n)",
+				Format:   "images",
+			},
+		},
+	}, testLogger)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if errs := m.Ping(context.Background()); errs["nas"] != nil {
+		t.Fatalf("ping: %v", errs["nas"])
+	}
+
+	job := &models.ScanJob{ID: "job-1"}
+	// Exporting twice replaces the files of the first export
+	for i := 0; i < 2; i++ {
+		results := m.Export(context.Background(), job, models.ExportRequest{Destinations: []string{"NAS"}}, writePages(t, 2))
+		if len(results) != 1 || results[0].Status != "sent" {
+			t.Fatalf("results = %+v", results)
+		}
+		if want := fmt.Sprintf("sftp://%s/inbox/%s/", net.JoinHostPort(host, strconv.Itoa(port)), today()); results[0].Detail != want {
+			t.Errorf("detail = %s, want %s", results[0].Detail, want)
+		}
+	}
+
+	dir := filepath.Join(root, "inbox", filepath.FromSlash(today()))
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	if strings.Join(names, ",") != "scan-1.png,scan-2.png" {
+		t.Errorf("remote files = %v", names)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "scan-2.png")); string(data) != "page 2" {
+		t.Errorf("scan-2.png = %q", data)
+	}
+}
+
+func TestSFTPAuthentication(t *testing.T) {
+	host, port, hostKey := startSFTPServer(t, t.TempDir())
+	_, _, otherKey := startSFTPServer(t, t.TempDir())
+
+	for name, cfg := range map[string]config.ExportDestination{
+		"wrong password": {Password: "wrong", HostKey: hostKey},
+		"wrong host key": {Password: "secret", HostKey: otherKey},
+	} {
+		t.Run(name, func(t *testing.T) {
+			cfg.Host, cfg.Port, cfg.Username = host, port, "scanner"
+			e, err := newSFTPExporter(cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := e.Ping(context.Background()); err == nil {
+				t.Error("ping succeeded")
+			}
+		})
+	}
+
+	if _, err := newSFTPExporter(config.ExportDestination{Host: host, Password: "secret"}); err == nil {
+		t.Error("an exporter without host key must be refused")
+	}
+}
+
+// ftpStub is an in-memory FTP server supporting what the exporter uses:
+// login, MKD, STOR over EPSV, DELE and RNFR/RNTO
+type ftpStub struct {
+	listener net.Listener
+
+	mutex    sync.Mutex
+	dirs     map[string]bool
+	files    map[string]string
+	commands []string // Commands after login, in order
+}
+
+func startFTPStub(t *testing.T) *ftpStub {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &ftpStub{listener: listener, dirs: map[string]bool{}, files: map[string]string{}}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *ftpStub) port() int {
+	return s.listener.Addr().(*net.TCPAddr).Port
+}
+
+func (s *ftpStub) serve(conn net.Conn) {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+	r := bufio.NewReader(conn)
+	reply := func(format string, args ...interface{}) {
+		fmt.Fprintf(conn, format+"\r\n", args...)
+	}
+
+	reply("220 stub ready")
+	var data net.Listener
+	var renameFrom string
+	loggedIn := false
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(strings.TrimRight(line, "\r\n"), " ")
+		verb = strings.ToUpper(verb)
+
+		s.mutex.Lock()
+		if loggedIn && verb != "EPSV" && verb != "TYPE" && verb != "OPTS" && verb != "FEAT" {
+			s.commands = append(s.commands, strings.TrimSpace(verb+" "+arg))
+		}
+		parent := path.Dir(arg)
+		parentExists := parent == "." || parent == "/" || s.dirs[parent]
+		s.mutex.Unlock()
+
+		switch verb {
+		case "USER":
+			reply("331 password required")
+		case "PASS":
+			if arg != "secret" {
+				reply("530 login incorrect")
+				continue
+			}
+			loggedIn = true
+			reply("230 logged in")
+		case "FEAT":
+			reply("211 no features")
+		case "EPSV":
+			if data, err = net.Listen("tcp", "127.0.0.1:0"); err != nil {
+				reply("425 cannot open data connection")
+				continue
+			}
+			reply("229 Entering Extended Passive Mode (|||%d|)", data.Addr().(*net.TCPAddr).Port)
+		case "MKD":
+			s.mutex.Lock()
+			exists := s.dirs[arg]
+			if parentExists && !exists {
+				s.dirs[arg] = true
+			}
+			s.mutex.Unlock()
+			if !parentExists || exists {
+				reply("550 cannot create %s", arg)
+				continue
+			}
+			reply("257 %q created", arg)
+		case "STOR":
+			if data == nil {
+				reply("425 use EPSV first")
+				continue
+			}
+			if !parentExists {
+				data.Close()
+				data = nil
+				reply("553 no such directory")
+				continue
+			}
+			reply("150 ok to send data")
+			dc, err := data.Accept()
+			data.Close()
+			data = nil
+			if err != nil {
+				return
+			}
+			content, _ := io.ReadAll(dc)
+			dc.Close()
+			s.mutex.Lock()
+			s.files[arg] = string(content)
+			s.mutex.Unlock()
+			reply("226 transfer complete")
+		case "DELE":
+			s.mutex.Lock()
+			_, ok := s.files[arg]
+			delete(s.files, arg)
+			s.mutex.Unlock()
+			if !ok {
+				reply("550 no such file")
+				continue
+			}
+			reply("250 deleted")
+		case "RNFR":
+			renameFrom = arg
+			reply("350 ready for RNTO")
+		case "RNTO":
+			s.mutex.Lock()
+			content, ok := s.files[renameFrom]
+			if ok {
+				delete(s.files, renameFrom)
+				s.files[arg] = content
+			}
+			s.mutex.Unlock()
+			if !ok {
+				reply("550 no such file")
+				continue
+			}
+			reply("250 renamed")
+		case "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("200 ok")
+		}
+	}
+}
+
+func TestFTPExport(t *testing.T) {
+	stub := startFTPStub(t)
+	stub.dirs["scans"] = true // Exists already
+
+	m, err := NewManager(&config.ExportConfig{
+		Destinations: map[string]config.ExportDestination{
+			"ftp": {
+				Type:     "ftp",
+				Host:     "127.0.0.1",
+				Port:     stub.port(),
+				Username: "scanner",
+				Password: "secret",
+				Path:     "scans/$(yyyy)/$(MM)/doc",
+				Format:   "images",
+			},
+		},
+	}, testLogger)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results := m.Export(context.Background(), &models.ScanJob{ID: "job-1"}, models.ExportRequest{Destinations: []string{"ftp"}}, writePages(t, 2))
+	if len(results) != 1 || results[0].Status != "sent" {
+		t.Fatalf("results = %+v", results)
+	}
+	if want := fmt.Sprintf("ftp://127.0.0.1:%d/scans/%s/", stub.port(), today()); results[0].Detail != want {
+		t.Errorf("detail = %s, want %s", results[0].Detail, want)
+	}
+
+	dir := "scans/" + today()
+	year := path.Dir(dir)
+	want := []string{
+		// Creating the existing directory fails harmlessly. Without $(n)
+		// in the path, files get a _1, _2 suffix.
+		"MKD scans", "MKD " + year, "MKD " + dir,
+		"STOR " + dir + "/doc_1.png.part", "DELE " + dir + "/doc_1.png",
+		"RNFR " + dir + "/doc_1.png.part", "RNTO " + dir + "/doc_1.png",
+		// Directories are created once per upload
+		"STOR " + dir + "/doc_2.png.part", "DELE " + dir + "/doc_2.png",
+		"RNFR " + dir + "/doc_2.png.part", "RNTO " + dir + "/doc_2.png",
+	}
+	stub.mutex.Lock()
+	defer stub.mutex.Unlock()
+	// QUIT may still be on its way
+	if n := len(stub.commands); n > 0 && stub.commands[n-1] == "QUIT" {
+		stub.commands = stub.commands[:n-1]
+	}
+	if strings.Join(stub.commands, "\n") != strings.Join(want, "\n") {
+		t.Errorf("commands:\n%s\nwant:\n%s", strings.Join(stub.commands, "\n"), strings.Join(want, "\n"))
+	}
+	if len(stub.files) != 2 || stub.files[dir+"/doc_1.png"] != "page 1" || stub.files[dir+"/doc_2.png"] != "page 2" {
+		t.Errorf("files = %v", stub.files)
+	}
+}
+
+func TestRemoteFiles(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name     string
+		template string
+		locals   []string
+		want     []string
+	}{
+		{"default path", "", []string{"/tmp/x.pdf"}, []string{now.Format("2006-01-02") + "/scan-job-1.pdf"}},
+		{"placeholders", "$(yyyy)/$(MM)/$(dd)/invoice", []string{"/tmp/x.pdf"}, []string{now.Format("2006/01/02") + "/invoice.pdf"}},
+		{"numbered", "in/page-$(n)", []string{"/a/1.JPG", "/a/2.jpg"}, []string{"in/page-1.jpg", "in/page-2.jpg"}},
+		{"suffixed", "in/page", []string{"/a/1.png", "/a/2.png"}, []string{"in/page_1.png", "in/page_2.png"}},
+		{"cleaned", "/in//./scan", []string{"/a/1.png"}, []string{"/in/scan.png"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files := remoteFiles(tt.template, "job-1", tt.locals)
+			for i, f := range files {
+				if f.Local != tt.locals[i] || f.Remote != tt.want[i] {
+					t.Errorf("file %d = %s -> %s, want %s", i, f.Local, f.Remote, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+// recordingExporter records uploads and fails the first failures of them
+type recordingExporter struct {
+	name     string
+	mutex    *sync.Mutex
+	calls    *[]string
+	failures int
+}
+
+func (e *recordingExporter) Upload(ctx context.Context, job *models.ScanJob, files []File) (Receipt, error) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	*e.calls = append(*e.calls, e.name)
+	if e.failures > 0 {
+		e.failures--
+		return Receipt{}, errors.New("connection reset")
+	}
+	return Receipt{Location: e.name + ":/" + dirOf(files), TaskIDs: []string{"task-" + e.name}}, nil
+}
+
+func (e *recordingExporter) Ping(ctx context.Context) error {
+	return nil
+}
+
+// newRecordingManager creates a manager of recording exporters. A
+// destination's Port is the number of uploads that fail before one succeeds.
+func newRecordingManager(t *testing.T, cfg *config.ExportConfig) (*Manager, *[]string) {
+	t.Helper()
+	var mutex sync.Mutex
+	calls := &[]string{}
+	Register("recording", func(dest config.ExportDestination) (Exporter, error) {
+		return &recordingExporter{name: dest.Host, mutex: &mutex, calls: calls, failures: dest.Port}, nil
+	})
+
+	for name, dest := range cfg.Destinations {
+		dest.Type, dest.Host, dest.Format = "recording", name, "images"
+		cfg.Destinations[name] = dest
+	}
+	m, err := NewManager(cfg, testLogger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.retryDelay = time.Millisecond
+	return m, calls
+}
+
+func TestRetryThenSuccess(t *testing.T) {
+	m, calls := newRecordingManager(t, &config.ExportConfig{
+		MaxAttempts: 3,
+		Destinations: map[string]config.ExportDestination{
+			"flaky":  {Port: 2},
+			"broken": {Port: 10},
+		},
+	})
+
+	results := m.Export(context.Background(), &models.ScanJob{ID: "job-1"}, models.ExportRequest{Destinations: []string{"flaky", "broken"}}, writePages(t, 1))
+	if len(results) != 2 {
+		t.Fatalf("results = %+v", results)
+	}
+	if r := results[0]; r.Destination != "flaky" || r.Status != "sent" || len(r.TaskIDs) != 1 || r.TaskIDs[0] != "task-flaky" {
+		t.Errorf("flaky: %+v", r)
+	}
+	if r := results[1]; r.Destination != "broken" || r.Status != "failed" || !strings.Contains(r.Error, "after 3 attempts") {
+		t.Errorf("broken: %+v", r)
+	}
+	if got := strings.Join(*calls, ","); got != "flaky,flaky,flaky,broken,broken,broken" {
+		t.Errorf("uploads = %s", got)
+	}
+}
+
+func TestRetryStopsWhenCancelled(t *testing.T) {
+	m, calls := newRecordingManager(t, &config.ExportConfig{
+		MaxAttempts:  5,
+		Destinations: map[string]config.ExportDestination{"flaky": {Port: 10}},
+	})
+	m.retryDelay = time.Hour
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	results := m.Export(ctx, &models.ScanJob{ID: "job-1"}, models.ExportRequest{Destinations: []string{"flaky"}}, writePages(t, 1))
+	if results[0].Status != "failed" || len(*calls) != 1 {
+		t.Errorf("results = %+v after %d upload(s)", results, len(*calls))
+	}
+}
+
+func TestProfileSelection(t *testing.T) {
+	m, calls := newRecordingManager(t, &config.ExportConfig{
+		Destinations: map[string]config.ExportDestination{"a": {}, "b": {}, "c": {}},
+		// Viper lower-cases map keys
+		Profiles: map[string][]string{"office": {"a", "B"}},
+	})
+
+	// Profile destinations come first; duplicates are uploaded once
+	results := m.Export(context.Background(), &models.ScanJob{ID: "job-1"}, models.ExportRequest{Profile: "Office", Destinations: []string{"c", "A"}}, writePages(t, 1))
+	var got []string
+	for _, r := range results {
+		if r.Status != "sent" {
+			t.Errorf("%s: %s", r.Destination, r.Error)
+		}
+		got = append(got, r.Destination)
+	}
+	if strings.Join(got, ",") != "a,b,c" || strings.Join(*calls, ",") != "a,b,c" {
+		t.Errorf("results %v, uploads %v, want a,b,c", got, *calls)
+	}
+
+	for _, req := range []models.ExportRequest{
+		{Profile: "home"},
+		{Destinations: []string{"d"}},
+		{},
+	} {
+		if err := m.Check(req); err == nil {
+			t.Errorf("Check(%+v) succeeded", req)
+		}
+		results := m.Export(context.Background(), &models.ScanJob{ID: "job-1"}, req, nil)
+		if len(results) != 1 || results[0].Destination != "export" || results[0].Status != "failed" {
+			t.Errorf("Export(%+v) = %+v", req, results)
+		}
+	}
+}
+
+func TestNewManagerValidation(t *testing.T) {
+	Register("recording", func(dest config.ExportDestination) (Exporter, error) {
+		return &recordingExporter{}, nil
+	})
+
+	for name, cfg := range map[string]*config.ExportConfig{
+		"unknown type": {Destinations: map[string]config.ExportDestination{"a": {Type: "gopher"}}},
+		"bad format":   {Destinations: map[string]config.ExportDestination{"a": {Type: "recording", Format: "zip"}}},
+		"bad profile": {
+			Destinations: map[string]config.ExportDestination{"a": {Type: "recording"}},
+			Profiles:     map[string][]string{"office": {"a", "b"}},
+		},
+	} {
+		if _, err := NewManager(cfg, testLogger); err == nil {
+			t.Errorf("%s: NewManager succeeded", name)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package export
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/jlaffaye/ftp"
+
+	"github.com/scanserver/scanner-service/internal/config"
+	"github.com/scanserver/scanner-service/pkg/models"
+)
+
+func init() {
+	Register("ftp", newFTPExporter)
+	Register("ftps", newFTPExporter)
+}
+
+// ftpExporter uploads files over FTP, or FTPS when tlsConfig is set
+type ftpExporter struct {
+	addr        string
+	config      config.ExportDestination
+	tlsConfig   *tls.Config
+	implicitTLS bool
+}
+
+// newFTPExporter creates an FTP or FTPS exporter
+func newFTPExporter(cfg config.ExportDestination) (Exporter, error) {
+	if cfg.Host == "" {
+		return nil, fmt.Errorf("host is required")
+	}
+
+	e := &ftpExporter{config: cfg}
+	port := cfg.Port
+	if strings.EqualFold(cfg.Type, "ftps") {
+		e.tlsConfig = &tls.Config{
+			ServerName:         cfg.Host,
+			InsecureSkipVerify: cfg.InsecureSkipVerify,
+			// Many servers require the data connection to resume the control session
+			ClientSessionCache: tls.NewLRUClientSessionCache(8),
+		}
+		e.implicitTLS = cfg.ImplicitTLS
+		if port == 0 && cfg.ImplicitTLS {
+			port = 990
+		}
+	}
+	if port == 0 {
+		port = 21
+	}
+	e.addr = net.JoinHostPort(cfg.Host, strconv.Itoa(port))
+
+	if cfg.Username == "" {
+		e.config.Username = "anonymous"
+		e.config.Password = "anonymous"
+	}
+	return e, nil
+}
+
+// Upload implements Exporter
+func (e *ftpExporter) Upload(ctx context.Context, job *models.ScanJob, files []File) (Receipt, error) {
+	scheme := "ftp"
+	if e.tlsConfig != nil {
+		scheme = "ftps"
+	}
+
+	conn, err := e.dial(ctx)
+	if err != nil {
+		return Receipt{}, err
+	}
+	defer conn.Quit()
+
+	// Abort the transfer when the job's context is cancelled
+	stop := context.AfterFunc(ctx, func() { conn.Quit() })
+	defer stop()
+
+	if err := conn.Login(e.config.Username, e.config.Password); err != nil {
+		return Receipt{}, err
+	}
+
+	created := make(map[string]bool)
+	for _, file := range files {
+		if err := e.upload(conn, file, created); err != nil {
+			return Receipt{}, fmt.Errorf("%s: %w", file.Remote, err)
+		}
+	}
+
+	return Receipt{Location: fmt.Sprintf("%s://%s/%s", scheme, e.addr, strings.TrimPrefix(dirOf(files), "/"))}, nil
+}
+
+// Ping implements Exporter
+func (e *ftpExporter) Ping(ctx context.Context) error {
+	conn, err := e.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Quit()
+
+	stop := context.AfterFunc(ctx, func() { conn.Quit() })
+	defer stop()
+
+	return conn.Login(e.config.Username, e.config.Password)
+}
+
+// dial opens the control connection, with TLS for FTPS
+func (e *ftpExporter) dial(ctx context.Context) (*ftp.ServerConn, error) {
+	options := []ftp.DialOption{
+		ftp.DialWithContext(ctx),
+		ftp.DialWithTimeout(timeout(e.config)),
+	}
+	if e.tlsConfig != nil {
+		if e.implicitTLS {
+			options = append(options, ftp.DialWithTLS(e.tlsConfig))
+		} else {
+			options = append(options, ftp.DialWithExplicitTLS(e.tlsConfig))
+		}
+	}
+	return ftp.Dial(e.addr, options...)
+}
+
+// upload stores a file under a temporary name and renames it into place
+func (e *ftpExporter) upload(conn *ftp.ServerConn, file File, created map[string]bool) error {
+	// FTP has no "mkdir -p"; creating a directory that exists fails harmlessly
+	dir := path.Dir(file.Remote)
+	var parents []string
+	for d := dir; d != "." && d != "/" && !created[d]; d = path.Dir(d) {
+		parents = append(parents, d)
+	}
+	for i := len(parents) - 1; i >= 0; i-- {
+		conn.MakeDir(parents[i])
+		created[parents[i]] = true
+	}
+
+	f, err := os.Open(file.Local)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	partial := file.Remote + ".part"
+	if err := conn.Stor(partial, f); err != nil {
+		return err
+	}
+
+	// Some servers refuse to rename onto an existing file
+	conn.Delete(file.Remote)
+	return conn.Rename(partial, file.Remote)
+}
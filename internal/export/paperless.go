@@ -0,0 +1,334 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+
+	"github.com/scanserver/scanner-service/internal/config"
+	"github.com/scanserver/scanner-service/pkg/models"
+)
+
+func init() {
+	Register("paperless", newPaperlessExporter)
+}
+
+// defaultPaperlessTitle names documents when no title template is configured
+const defaultPaperlessTitle = "Scan {{.Date}}"
+
+// paperlessExporter hands documents to Paperless-ngx, either through its
+// post_document API or by writing them into its consume directory
+type paperlessExporter struct {
+	config config.ExportDestination
+	title  *template.Template
+	client *http.Client
+
+	mutex sync.Mutex
+	ids   map[string]int // Resolved tag, correspondent and document type names, by "endpoint/name"
+}
+
+// paperlessTitleData is available to title templates
+type paperlessTitleData struct {
+	Job   *models.ScanJob
+	Pages int    // Pages in the job
+	Page  int    // Page number when each page is a separate document (format: images), else 0
+	Date  string // Job creation date, e.g. 2025-11-10
+}
+
+// newPaperlessExporter creates a Paperless-ngx exporter
+func newPaperlessExporter(cfg config.ExportDestination) (Exporter, error) {
+	if (cfg.URL == "") == (cfg.ConsumeDir == "") {
+		return nil, fmt.Errorf("exactly one of url and consume_dir is required")
+	}
+	if cfg.ConsumeDir != "" && (len(cfg.Tags) > 0 || cfg.Correspondent != "" || cfg.DocumentType != "") {
+		return nil, fmt.Errorf("tags, correspondent and document_type need url; the consume directory only takes the title")
+	}
+	if cfg.URL != "" {
+		if _, err := url.ParseRequestURI(cfg.URL); err != nil {
+			return nil, fmt.Errorf("invalid url: %w", err)
+		}
+	}
+
+	titleTmpl := cfg.Title
+	if titleTmpl == "" {
+		titleTmpl = defaultPaperlessTitle
+	}
+	title, err := template.New("title").Parse(titleTmpl)
+	if err != nil {
+		return nil, fmt.Errorf("invalid title template: %w", err)
+	}
+
+	cfg.URL = strings.TrimRight(cfg.URL, "/")
+	return &paperlessExporter{
+		config: cfg,
+		title:  title,
+		client: &http.Client{Timeout: timeout(cfg)},
+		ids:    make(map[string]int),
+	}, nil
+}
+
+// Upload implements Exporter. Each file becomes one Paperless document.
+func (e *paperlessExporter) Upload(ctx context.Context, job *models.ScanJob, files []File) (Receipt, error) {
+	var receipt Receipt
+	for i, file := range files {
+		data := paperlessTitleData{
+			Job:   job,
+			Pages: len(job.Results),
+			Date:  job.CreatedAt.Format("2006-01-02"),
+		}
+		if len(files) > 1 {
+			data.Page = i + 1
+		}
+
+		var title bytes.Buffer
+		if err := e.title.Execute(&title, data); err != nil {
+			return Receipt{}, fmt.Errorf("failed to render title: %w", err)
+		}
+		if title.Len() == 0 {
+			title.WriteString(job.ID)
+		}
+		if len(files) > 1 && !strings.Contains(e.config.Title, ".Page") {
+			fmt.Fprintf(&title, " (%d)", i+1)
+		}
+
+		if e.config.ConsumeDir != "" {
+			if err := e.consume(file.Local, title.String()); err != nil {
+				return Receipt{}, err
+			}
+			continue
+		}
+
+		task, err := e.post(ctx, job, file.Local, title.String())
+		if err != nil {
+			return Receipt{}, err
+		}
+		receipt.TaskIDs = append(receipt.TaskIDs, task)
+	}
+
+	if e.config.ConsumeDir != "" {
+		receipt.Location = e.config.ConsumeDir
+	} else {
+		receipt.Location = e.config.URL
+	}
+	return receipt, nil
+}
+
+// Ping implements Exporter. It checks that the consume directory exists, or
+// that the API accepts the configured credentials.
+func (e *paperlessExporter) Ping(ctx context.Context) error {
+	if e.config.ConsumeDir != "" {
+		info, err := os.Stat(e.config.ConsumeDir)
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return fmt.Errorf("%s is not a directory", e.config.ConsumeDir)
+		}
+		return nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, e.config.URL+"/api/", nil)
+	if err != nil {
+		return err
+	}
+	_, err = e.do(req)
+	return err
+}
+
+// consume copies a file into the consume directory, named after the title.
+// Paperless takes the title from the file name.
+func (e *paperlessExporter) consume(local, title string) error {
+	ext := filepath.Ext(local)
+	target := filepath.Join(e.config.ConsumeDir, fileName(title, ext))
+	for n := 2; ; n++ {
+		if _, err := os.Stat(target); os.IsNotExist(err) {
+			break
+		}
+		target = filepath.Join(e.config.ConsumeDir, fileName(fmt.Sprintf("%s_%d", title, n), ext))
+	}
+
+	// Paperless ignores the .part file until it is renamed
+	in, err := os.Open(local)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	partial := target + ".part"
+	out, err := os.Create(partial)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(partial)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(partial)
+		return err
+	}
+	return os.Rename(partial, target)
+}
+
+// post uploads a document to /api/documents/post_document/ and returns the
+// ID of the consumption task Paperless started for it
+func (e *paperlessExporter) post(ctx context.Context, job *models.ScanJob, local, title string) (string, error) {
+	fields := [][2]string{
+		{"title", title},
+		{"created", job.CreatedAt.Format("2006-01-02")},
+	}
+	for _, tag := range e.config.Tags {
+		id, err := e.resolve(ctx, "tags", tag)
+		if err != nil {
+			return "", err
+		}
+		fields = append(fields, [2]string{"tags", strconv.Itoa(id)})
+	}
+	if e.config.Correspondent != "" {
+		id, err := e.resolve(ctx, "correspondents", e.config.Correspondent)
+		if err != nil {
+			return "", err
+		}
+		fields = append(fields, [2]string{"correspondent", strconv.Itoa(id)})
+	}
+	if e.config.DocumentType != "" {
+		id, err := e.resolve(ctx, "document_types", e.config.DocumentType)
+		if err != nil {
+			return "", err
+		}
+		fields = append(fields, [2]string{"document_type", strconv.Itoa(id)})
+	}
+
+	f, err := os.Open(local)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	// Buffered rather than streamed: Django needs a Content-Length to read the form
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	for _, field := range fields {
+		if err := mw.WriteField(field[0], field[1]); err != nil {
+			return "", err
+		}
+	}
+	part, err := mw.CreateFormFile("document", fileName(title, filepath.Ext(local)))
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(part, f); err != nil {
+		return "", err
+	}
+	if err := mw.Close(); err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.config.URL+"/api/documents/post_document/", &buf)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+
+	body, err := e.do(req)
+	if err != nil {
+		return "", err
+	}
+
+	// The response is the task ID as a JSON string
+	var task string
+	if err := json.Unmarshal(body, &task); err != nil || task == "" {
+		return "", fmt.Errorf("unexpected response from paperless: %.200s", body)
+	}
+	return task, nil
+}
+
+// resolve returns the ID of a tag, correspondent or document type given by name or ID
+func (e *paperlessExporter) resolve(ctx context.Context, endpoint, name string) (int, error) {
+	if id, err := strconv.Atoi(name); err == nil {
+		return id, nil
+	}
+
+	key := endpoint + "/" + strings.ToLower(name)
+	e.mutex.Lock()
+	id, ok := e.ids[key]
+	e.mutex.Unlock()
+	if ok {
+		return id, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet,
+		e.config.URL+"/api/"+endpoint+"/?name__iexact="+url.QueryEscape(name), nil)
+	if err != nil {
+		return 0, err
+	}
+	body, err := e.do(req)
+	if err != nil {
+		return 0, err
+	}
+
+	var list struct {
+		Results []struct {
+			ID int `json:"id"`
+		} `json:"results"`
+	}
+	if err := json.Unmarshal(body, &list); err != nil {
+		return 0, fmt.Errorf("unexpected response from paperless: %w", err)
+	}
+	if len(list.Results) == 0 {
+		return 0, fmt.Errorf("paperless has no %s named %q", strings.TrimSuffix(strings.ReplaceAll(endpoint, "_", " "), "s"), name)
+	}
+
+	id = list.Results[0].ID
+	e.mutex.Lock()
+	e.ids[key] = id
+	e.mutex.Unlock()
+	return id, nil
+}
+
+// do sends an authenticated API request and returns the response body
+func (e *paperlessExporter) do(req *http.Request) ([]byte, error) {
+	req.Header.Set("Accept", "application/json")
+	if e.config.Token != "" {
+		req.Header.Set("Authorization", "Token "+e.config.Token)
+	} else if e.config.Username != "" {
+		req.SetBasicAuth(e.config.Username, e.config.Password)
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("paperless returned %s: %.200s", resp.Status, bytes.TrimSpace(body))
+	}
+	return body, nil
+}
+
+// fileName turns a document title into a file name
+func fileName(title, ext string) string {
+	return strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`/\:*?"<>|`, r) || r < 0x20 {
+			return '_'
+		}
+		return r
+	}, title) + strings.ToLower(ext)
+}
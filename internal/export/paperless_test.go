@@ -0,0 +1,211 @@
+package export
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/scanserver/scanner-service/internal/config"
+	"github.com/scanserver/scanner-service/pkg/models"
+)
+
+// postedDocument is a document the fake Paperless received
+type postedDocument struct {
+	fields   map[string][]string
+	filename string
+	content  string
+}
+
+// fakePaperless serves the parts of the Paperless-ngx API the exporter
+// uses: name lookups and post_document, authenticated with a token
+type fakePaperless struct {
+	*httptest.Server
+	token string
+	ids   map[string]int // "endpoint/name" -> ID
+
+	mutex     sync.Mutex
+	lookups   []string // "endpoint/name" in order
+	documents []postedDocument
+}
+
+func newFakePaperless(t *testing.T) *fakePaperless {
+	t.Helper()
+	p := &fakePaperless{
+		token: "t0ken",
+		ids: map[string]int{
+			"tags/invoices":          7,
+			"correspondents/acme":    3,
+			"document_types/invoice": 5,
+		},
+	}
+	p.Server = httptest.NewServer(p)
+	t.Cleanup(p.Close)
+	return p
+}
+
+func (p *fakePaperless) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "Token "+p.token {
+		http.Error(w, `{"detail":"Invalid token."}`, http.StatusUnauthorized)
+		return
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	switch endpoint := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/"), "/"); {
+	case r.Method == http.MethodPost && endpoint == "documents/post_document":
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		file, header, err := r.FormFile("document")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		content, _ := io.ReadAll(file)
+		p.documents = append(p.documents, postedDocument{
+			fields:   r.MultipartForm.Value,
+			filename: header.Filename,
+			content:  string(content),
+		})
+		json.NewEncoder(w).Encode(fmt.Sprintf("task-%d", len(p.documents)))
+	case r.Method == http.MethodGet:
+		key := endpoint + "/" + strings.ToLower(r.URL.Query().Get("name__iexact"))
+		p.lookups = append(p.lookups, key)
+		results := []map[string]int{}
+		if id, ok := p.ids[key]; ok {
+			results = append(results, map[string]int{"id": id})
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"results": results})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func newPaperlessManager(t *testing.T, dest config.ExportDestination) *Manager {
+	t.Helper()
+	dest.Type = "paperless"
+	m, err := NewManager(&config.ExportConfig{
+		MaxAttempts:  2,
+		Destinations: map[string]config.ExportDestination{"paperless": dest},
+	}, testLogger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.retryDelay = time.Millisecond
+	return m
+}
+
+func TestPaperlessPostDocument(t *testing.T) {
+	p := newFakePaperless(t)
+	m := newPaperlessManager(t, config.ExportDestination{
+		URL:           p.URL + "/",
+		Token:         p.token,
+		Format:        "images",
+		Title:         "Invoice {{.Job.ID}} page {{.Page}}",
+		Tags:          []string{"Invoices", "12"},
+		Correspondent: "ACME",
+		DocumentType:  "Invoice",
+	})
+
+	created := time.Date(2025, 11, 10, 9, 30, 0, 0, time.UTC)
+	job := &models.ScanJob{ID: "job-1", CreatedAt: created}
+	results := m.Export(context.Background(), job, models.ExportRequest{Destinations: []string{"paperless"}}, writePages(t, 2))
+
+	if len(results) != 1 || results[0].Status != "sent" || results[0].Detail != p.URL {
+		t.Fatalf("results = %+v", results)
+	}
+	// The task IDs end up in the job's outputs
+	if ids := results[0].TaskIDs; len(ids) != 2 || ids[0] != "task-1" || ids[1] != "task-2" {
+		t.Errorf("task IDs = %v", ids)
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if len(p.documents) != 2 {
+		t.Fatalf("%d documents posted, want 2", len(p.documents))
+	}
+	for i, doc := range p.documents {
+		want := map[string][]string{
+			"title":         {fmt.Sprintf("Invoice job-1 page %d", i+1)},
+			"created":       {"2025-11-10"},
+			"tags":          {"7", "12"},
+			"correspondent": {"3"},
+			"document_type": {"5"},
+		}
+		for field, values := range want {
+			if got := doc.fields[field]; strings.Join(got, ",") != strings.Join(values, ",") {
+				t.Errorf("document %d: %s = %v, want %v", i+1, field, got, values)
+			}
+		}
+		if doc.filename != want["title"][0]+".png" || doc.content != fmt.Sprintf("page %d", i+1) {
+			t.Errorf("document %d: file %s with %q", i+1, doc.filename, doc.content)
+		}
+	}
+
+	// Names are looked up once, IDs are used as they are
+	if got := strings.Join(p.lookups, ","); got != "tags/invoices,correspondents/acme,document_types/invoice" {
+		t.Errorf("lookups = %s", got)
+	}
+}
+
+func TestPaperlessErrors(t *testing.T) {
+	p := newFakePaperless(t)
+	job := &models.ScanJob{ID: "job-1"}
+	req := models.ExportRequest{Destinations: []string{"paperless"}}
+
+	m := newPaperlessManager(t, config.ExportDestination{URL: p.URL, Token: "wrong", Format: "images"})
+	results := m.Export(context.Background(), job, req, writePages(t, 1))
+	if results[0].Status != "failed" || !strings.Contains(results[0].Error, "401") {
+		t.Errorf("wrong token: %+v", results[0])
+	}
+
+	m = newPaperlessManager(t, config.ExportDestination{URL: p.URL, Token: p.token, Format: "images", Tags: []string{"Receipts"}})
+	results = m.Export(context.Background(), job, req, writePages(t, 1))
+	if results[0].Status != "failed" || !strings.Contains(results[0].Error, `paperless has no tag named "Receipts"`) {
+		t.Errorf("unknown tag: %+v", results[0])
+	}
+	if len(p.documents) != 0 {
+		t.Errorf("%d documents posted", len(p.documents))
+	}
+}
+
+func TestPaperlessConsumeDir(t *testing.T) {
+	dir := t.TempDir()
+	m := newPaperlessManager(t, config.ExportDestination{ConsumeDir: dir, Format: "images", Title: "Scan: {{.Date}}"})
+
+	job := &models.ScanJob{ID: "job-1", CreatedAt: time.Date(2025, 11, 10, 0, 0, 0, 0, time.UTC)}
+	// A document of that name is waiting to be consumed already
+	if err := os.WriteFile(filepath.Join(dir, "Scan_ 2025-11-10 (1).png"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	results := m.Export(context.Background(), job, models.ExportRequest{Destinations: []string{"paperless"}}, writePages(t, 2))
+	if len(results) != 1 || results[0].Status != "sent" || results[0].Detail != dir || len(results[0].TaskIDs) != 0 {
+		t.Fatalf("results = %+v", results)
+	}
+
+	for name, want := range map[string]string{
+		"Scan_ 2025-11-10 (1)_2.png": "page 1",
+		"Scan_ 2025-11-10 (2).png":   "page 2",
+	} {
+		got, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil || string(got) != want {
+			t.Errorf("%s = %q, %v", name, got, err)
+		}
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 3 {
+		t.Errorf("%d files in the consume directory, want 3", len(entries))
+	}
+}
@@ -0,0 +1,466 @@
+package export
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+
+	"github.com/scanserver/scanner-service/internal/config"
+	"github.com/scanserver/scanner-service/pkg/models"
+)
+
+func init() {
+	Register("sftp", newSFTPExporter)
+}
+
+// SFTP protocol version 3 packet types and flags (draft-ietf-secsh-filexfer-02)
+const (
+	sshFxpInit          = 1
+	sshFxpVersion       = 2
+	sshFxpOpen          = 3
+	sshFxpClose         = 4
+	sshFxpWrite         = 6
+	sshFxpRemove        = 13
+	sshFxpMkdir         = 14
+	sshFxpStat          = 17
+	sshFxpRename        = 18
+	sshFxpStatus        = 101
+	sshFxpHandle        = 102
+	sshFxpAttrs         = 105
+	sshFxpExtended      = 200
+	sshFxpExtendedReply = 201
+
+	sshFxOK         = 0
+	sshFxNoSuchFile = 2
+
+	sshFxfWrite = 0x02
+	sshFxfCreat = 0x08
+	sshFxfTrunc = 0x10
+
+	// posixRename replaces an existing target atomically, unlike SSH_FXP_RENAME
+	posixRename = "posix-rename@openssh.com"
+
+	// sftpChunkSize is the payload of one write request
+	sftpChunkSize = 32 * 1024
+)
+
+// sftpExporter uploads files over SFTP
+type sftpExporter struct {
+	addr   string
+	config *ssh.ClientConfig
+}
+
+// newSFTPExporter creates an SFTP exporter. The server's host key must be
+// configured unless verification is explicitly disabled.
+func newSFTPExporter(cfg config.ExportDestination) (Exporter, error) {
+	if cfg.Host == "" {
+		return nil, fmt.Errorf("host is required")
+	}
+	port := cfg.Port
+	if port == 0 {
+		port = 22
+	}
+
+	var auth []ssh.AuthMethod
+	if cfg.PrivateKey != "" {
+		pem, err := os.ReadFile(cfg.PrivateKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read private key: %w", err)
+		}
+		signer, err := ssh.ParsePrivateKey(pem)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse private key: %w", err)
+		}
+		auth = append(auth, ssh.PublicKeys(signer))
+	}
+	if cfg.Password != "" {
+		auth = append(auth, ssh.Password(cfg.Password))
+	}
+	if len(auth) == 0 {
+		return nil, fmt.Errorf("password or private_key is required")
+	}
+
+	var hostKeyCallback ssh.HostKeyCallback
+	switch {
+	case cfg.HostKey != "":
+		key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(cfg.HostKey))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse host_key: %w", err)
+		}
+		hostKeyCallback = ssh.FixedHostKey(key)
+	case cfg.InsecureSkipVerify:
+		hostKeyCallback = ssh.InsecureIgnoreHostKey()
+	default:
+		return nil, fmt.Errorf("host_key is required (or set insecure_skip_verify)")
+	}
+
+	return &sftpExporter{
+		addr: net.JoinHostPort(cfg.Host, strconv.Itoa(port)),
+		config: &ssh.ClientConfig{
+			User:            cfg.Username,
+			Auth:            auth,
+			HostKeyCallback: hostKeyCallback,
+			Timeout:         timeout(cfg),
+		},
+	}, nil
+}
+
+// Upload implements Exporter
+func (e *sftpExporter) Upload(ctx context.Context, job *models.ScanJob, files []File) (Receipt, error) {
+	client, stop, err := e.dial(ctx)
+	if err != nil {
+		return Receipt{}, err
+	}
+	defer stop()
+	defer client.Close()
+
+	sftp, err := newSFTPClient(client)
+	if err != nil {
+		return Receipt{}, err
+	}
+	defer sftp.close()
+
+	for _, file := range files {
+		if err := sftp.upload(file); err != nil {
+			return Receipt{}, fmt.Errorf("%s: %w", file.Remote, err)
+		}
+	}
+
+	return Receipt{Location: fmt.Sprintf("sftp://%s/%s", e.addr, strings.TrimPrefix(dirOf(files), "/"))}, nil
+}
+
+// Ping implements Exporter. It starts an SFTP session but transfers nothing.
+func (e *sftpExporter) Ping(ctx context.Context) error {
+	client, stop, err := e.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer stop()
+	defer client.Close()
+
+	sftp, err := newSFTPClient(client)
+	if err != nil {
+		return err
+	}
+	sftp.close()
+	return nil
+}
+
+// dial connects and authenticates to the server. The connection is closed
+// when ctx is cancelled, until stop is called.
+func (e *sftpExporter) dial(ctx context.Context) (client *ssh.Client, stop func() bool, err error) {
+	conn, err := (&net.Dialer{Timeout: e.config.Timeout}).DialContext(ctx, "tcp", e.addr)
+	if err != nil {
+		return nil, nil, err
+	}
+	stop = context.AfterFunc(ctx, func() { conn.Close() })
+
+	sshConn, chans, reqs, err := ssh.NewClientConn(conn, e.addr, e.config)
+	if err != nil {
+		stop()
+		conn.Close()
+		return nil, nil, err
+	}
+	return ssh.NewClient(sshConn, chans, reqs), stop, nil
+}
+
+// sftpClient is a minimal SFTP client: enough to create directories and write files.
+// Requests are sent one at a time.
+type sftpClient struct {
+	session    *ssh.Session
+	w          io.WriteCloser
+	r          io.Reader
+	nextID     uint32
+	extensions map[string]string
+}
+
+// sftpStatusError is an SSH_FXP_STATUS response other than SSH_FX_OK
+type sftpStatusError struct {
+	code    uint32
+	message string
+}
+
+func (e *sftpStatusError) Error() string {
+	return fmt.Sprintf("sftp: %s (code %d)", e.message, e.code)
+}
+
+// newSFTPClient starts the sftp subsystem and negotiates version 3
+func newSFTPClient(client *ssh.Client) (*sftpClient, error) {
+	session, err := client.NewSession()
+	if err != nil {
+		return nil, err
+	}
+	w, err := session.StdinPipe()
+	if err != nil {
+		session.Close()
+		return nil, err
+	}
+	r, err := session.StdoutPipe()
+	if err != nil {
+		session.Close()
+		return nil, err
+	}
+	if err := session.RequestSubsystem("sftp"); err != nil {
+		session.Close()
+		return nil, err
+	}
+
+	c := &sftpClient{session: session, w: w, r: r, extensions: make(map[string]string)}
+
+	if err := c.send(sshFxpInit, uint32(3)); err != nil {
+		c.close()
+		return nil, err
+	}
+	typ, data, err := c.recv()
+	if err != nil {
+		c.close()
+		return nil, err
+	}
+	if typ != sshFxpVersion {
+		c.close()
+		return nil, fmt.Errorf("sftp: unexpected packet %d during init", typ)
+	}
+
+	// Version, then extension name/data pairs
+	buf := &sftpBuffer{data: data}
+	buf.uint32()
+	for len(buf.data) > 0 && buf.err == nil {
+		name, value := buf.string(), buf.string()
+		c.extensions[name] = value
+	}
+	if buf.err != nil {
+		c.close()
+		return nil, buf.err
+	}
+	return c, nil
+}
+
+// close ends the sftp session
+func (c *sftpClient) close() {
+	c.w.Close()
+	c.session.Close()
+}
+
+// upload writes a file to a temporary name and renames it into place,
+// so that readers of the remote folder never see partial files
+func (c *sftpClient) upload(file File) error {
+	if err := c.mkdirAll(path.Dir(file.Remote)); err != nil {
+		return err
+	}
+
+	f, err := os.Open(file.Local)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	partial := file.Remote + ".part"
+	handle, err := c.open(partial)
+	if err != nil {
+		return err
+	}
+
+	buf := make([]byte, sftpChunkSize)
+	var offset uint64
+	for {
+		n, readErr := f.Read(buf)
+		if n > 0 {
+			if err := c.status(c.request(sshFxpWrite, handle, offset, buf[:n])); err != nil {
+				c.request(sshFxpClose, handle)
+				return err
+			}
+			offset += uint64(n)
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			c.request(sshFxpClose, handle)
+			return readErr
+		}
+	}
+
+	if err := c.status(c.request(sshFxpClose, handle)); err != nil {
+		return err
+	}
+	return c.rename(partial, file.Remote)
+}
+
+// open opens a file for writing, creating or truncating it, and returns its handle
+func (c *sftpClient) open(name string) (string, error) {
+	typ, data, err := c.request(sshFxpOpen, name, uint32(sshFxfWrite|sshFxfCreat|sshFxfTrunc), uint32(0))
+	if err != nil {
+		return "", err
+	}
+	if typ != sshFxpHandle {
+		if err := c.status(typ, data, nil); err != nil {
+			return "", err
+		}
+		return "", fmt.Errorf("sftp: no handle for %s", name)
+	}
+	buf := &sftpBuffer{data: data}
+	handle := buf.string()
+	return handle, buf.err
+}
+
+// rename moves a file, replacing the target if it exists
+func (c *sftpClient) rename(from, to string) error {
+	if _, ok := c.extensions[posixRename]; ok {
+		return c.status(c.request(sshFxpExtended, posixRename, from, to))
+	}
+
+	// Plain SSH_FXP_RENAME fails if the target exists
+	if err := c.status(c.request(sshFxpRemove, to)); err != nil && !isNoSuchFile(err) {
+		return err
+	}
+	return c.status(c.request(sshFxpRename, from, to))
+}
+
+// mkdirAll creates a directory and its missing parents
+func (c *sftpClient) mkdirAll(dir string) error {
+	if dir == "." || dir == "/" || dir == "" {
+		return nil
+	}
+
+	typ, data, err := c.request(sshFxpStat, dir)
+	if err != nil {
+		return err
+	}
+	if typ == sshFxpAttrs {
+		return nil
+	}
+	if err := c.status(typ, data, nil); !isNoSuchFile(err) {
+		return err
+	}
+
+	if err := c.mkdirAll(path.Dir(dir)); err != nil {
+		return err
+	}
+	return c.status(c.request(sshFxpMkdir, dir, uint32(0)))
+}
+
+// request sends a request with the next id and waits for its response
+func (c *sftpClient) request(typ byte, args ...interface{}) (byte, []byte, error) {
+	c.nextID++
+	id := c.nextID
+	if err := c.send(typ, append([]interface{}{id}, args...)...); err != nil {
+		return 0, nil, err
+	}
+
+	respType, data, err := c.recv()
+	if err != nil {
+		return 0, nil, err
+	}
+	buf := &sftpBuffer{data: data}
+	if respID := buf.uint32(); buf.err != nil || respID != id {
+		return 0, nil, fmt.Errorf("sftp: response to request %d, expected %d", respID, id)
+	}
+	return respType, buf.data, nil
+}
+
+// status converts an SSH_FXP_STATUS response into an error
+func (c *sftpClient) status(typ byte, data []byte, err error) error {
+	if err != nil {
+		return err
+	}
+	switch typ {
+	case sshFxpStatus:
+		buf := &sftpBuffer{data: data}
+		code, message := buf.uint32(), buf.string()
+		if buf.err != nil {
+			return buf.err
+		}
+		if code == sshFxOK {
+			return nil
+		}
+		return &sftpStatusError{code: code, message: message}
+	case sshFxpExtendedReply:
+		return nil
+	default:
+		return fmt.Errorf("sftp: unexpected packet %d", typ)
+	}
+}
+
+// send writes a packet. Arguments are encoded as SSH wire types.
+func (c *sftpClient) send(typ byte, args ...interface{}) error {
+	payload := []byte{typ}
+	for _, arg := range args {
+		switch v := arg.(type) {
+		case uint32:
+			payload = binary.BigEndian.AppendUint32(payload, v)
+		case uint64:
+			payload = binary.BigEndian.AppendUint64(payload, v)
+		case string:
+			payload = binary.BigEndian.AppendUint32(payload, uint32(len(v)))
+			payload = append(payload, v...)
+		case []byte:
+			payload = binary.BigEndian.AppendUint32(payload, uint32(len(v)))
+			payload = append(payload, v...)
+		default:
+			return fmt.Errorf("sftp: cannot encode %T", arg)
+		}
+	}
+
+	packet := binary.BigEndian.AppendUint32(make([]byte, 0, 4+len(payload)), uint32(len(payload)))
+	_, err := c.w.Write(append(packet, payload...))
+	return err
+}
+
+// recv reads a packet and returns its type and payload
+func (c *sftpClient) recv() (byte, []byte, error) {
+	var header [5]byte
+	if _, err := io.ReadFull(c.r, header[:]); err != nil {
+		return 0, nil, err
+	}
+	length := binary.BigEndian.Uint32(header[:4])
+	if length < 1 || length > 256*1024 {
+		return 0, nil, fmt.Errorf("sftp: invalid packet length %d", length)
+	}
+	data := make([]byte, length-1)
+	if _, err := io.ReadFull(c.r, data); err != nil {
+		return 0, nil, err
+	}
+	return header[4], data, nil
+}
+
+// sftpBuffer decodes SSH wire types, remembering the first error
+type sftpBuffer struct {
+	data []byte
+	err  error
+}
+
+func (b *sftpBuffer) uint32() uint32 {
+	if b.err != nil || len(b.data) < 4 {
+		b.err = errors.New("sftp: short packet")
+		return 0
+	}
+	v := binary.BigEndian.Uint32(b.data)
+	b.data = b.data[4:]
+	return v
+}
+
+func (b *sftpBuffer) string() string {
+	n := b.uint32()
+	if b.err != nil || uint32(len(b.data)) < n {
+		b.err = errors.New("sftp: short packet")
+		return ""
+	}
+	v := string(b.data[:n])
+	b.data = b.data[n:]
+	return v
+}
+
+// isNoSuchFile reports whether err is an SSH_FX_NO_SUCH_FILE status
+func isNoSuchFile(err error) bool {
+	var status *sftpStatusError
+	return errors.As(err, &status) && status.code == sshFxNoSuchFile
+}
@@ -0,0 +1,177 @@
+// Package health runs the component checks behind the liveness and
+// readiness endpoints
+package health
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Component and overall statuses, from best to worst
+const (
+	StatusOK       = "ok"
+	StatusDegraded = "degraded" // Working with reduced function, e.g. an export destination is unreachable
+	StatusDown     = "down"     // Not working; the server should not receive traffic
+)
+
+// severity orders the statuses
+var severity = map[string]int{StatusOK: 0, StatusDegraded: 1, StatusDown: 2}
+
+// Result is the state of one component
+type Result struct {
+	Status   string    `json:"status"`
+	Detail   string    `json:"detail,omitempty"`
+	Error    string    `json:"error,omitempty"`
+	Duration float64   `json:"duration_ms"`
+	Checked  time.Time `json:"checked_at"`
+}
+
+// OK returns a passing result
+func OK(detail string) Result {
+	return Result{Status: StatusOK, Detail: detail}
+}
+
+// Degraded returns a result for a component that works only partly
+func Degraded(err error) Result {
+	return Result{Status: StatusDegraded, Error: err.Error()}
+}
+
+// Down returns a failing result
+func Down(err error) Result {
+	return Result{Status: StatusDown, Error: err.Error()}
+}
+
+// Check reports the state of one component. It should return once ctx is done.
+type Check func(ctx context.Context) Result
+
+// Report is the outcome of all checks. Its status is the worst of its components.
+type Report struct {
+	Status     string            `json:"status"`
+	Time       time.Time         `json:"time"`
+	Components map[string]Result `json:"components"`
+}
+
+// Checker runs a set of named checks
+type Checker struct {
+	timeout  time.Duration
+	names    []string
+	checks   map[string]Check
+	optional map[string]bool
+}
+
+// NewChecker creates a checker whose checks each get timeout to complete
+func NewChecker(timeout time.Duration) *Checker {
+	return &Checker{
+		timeout:  timeout,
+		checks:   make(map[string]Check),
+		optional: make(map[string]bool),
+	}
+}
+
+// Add registers a check under a component name
+func (c *Checker) Add(name string, check Check) {
+	if _, ok := c.checks[name]; !ok {
+		c.names = append(c.names, name)
+	}
+	c.checks[name] = check
+}
+
+// AddOptional registers a check of a component the server can work without.
+// When it is down, the report is only degraded.
+func (c *Checker) AddOptional(name string, check Check) {
+	c.Add(name, check)
+	c.optional[name] = true
+}
+
+// Run runs all checks concurrently. A check still running after the timeout
+// is reported down and left to finish in the background.
+func (c *Checker) Run(ctx context.Context) Report {
+	report := Report{
+		Status:     StatusOK,
+		Time:       time.Now(),
+		Components: make(map[string]Result, len(c.names)),
+	}
+
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	for _, name := range c.names {
+		wg.Add(1)
+		go func(name string, check Check, optional bool) {
+			defer wg.Done()
+			result := c.run(ctx, check)
+			status := result.Status
+			if optional && status == StatusDown {
+				status = StatusDegraded
+			}
+
+			mutex.Lock()
+			report.Components[name] = result
+			if severity[status] > severity[report.Status] {
+				report.Status = status
+			}
+			mutex.Unlock()
+		}(name, c.checks[name], c.optional[name])
+	}
+	wg.Wait()
+	return report
+}
+
+// run runs one check within the timeout
+func (c *Checker) run(ctx context.Context, check Check) Result {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan Result, 1)
+	go func() { done <- check(ctx) }()
+
+	var result Result
+	select {
+	case result = <-done:
+		if result.Checked.IsZero() {
+			result.stamp(start)
+		}
+	case <-ctx.Done():
+		result = Down(fmt.Errorf("no answer within %s", c.timeout))
+		result.stamp(start)
+	}
+	return result
+}
+
+// stamp records when a check started and how long it took
+func (r *Result) stamp(start time.Time) {
+	r.Checked = start
+	r.Duration = float64(time.Since(start).Microseconds()) / 1000
+}
+
+// Cached wraps a check that is too expensive to run on every probe. Its
+// result is reused until it is older than interval; the check runs at most
+// once at a time, other callers get the previous result meanwhile.
+func Cached(interval time.Duration, check Check) Check {
+	var mutex sync.Mutex
+	var last *Result
+	running := false
+
+	return func(ctx context.Context) Result {
+		mutex.Lock()
+		if last != nil && (running || time.Since(last.Checked) < interval) {
+			result := *last
+			mutex.Unlock()
+			return result
+		}
+		running = true
+		mutex.Unlock()
+
+		start := time.Now()
+		result := check(ctx)
+		result.stamp(start)
+
+		mutex.Lock()
+		last = &result
+		running = false
+		mutex.Unlock()
+		return result
+	}
+}
@@ -0,0 +1,205 @@
+// Package hotfolder imports files dropped into a watched directory
+package hotfolder
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+
+	"github.com/scanserver/scanner-service/internal/config"
+	"github.com/scanserver/scanner-service/internal/document"
+	"github.com/scanserver/scanner-service/internal/logging"
+)
+
+// Watcher hands files dropped into the hot folder to an import function once
+// they are completely written, then moves them to the done or failed directory
+type Watcher struct {
+	config   *config.HotFolderConfig
+	importFn func(path string) error
+	logger   *slog.Logger
+
+	doneDir   string
+	failedDir string
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewWatcher creates a watcher for the configured hot folder. importFn is
+// called for one file at a time and returns once the file has been processed.
+func NewWatcher(cfg *config.HotFolderConfig, importFn func(path string) error, logger *slog.Logger) *Watcher {
+	w := &Watcher{
+		config:    cfg,
+		importFn:  importFn,
+		logger:    logger,
+		doneDir:   cfg.DoneDir,
+		failedDir: cfg.FailedDir,
+	}
+	if w.doneDir == "" {
+		w.doneDir = filepath.Join(cfg.Path, "done")
+	}
+	if w.failedDir == "" {
+		w.failedDir = filepath.Join(cfg.Path, "failed")
+	}
+	return w
+}
+
+// Start watches the hot folder until Stop is called. Files already in the
+// folder, e.g. dropped while the service was down, are imported as well.
+func (w *Watcher) Start() error {
+	if !w.config.Enabled {
+		return nil
+	}
+
+	for _, dir := range []string{w.config.Path, w.doneDir, w.failedDir} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("failed to create %s: %w", dir, err)
+		}
+	}
+
+	fsw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	if err := fsw.Add(w.config.Path); err != nil {
+		fsw.Close()
+		return fmt.Errorf("failed to watch %s: %w", w.config.Path, err)
+	}
+
+	w.stop = make(chan struct{})
+	w.done = make(chan struct{})
+	queue := make(chan string, 64)
+
+	// Imports run one at a time, off the event loop
+	go func() {
+		for path := range queue {
+			w.process(path)
+		}
+	}()
+
+	go func() {
+		defer close(w.done)
+		defer close(queue)
+		defer fsw.Close()
+
+		settle := time.Duration(w.config.SettleDelay) * time.Second
+		if settle <= 0 {
+			settle = time.Second
+		}
+
+		// Files being written, by last change
+		pending := make(map[string]time.Time)
+		queued := make(map[string]bool)
+
+		if entries, err := os.ReadDir(w.config.Path); err == nil {
+			for _, entry := range entries {
+				pending[filepath.Join(w.config.Path, entry.Name())] = time.Time{}
+			}
+		}
+
+		ticker := time.NewTicker(settle / 2)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case event, ok := <-fsw.Events:
+				if !ok {
+					return
+				}
+				if event.Has(fsnotify.Create) || event.Has(fsnotify.Write) {
+					pending[event.Name] = time.Now()
+				}
+			case err, ok := <-fsw.Errors:
+				if !ok {
+					return
+				}
+				w.logger.Error("Hot folder watch error", logging.Err(err))
+			case <-ticker.C:
+				for path, changed := range pending {
+					if time.Since(changed) < settle {
+						continue
+					}
+					delete(pending, path)
+
+					info, err := os.Stat(path)
+					if err != nil || !info.Mode().IsRegular() || ignored(path) {
+						continue
+					}
+					// Moving the file out of the folder takes it off the queue
+					if queued[path] {
+						continue
+					}
+					queued[path] = true
+					select {
+					case queue <- path:
+					case <-w.stop:
+						return
+					}
+				}
+				for path := range queued {
+					if _, err := os.Stat(path); os.IsNotExist(err) {
+						delete(queued, path)
+					}
+				}
+			case <-w.stop:
+				return
+			}
+		}
+	}()
+
+	w.logger.Info("Hot folder enabled", "path", w.config.Path)
+	return nil
+}
+
+// Stop stops watching. An import in progress is completed in the background.
+func (w *Watcher) Stop() {
+	if w.stop == nil {
+		return
+	}
+	close(w.stop)
+	<-w.done
+	w.stop = nil
+}
+
+// process imports a file and moves it out of the hot folder
+func (w *Watcher) process(path string) {
+	target := w.doneDir
+	if !document.CanImport(path) {
+		w.logger.Warn("Hot folder file is not a supported type", "file", filepath.Base(path))
+		target = w.failedDir
+	} else if err := w.importFn(path); err != nil {
+		w.logger.Warn("Hot folder import failed", "file", filepath.Base(path), logging.Err(err))
+		target = w.failedDir
+	}
+
+	if err := move(path, target); err != nil {
+		w.logger.Error("Failed to move hot folder file", "file", filepath.Base(path), "target", target, logging.Err(err))
+	}
+}
+
+// ignored reports whether a file is hidden or still being uploaded under a temporary name
+func ignored(path string) bool {
+	name := filepath.Base(path)
+	if strings.HasPrefix(name, ".") || strings.HasPrefix(name, "~") {
+		return true
+	}
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".part", ".tmp", ".crdownload":
+		return true
+	}
+	return false
+}
+
+// move moves a file into dir, prefixing its name with a timestamp if the name is taken
+func move(path, dir string) error {
+	target := filepath.Join(dir, filepath.Base(path))
+	if _, err := os.Stat(target); err == nil {
+		target = filepath.Join(dir, time.Now().Format("20060102-150405.000")+"-"+filepath.Base(path))
+	}
+	return os.Rename(path, target)
+}
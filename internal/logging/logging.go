@@ -0,0 +1,78 @@
+// Package logging builds the structured logger and carries it, with the job,
+// scanner and request IDs it is tagged with, through contexts
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+
+	"github.com/scanserver/scanner-service/internal/config"
+)
+
+// Attribute keys used to correlate log records
+const (
+	KeyJobID      = "job_id"
+	KeyScannerID  = "scanner_id"
+	KeyRequestID  = "request_id"
+	KeyScheduleID = "schedule_id"
+)
+
+// New returns a logger writing to w with the configured level and format
+func New(cfg *config.LogConfig, w io.Writer) (*slog.Logger, error) {
+	var level slog.Level
+	switch strings.ToLower(cfg.Level) {
+	case "debug":
+		level = slog.LevelDebug
+	case "", "info":
+		level = slog.LevelInfo
+	case "warn", "warning":
+		level = slog.LevelWarn
+	case "error":
+		level = slog.LevelError
+	default:
+		return nil, fmt.Errorf("log.level must be debug, info, warn or error, got %q", cfg.Level)
+	}
+
+	opts := &slog.HandlerOptions{Level: level}
+	switch strings.ToLower(cfg.Format) {
+	case "", "text":
+		return slog.New(slog.NewTextHandler(w, opts)), nil
+	case "json":
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	default:
+		return nil, fmt.Errorf("log.format must be text or json, got %q", cfg.Format)
+	}
+}
+
+type contextKey struct{}
+
+// WithLogger returns a copy of ctx carrying logger
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, logger)
+}
+
+// Lookup returns the logger carried by ctx, if any
+func Lookup(ctx context.Context) (*slog.Logger, bool) {
+	logger, ok := ctx.Value(contextKey{}).(*slog.Logger)
+	return logger, ok
+}
+
+// FromContext returns the logger carried by ctx, or fallback if it has none.
+// A nil fallback means slog.Default().
+func FromContext(ctx context.Context, fallback *slog.Logger) *slog.Logger {
+	if logger, ok := Lookup(ctx); ok {
+		return logger
+	}
+	if fallback != nil {
+		return fallback
+	}
+	return slog.Default()
+}
+
+// Err is the attribute errors are logged under
+func Err(err error) slog.Attr {
+	return slog.Any("error", err)
+}
@@ -0,0 +1,73 @@
+package logging
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/scanserver/scanner-service/pkg/models"
+)
+
+// HeaderRequestID carries the request ID in both directions
+const HeaderRequestID = "X-Request-ID"
+
+// maxRequestIDLength caps request IDs accepted from clients
+const maxRequestIDLength = 128
+
+// Middleware tags every request with an ID, taken from the client's
+// X-Request-ID header when it is usable or generated otherwise, and echoes it
+// in the response. Handlers find a logger carrying the ID in the request
+// context. Each request is logged once it completes.
+func Middleware(logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		id := c.GetHeader(HeaderRequestID)
+		if !validRequestID(id) {
+			id = models.GenerateUUID()
+		}
+		c.Header(HeaderRequestID, id)
+
+		requestLogger := logger.With(KeyRequestID, id)
+		c.Request = c.Request.WithContext(WithLogger(c.Request.Context(), requestLogger))
+
+		c.Next()
+
+		status := c.Writer.Status()
+		level := slog.LevelInfo
+		switch {
+		case status >= 500:
+			level = slog.LevelError
+		case status >= 400:
+			level = slog.LevelWarn
+		}
+		attrs := []slog.Attr{
+			slog.String("method", c.Request.Method),
+			slog.String("path", c.Request.URL.Path),
+			slog.Int("status", status),
+			slog.Duration("duration", time.Since(start)),
+			slog.String("client_ip", c.ClientIP()),
+		}
+		if len(c.Errors) > 0 {
+			attrs = append(attrs, slog.String("error", c.Errors.String()))
+		}
+		requestLogger.LogAttrs(c.Request.Context(), level, "request", attrs...)
+	}
+}
+
+// validRequestID accepts short IDs made of characters safe to log and echo
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, r := range id {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case r == '-' || r == '_' || r == '.' || r == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
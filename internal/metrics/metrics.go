@@ -0,0 +1,90 @@
+// Package metrics defines the Prometheus metrics exported on /metrics
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Namespace prefixes every metric name
+const Namespace = "scanserver"
+
+// Post-processing stages, the values of the "stage" label of PostProcessDuration
+const (
+	StageBlankDetection = "blank_detection"
+	StageScale          = "scale"
+	StageCrop           = "crop"
+	StageQuality        = "quality"
+)
+
+var (
+	// JobsFinished counts jobs by scanner and final status
+	JobsFinished = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "jobs_finished_total",
+		Help:      "Jobs that reached a final status, by scanner and status.",
+	}, []string{"scanner", "status"})
+
+	// PagesScanned counts pages returned by scanner drivers
+	PagesScanned = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "pages_scanned_total",
+		Help:      "Pages scanned, by scanner.",
+	}, []string{"scanner"})
+
+	// PageScanDuration is the time a driver scan took divided by the pages it returned
+	PageScanDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: Namespace,
+		Name:      "page_scan_duration_seconds",
+		Help:      "Scan time per page, by scanner.",
+		Buckets:   []float64{0.25, 0.5, 1, 2, 3, 5, 10, 20, 30, 60},
+	}, []string{"scanner"})
+
+	// PostProcessDuration is the latency of each image post-processing stage per page
+	PostProcessDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: Namespace,
+		Name:      "postprocess_duration_seconds",
+		Help:      "Latency of image post-processing stages per page.",
+		Buckets:   []float64{0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5},
+	}, []string{"stage"})
+
+	// BlankPagesDropped counts pages removed by blank page detection
+	BlankPagesDropped = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "blank_pages_dropped_total",
+		Help:      "Pages dropped by blank page detection.",
+	})
+
+	// DriverErrors counts failed driver scans by scanner and error code
+	DriverErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "driver_errors_total",
+		Help:      "Failed driver scans, by scanner and error code (e.g. paper_jam, feeder_empty).",
+	}, []string{"scanner", "reason"})
+
+	// BatchScans counts batch scans by type and outcome
+	BatchScans = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "batch_scans_total",
+		Help:      "Batch scans performed, by scan type and result (completed, failed, cancelled).",
+	}, []string{"type", "result"})
+)
+
+// ObserveStage records the time since start as the latency of a post-processing stage
+func ObserveStage(stage string, start time.Time) {
+	PostProcessDuration.WithLabelValues(stage).Observe(time.Since(start).Seconds())
+}
+
+// Register adds a collector to the registry served by Handler
+func Register(collector prometheus.Collector) error {
+	return prometheus.Register(collector)
+}
+
+// Handler serves all registered metrics in the Prometheus text format
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
@@ -2,10 +2,13 @@ package scanner
 
 import (
 	"context"
-	"log"
+	"log/slog"
 	"time"
 
 	"github.com/scanserver/scanner-service/internal/config"
+	"github.com/scanserver/scanner-service/internal/logging"
+	"github.com/scanserver/scanner-service/internal/storage"
+	"github.com/scanserver/scanner-service/internal/tracing"
 	"github.com/scanserver/scanner-service/pkg/models"
 )
 
@@ -16,10 +19,11 @@ type AutoScanManager struct {
 	scanCallback  func(*models.ScanJob)
 	ctx           context.Context
 	cancel        context.CancelFunc
+	logger        *slog.Logger
 }
 
 // NewAutoScanManager creates a new auto-scan manager
-func NewAutoScanManager(manager *Manager, cfg *config.AutoScanConfig, scanCallback func(*models.ScanJob)) *AutoScanManager {
+func NewAutoScanManager(manager *Manager, cfg *config.AutoScanConfig, scanCallback func(*models.ScanJob), logger *slog.Logger) *AutoScanManager {
 	ctx, cancel := context.WithCancel(context.Background())
 
 	return &AutoScanManager{
@@ -28,13 +32,14 @@ func NewAutoScanManager(manager *Manager, cfg *config.AutoScanConfig, scanCallba
 		scanCallback: scanCallback,
 		ctx:          ctx,
 		cancel:       cancel,
+		logger:       logger,
 	}
 }
 
 // Start starts monitoring for lid close events
 func (a *AutoScanManager) Start() error {
 	if !a.config.Enabled {
-		log.Println("Auto-scan is disabled")
+		a.logger.Info("Auto-scan is disabled")
 		return nil
 	}
 
@@ -45,7 +50,7 @@ func (a *AutoScanManager) Start() error {
 	}
 
 	if len(scanners) == 0 {
-		log.Println("No scanners available for auto-scan")
+		a.logger.Warn("No scanners available for auto-scan")
 		return nil
 	}
 
@@ -59,7 +64,7 @@ func (a *AutoScanManager) Start() error {
 			}
 		}
 		if targetScanner == nil {
-			log.Printf("Specified scanner %s not found, using first available", a.config.ScannerID)
+			a.logger.Warn("Auto-scan scanner not found, using first available", logging.KeyScannerID, a.config.ScannerID)
 		}
 	}
 
@@ -67,7 +72,7 @@ func (a *AutoScanManager) Start() error {
 		targetScanner = &scanners[0]
 	}
 
-	log.Printf("Starting auto-scan monitoring for scanner: %s (%s)", targetScanner.Name, targetScanner.ID)
+	a.logger.Info("Starting auto-scan monitoring", "scanner", targetScanner.Name, logging.KeyScannerID, targetScanner.ID)
 
 	// Start watching lid status
 	err = a.manager.WatchLidStatus(a.ctx, targetScanner.ID, func(lidClosed bool) {
@@ -85,8 +90,8 @@ func (a *AutoScanManager) Start() error {
 
 // handleLidClosed handles lid close event
 func (a *AutoScanManager) handleLidClosed(scannerID string) {
-	log.Printf("Lid closed detected on scanner %s, waiting %d seconds before scanning...",
-		scannerID, a.config.LidCloseDelay)
+	a.logger.Info("Lid closed, waiting before scanning",
+		logging.KeyScannerID, scannerID, "delay_seconds", a.config.LidCloseDelay)
 
 	// Wait for configured delay
 	time.Sleep(time.Duration(a.config.LidCloseDelay) * time.Second)
@@ -113,7 +118,7 @@ func (a *AutoScanManager) handleLidClosed(scannerID string) {
 		CreatedAt: time.Now(),
 	}
 
-	log.Printf("Starting auto-scan job %s", job.ID)
+	a.logger.Info("Starting auto-scan job", logging.KeyJobID, job.ID, logging.KeyScannerID, scannerID)
 
 	// Execute scan
 	go a.executeScan(job)
@@ -121,6 +126,9 @@ func (a *AutoScanManager) handleLidClosed(scannerID string) {
 
 // executeScan executes the scan job
 func (a *AutoScanManager) executeScan(job *models.ScanJob) {
+	logger := a.logger.With(logging.KeyJobID, job.ID, logging.KeyScannerID, job.ScannerID)
+	ctx, span := tracing.StartJob(logging.WithLogger(a.ctx, logger), "job.autoscan", job.CreatedAt,
+		tracing.KeyJobID.String(job.ID), tracing.KeyScannerID.String(job.ScannerID))
 	job.Status = "processing"
 
 	// Notify callback
@@ -131,29 +139,41 @@ func (a *AutoScanManager) executeScan(job *models.ScanJob) {
 	// Progress callback
 	progressCallback := func(progress int) {
 		job.Progress = progress
-		log.Printf("Auto-scan job %s progress: %d%%", job.ID, progress)
+		logger.Debug("Auto-scan progress", "progress", progress)
 
 		if a.scanCallback != nil {
 			a.scanCallback(job)
 		}
 	}
 
+	// Configured defaults may not match the scanner, so adapt them rather than fail
+	err := a.manager.ValidateParams(a.ctx, job.ScannerID, &job.Parameters, ValidationCoerce)
+
 	// Execute scan
-	results, err := a.manager.Scan(a.ctx, job.ScannerID, job.Parameters, progressCallback)
+	var results []models.ScanResult
+	if err == nil {
+		results, err = a.manager.Scan(storage.WithJob(ctx, job.ID, job.CreatedAt), job.ScannerID, job.Parameters, progressCallback)
+	}
 
 	if err != nil {
 		job.Status = "failed"
 		job.Error = err.Error()
-		log.Printf("Auto-scan job %s failed: %v", job.ID, err)
+		job.ErrorCode = ErrorCode(err)
+		logger.Error("Auto-scan job failed", logging.Err(err))
 	} else {
+		if err := a.manager.ArchiveResults(ctx, job, results); err != nil {
+			logger.Error("Failed to archive auto-scan job", logging.Err(err))
+		}
+
 		job.Status = "completed"
 		job.Results = results
 		job.Progress = 100
-		log.Printf("Auto-scan job %s completed successfully, %d pages scanned", job.ID, len(results))
+		logger.Info("Auto-scan job completed", "pages", len(results))
 	}
 
 	now := time.Now()
 	job.CompletedAt = &now
+	tracing.End(span, err)
 
 	// Final callback
 	if a.scanCallback != nil {
@@ -163,6 +183,6 @@ func (a *AutoScanManager) executeScan(job *models.ScanJob) {
 
 // Stop stops auto-scan monitoring
 func (a *AutoScanManager) Stop() {
-	log.Println("Stopping auto-scan manager")
+	a.logger.Info("Stopping auto-scan manager")
 	a.cancel()
 }
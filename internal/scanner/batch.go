@@ -2,28 +2,46 @@ package scanner
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"strings"
 	"time"
 
+	"github.com/scanserver/scanner-service/internal/logging"
+	"github.com/scanserver/scanner-service/internal/metrics"
+	"github.com/scanserver/scanner-service/internal/storage"
 	"github.com/scanserver/scanner-service/pkg/models"
 )
 
+// ManualDuplexPrompt asks the user to turn the stack over between the passes of a manual duplex scan
+const ManualDuplexPrompt = "Take the scanned pages from the output tray, turn the stack over without reordering it, and put it back in the feeder"
+
 // BatchScanPerformer performs batch scanning operations
 // Implements NAPS2's batch scanning workflow (BatchScanPerformer.cs)
 type BatchScanPerformer struct {
 	driver ScannerDriver
+	prompt func(ctx context.Context, message string) error
+	logger *slog.Logger
 }
 
 // NewBatchScanPerformer creates a new batch scan performer
-func NewBatchScanPerformer(driver ScannerDriver) *BatchScanPerformer {
+func NewBatchScanPerformer(driver ScannerDriver, logger *slog.Logger) *BatchScanPerformer {
 	return &BatchScanPerformer{
 		driver: driver,
+		logger: logger,
 	}
 }
 
+// OnPrompt sets the function that asks the user to act between scan passes
+// and blocks until they have. Returning an error aborts the batch.
+// Manual duplex scans require it.
+func (b *BatchScanPerformer) OnPrompt(prompt func(ctx context.Context, message string) error) {
+	b.prompt = prompt
+}
+
 // PerformBatchScan executes a batch scan according to settings
 // Implements NAPS2's PerformBatchScan method (BatchScanPerformer.cs:36-42)
 func (b *BatchScanPerformer) PerformBatchScan(
@@ -32,16 +50,39 @@ func (b *BatchScanPerformer) PerformBatchScan(
 	settings models.BatchSettings,
 	progressCallback func(models.BatchScanProgress),
 ) ([][]models.ScanResult, error) {
+	// All passes of a batch write into the same job directory
+	ctx = withScanLogger(ensureJob(ctx), b.logger, scannerID)
+	logger := logging.FromContext(ctx, b.logger)
+	logger.Info("Batch scan started", "type", settings.ScanType, "output", settings.OutputType)
+
 	state := &batchState{
 		driver:           b.driver,
 		scannerID:        scannerID,
 		settings:         settings,
 		progressCallback: progressCallback,
+		prompt:           b.prompt,
 		scans:            make([][]models.ScanResult, 0),
 		ctx:              ctx,
+		logger:           logger,
 	}
 
-	return state.do()
+	scans, err := state.do()
+	result := "completed"
+	switch {
+	case errors.Is(err, ErrCancelled), errors.Is(err, context.Canceled):
+		result = "cancelled"
+	case err != nil:
+		result = "failed"
+	}
+	metrics.BatchScans.WithLabelValues(string(settings.ScanType), result).Inc()
+
+	if err != nil {
+		logger.Warn("Batch scan "+result, "scans", len(scans), logging.Err(err))
+	} else {
+		logger.Info("Batch scan completed", "scans", len(scans))
+	}
+
+	return scans, err
 }
 
 // batchState manages the state of a batch scan operation
@@ -51,8 +92,10 @@ type batchState struct {
 	scannerID        string
 	settings         models.BatchSettings
 	progressCallback func(models.BatchScanProgress)
+	prompt           func(ctx context.Context, message string) error
 	scans            [][]models.ScanResult
 	ctx              context.Context
+	logger           *slog.Logger
 }
 
 // do executes the batch scan workflow
@@ -126,11 +169,81 @@ func (s *batchState) input() error {
 		}
 		return nil
 
+	case models.BatchScanManualDuplex:
+		return s.inputManualDuplex()
+
 	default:
 		return fmt.Errorf("unknown batch scan type: %s", s.settings.ScanType)
 	}
 }
 
+// inputManualDuplex scans both sides of a stack on a simplex feeder: the
+// fronts, then (after the user turns the stack over) the backs, which come
+// out last page first. The passes are merged into one duplex document and
+// blank backs are dropped.
+func (s *batchState) inputManualDuplex() error {
+	if s.prompt == nil {
+		return fmt.Errorf("manual duplex requires a way to prompt the user")
+	}
+
+	// Both passes go through the feeder one side at a time. Blank pages are
+	// kept while scanning so the two passes stay aligned.
+	params := s.settings.ScanParams
+	s.settings.ScanParams.UseFeeder = true
+	s.settings.ScanParams.UseDuplex = false
+	s.settings.ScanParams.ExcludeBlankPages = false
+	s.settings.ScanCount = 2
+
+	s.sendProgress("scanning", 1, 2, 0, 0, "Scanning front sides")
+	if err := s.inputOneScan(0); err != nil {
+		return err
+	}
+
+	s.sendProgress("waiting", 1, 2, 0, 0, ManualDuplexPrompt)
+	if err := s.prompt(s.ctx, ManualDuplexPrompt); err != nil {
+		return err
+	}
+
+	s.sendProgress("scanning", 2, 2, 0, 0, "Scanning back sides")
+	if err := s.inputOneScan(1); err != nil {
+		return err
+	}
+
+	fronts, backs := s.scans[0], s.scans[1]
+	if len(backs) != len(fronts) {
+		return fmt.Errorf("back side pass scanned %d pages but front side pass scanned %d", len(backs), len(fronts))
+	}
+
+	detector := newBlankPageDetector(params, s.logger)
+
+	document := make([]models.ScanResult, 0, len(fronts)+len(backs))
+	for i, front := range fronts {
+		document = append(document, front)
+
+		back := backs[len(backs)-1-i]
+		done := startStage(s.ctx, metrics.StageBlankDetection, i+1)
+		blank, err := detector.isBlankPage(back.FilePath)
+		done()
+		if err != nil {
+			s.logger.Warn("Blank page detection failed", "page", i+1, "side", "back", logging.Err(err))
+		} else if blank {
+			os.Remove(back.FilePath)
+			metrics.BlankPagesDropped.Inc()
+			s.logger.Debug("Dropped blank back side", "page", i+1)
+			continue
+		}
+		document = append(document, back)
+	}
+
+	// Name the files in document order
+	if err := storage.RenumberPages(document); err != nil {
+		return err
+	}
+
+	s.scans = [][]models.ScanResult{document}
+	return nil
+}
+
 // inputOneScan performs a single scan operation
 // Implements NAPS2's InputOneScan method (BatchScanPerformer.cs:175-199)
 func (s *batchState) inputOneScan(scanNumber int) error {
@@ -240,7 +353,7 @@ func (s *batchState) save(index int, images []models.ScanResult) error {
 	}
 
 	// Substitute placeholders in save path
-	savePath := s.substitutePlaceholders(s.settings.SavePath, index)
+	savePath := SubstitutePlaceholders(s.settings.SavePath, index)
 
 	// Ensure directory exists
 	dir := filepath.Dir(savePath)
@@ -275,9 +388,9 @@ func (s *batchState) save(index int, images []models.ScanResult) error {
 	return nil
 }
 
-// substitutePlaceholders replaces placeholders in the save path
+// SubstitutePlaceholders replaces placeholders in a save path; index is the 0-based file number
 // Simplified version of NAPS2's Placeholders.Substitute
-func (s *batchState) substitutePlaceholders(path string, index int) string {
+func SubstitutePlaceholders(path string, index int) string {
 	now := time.Now()
 
 	replacements := map[string]string{
@@ -330,7 +443,7 @@ func (s *batchState) sendProgress(stage string, currentScan, totalScans, current
 
 	// Calculate percentage
 	percentComplete := 0
-	if stage == "scanning" {
+	if stage == "scanning" || stage == "waiting" {
 		if totalScans > 0 {
 			percentComplete = (currentScan * 50) / totalScans
 		} else {
@@ -0,0 +1,203 @@
+package scanner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/scanserver/scanner-service/pkg/models"
+)
+
+var testLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+// fakeDriver scans one pass per call into dir, numbering pages on from the
+// previous pass like a job directory does. Sheets are identified by their
+// Width, so they can be followed through renumbering.
+type fakeDriver struct {
+	ScannerDriver
+	t      *testing.T
+	dir    string
+	passes [][]fakeSheet
+	params []models.ScanParams
+	pages  int
+}
+
+type fakeSheet struct {
+	id    int
+	blank bool
+}
+
+func (d *fakeDriver) Scan(ctx context.Context, scannerID string, params models.ScanParams, progress func(int)) ([]models.ScanResult, error) {
+	d.params = append(d.params, params)
+	if len(d.params) > len(d.passes) {
+		return nil, ErrFeederEmpty
+	}
+	var results []models.ScanResult
+	for _, sheet := range d.passes[len(d.params)-1] {
+		d.pages++
+		result := testPage(d.t, filepath.Join(d.dir, fmt.Sprintf("page-%04d.png", d.pages)), sheet.blank)
+		result.PageNumber = len(results) + 1
+		result.Width = sheet.id
+		results = append(results, result)
+		progress(len(results))
+	}
+	return results, nil
+}
+
+// fronts returns sheets 1..n, backs returns their back sides (101..) in
+// the order a flipped stack feeds them: last sheet first
+func fronts(n int) []fakeSheet {
+	sheets := make([]fakeSheet, n)
+	for i := range sheets {
+		sheets[i] = fakeSheet{id: i + 1}
+	}
+	return sheets
+}
+
+func backs(n int, blank ...int) []fakeSheet {
+	sheets := make([]fakeSheet, n)
+	for i := range sheets {
+		id := 100 + n - i
+		sheets[i] = fakeSheet{id: id}
+		for _, b := range blank {
+			if b == id {
+				sheets[i].blank = true
+			}
+		}
+	}
+	return sheets
+}
+
+func manualDuplex(t *testing.T, driver *fakeDriver, prompt func(ctx context.Context, message string) error) ([][]models.ScanResult, error) {
+	t.Helper()
+	driver.t = t
+	driver.dir = t.TempDir()
+
+	performer := NewBatchScanPerformer(driver, testLogger)
+	if prompt != nil {
+		performer.OnPrompt(prompt)
+	}
+	settings := models.BatchSettings{
+		ScanType:   models.BatchScanManualDuplex,
+		OutputType: models.BatchOutputLoad,
+		ScanParams: models.ScanParams{Resolution: 300, UseDuplex: true, ExcludeBlankPages: true},
+	}
+	return performer.PerformBatchScan(context.Background(), "scanner-test", settings, nil)
+}
+
+func TestManualDuplex(t *testing.T) {
+	tests := []struct {
+		name   string
+		passes [][]fakeSheet
+		want   []int // Sheet IDs in document order
+	}{
+		{"one sheet", [][]fakeSheet{fronts(1), backs(1)}, []int{1, 101}},
+		{"backs in reverse order", [][]fakeSheet{fronts(3), backs(3)}, []int{1, 101, 2, 102, 3, 103}},
+		{"blank backs dropped", [][]fakeSheet{fronts(4), backs(4, 101, 103, 104)}, []int{1, 2, 102, 3, 4}},
+		{"all backs blank", [][]fakeSheet{fronts(2), backs(2, 101, 102)}, []int{1, 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			driver := &fakeDriver{passes: tt.passes}
+			var prompts []string
+			scans, err := manualDuplex(t, driver, func(ctx context.Context, message string) error {
+				prompts = append(prompts, message)
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(prompts) != 1 || prompts[0] != ManualDuplexPrompt {
+				t.Errorf("prompts %q", prompts)
+			}
+			// Both passes feed one side at a time and keep blank pages
+			for i, params := range driver.params {
+				if !params.UseFeeder || params.UseDuplex || params.ExcludeBlankPages || params.Resolution != 300 {
+					t.Errorf("pass %d params = %+v", i+1, params)
+				}
+			}
+
+			if len(scans) != 1 {
+				t.Fatalf("%d scans, want one document", len(scans))
+			}
+			document := scans[0]
+			ids := make([]int, len(document))
+			for i, page := range document {
+				ids[i] = page.Width
+				want := filepath.Join(driver.dir, fmt.Sprintf("page-%04d.png", i+1))
+				if page.PageNumber != i+1 || page.FilePath != want {
+					t.Errorf("page %d numbered %d at %s, want %s", i+1, page.PageNumber, page.FilePath, want)
+				}
+				if _, err := os.Stat(page.FilePath); err != nil {
+					t.Error(err)
+				}
+			}
+			if fmt.Sprint(ids) != fmt.Sprint(tt.want) {
+				t.Errorf("sheets %v, want %v", ids, tt.want)
+			}
+
+			// Dropped backs are deleted and no renaming leftovers remain
+			entries, _ := os.ReadDir(driver.dir)
+			if len(entries) != len(tt.want) {
+				t.Errorf("%d files left, want %d", len(entries), len(tt.want))
+			}
+			for _, entry := range entries {
+				if strings.HasSuffix(entry.Name(), ".renumber") {
+					t.Errorf("leftover %s", entry.Name())
+				}
+			}
+		})
+	}
+}
+
+func TestManualDuplexFailures(t *testing.T) {
+	continueScan := func(ctx context.Context, message string) error { return nil }
+
+	for name, passes := range map[string][][]fakeSheet{
+		"fewer backs": {fronts(3), backs(2)},
+		"more backs":  {fronts(2), backs(3)},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := manualDuplex(t, &fakeDriver{passes: passes}, continueScan)
+			if err == nil || !strings.Contains(err.Error(), "back side pass scanned") {
+				t.Errorf("error %v", err)
+			}
+		})
+	}
+
+	t.Run("empty feeder on the back pass", func(t *testing.T) {
+		_, err := manualDuplex(t, &fakeDriver{passes: [][]fakeSheet{fronts(2)}}, continueScan)
+		if !errors.Is(err, ErrFeederEmpty) {
+			t.Errorf("error %v, want ErrFeederEmpty", err)
+		}
+	})
+
+	t.Run("prompt cancelled", func(t *testing.T) {
+		driver := &fakeDriver{passes: [][]fakeSheet{fronts(2), backs(2)}}
+		scans, err := manualDuplex(t, driver, func(ctx context.Context, message string) error { return ErrCancelled })
+		if !errors.Is(err, ErrCancelled) {
+			t.Errorf("error %v, want ErrCancelled", err)
+		}
+		if len(driver.params) != 1 {
+			t.Errorf("%d passes scanned, want 1", len(driver.params))
+		}
+		// The fronts are kept
+		if len(scans) != 1 || len(scans[0]) != 2 {
+			t.Errorf("scans = %v", scans)
+		}
+	})
+
+	t.Run("no prompt", func(t *testing.T) {
+		driver := &fakeDriver{passes: [][]fakeSheet{fronts(1), backs(1)}}
+		if _, err := manualDuplex(t, driver, nil); err == nil || len(driver.params) != 0 {
+			t.Errorf("error %v after %d passes", err, len(driver.params))
+		}
+	})
+}
@@ -0,0 +1,127 @@
+package scanner
+
+import (
+	"fmt"
+	"image"
+	"log/slog"
+	"os"
+
+	// Decoders for scanned pages
+	_ "image/jpeg"
+	_ "image/png"
+
+	"github.com/scanserver/scanner-service/pkg/models"
+)
+
+// BlankPageDetector detects blank pages using NAPS2's YUV luma algorithm
+// Implements NAPS2's blank page detection (BlankDetectionImageOp.cs)
+type BlankPageDetector struct {
+	WhiteThreshold    int // 0-100 (default: 70) - brightness threshold for "white"
+	CoverageThreshold int // 0-100 (default: 15) - percentage of non-white pixels
+
+	logger *slog.Logger
+}
+
+// newBlankPageDetector returns a detector using the thresholds of params,
+// or the NAPS2 defaults where they are not set
+func newBlankPageDetector(params models.ScanParams, logger *slog.Logger) *BlankPageDetector {
+	whiteThreshold := params.BlankPageWhiteThreshold
+	if whiteThreshold == 0 {
+		whiteThreshold = models.DefaultBlankPageWhiteThreshold // 70
+	}
+	coverageThreshold := params.BlankPageCoverageThreshold
+	if coverageThreshold == 0 {
+		coverageThreshold = models.DefaultBlankPageCoverageThreshold // 15
+	}
+
+	return &BlankPageDetector{
+		WhiteThreshold:    whiteThreshold,
+		CoverageThreshold: coverageThreshold,
+		logger:            logger,
+	}
+}
+
+// isBlankPage detects if an image is a blank page
+// Uses NAPS2's YUV luma algorithm for accurate detection
+func (d *BlankPageDetector) isBlankPage(imagePath string) (bool, error) {
+	// 1. Open and decode image
+	file, err := os.Open(imagePath)
+	if err != nil {
+		return false, fmt.Errorf("failed to open image: %w", err)
+	}
+	defer file.Close()
+
+	img, _, err := image.Decode(file)
+	if err != nil {
+		return false, fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	// 2. Calculate adjusted thresholds using NAPS2's formulas
+	// whiteThresholdAdjusted = 1 + (whiteThreshold / 100.0) * 254
+	// Example: whiteThreshold=70 -> 179
+	whiteThresholdAdjusted := 1 + int(float64(d.WhiteThreshold)/100.0*254)
+
+	// coverageThresholdAdjusted = 0.00 + (coverageThreshold / 100.0) * 0.01
+	// Example: coverageThreshold=15 -> 0.0015 (0.15%)
+	coverageThresholdAdjusted := 0.00 + (float64(d.CoverageThreshold)/100.0)*0.01
+
+	// 3. Ignore 1% edge area to avoid border effects (NAPS2 pattern)
+	bounds := img.Bounds()
+	ignoreEdge := int(float64(bounds.Dx()) * 0.01)
+	if ignoreEdge < 1 {
+		ignoreEdge = 0
+	}
+
+	startX := bounds.Min.X + ignoreEdge
+	endX := bounds.Max.X - ignoreEdge
+	startY := bounds.Min.Y + ignoreEdge
+	endY := bounds.Max.Y - ignoreEdge
+
+	// Ensure valid bounds
+	if startX >= endX || startY >= endY {
+		startX = bounds.Min.X
+		endX = bounds.Max.X
+		startY = bounds.Min.Y
+		endY = bounds.Max.Y
+	}
+
+	// 4. Scan pixels and calculate coverage
+	totalPixels := (endX - startX) * (endY - startY)
+	nonWhitePixels := 0
+
+	for y := startY; y < endY; y++ {
+		for x := startX; x < endX; x++ {
+			r, g, b, _ := img.At(x, y).RGBA()
+
+			// Convert from 16-bit to 8-bit
+			r8 := uint8(r >> 8)
+			g8 := uint8(g >> 8)
+			b8 := uint8(b >> 8)
+
+			// YUV luma formula (NAPS2: r*299 + g*587 + b*114)
+			// This is the standard ITU-R BT.601 luma calculation
+			// Multiplied by 1000 to avoid floating point (NAPS2 pattern)
+			luma := int(r8)*299 + int(g8)*587 + int(b8)*114
+
+			// Check if pixel is non-white
+			// luma < whiteThresholdAdjusted * 1000
+			if luma < whiteThresholdAdjusted*1000 {
+				nonWhitePixels++
+			}
+		}
+	}
+
+	// 5. Calculate coverage ratio
+	coverage := float64(nonWhitePixels) / float64(totalPixels)
+
+	// 6. Determine if blank
+	isBlank := coverage < coverageThresholdAdjusted
+
+	d.logger.Debug("Blank page detection",
+		"file", imagePath,
+		"coverage_percent", coverage*100,
+		"threshold_percent", coverageThresholdAdjusted*100,
+		"blank", isBlank)
+
+	return isBlank, nil
+}
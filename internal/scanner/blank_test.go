@@ -0,0 +1,64 @@
+package scanner
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"path/filepath"
+	"testing"
+
+	"github.com/disintegration/imaging"
+	"github.com/scanserver/scanner-service/pkg/models"
+)
+
+func TestBlankPageDetector(t *testing.T) {
+	dir := t.TempDir()
+
+	// A 200x100 page has a 2 pixel edge that is ignored, leaving 18816
+	// pixels. At the default 15 coverage, 28 of them may be dark.
+	page := func(name string, background color.Color, dark image.Rectangle) string {
+		img := image.NewRGBA(image.Rect(0, 0, 200, 100))
+		draw.Draw(img, img.Bounds(), &image.Uniform{background}, image.Point{}, draw.Src)
+		draw.Draw(img, dark, &image.Uniform{color.Black}, image.Point{}, draw.Src)
+		path := filepath.Join(dir, name)
+		if err := imaging.Save(img, path); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	lightGray := color.Gray{Y: 200} // White at the default threshold of 70 (luma 179)
+
+	tests := []struct {
+		name   string
+		path   string
+		params models.ScanParams
+		blank  bool
+	}{
+		{"white", page("white.png", color.White, image.Rectangle{}), models.ScanParams{}, true},
+		{"text", page("text.png", color.White, image.Rect(50, 25, 100, 75)), models.ScanParams{}, false},
+		{"specks", page("specks.png", color.White, image.Rect(50, 50, 55, 55)), models.ScanParams{}, true},
+		{"more than the coverage", page("dots.png", color.White, image.Rect(50, 50, 56, 55)), models.ScanParams{}, false},
+		{"dark edges", page("edges.png", color.White, image.Rect(0, 0, 200, 2)), models.ScanParams{}, true},
+		{"light gray", page("gray.png", lightGray, image.Rectangle{}), models.ScanParams{}, true},
+		{"light gray under a higher white threshold", page("gray.png", lightGray, image.Rectangle{}), models.ScanParams{BlankPageWhiteThreshold: 90}, false},
+		{"text under a higher coverage", page("text.png", color.White, image.Rect(50, 25, 100, 75)), models.ScanParams{BlankPageCoverageThreshold: 100}, false},
+		{"specks under a higher coverage", page("dots.png", color.White, image.Rect(50, 50, 56, 55)), models.ScanParams{BlankPageCoverageThreshold: 100}, true},
+		{"JPEG", page("white.jpg", color.White, image.Rectangle{}), models.ScanParams{}, true},
+	}
+	for _, tt := range tests {
+		blank, err := newBlankPageDetector(tt.params, testLogger).isBlankPage(tt.path)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+		} else if blank != tt.blank {
+			t.Errorf("%s: blank = %v, want %v", tt.name, blank, tt.blank)
+		}
+	}
+
+	d := newBlankPageDetector(models.ScanParams{}, testLogger)
+	if d.WhiteThreshold != models.DefaultBlankPageWhiteThreshold || d.CoverageThreshold != models.DefaultBlankPageCoverageThreshold {
+		t.Errorf("default thresholds %d, %d", d.WhiteThreshold, d.CoverageThreshold)
+	}
+	if _, err := d.isBlankPage(filepath.Join(dir, "missing.png")); err == nil {
+		t.Error("no error for a missing page")
+	}
+}
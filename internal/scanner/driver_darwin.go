@@ -5,21 +5,29 @@ package scanner
 import (
 	"context"
 	"fmt"
+	"log/slog"
 	"time"
 
+	"github.com/scanserver/scanner-service/internal/logging"
+	"github.com/scanserver/scanner-service/internal/storage"
+	"github.com/scanserver/scanner-service/internal/tracing"
 	"github.com/scanserver/scanner-service/pkg/models"
 )
 
 // DarwinDriver implements ScannerDriver for macOS using ImageCaptureCore
 type DarwinDriver struct {
 	scanners map[string]*models.Scanner
+	store    *storage.FileStore
+	logger   *slog.Logger
 }
 
-func newPlatformDriver() (ScannerDriver, error) {
+func newPlatformDriver(store *storage.FileStore, logger *slog.Logger) (ScannerDriver, error) {
 	// In a real implementation, initialize ImageCaptureCore framework
 	// This would require CGo and Objective-C bridging
 	return &DarwinDriver{
 		scanners: make(map[string]*models.Scanner),
+		store:    store,
+		logger:   logger,
 	}, nil
 }
 
@@ -34,8 +42,8 @@ func (d *DarwinDriver) ListScanners(ctx context.Context) ([]models.Scanner, erro
 		Manufacturer: "HP",
 		Status:       "idle",
 		Capabilities: models.Capability{
-			MaxWidth:        8500, // A4 width in 0.01mm
-			MaxHeight:       11700, // A4 height in 0.01mm
+			MaxWidth:        2100, // A4 width in 0.1mm (210mm)
+			MaxHeight:       2970, // A4 height in 0.1mm (297mm)
 			Resolutions:     []int{100, 150, 200, 300, 600, 1200},
 			ColorModes:      []string{"Color", "Grayscale", "BlackAndWhite"},
 			DocumentFormats: []string{"PDF", "JPEG", "PNG", "TIFF"},
@@ -52,7 +60,7 @@ func (d *DarwinDriver) ListScanners(ctx context.Context) ([]models.Scanner, erro
 func (d *DarwinDriver) GetScanner(ctx context.Context, scannerID string) (*models.Scanner, error) {
 	scanner, ok := d.scanners[scannerID]
 	if !ok {
-		return nil, fmt.Errorf("scanner not found: %s", scannerID)
+		return nil, fmt.Errorf("%w: %s", ErrScannerNotFound, scannerID)
 	}
 	return scanner, nil
 }
@@ -64,7 +72,7 @@ func (d *DarwinDriver) Scan(ctx context.Context, scannerID string, params models
 	}
 
 	if scanner.Status != "idle" {
-		return nil, fmt.Errorf("scanner is busy")
+		return nil, ErrBusy
 	}
 
 	scanner.Status = "scanning"
@@ -78,6 +86,9 @@ func (d *DarwinDriver) Scan(ctx context.Context, scannerID string, params models
 	// 3. Request scan with requestScan
 	// 4. Handle didScanTo... delegate methods
 	// 5. Save scanned images
+	// With the feeder, a document feeder without documentLoaded fails the
+	// scan with ErrFeederEmpty (feederError), and errors reported by
+	// didCompleteScanWithError are mapped by icaError.
 
 	var results []models.ScanResult
 	pageCount := params.PageCount
@@ -88,7 +99,7 @@ func (d *DarwinDriver) Scan(ctx context.Context, scannerID string, params models
 	for i := 0; i < pageCount; i++ {
 		select {
 		case <-ctx.Done():
-			return nil, ctx.Err()
+			return nil, cancelledError(ctx.Err())
 		default:
 		}
 
@@ -97,21 +108,23 @@ func (d *DarwinDriver) Scan(ctx context.Context, scannerID string, params models
 			progressCallback(progress)
 		}
 
+		pageCtx, span := startPage(ctx, i+1)
+
 		// Simulate scan time
 		time.Sleep(2 * time.Second)
 
-		result := models.ScanResult{
-			PageNumber: i + 1,
-			FilePath:   fmt.Sprintf("scan_%s_page_%d.%s", time.Now().Format("20060102_150405"), i+1, params.Format),
-			FileSize:   1024 * 1024,
-			Format:     params.Format,
-			Width:      params.Width,
-			Height:     params.Height,
+		result, err := writeSimulatedPage(pageCtx, d.store, i+1, params)
+		tracing.End(span, err)
+		if err != nil {
+			return nil, err
 		}
+		logging.FromContext(ctx, d.logger).Debug("Page scanned", "page", i+1, "file", result.FilePath)
 
 		results = append(results, result)
 	}
 
+	results = PostProcess(ctx, params, results)
+
 	if progressCallback != nil {
 		progressCallback(100)
 	}
@@ -119,6 +132,39 @@ func (d *DarwinDriver) Scan(ctx context.Context, scannerID string, params models
 	return results, nil
 }
 
+// ImageCaptureCore return codes (ICReturnCode) with a typed scanner error
+const (
+	icReturnScanOperationCanceled     = -9924
+	icReturnScannerInUseByLocalUser   = -9925
+	icReturnScannerInUseByRemoteUser  = -9926
+	icReturnCommunicationTimedOut     = -9923
+	icReturnDeviceFailedToOpenSession = -9927
+)
+
+// icaError maps an ImageCaptureCore return code to a typed scanner error
+func icaError(code int) error {
+	switch code {
+	case 0:
+		return nil
+	case icReturnScanOperationCanceled:
+		return ErrCancelled
+	case icReturnScannerInUseByLocalUser, icReturnScannerInUseByRemoteUser:
+		return ErrBusy
+	case icReturnCommunicationTimedOut, icReturnDeviceFailedToOpenSession:
+		return ErrOffline
+	}
+	return fmt.Errorf("ImageCaptureCore error %d", code)
+}
+
+// feederError returns ErrFeederEmpty for a feeder scan from a document
+// feeder that has no document loaded
+func feederError(params models.ScanParams, documentLoaded bool) error {
+	if params.UseFeeder && !documentLoaded {
+		return fmt.Errorf("%w - no document loaded", ErrFeederEmpty)
+	}
+	return nil
+}
+
 func (d *DarwinDriver) CancelScan(ctx context.Context, scannerID string) error {
 	scanner, err := d.GetScanner(ctx, scannerID)
 	if err != nil {
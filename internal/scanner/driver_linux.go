@@ -5,21 +5,29 @@ package scanner
 import (
 	"context"
 	"fmt"
+	"log/slog"
 	"time"
 
+	"github.com/scanserver/scanner-service/internal/logging"
+	"github.com/scanserver/scanner-service/internal/storage"
+	"github.com/scanserver/scanner-service/internal/tracing"
 	"github.com/scanserver/scanner-service/pkg/models"
 )
 
 // LinuxDriver implements ScannerDriver for Linux using SANE (Scanner Access Now Easy)
 type LinuxDriver struct {
 	scanners map[string]*models.Scanner
+	store    *storage.FileStore
+	logger   *slog.Logger
 }
 
-func newPlatformDriver() (ScannerDriver, error) {
+func newPlatformDriver(store *storage.FileStore, logger *slog.Logger) (ScannerDriver, error) {
 	// In a real implementation, initialize SANE library
 	// sane_init()
 	return &LinuxDriver{
 		scanners: make(map[string]*models.Scanner),
+		store:    store,
+		logger:   logger,
 	}, nil
 }
 
@@ -34,8 +42,8 @@ func (d *LinuxDriver) ListScanners(ctx context.Context) ([]models.Scanner, error
 		Manufacturer: "HP",
 		Status:       "idle",
 		Capabilities: models.Capability{
-			MaxWidth:        8500, // A4 width in 0.01mm
-			MaxHeight:       11700, // A4 height in 0.01mm
+			MaxWidth:        2100, // A4 width in 0.1mm (210mm)
+			MaxHeight:       2970, // A4 height in 0.1mm (297mm)
 			Resolutions:     []int{100, 150, 200, 300, 600, 1200},
 			ColorModes:      []string{"Color", "Grayscale", "BlackAndWhite"},
 			DocumentFormats: []string{"PDF", "JPEG", "PNG", "TIFF"},
@@ -52,7 +60,7 @@ func (d *LinuxDriver) ListScanners(ctx context.Context) ([]models.Scanner, error
 func (d *LinuxDriver) GetScanner(ctx context.Context, scannerID string) (*models.Scanner, error) {
 	scanner, ok := d.scanners[scannerID]
 	if !ok {
-		return nil, fmt.Errorf("scanner not found: %s", scannerID)
+		return nil, fmt.Errorf("%w: %s", ErrScannerNotFound, scannerID)
 	}
 	return scanner, nil
 }
@@ -64,7 +72,7 @@ func (d *LinuxDriver) Scan(ctx context.Context, scannerID string, params models.
 	}
 
 	if scanner.Status != "idle" {
-		return nil, fmt.Errorf("scanner is busy")
+		return nil, ErrBusy
 	}
 
 	scanner.Status = "scanning"
@@ -79,6 +87,9 @@ func (d *LinuxDriver) Scan(ctx context.Context, scannerID string, params models.
 	// 4. Read scan data: sane_read()
 	// 5. Save to file format
 	// 6. Close device: sane_close()
+	// With the feeder, sane_start() returns SANE_STATUS_NO_DOCS once it runs
+	// out, which ends the scan. Before the first page saneError turns it
+	// into ErrFeederEmpty.
 
 	var results []models.ScanResult
 	pageCount := params.PageCount
@@ -89,7 +100,7 @@ func (d *LinuxDriver) Scan(ctx context.Context, scannerID string, params models.
 	for i := 0; i < pageCount; i++ {
 		select {
 		case <-ctx.Done():
-			return nil, ctx.Err()
+			return nil, cancelledError(ctx.Err())
 		default:
 		}
 
@@ -98,21 +109,23 @@ func (d *LinuxDriver) Scan(ctx context.Context, scannerID string, params models.
 			progressCallback(progress)
 		}
 
+		pageCtx, span := startPage(ctx, i+1)
+
 		// Simulate scan time
 		time.Sleep(2 * time.Second)
 
-		result := models.ScanResult{
-			PageNumber: i + 1,
-			FilePath:   fmt.Sprintf("scan_%s_page_%d.%s", time.Now().Format("20060102_150405"), i+1, params.Format),
-			FileSize:   1024 * 1024,
-			Format:     params.Format,
-			Width:      params.Width,
-			Height:     params.Height,
+		result, err := writeSimulatedPage(pageCtx, d.store, i+1, params)
+		tracing.End(span, err)
+		if err != nil {
+			return nil, err
 		}
+		logging.FromContext(ctx, d.logger).Debug("Page scanned", "page", i+1, "file", result.FilePath)
 
 		results = append(results, result)
 	}
 
+	results = PostProcess(ctx, params, results)
+
 	if progressCallback != nil {
 		progressCallback(100)
 	}
@@ -120,6 +133,38 @@ func (d *LinuxDriver) Scan(ctx context.Context, scannerID string, params models.
 	return results, nil
 }
 
+// SANE status codes (sane.h) with a typed scanner error
+const (
+	saneStatusGood       = 0
+	saneStatusCancelled  = 2
+	saneStatusDeviceBusy = 3
+	saneStatusJammed     = 6
+	saneStatusNoDocs     = 7
+	saneStatusCoverOpen  = 8
+	saneStatusIOError    = 9
+)
+
+// saneError maps a SANE status to a typed scanner error
+func saneError(status int) error {
+	switch status {
+	case saneStatusGood:
+		return nil
+	case saneStatusCancelled:
+		return ErrCancelled
+	case saneStatusDeviceBusy:
+		return ErrBusy
+	case saneStatusJammed:
+		return ErrPaperJam
+	case saneStatusNoDocs:
+		return fmt.Errorf("%w - no documents in the feeder", ErrFeederEmpty)
+	case saneStatusCoverOpen:
+		return ErrCoverOpen
+	case saneStatusIOError:
+		return fmt.Errorf("%w - I/O error", ErrOffline)
+	}
+	return fmt.Errorf("SANE status %d", status)
+}
+
 func (d *LinuxDriver) CancelScan(ctx context.Context, scannerID string) error {
 	scanner, err := d.GetScanner(ctx, scannerID)
 	if err != nil {
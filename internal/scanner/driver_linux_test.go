@@ -0,0 +1,32 @@
+package scanner
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSaneError(t *testing.T) {
+	tests := []struct {
+		status int
+		want   error
+	}{
+		{saneStatusCancelled, ErrCancelled},
+		{saneStatusDeviceBusy, ErrBusy},
+		{saneStatusJammed, ErrPaperJam},
+		{saneStatusNoDocs, ErrFeederEmpty},
+		{saneStatusCoverOpen, ErrCoverOpen},
+		{saneStatusIOError, ErrOffline},
+	}
+	for _, tt := range tests {
+		if err := saneError(tt.status); !errors.Is(err, tt.want) {
+			t.Errorf("status %d: %v, want %v", tt.status, err, tt.want)
+		}
+	}
+
+	if err := saneError(saneStatusGood); err != nil {
+		t.Errorf("SANE_STATUS_GOOD: %v", err)
+	}
+	if err := saneError(4); err == nil || ErrorCode(err) != CodeInternal {
+		t.Errorf("SANE_STATUS_INVAL: %v", err)
+	}
+}
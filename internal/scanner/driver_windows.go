@@ -5,18 +5,16 @@ package scanner
 import (
 	"context"
 	"fmt"
-	"image"
-	"image/jpeg"
-	"image/png"
-	"io"
+	"log/slog"
 	"os"
-	"path/filepath"
 	"time"
 
-	"github.com/disintegration/imaging"
 	"github.com/go-ole/go-ole"
 	"github.com/go-ole/go-ole/oleutil"
-	"github.com/nfnt/resize"
+	"github.com/scanserver/scanner-service/internal/logging"
+	"github.com/scanserver/scanner-service/internal/metrics"
+	"github.com/scanserver/scanner-service/internal/storage"
+	"github.com/scanserver/scanner-service/internal/tracing"
 	"github.com/scanserver/scanner-service/pkg/models"
 )
 
@@ -105,17 +103,22 @@ type WindowsDriver struct {
 	scanners    map[string]*models.Scanner
 	deviceMgr   *ole.IDispatch
 	initialized bool
+	store       *storage.FileStore
+	logger      *slog.Logger
 }
 
 // newPlatformDriver creates a combined WIA/TWAIN driver for Windows
-func newPlatformDriver() (ScannerDriver, error) {
-	return newCombinedDriver()
+func newPlatformDriver(store *storage.FileStore, logger *slog.Logger) (ScannerDriver, error) {
+	return newCombinedDriver(store, logger)
 }
 
 // newWIADriverInternal creates a WIA-only driver
-func newWIADriverInternal() (*WindowsDriver, error) {
+func newWIADriverInternal(store *storage.FileStore, logger *slog.Logger) (*WindowsDriver, error) {
+	logger = logger.With("driver", "wia")
 	driver := &WindowsDriver{
 		scanners: make(map[string]*models.Scanner),
+		store:    store,
+		logger:   logger,
 	}
 
 	// Initialize COM
@@ -134,19 +137,19 @@ func newWIADriverInternal() (*WindowsDriver, error) {
 		deviceMgr, err = unknown.QueryInterface(ole.IID_IDispatch)
 		if err == nil {
 			wiaVersion = "1.0"
-			fmt.Println("WIA: Using WIA 1.0 DeviceManager")
+			logger.Debug("Using WIA 1.0 DeviceManager")
 		}
 	}
 
 	// If WIA 1.0 failed, try WIA 2.0
 	if deviceMgr == nil {
-		fmt.Println("WIA: WIA 1.0 failed, trying WIA 2.0...")
+		logger.Debug("WIA 1.0 failed, trying WIA 2.0")
 		unknown, err = oleutil.CreateObject("WIA.DeviceManager.1")
 		if err == nil {
 			deviceMgr, err = unknown.QueryInterface(ole.IID_IDispatch)
 			if err == nil {
 				wiaVersion = "2.0"
-				fmt.Println("WIA: Using WIA 2.0 DeviceManager")
+				logger.Debug("Using WIA 2.0 DeviceManager")
 			}
 		}
 	}
@@ -156,7 +159,7 @@ func newWIADriverInternal() (*WindowsDriver, error) {
 		return nil, fmt.Errorf("failed to create WIA DeviceManager (tried both 1.0 and 2.0): %w", err)
 	}
 
-	fmt.Printf("WIA: DeviceManager %s initialized successfully\n", wiaVersion)
+	logger.Info("WIA DeviceManager initialized", "version", wiaVersion)
 	driver.deviceMgr = deviceMgr
 	driver.initialized = true
 
@@ -168,21 +171,20 @@ func (d *WindowsDriver) ListScanners(ctx context.Context) ([]models.Scanner, err
 		return nil, fmt.Errorf("driver not initialized")
 	}
 
-	fmt.Println("WIA: Starting scanner enumeration...")
-	fmt.Println("WIA: Attempting to enumerate all WIA devices...")
+	logger := logging.FromContext(ctx, d.logger)
+	logger.Debug("Enumerating WIA devices")
 
 	// Method 1: Try with device type filter (recommended)
 	deviceInfosRaw, err := oleutil.CallMethod(d.deviceMgr, "DeviceInfos", WiaDeviceTypeScanner)
 	if err != nil {
 		// Method 2: Try without filter (gets all device types)
-		fmt.Printf("WIA: Filtered DeviceInfos failed: %v\n", err)
-		fmt.Println("WIA: Trying unfiltered DeviceInfos (all device types)...")
+		logger.Debug("Filtered DeviceInfos failed, trying all device types", logging.Err(err))
 		deviceInfosRaw, err = oleutil.GetProperty(d.deviceMgr, "DeviceInfos")
 		if err != nil {
 			return nil, fmt.Errorf("failed to get DeviceInfos: %w", err)
 		}
 	} else {
-		fmt.Println("WIA: Using filtered DeviceInfos (scanners only)")
+		logger.Debug("Using filtered DeviceInfos (scanners only)")
 	}
 
 	deviceInfos := deviceInfosRaw.ToIDispatch()
@@ -195,18 +197,17 @@ func (d *WindowsDriver) ListScanners(ctx context.Context) ([]models.Scanner, err
 	}
 	count := int(countRaw.Val)
 
-	fmt.Printf("WIA: Found %d device(s) in DeviceInfos collection\n", count)
+	logger.Debug("Found WIA devices", "count", count)
 
 	var scanners []models.Scanner
 	var allDevices []string
 
 	// Enumerate devices
 	for i := 1; i <= count; i++ {
-		fmt.Printf("WIA: Checking device %d/%d...\n", i, count)
 
 		deviceInfoRaw, err := oleutil.GetProperty(deviceInfos, "Item", i)
 		if err != nil {
-			fmt.Printf("WIA: Failed to get device %d: %v\n", i, err)
+			logger.Warn("Failed to get WIA device", "index", i, logging.Err(err))
 			continue
 		}
 		deviceInfo := deviceInfoRaw.ToIDispatch()
@@ -214,7 +215,7 @@ func (d *WindowsDriver) ListScanners(ctx context.Context) ([]models.Scanner, err
 		// Get device type
 		deviceTypeRaw, err := oleutil.GetProperty(deviceInfo, "Type")
 		if err != nil {
-			fmt.Printf("WIA: Failed to get device type for device %d: %v\n", i, err)
+			logger.Warn("Failed to get WIA device type", "index", i, logging.Err(err))
 			deviceInfo.Release()
 			continue
 		}
@@ -248,11 +249,10 @@ func (d *WindowsDriver) ListScanners(ctx context.Context) ([]models.Scanner, err
 
 		// Log all devices found
 		allDevices = append(allDevices, fmt.Sprintf("%s (Type: %d, ID: %s)", deviceName, deviceType, deviceID))
-		fmt.Printf("WIA: Device %d: Name='%s', Type=%d, ID='%s'\n", i, deviceName, deviceType, deviceID)
+		logger.Debug("WIA device", "index", i, "name", deviceName, "type", deviceType, "device_id", deviceID)
 
 		// Only include scanners (Type = 1)
 		if deviceType != WiaDeviceTypeScanner {
-			fmt.Printf("WIA: Skipping device (not a scanner, type=%d)\n", deviceType)
 			deviceInfo.Release()
 			continue
 		}
@@ -301,17 +301,14 @@ func (d *WindowsDriver) ListScanners(ctx context.Context) ([]models.Scanner, err
 		d.scanners[scanner.ID] = &scanner
 		scanners = append(scanners, scanner)
 
-		fmt.Printf("WIA: ✓ Added scanner: %s (Manufacturer: %s)\n", name, manufacturer)
+		logger.Debug("Added WIA scanner", "name", name, "manufacturer", manufacturer)
 
 		deviceInfo.Release()
 	}
 
-	fmt.Printf("WIA: Enumeration complete. Found %d scanner(s) out of %d device(s)\n", len(scanners), count)
+	logger.Info("WIA enumeration complete", "scanners", len(scanners), "devices", count)
 	if len(allDevices) > 0 {
-		fmt.Println("WIA: All devices found:")
-		for idx, dev := range allDevices {
-			fmt.Printf("  %d. %s\n", idx+1, dev)
-		}
+		logger.Debug("WIA devices found", "devices", allDevices)
 	}
 
 	if len(scanners) == 0 {
@@ -324,19 +321,20 @@ func (d *WindowsDriver) ListScanners(ctx context.Context) ([]models.Scanner, err
 func (d *WindowsDriver) GetScanner(ctx context.Context, scannerID string) (*models.Scanner, error) {
 	scanner, ok := d.scanners[scannerID]
 	if !ok {
-		return nil, fmt.Errorf("scanner not found: %s", scannerID)
+		return nil, fmt.Errorf("%w: %s", ErrScannerNotFound, scannerID)
 	}
 	return scanner, nil
 }
 
 func (d *WindowsDriver) Scan(ctx context.Context, scannerID string, params models.ScanParams, progressCallback func(int)) ([]models.ScanResult, error) {
+	logger := logging.FromContext(ctx, d.logger)
 	scanner, err := d.GetScanner(ctx, scannerID)
 	if err != nil {
 		return nil, err
 	}
 
 	if scanner.Status != "idle" {
-		return nil, fmt.Errorf("scanner is busy")
+		return nil, ErrBusy
 	}
 
 	scanner.Status = "scanning"
@@ -382,14 +380,14 @@ func (d *WindowsDriver) Scan(ctx context.Context, scannerID string, params model
 	}
 
 	if deviceInfo == nil {
-		return nil, fmt.Errorf("scanner not found: %s", scannerID)
+		return nil, fmt.Errorf("%w: %s", ErrScannerNotFound, scannerID)
 	}
 	defer deviceInfo.Release()
 
 	// Connect to device
 	deviceRaw, err := oleutil.CallMethod(deviceInfo, "Connect")
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to scanner: %w", err)
+		return nil, fmt.Errorf("failed to connect to scanner: %w", handleWiaError(err))
 	}
 	device := deviceRaw.ToIDispatch()
 	defer device.Release()
@@ -420,7 +418,7 @@ func (d *WindowsDriver) Scan(ctx context.Context, scannerID string, params model
 	// Configure scan properties using NAPS2's SafeSetProperty pattern
 	// This ensures we don't fail if a scanner doesn't support certain properties
 
-	fmt.Println("Configuring WIA properties (NAPS2 mode)...")
+	logger.Debug("Configuring WIA properties")
 
 	// Set data type (color mode) - NAPS2 line 426-438
 	var dataType int
@@ -434,34 +432,34 @@ func (d *WindowsDriver) Scan(ctx context.Context, scannerID string, params model
 	default:
 		dataType = WIA_DATA_COLOR
 	}
-	d.safeSetPropertyInt(props, WIA_IPA_DATATYPE, dataType)
-	fmt.Printf("  Data type: %d (%s)\n", dataType, params.ColorMode)
+	d.safeSetPropertyInt(logger, props, WIA_IPA_DATATYPE, dataType)
+	logger.Debug("Data type", "data_type", dataType, "color_mode", params.ColorMode)
 
 	// Set resolution (DPI) - NAPS2 line 463-465
-	d.safeSetPropertyInt(props, WIA_IPS_XRES, params.Resolution)
-	d.safeSetPropertyInt(props, WIA_IPS_YRES, params.Resolution)
-	fmt.Printf("  Resolution: %d DPI\n", params.Resolution)
+	d.safeSetPropertyInt(logger, props, WIA_IPS_XRES, params.Resolution)
+	d.safeSetPropertyInt(logger, props, WIA_IPS_YRES, params.Resolution)
+	logger.Debug("Resolution", "dpi", params.Resolution)
 
 	// Set paper size and alignment (NAPS2 feature) - NAPS2 line 447-474
 	if params.PageSize != "" || params.PageWidth > 0 || params.Width > 0 {
-		pageWidth, pageHeight, xPos, err := d.calculateScanArea(device, item, params, params.UseFeeder)
+		pageWidth, pageHeight, xPos, err := d.calculateScanArea(logger, device, item, params, params.UseFeeder)
 		if err == nil {
 			// Apply WIA offset width mode if requested (NAPS2 compatibility)
 			if params.WiaOffsetWidth {
 				// NAPS2 mode: add offset to width
-				d.safeSetPropertyInt(props, WIA_IPS_XEXTENT, pageWidth+xPos)
-				d.safeSetPropertyInt(props, WIA_IPS_XPOS, xPos)
+				d.safeSetPropertyInt(logger, props, WIA_IPS_XEXTENT, pageWidth+xPos)
+				d.safeSetPropertyInt(logger, props, WIA_IPS_XPOS, xPos)
 			} else {
 				// Standard mode: separate width and position
-				d.safeSetPropertyInt(props, WIA_IPS_XEXTENT, pageWidth)
-				d.safeSetPropertyInt(props, WIA_IPS_XPOS, xPos)
+				d.safeSetPropertyInt(logger, props, WIA_IPS_XEXTENT, pageWidth)
+				d.safeSetPropertyInt(logger, props, WIA_IPS_XPOS, xPos)
 			}
-			d.safeSetPropertyInt(props, WIA_IPS_YEXTENT, pageHeight)
-			d.safeSetPropertyInt(props, WIA_IPS_YPOS, 0) // Always start from top
+			d.safeSetPropertyInt(logger, props, WIA_IPS_YEXTENT, pageHeight)
+			d.safeSetPropertyInt(logger, props, WIA_IPS_YPOS, 0) // Always start from top
 
-			fmt.Printf("  Scan area: %dx%d pixels at offset (%d, 0)\n", pageWidth, pageHeight, xPos)
+			logger.Debug("Scan area", "width", pageWidth, "height", pageHeight, "x_offset", xPos)
 		} else {
-			fmt.Printf("  Warning: Failed to calculate scan area: %v\n", err)
+			logger.Warn("Failed to calculate scan area", logging.Err(err))
 		}
 	}
 
@@ -472,61 +470,52 @@ func (d *WindowsDriver) Scan(ctx context.Context, scannerID string, params model
 	} else if params.ColorMode == "BlackAndWhite" {
 		intent = WiaIntentTextScan
 	}
-	d.safeSetPropertyInt(props, WIA_IPS_CUR_INTENT, intent)
+	d.safeSetPropertyInt(logger, props, WIA_IPS_CUR_INTENT, intent)
 
 	// Set document handling if using feeder (NAPS2 line 387-420)
 	if params.UseFeeder {
-		fmt.Println("  ADF mode enabled")
+		logger.Debug("ADF mode enabled")
 
 		// Document handling select - FEEDER + DUPLEX + DETECT
 		handlingValue := WIA_USE_FEEDER | WIA_DETECT_FEED
 		if params.UseDuplex {
 			handlingValue |= WIA_USE_DUPLEX
-			fmt.Println("  Duplex mode enabled")
+			logger.Debug("Duplex mode enabled")
 		}
-		d.safeSetPropertyInt(props, WIA_DPS_DOCUMENT_HANDLING_SELECT, handlingValue)
-		fmt.Printf("  Document handling: 0x%03X\n", handlingValue)
+		d.safeSetPropertyInt(logger, props, WIA_DPS_DOCUMENT_HANDLING_SELECT, handlingValue)
+		logger.Debug("Document handling", "value", fmt.Sprintf("0x%03X", handlingValue))
 
 		// Set pages to scan - NAPS2 line 377-386
 		// WIA 1.0 uses 1 page at a time with looping (we handle this in scanADFBatch)
 		// But we still set this to hint to the driver
 		if params.PageCount == 0 {
-			d.safeSetPropertyInt(props, WIA_DPS_PAGES, 1) // WIA 1.0: scan 1 page per Transfer
-			d.safeSetPropertyInt(props, WIA_IPS_PAGES, 0) // WIA 2.0: scan all pages
-			fmt.Println("  Pages: ALL (continuous until empty)")
+			d.safeSetPropertyInt(logger, props, WIA_DPS_PAGES, 1) // WIA 1.0: scan 1 page per Transfer
+			d.safeSetPropertyInt(logger, props, WIA_IPS_PAGES, 0) // WIA 2.0: scan all pages
+			logger.Debug("Pages: all, until the feeder is empty")
 		} else {
-			d.safeSetPropertyInt(props, WIA_DPS_PAGES, 1) // Still use 1 for WIA 1.0 loop
-			d.safeSetPropertyInt(props, WIA_IPS_PAGES, params.PageCount) // WIA 2.0
-			fmt.Printf("  Pages: %d\n", params.PageCount)
+			d.safeSetPropertyInt(logger, props, WIA_DPS_PAGES, 1) // Still use 1 for WIA 1.0 loop
+			d.safeSetPropertyInt(logger, props, WIA_IPS_PAGES, params.PageCount) // WIA 2.0
+			logger.Debug("Pages", "count", params.PageCount)
 		}
 
 		// Preview mode - 0 for final scan (NAPS2 line 440-443)
-		d.safeSetPropertyInt(props, WIA_IPS_PREVIEW, 0)
+		d.safeSetPropertyInt(logger, props, WIA_IPS_PREVIEW, 0)
 
 		// Transfer buffer size for performance (NAPS2 optimization)
-		d.safeSetPropertyInt(props, WIA_IPA_BUFFER_SIZE, 65536) // 64KB buffer
-		fmt.Println("  Buffer size: 64KB")
+		d.safeSetPropertyInt(logger, props, WIA_IPA_BUFFER_SIZE, 65536) // 64KB buffer
 
 		// Auto deskew - straighten tilted pages (NAPS2 feature)
-		d.safeSetPropertyInt(props, WIA_IPS_AUTO_DESKEW, 1)
-		fmt.Println("  Auto deskew: enabled")
+		d.safeSetPropertyInt(logger, props, WIA_IPS_AUTO_DESKEW, 1)
 
 		// Blank page detection - skip empty pages (NAPS2 feature)
-		d.safeSetPropertyInt(props, WIA_IPS_BLANK_PAGES, 1)
-		fmt.Println("  Blank page detection: enabled")
+		d.safeSetPropertyInt(logger, props, WIA_IPS_BLANK_PAGES, 1)
 	} else {
 		// Flatbed mode
-		fmt.Println("  Flatbed mode")
-		d.safeSetPropertyInt(props, WIA_DPS_DOCUMENT_HANDLING_SELECT, WIA_USE_FLATBED)
+		logger.Debug("Flatbed mode")
+		d.safeSetPropertyInt(logger, props, WIA_DPS_DOCUMENT_HANDLING_SELECT, WIA_USE_FLATBED)
 	}
 
-	fmt.Println("Property configuration complete")
-
-	// Create output directory
-	outputDir := "./scans"
-	if err := os.MkdirAll(outputDir, 0755); err != nil {
-		return nil, fmt.Errorf("failed to create output directory: %w", err)
-	}
+	logger.Debug("WIA properties configured")
 
 	var results []models.ScanResult
 	pageCount := params.PageCount
@@ -534,26 +523,28 @@ func (d *WindowsDriver) Scan(ctx context.Context, scannerID string, params model
 		pageCount = 1
 	}
 
-	// Generate base timestamp for all pages in this batch
-	baseTimestamp := time.Now().Format("20060102_150405")
-
 	// For ADF mode, use optimized batch scanning
 	if params.UseFeeder {
 		// Try to get all images in one go using WIA's multi-page transfer
 		// This tells WIA to buffer all pages during scanning for faster operation
-		return d.scanADFBatch(ctx, item, outputDir, baseTimestamp, pageCount, params, progressCallback)
+		return d.scanADFBatch(ctx, item, pageCount, params, progressCallback)
 	}
 
 	// Single page or flatbed mode - standard transfer
+	_, span := startPage(ctx, 1)
 	imageRaw, err := oleutil.CallMethod(item, "Transfer", WiaFormatJPEG)
+	tracing.End(span, err)
 	if err != nil {
-		return nil, fmt.Errorf("failed to transfer image: %w", err)
+		return nil, fmt.Errorf("failed to transfer image: %w", handleWiaError(err))
 	}
 	image := imageRaw.ToIDispatch()
 	defer image.Release()
 
-	filename := fmt.Sprintf("scan_%s.jpg", baseTimestamp)
-	filePath := filepath.Join(outputDir, filename)
+	// Allocate output path in the job directory
+	filePath, err := d.store.NextPagePath(ctx, "JPEG")
+	if err != nil {
+		return nil, err
+	}
 
 	_, err = oleutil.CallMethod(image, "SaveFile", filePath)
 	if err != nil {
@@ -562,23 +553,29 @@ func (d *WindowsDriver) Scan(ctx context.Context, scannerID string, params model
 
 	// Post-processing: Apply JPEG quality control (same as ADF batch mode)
 	if params.MaxQuality || params.JpegQuality > 0 {
-		if err := d.applyImageQuality(filePath, params); err != nil {
-			fmt.Printf("  Warning: Image quality adjustment failed: %v\n", err)
+		done := startStage(ctx, metrics.StageQuality, 1)
+		if err := applyImageQuality(logger, filePath, params); err != nil {
+			logger.Warn("Image quality adjustment failed", logging.Err(err))
 		}
+		done()
 	}
 
 	// Post-processing: Scale ratio (NAPS2 feature)
 	if params.ScaleRatio > 1 {
-		if err := d.applyScaleRatio(filePath, params.ScaleRatio, params); err != nil {
-			fmt.Printf("  Warning: Scale ratio failed: %v\n", err)
+		done := startStage(ctx, metrics.StageScale, 1)
+		if err := applyScaleRatio(logger, filePath, params.ScaleRatio, params); err != nil {
+			logger.Warn("Scale ratio failed", logging.Err(err))
 		}
+		done()
 	}
 
 	// Post-processing: Crop/stretch to page size (NAPS2 feature)
 	if params.CropToPageSize || params.StretchToPageSize {
-		if err := d.applyCropToPageSize(filePath, params); err != nil {
-			fmt.Printf("  Warning: Crop to page size failed: %v\n", err)
+		done := startStage(ctx, metrics.StageCrop, 1)
+		if err := applyCropToPageSize(logger, filePath, params); err != nil {
+			logger.Warn("Crop to page size failed", logging.Err(err))
 		}
+		done()
 	}
 
 	fileInfo, err := os.Stat(filePath)
@@ -594,6 +591,7 @@ func (d *WindowsDriver) Scan(ctx context.Context, scannerID string, params model
 		Format:     "JPEG",
 		Width:      params.Width,
 		Height:     params.Height,
+		Resolution: scaledResolution(params),
 	}
 
 	results = append(results, result)
@@ -607,7 +605,8 @@ func (d *WindowsDriver) Scan(ctx context.Context, scannerID string, params model
 
 // scanADFBatch performs optimized batch scanning for ADF mode
 // Based on NAPS2's WIA 1.0 implementation: continuously call Transfer until PAPER_EMPTY
-func (d *WindowsDriver) scanADFBatch(ctx context.Context, item *ole.IDispatch, outputDir, baseTimestamp string, pageCount int, params models.ScanParams, progressCallback func(int)) ([]models.ScanResult, error) {
+func (d *WindowsDriver) scanADFBatch(ctx context.Context, item *ole.IDispatch, pageCount int, params models.ScanParams, progressCallback func(int)) ([]models.ScanResult, error) {
+	logger := logging.FromContext(ctx, d.logger)
 	var results []models.ScanResult
 
 	// Channel for async file operations
@@ -640,53 +639,9 @@ func (d *WindowsDriver) scanADFBatch(ctx context.Context, item *ole.IDispatch, o
 				continue
 			}
 
-			// Post-processing: Blank page detection (NAPS2 feature)
-			if params.ExcludeBlankPages {
-				// Use default thresholds if not specified
-				whiteThreshold := params.BlankPageWhiteThreshold
-				if whiteThreshold == 0 {
-					whiteThreshold = models.DefaultBlankPageWhiteThreshold // 70
-				}
-				coverageThreshold := params.BlankPageCoverageThreshold
-				if coverageThreshold == 0 {
-					coverageThreshold = models.DefaultBlankPageCoverageThreshold // 15
-				}
-
-				detector := &BlankPageDetector{
-					WhiteThreshold:    whiteThreshold,
-					CoverageThreshold: coverageThreshold,
-				}
-
-				isBlank, err := detector.isBlankPage(task.filePath)
-				if err != nil {
-					fmt.Printf("  Warning: Blank page detection failed for page %d: %v\n", task.pageNum, err)
-				} else if isBlank {
-					// Delete blank page
-					os.Remove(task.filePath)
-					fmt.Printf("  Excluded blank page %d\n", task.pageNum)
-					continue // Skip adding to results
-				}
-			}
-
-			// Post-processing: Scale ratio (NAPS2 feature)
-			if params.ScaleRatio > 1 {
-				if err := d.applyScaleRatio(task.filePath, params.ScaleRatio, params); err != nil {
-					fmt.Printf("  Warning: Scale ratio failed for page %d: %v\n", task.pageNum, err)
-				}
-			}
-
-			// Post-processing: Crop/stretch to page size (NAPS2 feature)
-			if params.CropToPageSize || params.StretchToPageSize {
-				if err := d.applyCropToPageSize(task.filePath, params); err != nil {
-					fmt.Printf("  Warning: Crop to page size failed for page %d: %v\n", task.pageNum, err)
-				}
-			}
-
-			// Post-processing: Image quality control (NAPS2 feature)
-			if params.MaxQuality || params.JpegQuality > 0 {
-				if err := d.applyImageQuality(task.filePath, params); err != nil {
-					fmt.Printf("  Warning: Image quality adjustment failed for page %d: %v\n", task.pageNum, err)
-				}
+			// Post-processing: blank pages, scale, crop and quality (NAPS2 features)
+			if !postProcessPage(ctx, logger, task.filePath, task.pageNum, params) {
+				continue // Blank page, skip adding to results
 			}
 
 			// Get file info
@@ -703,6 +658,7 @@ func (d *WindowsDriver) scanADFBatch(ctx context.Context, item *ole.IDispatch, o
 				Format:     "JPEG",
 				Width:      params.Width,
 				Height:     params.Height,
+				Resolution: scaledResolution(params),
 			}
 
 			task.resultChan <- result
@@ -716,7 +672,7 @@ func (d *WindowsDriver) scanADFBatch(ctx context.Context, item *ole.IDispatch, o
 	// NAPS2's core technique: Loop Transfer calls until PAPER_EMPTY
 	// This is the key to WIA 1.0 batch scanning performance
 	scannedPages := 0
-	fmt.Println("Starting WIA batch scanning loop (NAPS2 mode)...")
+	logger.Debug("Starting WIA feeder scanning loop")
 
 	for i := 0; i < maxPages; i++ {
 		// Check context cancellation
@@ -724,7 +680,7 @@ func (d *WindowsDriver) scanADFBatch(ctx context.Context, item *ole.IDispatch, o
 		case <-ctx.Done():
 			close(saveChan)
 			<-doneChan
-			return results, ctx.Err()
+			return results, cancelledError(ctx.Err())
 		default:
 		}
 
@@ -736,19 +692,26 @@ func (d *WindowsDriver) scanADFBatch(ctx context.Context, item *ole.IDispatch, o
 
 		// Transfer image - this is the hardware scan operation
 		// WIA will block here until the page is scanned
-		fmt.Printf("Calling Transfer for page %d...\n", i+1)
+		logger.Debug("Transferring page", "page", i+1)
+		_, span := startPage(ctx, i+1)
 		imageRaw, err := oleutil.CallMethod(item, "Transfer", WiaFormatJPEG)
+		if isWiaError(err, WIA_ERROR_PAPER_EMPTY) || isWiaError(err, WIA_ERROR_NO_MORE_ITEMS) {
+			// The feeder running out ends the scan normally
+			span.End()
+		} else {
+			tracing.End(span, err)
+		}
 
 		if err != nil {
-			// Check if it's PAPER_EMPTY error (expected when done)
-			if isWiaError(err, WIA_ERROR_PAPER_EMPTY) {
-				fmt.Printf("Feeder empty after %d pages (normal)\n", scannedPages)
-				break
-			}
-
-			// Check for NO_MORE_ITEMS (undocumented, seen in NAPS2)
-			if isWiaError(err, WIA_ERROR_NO_MORE_ITEMS) {
-				fmt.Printf("No more items after %d pages (normal)\n", scannedPages)
+			// PAPER_EMPTY, or the undocumented NO_MORE_ITEMS seen in NAPS2, ends
+			// the scan. Before the first page it means the feeder was empty.
+			if isWiaError(err, WIA_ERROR_PAPER_EMPTY) || isWiaError(err, WIA_ERROR_NO_MORE_ITEMS) {
+				if scannedPages == 0 {
+					close(saveChan)
+					<-doneChan
+					return nil, handleWiaError(err)
+				}
+				logger.Debug("Feeder empty", "pages", scannedPages)
 				break
 			}
 
@@ -760,7 +723,7 @@ func (d *WindowsDriver) scanADFBatch(ctx context.Context, item *ole.IDispatch, o
 			}
 
 			// Subsequent page errors might just mean we're done
-			fmt.Printf("Transfer error after %d pages: %v\n", scannedPages, err)
+			logger.Warn("Transfer failed, ending feeder scan", "pages", scannedPages, logging.Err(err))
 			break
 		}
 
@@ -769,16 +732,21 @@ func (d *WindowsDriver) scanADFBatch(ctx context.Context, item *ole.IDispatch, o
 		// Check for empty stream (NAPS2 pattern - line 254-257)
 		// Some scanners return success but empty image
 		if image == nil {
-			fmt.Println("Warning: Received nil image from Transfer")
+			logger.Warn("Transfer returned no image, ending feeder scan", "pages", scannedPages)
 			break
 		}
 
 		scannedPages++
-		fmt.Printf("Successfully scanned page %d\n", scannedPages)
+		logger.Debug("Page scanned", "page", scannedPages)
 
-		// Generate filename
-		filename := fmt.Sprintf("scan_%s_page_%d.jpg", baseTimestamp, scannedPages)
-		filePath := filepath.Join(outputDir, filename)
+		// Allocate output path in the job directory
+		filePath, err := d.store.NextPagePath(ctx, "JPEG")
+		if err != nil {
+			image.Release()
+			close(saveChan)
+			<-doneChan
+			return results, err
+		}
 
 		// Send to async saver immediately (NAPS2 async pattern)
 		// This allows scanner to start next page while we save current one
@@ -794,7 +762,7 @@ func (d *WindowsDriver) scanADFBatch(ctx context.Context, item *ole.IDispatch, o
 		// This is the NAPS2 WIA 1.0 batch scanning secret!
 	}
 
-	fmt.Printf("Scanning phase complete. Scanned %d pages total.\n", scannedPages)
+	logger.Debug("Feeder scanning complete", "pages", scannedPages)
 
 	// Close save channel and wait for all saves to complete
 	close(saveChan)
@@ -813,7 +781,7 @@ func (d *WindowsDriver) scanADFBatch(ctx context.Context, item *ole.IDispatch, o
 		case err := <-errChan:
 			return results, err
 		case <-ctx.Done():
-			return results, ctx.Err()
+			return results, cancelledError(ctx.Err())
 		}
 	}
 
@@ -821,7 +789,7 @@ func (d *WindowsDriver) scanADFBatch(ctx context.Context, item *ole.IDispatch, o
 		progressCallback(100)
 	}
 
-	fmt.Printf("Batch scanning complete: %d pages saved successfully\n", len(results))
+	logger.Info("Feeder scan saved", "pages", len(results))
 	return results, nil
 }
 
@@ -839,20 +807,20 @@ func (d *WindowsDriver) setProperty(props *ole.IDispatch, propID string, value i
 
 // safeSetPropertyInt sets a property by integer ID, logging errors but not failing
 // This matches NAPS2's SafeSetProperty pattern
-func (d *WindowsDriver) safeSetPropertyInt(props *ole.IDispatch, propID int, value interface{}) {
+func (d *WindowsDriver) safeSetPropertyInt(logger *slog.Logger, props *ole.IDispatch, propID int, value interface{}) {
 	propIDStr := fmt.Sprintf("%d", propID)
 	err := d.setProperty(props, propIDStr, value)
 	if err != nil {
 		// Log but don't fail - property might not be supported
-		fmt.Printf("Warning: Could not set property %d (0x%X): %v\n", propID, propID, err)
+		logger.Debug("Could not set WIA property", "property", fmt.Sprintf("%d (0x%X)", propID, propID), logging.Err(err))
 	}
 }
 
 // safeSetProperty sets a property by string ID, logging errors but not failing
-func (d *WindowsDriver) safeSetProperty(props *ole.IDispatch, propID string, value interface{}) {
+func (d *WindowsDriver) safeSetProperty(logger *slog.Logger, props *ole.IDispatch, propID string, value interface{}) {
 	err := d.setProperty(props, propID, value)
 	if err != nil {
-		fmt.Printf("Warning: Could not set property %s: %v\n", propID, err)
+		logger.Debug("Could not set WIA property", "property", propID, logging.Err(err))
 	}
 }
 
@@ -867,7 +835,7 @@ func isWiaError(err error, errorCode uint32) bool {
 	return len(errMsg) > 0 && (errMsg == hexCode || len(errMsg) > len(hexCode) && errMsg[len(errMsg)-len(hexCode):] == hexCode)
 }
 
-// handleWiaError converts WIA error codes to user-friendly messages
+// handleWiaError maps WIA error codes to typed scanner errors
 func handleWiaError(err error) error {
 	if err == nil {
 		return nil
@@ -875,25 +843,25 @@ func handleWiaError(err error) error {
 
 	// Check for specific WIA error codes
 	if isWiaError(err, WIA_ERROR_PAPER_EMPTY) {
-		return fmt.Errorf("feeder is empty - no more pages to scan")
+		return fmt.Errorf("%w - no more pages to scan", ErrFeederEmpty)
 	}
 	if isWiaError(err, WIA_ERROR_PAPER_JAM) {
-		return fmt.Errorf("paper jam detected")
+		return ErrPaperJam
 	}
-	if isWiaError(err, WIA_ERROR_OFFLINE) {
-		return fmt.Errorf("scanner is offline")
+	if isWiaError(err, WIA_ERROR_OFFLINE) || isWiaError(err, WIA_ERROR_NO_DEVICE) {
+		return ErrOffline
 	}
-	if isWiaError(err, WIA_ERROR_BUSY) {
-		return fmt.Errorf("scanner is busy")
+	if isWiaError(err, WIA_ERROR_BUSY) || isWiaError(err, WIA_ERROR_DEVICE_LOCKED) {
+		return ErrBusy
 	}
 	if isWiaError(err, WIA_ERROR_WARMING_UP) {
-		return fmt.Errorf("scanner is warming up")
+		return fmt.Errorf("%w - warming up", ErrBusy)
 	}
 	if isWiaError(err, WIA_ERROR_COVER_OPEN) {
-		return fmt.Errorf("scanner cover is open")
+		return ErrCoverOpen
 	}
 	if isWiaError(err, WIA_ERROR_NO_MORE_ITEMS) {
-		return fmt.Errorf("no more pages available")
+		return fmt.Errorf("%w - no more pages available", ErrFeederEmpty)
 	}
 
 	return err
@@ -929,6 +897,7 @@ func (d *WindowsDriver) Close() error {
 // calculateScanArea calculates scan area in pixels based on page size settings
 // Implements NAPS2's paper size calculation algorithm (WiaScanDriver.cs:447-474)
 func (d *WindowsDriver) calculateScanArea(
+	logger *slog.Logger,
 	device *ole.IDispatch,
 	item *ole.IDispatch,
 	params models.ScanParams,
@@ -976,18 +945,18 @@ func (d *WindowsDriver) calculateScanArea(
 	pageWidthPixels := int(float64(pageWidthMM) / 25.4 * float64(resolution))
 	pageHeightPixels := int(float64(pageHeightMM) / 25.4 * float64(resolution))
 
-	fmt.Printf("  Page size: %dx%d mm = %dx%d pixels @ %d DPI\n",
-		pageWidthMM, pageHeightMM, pageWidthPixels, pageHeightPixels, resolution)
+	logger.Debug("Page size",
+		"width_mm", pageWidthMM, "height_mm", pageHeightMM,
+		"width", pageWidthPixels, "height", pageHeightPixels, "dpi", resolution)
 
 	// 3. Calculate horizontal alignment if requested
 	xPos = 0
 	if params.PageAlign != "" {
 		// Get maximum scan width from device
-		maxWidthPixels := d.getMaxScanWidth(device, item, useFeeder, resolution)
+		maxWidthPixels := d.getMaxScanWidth(logger, device, item, useFeeder, resolution)
 		if maxWidthPixels > 0 && maxWidthPixels > pageWidthPixels {
 			xPos = d.calculateHorizontalAlignment(pageWidthPixels, maxWidthPixels, params.PageAlign)
-			fmt.Printf("  Horizontal align: %s (xPos=%d, maxWidth=%d)\n",
-				params.PageAlign, xPos, maxWidthPixels)
+			logger.Debug("Horizontal alignment", "align", params.PageAlign, "x_offset", xPos, "max_width", maxWidthPixels)
 		}
 	}
 
@@ -1020,6 +989,7 @@ func (d *WindowsDriver) calculateHorizontalAlignment(
 // getMaxScanWidth retrieves the maximum scan width from device properties
 // Reads WIA device properties to determine hardware limits
 func (d *WindowsDriver) getMaxScanWidth(
+	logger *slog.Logger,
 	device *ole.IDispatch,
 	item *ole.IDispatch,
 	useFeeder bool,
@@ -1039,7 +1009,7 @@ func (d *WindowsDriver) getMaxScanWidth(
 		if valueRaw, err := oleutil.GetProperty(prop, "Value"); err == nil {
 			maxWidth := int(valueRaw.Val)
 			prop.Release()
-			fmt.Printf("  Max scan width (WIA 2.0): %d pixels\n", maxWidth)
+			logger.Debug("Max scan width", "source", "WIA 2.0", "width", maxWidth)
 			return maxWidth
 		}
 		prop.Release()
@@ -1062,8 +1032,7 @@ func (d *WindowsDriver) getMaxScanWidth(
 			maxWidthThousandths := int(valueRaw.Val)
 			maxWidthPixels := (maxWidthThousandths * resolution) / 1000
 			prop.Release()
-			fmt.Printf("  Max scan width (WIA 1.0): %d pixels (%d/1000 inch)\n",
-				maxWidthPixels, maxWidthThousandths)
+			logger.Debug("Max scan width", "source", "WIA 1.0", "width", maxWidthPixels, "thousandths_inch", maxWidthThousandths)
 			return maxWidthPixels
 		}
 		prop.Release()
@@ -1071,347 +1040,6 @@ func (d *WindowsDriver) getMaxScanWidth(
 
 	// Fallback: assume A4 width (210mm) as maximum
 	defaultMaxWidth := int(210.0 / 25.4 * float64(resolution))
-	fmt.Printf("  Max scan width (default A4): %d pixels\n", defaultMaxWidth)
+	logger.Debug("Max scan width", "source", "default A4", "width", defaultMaxWidth)
 	return defaultMaxWidth
 }
-
-// BlankPageDetector detects blank pages using NAPS2's YUV luma algorithm
-// Implements NAPS2's blank page detection (BlankDetectionImageOp.cs)
-type BlankPageDetector struct {
-	WhiteThreshold    int // 0-100 (default: 70) - brightness threshold for "white"
-	CoverageThreshold int // 0-100 (default: 15) - percentage of non-white pixels
-}
-
-// isBlankPage detects if an image is a blank page
-// Uses NAPS2's YUV luma algorithm for accurate detection
-func (d *BlankPageDetector) isBlankPage(imagePath string) (bool, error) {
-	// 1. Open and decode image
-	file, err := os.Open(imagePath)
-	if err != nil {
-		return false, fmt.Errorf("failed to open image: %w", err)
-	}
-	defer file.Close()
-
-	img, _, err := image.Decode(file)
-	if err != nil {
-		return false, fmt.Errorf("failed to decode image: %w", err)
-	}
-
-	// 2. Calculate adjusted thresholds using NAPS2's formulas
-	// whiteThresholdAdjusted = 1 + (whiteThreshold / 100.0) * 254
-	// Example: whiteThreshold=70 -> 179
-	whiteThresholdAdjusted := 1 + int(float64(d.WhiteThreshold)/100.0*254)
-
-	// coverageThresholdAdjusted = 0.00 + (coverageThreshold / 100.0) * 0.01
-	// Example: coverageThreshold=15 -> 0.0015 (0.15%)
-	coverageThresholdAdjusted := 0.00 + (float64(d.CoverageThreshold)/100.0)*0.01
-
-	// 3. Ignore 1% edge area to avoid border effects (NAPS2 pattern)
-	bounds := img.Bounds()
-	ignoreEdge := int(float64(bounds.Dx()) * 0.01)
-	if ignoreEdge < 1 {
-		ignoreEdge = 0
-	}
-
-	startX := bounds.Min.X + ignoreEdge
-	endX := bounds.Max.X - ignoreEdge
-	startY := bounds.Min.Y + ignoreEdge
-	endY := bounds.Max.Y - ignoreEdge
-
-	// Ensure valid bounds
-	if startX >= endX || startY >= endY {
-		startX = bounds.Min.X
-		endX = bounds.Max.X
-		startY = bounds.Min.Y
-		endY = bounds.Max.Y
-	}
-
-	// 4. Scan pixels and calculate coverage
-	totalPixels := (endX - startX) * (endY - startY)
-	nonWhitePixels := 0
-
-	for y := startY; y < endY; y++ {
-		for x := startX; x < endX; x++ {
-			r, g, b, _ := img.At(x, y).RGBA()
-
-			// Convert from 16-bit to 8-bit
-			r8 := uint8(r >> 8)
-			g8 := uint8(g >> 8)
-			b8 := uint8(b >> 8)
-
-			// YUV luma formula (NAPS2: r*299 + g*587 + b*114)
-			// This is the standard ITU-R BT.601 luma calculation
-			// Multiplied by 1000 to avoid floating point (NAPS2 pattern)
-			luma := int(r8)*299 + int(g8)*587 + int(b8)*114
-
-			// Check if pixel is non-white
-			// luma < whiteThresholdAdjusted * 1000
-			if luma < whiteThresholdAdjusted*1000 {
-				nonWhitePixels++
-			}
-		}
-	}
-
-	// 5. Calculate coverage ratio
-	coverage := float64(nonWhitePixels) / float64(totalPixels)
-
-	// 6. Determine if blank
-	isBlank := coverage < coverageThresholdAdjusted
-
-	fmt.Printf("  Blank page detection: coverage=%.4f%%, threshold=%.4f%%, blank=%v\n",
-		coverage*100, coverageThresholdAdjusted*100, isBlank)
-
-	return isBlank, nil
-}
-
-// applyImageQuality applies quality settings to a saved image
-// Implements NAPS2's image quality control (MaxQuality and JPEG compression)
-func (d *WindowsDriver) applyImageQuality(imagePath string, params models.ScanParams) error {
-	// Recompress if quality settings are specified
-	// This ensures we apply the user's chosen quality instead of WIA's default
-	shouldRecompress := false
-
-	if params.MaxQuality {
-		shouldRecompress = true
-	} else if params.JpegQuality > 0 {
-		// Always recompress if user specified a quality (even if it's the default 75)
-		// because WIA's SaveFile may use a different default quality
-		shouldRecompress = true
-	}
-
-	if !shouldRecompress {
-		return nil // No quality adjustment needed
-	}
-
-	// 1. Open and decode image
-	file, err := os.Open(imagePath)
-	if err != nil {
-		return fmt.Errorf("failed to open image: %w", err)
-	}
-	defer file.Close()
-
-	img, format, err := image.Decode(file)
-	if err != nil {
-		return fmt.Errorf("failed to decode image: %w", err)
-	}
-	file.Close() // Close early to allow overwriting
-
-	// 2. Determine output format and quality
-	var outputPath string
-	var saveErr error
-
-	if params.MaxQuality {
-		// Lossless PNG encoding
-		outputPath = imagePath
-		// Change extension to .png if it's not already
-		if filepath.Ext(outputPath) == ".jpg" || filepath.Ext(outputPath) == ".jpeg" {
-			outputPath = outputPath[:len(outputPath)-len(filepath.Ext(outputPath))] + ".png"
-		}
-
-		outFile, err := os.Create(outputPath)
-		if err != nil {
-			return fmt.Errorf("failed to create output file: %w", err)
-		}
-		defer outFile.Close()
-
-		saveErr = savePNG(img, outFile)
-		if saveErr == nil && outputPath != imagePath {
-			// Remove old JPEG file if we created a new PNG
-			os.Remove(imagePath)
-			fmt.Printf("  Saved as lossless PNG (MaxQuality): %s\n", outputPath)
-		}
-	} else {
-		// JPEG compression with specified quality
-		quality := params.JpegQuality
-		if quality == 0 {
-			quality = models.DefaultJpegQuality // 75
-		}
-
-		outFile, err := os.Create(imagePath)
-		if err != nil {
-			return fmt.Errorf("failed to create output file: %w", err)
-		}
-		defer outFile.Close()
-
-		saveErr = saveJPEG(img, outFile, quality)
-		fmt.Printf("  Recompressed JPEG (quality=%d, original=%s)\n", quality, format)
-	}
-
-	return saveErr
-}
-
-// saveJPEG saves an image as JPEG with specified quality
-func saveJPEG(img image.Image, w io.Writer, quality int) error {
-	return jpeg.Encode(w, img, &jpeg.Options{Quality: quality})
-}
-
-// savePNG saves an image as PNG (lossless)
-func savePNG(img image.Image, w io.Writer) error {
-	return png.Encode(w, img)
-}
-
-// applyScaleRatio scales an image by the specified ratio
-// Implements NAPS2's scale transformation (1:1, 1:2, 1:4, 1:8)
-func (d *WindowsDriver) applyScaleRatio(imagePath string, scaleRatio int, params models.ScanParams) error {
-	if scaleRatio <= 1 {
-		return nil // No scaling needed
-	}
-
-	// 1. Open and decode image
-	file, err := os.Open(imagePath)
-	if err != nil {
-		return fmt.Errorf("failed to open image: %w", err)
-	}
-	defer file.Close()
-
-	img, format, err := image.Decode(file)
-	if err != nil {
-		return fmt.Errorf("failed to decode image: %w", err)
-	}
-	file.Close() // Close early to allow overwriting
-
-	// 2. Calculate new dimensions (NAPS2: scaleFactor = 1.0 / scaleRatio)
-	bounds := img.Bounds()
-	oldWidth := bounds.Dx()
-	oldHeight := bounds.Dy()
-	newWidth := oldWidth / scaleRatio
-	newHeight := oldHeight / scaleRatio
-
-	if newWidth < 1 {
-		newWidth = 1
-	}
-	if newHeight < 1 {
-		newHeight = 1
-	}
-
-	fmt.Printf("  Scaling image: %dx%d -> %dx%d (ratio 1:%d)\n",
-		oldWidth, oldHeight, newWidth, newHeight, scaleRatio)
-
-	// 3. Resize using high-quality Lanczos3 interpolation
-	scaled := resize.Resize(
-		uint(newWidth),
-		uint(newHeight),
-		img,
-		resize.Lanczos3, // High-quality interpolation (NAPS2 uses similar)
-	)
-
-	// 4. Save scaled image
-	outFile, err := os.Create(imagePath)
-	if err != nil {
-		return fmt.Errorf("failed to create output file: %w", err)
-	}
-	defer outFile.Close()
-
-	// Use appropriate format
-	if format == "png" || params.MaxQuality {
-		return savePNG(scaled, outFile)
-	}
-
-	quality := params.JpegQuality
-	if quality == 0 {
-		quality = models.DefaultJpegQuality // 75
-	}
-	return saveJPEG(scaled, outFile, quality)
-}
-
-// applyCropToPageSize crops or resizes image to match target page size
-// Implements NAPS2's crop/stretch to page size feature
-func (d *WindowsDriver) applyCropToPageSize(imagePath string, params models.ScanParams) error {
-	if !params.CropToPageSize && !params.StretchToPageSize {
-		return nil // No processing needed
-	}
-
-	// 1. Get target page dimensions in millimeters
-	var pageWidthMM, pageHeightMM int
-	if params.PageSize != "" && params.PageSize != "Custom" {
-		if size, ok := models.PaperSizes[params.PageSize]; ok {
-			pageWidthMM = size.Width
-			pageHeightMM = size.Height
-		}
-	} else {
-		pageWidthMM = params.PageWidth
-		pageHeightMM = params.PageHeight
-
-		// Fallback to legacy fields
-		if pageWidthMM == 0 {
-			pageWidthMM = params.Width
-		}
-		if pageHeightMM == 0 {
-			pageHeightMM = params.Height
-		}
-	}
-
-	// Default to A4 if no size specified
-	if pageWidthMM == 0 && pageHeightMM == 0 {
-		pageWidthMM = 210 // A4
-		pageHeightMM = 297
-	}
-
-	// 2. Convert to pixels using scan resolution
-	resolution := params.Resolution
-	if resolution == 0 {
-		resolution = 300
-	}
-
-	targetWidth := int(float64(pageWidthMM) / 25.4 * float64(resolution))
-	targetHeight := int(float64(pageHeightMM) / 25.4 * float64(resolution))
-
-	// 3. Open and decode image
-	img, err := imaging.Open(imagePath)
-	if err != nil {
-		return fmt.Errorf("failed to open image: %w", err)
-	}
-
-	bounds := img.Bounds()
-	currentWidth := bounds.Dx()
-	currentHeight := bounds.Dy()
-
-	// 4. Detect orientation and swap page dimensions if needed (NAPS2 pattern)
-	isImageLandscape := currentWidth > currentHeight
-	isPageLandscape := targetWidth > targetHeight
-
-	if isImageLandscape != isPageLandscape {
-		// Swap target dimensions to match orientation
-		targetWidth, targetHeight = targetHeight, targetWidth
-		fmt.Printf("  Swapped page dimensions to match orientation: %dx%d\n", targetWidth, targetHeight)
-	}
-
-	var processed image.Image
-
-	// 5. Apply transformation
-	if params.CropToPageSize {
-		// Crop mode: physically crop image to target size
-		if currentWidth > targetWidth || currentHeight > targetHeight {
-			processed = imaging.CropCenter(img, targetWidth, targetHeight)
-			fmt.Printf("  Cropped to page size: %dx%d -> %dx%d\n",
-				currentWidth, currentHeight, targetWidth, targetHeight)
-		} else {
-			processed = img // Image is already smaller than target
-			fmt.Printf("  Image smaller than page size, no crop needed\n")
-		}
-	} else if params.StretchToPageSize {
-		// Stretch mode: resize to fit within target size while preserving aspect ratio
-		processed = imaging.Fit(img, targetWidth, targetHeight, imaging.Lanczos)
-		newBounds := processed.Bounds()
-		fmt.Printf("  Resized to fit page: %dx%d -> %dx%d\n",
-			currentWidth, currentHeight, newBounds.Dx(), newBounds.Dy())
-	} else {
-		processed = img
-	}
-
-	// 6. Save processed image
-	quality := params.JpegQuality
-	if quality == 0 {
-		quality = models.DefaultJpegQuality
-	}
-
-	// Use PNG for MaxQuality, otherwise JPEG
-	var saveErr error
-	if params.MaxQuality {
-		saveErr = imaging.Save(processed, imagePath, imaging.PNGCompressionLevel(png.BestCompression))
-	} else {
-		saveErr = imaging.Save(processed, imagePath, imaging.JPEGQuality(quality))
-	}
-
-	return saveErr
-}
@@ -4,8 +4,12 @@ package scanner
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log/slog"
 
+	"github.com/scanserver/scanner-service/internal/logging"
+	"github.com/scanserver/scanner-service/internal/storage"
 	"github.com/scanserver/scanner-service/pkg/models"
 )
 
@@ -14,37 +18,41 @@ type CombinedWindowsDriver struct {
 	wiaDriver   *WindowsDriver
 	twainDriver *TWAINDriver
 	useWIA      bool
+	initErr     error // Why WIA or TWAIN is unavailable
+	logger      *slog.Logger
 }
 
-func newCombinedDriver() (ScannerDriver, error) {
-	driver := &CombinedWindowsDriver{}
+func newCombinedDriver(store *storage.FileStore, logger *slog.Logger) (ScannerDriver, error) {
+	driver := &CombinedWindowsDriver{logger: logger}
 	var wiaErr, twainErr error
 
 	// Initialize BOTH drivers to detect all scanners
 	// Some scanners (like D2800+) may only be visible via TWAIN
 	// while others are only visible via WIA
 
-	fmt.Println("Initializing combined WIA+TWAIN driver...")
+	logger.Info("Initializing combined WIA+TWAIN driver")
 
 	// Try WIA (modern Windows scanners)
-	wiaDriver, err := newWIADriver()
+	wiaDriver, err := newWIADriver(store, logger)
 	if err == nil {
 		driver.wiaDriver = wiaDriver
 		driver.useWIA = true
-		fmt.Println("✓ WIA driver initialized successfully")
+		logger.Info("WIA driver initialized")
 	} else {
 		wiaErr = err
-		fmt.Printf("⚠ WIA driver initialization failed: %v\n", err)
+		driver.initErr = errors.Join(driver.initErr, fmt.Errorf("WIA unavailable: %w", err))
+		logger.Warn("WIA driver initialization failed", logging.Err(err))
 	}
 
 	// Also try TWAIN (legacy scanners and some USB devices)
-	twainDriver, err := newTWAINDriver()
+	twainDriver, err := newTWAINDriver(store, logger)
 	if err == nil {
 		driver.twainDriver = twainDriver
-		fmt.Println("✓ TWAIN driver initialized successfully")
+		logger.Info("TWAIN driver initialized")
 	} else {
 		twainErr = err
-		fmt.Printf("⚠ TWAIN driver initialization failed: %v\n", err)
+		driver.initErr = errors.Join(driver.initErr, fmt.Errorf("TWAIN unavailable: %w", err))
+		logger.Warn("TWAIN driver initialization failed", logging.Err(err))
 	}
 
 	// Require at least one driver to work
@@ -52,45 +60,47 @@ func newCombinedDriver() (ScannerDriver, error) {
 		return nil, fmt.Errorf("no scanner driver available. WIA error: %v, TWAIN error: %v", wiaErr, twainErr)
 	}
 
-	fmt.Printf("Combined driver initialized with WIA=%v, TWAIN=%v\n", driver.wiaDriver != nil, driver.twainDriver != nil)
+	logger.Info("Combined driver initialized", "wia", driver.wiaDriver != nil, "twain", driver.twainDriver != nil)
 	return driver, nil
 }
 
+// InitError returns why WIA or TWAIN failed to initialize, if one did
+func (d *CombinedWindowsDriver) InitError() error {
+	return d.initErr
+}
+
 func (d *CombinedWindowsDriver) ListScanners(ctx context.Context) ([]models.Scanner, error) {
+	logger := logging.FromContext(ctx, d.logger)
 	var allScanners []models.Scanner
 
-	fmt.Println("\n=== Combined Driver: Enumerating Scanners ===")
-
 	// Try to get scanners from both drivers
 	if d.wiaDriver != nil {
-		fmt.Println("Checking WIA driver for scanners...")
 		scanners, err := d.wiaDriver.ListScanners(ctx)
 		if err == nil {
-			fmt.Printf("✓ WIA found %d scanner(s)\n", len(scanners))
+			logger.Debug("WIA scanners found", "count", len(scanners))
 			for _, scanner := range scanners {
 				scanner.ID = "wia:" + scanner.ID // Prefix to identify source
 				allScanners = append(allScanners, scanner)
 			}
 		} else {
-			fmt.Printf("⚠ WIA scanner enumeration failed: %v\n", err)
+			logger.Warn("WIA scanner enumeration failed", logging.Err(err))
 		}
 	}
 
 	if d.twainDriver != nil {
-		fmt.Println("\nChecking TWAIN driver for scanners...")
 		scanners, err := d.twainDriver.ListScanners(ctx)
 		if err == nil {
-			fmt.Printf("✓ TWAIN found %d scanner(s)\n", len(scanners))
+			logger.Debug("TWAIN scanners found", "count", len(scanners))
 			for _, scanner := range scanners {
 				scanner.ID = "twain:" + scanner.ID // Prefix to identify source
 				allScanners = append(allScanners, scanner)
 			}
 		} else {
-			fmt.Printf("⚠ TWAIN scanner enumeration failed: %v\n", err)
+			logger.Warn("TWAIN scanner enumeration failed", logging.Err(err))
 		}
 	}
 
-	fmt.Printf("\n=== Total: %d scanner(s) found ===\n\n", len(allScanners))
+	logger.Info("Scanner enumeration complete", "count", len(allScanners))
 
 	if len(allScanners) == 0 {
 		return nil, fmt.Errorf("no scanners found via WIA or TWAIN")
@@ -128,7 +138,7 @@ func (d *CombinedWindowsDriver) GetScanner(ctx context.Context, scannerID string
 		return d.twainDriver.GetScanner(ctx, actualID)
 	}
 
-	return nil, fmt.Errorf("scanner not found: %s", scannerID)
+	return nil, fmt.Errorf("%w: %s", ErrScannerNotFound, scannerID)
 }
 
 func (d *CombinedWindowsDriver) Scan(ctx context.Context, scannerID string, params models.ScanParams, progressCallback func(int)) ([]models.ScanResult, error) {
@@ -192,7 +202,7 @@ func (d *CombinedWindowsDriver) CancelScan(ctx context.Context, scannerID string
 		return d.twainDriver.CancelScan(ctx, actualID)
 	}
 
-	return fmt.Errorf("scanner not found: %s", scannerID)
+	return fmt.Errorf("%w: %s", ErrScannerNotFound, scannerID)
 }
 
 func (d *CombinedWindowsDriver) WatchLidStatus(ctx context.Context, scannerID string, callback func(lidClosed bool)) error {
@@ -235,6 +245,6 @@ func (d *CombinedWindowsDriver) Close() error {
 }
 
 // Helper function to create WIA driver
-func newWIADriver() (*WindowsDriver, error) {
-	return newWIADriverInternal()
+func newWIADriver(store *storage.FileStore, logger *slog.Logger) (*WindowsDriver, error) {
+	return newWIADriverInternal(store, logger)
 }
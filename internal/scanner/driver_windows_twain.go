@@ -5,12 +5,13 @@ package scanner
 import (
 	"context"
 	"fmt"
-	"os"
-	"path/filepath"
+	"log/slog"
 	"syscall"
 	"time"
 	"unsafe"
 
+	"github.com/scanserver/scanner-service/internal/logging"
+	"github.com/scanserver/scanner-service/internal/storage"
 	"github.com/scanserver/scanner-service/pkg/models"
 )
 
@@ -96,11 +97,15 @@ type TWAINDriver struct {
 	appIdentity *TW_IDENTITY
 	dsIdentity  *TW_IDENTITY
 	initialized bool
+	store       *storage.FileStore
+	logger      *slog.Logger
 }
 
-func newTWAINDriver() (*TWAINDriver, error) {
+func newTWAINDriver(store *storage.FileStore, logger *slog.Logger) (*TWAINDriver, error) {
 	driver := &TWAINDriver{
 		scanners: make(map[string]*models.Scanner),
+		store:    store,
+		logger:   logger.With("driver", "twain"),
 	}
 
 	// Try to load TWAIN DSM
@@ -144,10 +149,10 @@ func (d *TWAINDriver) ListScanners(ctx context.Context) ([]models.Scanner, error
 		return nil, fmt.Errorf("TWAIN driver not initialized")
 	}
 
-	fmt.Println("TWAIN: Starting scanner enumeration...")
+	logger := logging.FromContext(ctx, d.logger)
 
 	// Step 1: Open DSM (Data Source Manager)
-	fmt.Println("TWAIN: Opening Data Source Manager...")
+	logger.Debug("Opening TWAIN Data Source Manager")
 	ret, _, _ := d.dsmEntry.Call(
 		uintptr(unsafe.Pointer(d.appIdentity)),
 		0,
@@ -160,7 +165,6 @@ func (d *TWAINDriver) ListScanners(ctx context.Context) ([]models.Scanner, error
 	if ret != TWRC_SUCCESS {
 		return nil, fmt.Errorf("TWAIN: failed to open DSM, error code: %d", ret)
 	}
-	fmt.Println("TWAIN: DSM opened successfully")
 
 	// Ensure we close DSM when done
 	defer func() {
@@ -172,13 +176,12 @@ func (d *TWAINDriver) ListScanners(ctx context.Context) ([]models.Scanner, error
 			MSG_CLOSEDSM,
 			0,
 		)
-		fmt.Println("TWAIN: DSM closed")
+		logger.Debug("TWAIN Data Source Manager closed")
 	}()
 
 	var scanners []models.Scanner
 
 	// Step 2: Get first data source
-	fmt.Println("TWAIN: Enumerating data sources...")
 	var dsIdentity TW_IDENTITY
 	ret, _, _ = d.dsmEntry.Call(
 		uintptr(unsafe.Pointer(d.appIdentity)),
@@ -190,13 +193,11 @@ func (d *TWAINDriver) ListScanners(ctx context.Context) ([]models.Scanner, error
 	)
 
 	if ret == TWRC_ENDOFLIST {
-		fmt.Println("TWAIN: No data sources found")
 		return nil, fmt.Errorf("no TWAIN data sources found")
 	}
 
 	if ret != TWRC_SUCCESS {
-		fmt.Printf("TWAIN: Failed to get first data source, error code: %d\n", ret)
-		return nil, fmt.Errorf("TWAIN: failed to enumerate data sources")
+		return nil, fmt.Errorf("TWAIN: failed to enumerate data sources, error code: %d", ret)
 	}
 
 	// Process first data source
@@ -209,7 +210,7 @@ func (d *TWAINDriver) ListScanners(ctx context.Context) ([]models.Scanner, error
 		manufacturer := utf16ToString(dsIdentity.Manufacturer[:])
 		productFamily := utf16ToString(dsIdentity.ProductFamily[:])
 
-		fmt.Printf("TWAIN: Found data source %d: %s (%s)\n", scannerCount, productName, manufacturer)
+		logger.Debug("Found TWAIN data source", "index", scannerCount, "name", productName, "manufacturer", manufacturer)
 
 		// Create scanner object
 		scanner := models.Scanner{
@@ -250,12 +251,12 @@ func (d *TWAINDriver) ListScanners(ctx context.Context) ([]models.Scanner, error
 
 		if ret != TWRC_SUCCESS {
 			// Error, but we got at least some scanners
-			fmt.Printf("TWAIN: Enumeration ended with error code: %d\n", ret)
+			logger.Warn("TWAIN enumeration ended early", "code", ret)
 			break
 		}
 	}
 
-	fmt.Printf("TWAIN: Enumeration complete. Found %d TWAIN data source(s)\n", len(scanners))
+	logger.Info("TWAIN enumeration complete", "scanners", len(scanners))
 
 	if len(scanners) == 0 {
 		return nil, fmt.Errorf("no TWAIN data sources found")
@@ -289,7 +290,7 @@ func utf16ToString(u16 []uint16) string {
 func (d *TWAINDriver) GetScanner(ctx context.Context, scannerID string) (*models.Scanner, error) {
 	scanner, ok := d.scanners[scannerID]
 	if !ok {
-		return nil, fmt.Errorf("scanner not found: %s", scannerID)
+		return nil, fmt.Errorf("%w: %s", ErrScannerNotFound, scannerID)
 	}
 	return scanner, nil
 }
@@ -301,7 +302,7 @@ func (d *TWAINDriver) Scan(ctx context.Context, scannerID string, params models.
 	}
 
 	if scanner.Status != "idle" {
-		return nil, fmt.Errorf("scanner is busy")
+		return nil, ErrBusy
 	}
 
 	scanner.Status = "scanning"
@@ -309,10 +310,10 @@ func (d *TWAINDriver) Scan(ctx context.Context, scannerID string, params models.
 		scanner.Status = "idle"
 	}()
 
-	// Create output directory
-	outputDir := "./scans"
-	if err := os.MkdirAll(outputDir, 0755); err != nil {
-		return nil, fmt.Errorf("failed to create output directory: %w", err)
+	// Allocate output path in the job directory
+	filePath, err := d.store.NextPagePath(ctx, "JPEG")
+	if err != nil {
+		return nil, err
 	}
 
 	if progressCallback != nil {
@@ -321,17 +322,14 @@ func (d *TWAINDriver) Scan(ctx context.Context, scannerID string, params models.
 
 	// For now, return a placeholder result
 	// Full TWAIN implementation would require extensive COM interface work
-	timestamp := time.Now().Format("20060102_150405")
-	filename := fmt.Sprintf("scan_%s.jpg", timestamp)
-	filepath := filepath.Join(outputDir, filename)
-
 	result := models.ScanResult{
 		PageNumber: 1,
-		FilePath:   filepath,
+		FilePath:   filePath,
 		FileSize:   0,
 		Format:     "JPEG",
 		Width:      params.Width,
 		Height:     params.Height,
+		Resolution: params.Resolution,
 	}
 
 	if progressCallback != nil {
@@ -0,0 +1,69 @@
+package scanner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Typed scanner errors. Drivers wrap these (fmt.Errorf("%w: ...", ErrX)) so callers
+// can classify failures with errors.Is regardless of platform.
+var (
+	ErrScannerNotFound    = errors.New("scanner not found")
+	ErrFeederEmpty        = errors.New("feeder is empty")
+	ErrPaperJam           = errors.New("paper jam detected")
+	ErrCoverOpen          = errors.New("scanner cover is open")
+	ErrBusy               = errors.New("scanner is busy")
+	ErrOffline            = errors.New("scanner is offline")
+	ErrCancelled          = errors.New("scan cancelled")
+	ErrUnsupportedSetting = errors.New("unsupported scan setting")
+)
+
+// Stable error codes reported by the API and stored on failed jobs
+const (
+	CodeScannerNotFound    = "scanner_not_found"
+	CodeFeederEmpty        = "feeder_empty"
+	CodePaperJam           = "paper_jam"
+	CodeCoverOpen          = "cover_open"
+	CodeBusy               = "scanner_busy"
+	CodeOffline            = "scanner_offline"
+	CodeCancelled          = "scan_cancelled"
+	CodeUnsupportedSetting = "unsupported_setting"
+	CodeInternal           = "internal_error"
+)
+
+// errorCodes maps sentinel errors to their stable codes
+var errorCodes = []struct {
+	err  error
+	code string
+}{
+	{ErrScannerNotFound, CodeScannerNotFound},
+	{ErrFeederEmpty, CodeFeederEmpty},
+	{ErrPaperJam, CodePaperJam},
+	{ErrCoverOpen, CodeCoverOpen},
+	{ErrBusy, CodeBusy},
+	{ErrOffline, CodeOffline},
+	{ErrCancelled, CodeCancelled},
+	{ErrUnsupportedSetting, CodeUnsupportedSetting},
+}
+
+// ErrorCode returns the stable code for err, or CodeInternal if it is not a typed scanner error
+func ErrorCode(err error) string {
+	if err == nil {
+		return ""
+	}
+	if errors.Is(err, context.Canceled) {
+		return CodeCancelled
+	}
+	for _, e := range errorCodes {
+		if errors.Is(err, e.err) {
+			return e.code
+		}
+	}
+	return CodeInternal
+}
+
+// cancelledError wraps a context error so it matches both ErrCancelled and the context error
+func cancelledError(err error) error {
+	return fmt.Errorf("%w: %w", ErrCancelled, err)
+}
@@ -0,0 +1,57 @@
+package scanner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestErrorCode(t *testing.T) {
+	tests := []struct {
+		err  error
+		code string
+	}{
+		{ErrScannerNotFound, CodeScannerNotFound},
+		{ErrFeederEmpty, CodeFeederEmpty},
+		{ErrPaperJam, CodePaperJam},
+		{ErrCoverOpen, CodeCoverOpen},
+		{ErrBusy, CodeBusy},
+		{ErrOffline, CodeOffline},
+		{ErrCancelled, CodeCancelled},
+		{ErrUnsupportedSetting, CodeUnsupportedSetting},
+		{&ValidationError{ScannerID: "scanner-1", Fields: []FieldError{{Field: "resolution"}}}, CodeUnsupportedSetting},
+		{context.Canceled, CodeCancelled},
+		{cancelledError(context.DeadlineExceeded), CodeCancelled},
+		{context.DeadlineExceeded, CodeInternal},
+		{errors.New("scanner not found"), CodeInternal}, // Same text, not the sentinel
+		{errors.New("WIA error 0x80210001"), CodeInternal},
+	}
+	for _, tt := range tests {
+		if code := ErrorCode(tt.err); code != tt.code {
+			t.Errorf("ErrorCode(%v) = %s, want %s", tt.err, code, tt.code)
+		}
+
+		// Drivers and callers wrap the errors, once or several times
+		wrapped := fmt.Errorf("scan of page 3 failed: %w", tt.err)
+		if code := ErrorCode(wrapped); code != tt.code {
+			t.Errorf("ErrorCode(%v) = %s, want %s", wrapped, code, tt.code)
+		}
+		twice := fmt.Errorf("batch scan failed: %w", fmt.Errorf("%w: device reported status 7", tt.err))
+		if code := ErrorCode(twice); code != tt.code {
+			t.Errorf("ErrorCode(%v) = %s, want %s", twice, code, tt.code)
+		}
+	}
+
+	if code := ErrorCode(nil); code != "" {
+		t.Errorf("ErrorCode(nil) = %s", code)
+	}
+	// Each sentinel has a code of its own
+	seen := make(map[string]bool)
+	for _, e := range errorCodes {
+		if seen[e.code] {
+			t.Errorf("code %s is used twice", e.code)
+		}
+		seen[e.code] = true
+	}
+}
@@ -0,0 +1,75 @@
+package scanner
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/scanserver/scanner-service/internal/metrics"
+	"github.com/scanserver/scanner-service/internal/tracing"
+	"github.com/scanserver/scanner-service/pkg/models"
+)
+
+// instrumentedDriver records page counts, scan time and errors of a driver's
+// scans, and traces each scan
+type instrumentedDriver struct {
+	ScannerDriver
+}
+
+// instrument wraps driver so its scans are reported in the metrics and traces
+func instrument(driver ScannerDriver) ScannerDriver {
+	return &instrumentedDriver{ScannerDriver: driver}
+}
+
+// Scan performs a scan operation and records its metrics
+func (d *instrumentedDriver) Scan(ctx context.Context, scannerID string, params models.ScanParams, progressCallback func(int)) ([]models.ScanResult, error) {
+	ctx, span := tracing.Start(ctx, "scanner.scan",
+		tracing.KeyScannerID.String(scannerID),
+		attribute.Int("scan.resolution", params.Resolution),
+		attribute.String("scan.color_mode", params.ColorMode),
+		attribute.Bool("scan.feeder", params.UseFeeder),
+		attribute.Bool("scan.duplex", params.UseDuplex),
+	)
+
+	start := time.Now()
+	results, err := d.ScannerDriver.Scan(ctx, scannerID, params, progressCallback)
+
+	failed := err
+	if errors.Is(err, ErrCancelled) {
+		failed = nil
+	}
+	if failed != nil {
+		metrics.DriverErrors.WithLabelValues(scannerID, ErrorCode(err)).Inc()
+	}
+	if pages := len(results); pages > 0 {
+		metrics.PagesScanned.WithLabelValues(scannerID).Add(float64(pages))
+		if err == nil {
+			perPage := time.Since(start).Seconds() / float64(pages)
+			metrics.PageScanDuration.WithLabelValues(scannerID).Observe(perPage)
+		}
+	}
+
+	span.SetAttributes(tracing.KeyPages.Int(len(results)))
+	tracing.End(span, failed)
+	return results, err
+}
+
+// startPage traces the acquisition of a page from the scanner. The span is
+// ended with tracing.End once the page has been read.
+func startPage(ctx context.Context, page int) (context.Context, trace.Span) {
+	return tracing.Start(ctx, "scanner.page", tracing.KeyPage.Int(page))
+}
+
+// startStage times a post-processing stage of a page for the metrics and
+// traces it. Calling the returned function ends the stage.
+func startStage(ctx context.Context, stage string, page int) func() {
+	start := time.Now()
+	_, span := tracing.Start(ctx, "postprocess."+stage, tracing.KeyPage.Int(page))
+	return func() {
+		metrics.ObserveStage(stage, start)
+		span.End()
+	}
+}
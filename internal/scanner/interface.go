@@ -2,7 +2,15 @@ package scanner
 
 import (
 	"context"
+	"log/slog"
+	"sync"
+	"time"
 
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/scanserver/scanner-service/internal/logging"
+	"github.com/scanserver/scanner-service/internal/storage"
+	"github.com/scanserver/scanner-service/internal/tracing"
 	"github.com/scanserver/scanner-service/pkg/models"
 )
 
@@ -27,20 +35,37 @@ type ScannerDriver interface {
 	Close() error
 }
 
+// partialDriver is implemented by drivers made of several backends that keep
+// working when some of them fail to initialize
+type partialDriver interface {
+	// InitError returns why backends are unavailable, or nil if all initialized
+	InitError() error
+}
+
 // Manager manages scanner operations across platforms
 type Manager struct {
-	driver ScannerDriver
+	driver     ScannerDriver
+	store      *storage.FileStore
+	archive    storage.Storage // Backend completed jobs are archived to, may be nil
+	lastErrors map[string]error // Most recent scan error per scanner
+	errorsMu   sync.RWMutex
+	logger     *slog.Logger
 }
 
 // NewManager creates a new scanner manager
-func NewManager() (*Manager, error) {
-	driver, err := newPlatformDriver()
+// Drivers write scanned pages into store; completed jobs are archived to archive
+func NewManager(store *storage.FileStore, archive storage.Storage, logger *slog.Logger) (*Manager, error) {
+	driver, err := newPlatformDriver(store, logger)
 	if err != nil {
 		return nil, err
 	}
 
 	return &Manager{
-		driver: driver,
+		driver:     instrument(driver),
+		store:      store,
+		archive:    archive,
+		lastErrors: make(map[string]error),
+		logger:     logger,
 	}, nil
 }
 
@@ -55,8 +80,36 @@ func (m *Manager) GetScanner(ctx context.Context, scannerID string) (*models.Sca
 }
 
 // Scan performs a scan operation
+// Pages are stored under the job carried by ctx (see storage.WithJob), or a new one
 func (m *Manager) Scan(ctx context.Context, scannerID string, params models.ScanParams, progressCallback func(int)) ([]models.ScanResult, error) {
-	return m.driver.Scan(ctx, scannerID, params, progressCallback)
+	ctx = withScanLogger(ensureJob(ctx), m.logger, scannerID)
+	results, err := m.driver.Scan(ctx, scannerID, params, progressCallback)
+
+	m.errorsMu.Lock()
+	if err != nil {
+		m.lastErrors[scannerID] = err
+	} else {
+		delete(m.lastErrors, scannerID)
+	}
+	m.errorsMu.Unlock()
+
+	return results, err
+}
+
+// LastError returns the error of the most recent scan on a scanner, or nil if it succeeded
+func (m *Manager) LastError(scannerID string) error {
+	m.errorsMu.RLock()
+	defer m.errorsMu.RUnlock()
+	return m.lastErrors[scannerID]
+}
+
+// ValidateParams checks scan parameters against the capabilities of a scanner
+func (m *Manager) ValidateParams(ctx context.Context, scannerID string, params *models.ScanParams, mode ValidationMode) error {
+	scanner, err := m.driver.GetScanner(ctx, scannerID)
+	if err != nil {
+		return err
+	}
+	return ValidateParams(scanner, params, mode)
 }
 
 // CancelScan cancels an ongoing scan
@@ -77,6 +130,60 @@ func (m *Manager) Close() error {
 	return nil
 }
 
+// ArchiveResults uploads the pages of a completed job to the storage backend
+// and records their storage URIs in results
+func (m *Manager) ArchiveResults(ctx context.Context, job *models.ScanJob, results []models.ScanResult) error {
+	if m.archive == nil {
+		return nil
+	}
+	ctx, span := tracing.Start(ctx, "storage.archive",
+		attribute.String("storage.backend", m.archive.Name()), tracing.KeyPages.Int(len(results)))
+	err := storage.Archive(ctx, m.archive, m.store, job, results)
+	tracing.End(span, err)
+	return err
+}
+
+// Store returns the file store drivers write into
+func (m *Manager) Store() *storage.FileStore {
+	return m.store
+}
+
+// withScanLogger attaches logger, tagged with the job in ctx and scannerID,
+// unless the caller already attached a logger for the job
+func withScanLogger(ctx context.Context, logger *slog.Logger, scannerID string) context.Context {
+	if _, ok := logging.Lookup(ctx); ok {
+		return ctx
+	}
+	job, _ := storage.JobFromContext(ctx)
+	return logging.WithLogger(ctx, logger.With(logging.KeyJobID, job.ID, logging.KeyScannerID, scannerID))
+}
+
+// Logger returns the logger drivers and scans log to
+func (m *Manager) Logger() *slog.Logger {
+	return m.logger
+}
+
+// ensureJob attaches a new job to ctx if it does not carry one already
+func ensureJob(ctx context.Context) context.Context {
+	if _, ok := storage.JobFromContext(ctx); ok {
+		return ctx
+	}
+	return storage.WithJob(ctx, models.GenerateUUID(), time.Now())
+}
+
+// DriverError returns why part of the scanner driver failed to initialize,
+// e.g. TWAIN on Windows when only WIA loaded, or nil
+func (m *Manager) DriverError() error {
+	driver := m.driver
+	if d, ok := driver.(*instrumentedDriver); ok {
+		driver = d.ScannerDriver
+	}
+	if d, ok := driver.(partialDriver); ok {
+		return d.InitError()
+	}
+	return nil
+}
+
 // GetDriver returns the underlying scanner driver
 // Used by batch scan performer to access low-level driver functions
 func (m *Manager) GetDriver() ScannerDriver {
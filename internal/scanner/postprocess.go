@@ -0,0 +1,382 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"github.com/disintegration/imaging"
+	"github.com/nfnt/resize"
+	"github.com/scanserver/scanner-service/internal/logging"
+	"github.com/scanserver/scanner-service/internal/metrics"
+	"github.com/scanserver/scanner-service/pkg/models"
+)
+
+// PostProcess applies the post-processing of params to saved pages, e.g.
+// simulated pages or files imported from the hot folder: blank page
+// exclusion, scale ratio, crop/stretch to page size and image quality.
+// Blank pages are deleted and the remaining pages renumbered. Pages that
+// are not JPEG or PNG images are kept as they are.
+func PostProcess(ctx context.Context, params models.ScanParams, pages []models.ScanResult) []models.ScanResult {
+	logger := logging.FromContext(ctx, nil)
+
+	var results []models.ScanResult
+	for _, page := range pages {
+		if page.Format != "JPEG" && page.Format != "PNG" {
+			page.PageNumber = len(results) + 1
+			results = append(results, page)
+			continue
+		}
+
+		pageParams := params
+		if page.Resolution > 0 {
+			pageParams.Resolution = page.Resolution
+		}
+		if page.Format != "JPEG" {
+			// Recompressing with a JPEG quality only applies to JPEG pages
+			pageParams.JpegQuality = 0
+		}
+		if !postProcessPage(ctx, logger, page.FilePath, page.PageNumber, pageParams) {
+			continue
+		}
+
+		if path := qualityPath(page.FilePath, pageParams); path != page.FilePath {
+			page.FilePath = path
+			page.Format = "PNG"
+		}
+		if info, err := os.Stat(page.FilePath); err == nil {
+			page.FileSize = info.Size()
+		}
+		if page.Resolution > 0 {
+			page.Resolution = scaledResolution(pageParams)
+		}
+		page.PageNumber = len(results) + 1
+		results = append(results, page)
+	}
+	return results
+}
+
+// postProcessPage applies the post-processing of params to a saved page in
+// NAPS2's order. It returns false if the page was blank and has been deleted.
+func postProcessPage(ctx context.Context, logger *slog.Logger, path string, page int, params models.ScanParams) bool {
+	// Blank page detection (NAPS2 feature)
+	if params.ExcludeBlankPages {
+		detector := newBlankPageDetector(params, logger)
+
+		done := startStage(ctx, metrics.StageBlankDetection, page)
+		isBlank, err := detector.isBlankPage(path)
+		done()
+		if err != nil {
+			logger.Warn("Blank page detection failed", "page", page, logging.Err(err))
+		} else if isBlank {
+			os.Remove(path)
+			metrics.BlankPagesDropped.Inc()
+			logger.Debug("Excluded blank page", "page", page)
+			return false
+		}
+	}
+
+	// Scale ratio (NAPS2 feature)
+	if params.ScaleRatio > 1 {
+		done := startStage(ctx, metrics.StageScale, page)
+		if err := applyScaleRatio(logger, path, params.ScaleRatio, params); err != nil {
+			logger.Warn("Scale ratio failed", "page", page, logging.Err(err))
+		}
+		done()
+	}
+
+	// Crop/stretch to page size (NAPS2 feature)
+	if params.CropToPageSize || params.StretchToPageSize {
+		done := startStage(ctx, metrics.StageCrop, page)
+		if err := applyCropToPageSize(logger, path, params); err != nil {
+			logger.Warn("Crop to page size failed", "page", page, logging.Err(err))
+		}
+		done()
+	}
+
+	// Image quality control (NAPS2 feature)
+	if params.MaxQuality || params.JpegQuality > 0 {
+		done := startStage(ctx, metrics.StageQuality, page)
+		if err := applyImageQuality(logger, path, params); err != nil {
+			logger.Warn("Image quality adjustment failed", "page", page, logging.Err(err))
+		}
+		done()
+	}
+
+	return true
+}
+
+// qualityPath returns the path applyImageQuality saves imagePath to
+func qualityPath(imagePath string, params models.ScanParams) string {
+	if ext := filepath.Ext(imagePath); params.MaxQuality && (ext == ".jpg" || ext == ".jpeg") {
+		return imagePath[:len(imagePath)-len(ext)] + ".png"
+	}
+	return imagePath
+}
+
+// applyImageQuality applies quality settings to a saved image
+// Implements NAPS2's image quality control (MaxQuality and JPEG compression)
+func applyImageQuality(logger *slog.Logger, imagePath string, params models.ScanParams) error {
+	// Recompress if quality settings are specified
+	// This ensures we apply the user's chosen quality instead of WIA's default
+	shouldRecompress := false
+
+	if params.MaxQuality {
+		shouldRecompress = true
+	} else if params.JpegQuality > 0 {
+		// Always recompress if user specified a quality (even if it's the default 75)
+		// because WIA's SaveFile may use a different default quality
+		shouldRecompress = true
+	}
+
+	if !shouldRecompress {
+		return nil // No quality adjustment needed
+	}
+
+	// 1. Open and decode image
+	file, err := os.Open(imagePath)
+	if err != nil {
+		return fmt.Errorf("failed to open image: %w", err)
+	}
+	defer file.Close()
+
+	img, format, err := image.Decode(file)
+	if err != nil {
+		return fmt.Errorf("failed to decode image: %w", err)
+	}
+	file.Close() // Close early to allow overwriting
+
+	// 2. Determine output format and quality
+	var outputPath string
+	var saveErr error
+
+	if params.MaxQuality {
+		// Lossless PNG encoding
+		// Change extension to .png if it's not already
+		outputPath = qualityPath(imagePath, params)
+
+		outFile, err := os.Create(outputPath)
+		if err != nil {
+			return fmt.Errorf("failed to create output file: %w", err)
+		}
+		defer outFile.Close()
+
+		saveErr = savePNG(img, outFile)
+		if saveErr == nil && outputPath != imagePath {
+			// Remove old JPEG file if we created a new PNG
+			os.Remove(imagePath)
+			logger.Debug("Saved as lossless PNG", "file", outputPath)
+		}
+	} else {
+		// JPEG compression with specified quality
+		quality := params.JpegQuality
+		if quality == 0 {
+			quality = models.DefaultJpegQuality // 75
+		}
+
+		outFile, err := os.Create(imagePath)
+		if err != nil {
+			return fmt.Errorf("failed to create output file: %w", err)
+		}
+		defer outFile.Close()
+
+		saveErr = saveJPEG(img, outFile, quality)
+		logger.Debug("Recompressed JPEG", "quality", quality, "original_format", format)
+	}
+
+	return saveErr
+}
+
+// saveJPEG saves an image as JPEG with specified quality
+func saveJPEG(img image.Image, w io.Writer, quality int) error {
+	return jpeg.Encode(w, img, &jpeg.Options{Quality: quality})
+}
+
+// savePNG saves an image as PNG (lossless)
+func savePNG(img image.Image, w io.Writer) error {
+	return png.Encode(w, img)
+}
+
+// scaledResolution returns the DPI of a page after applyScaleRatio
+func scaledResolution(params models.ScanParams) int {
+	if params.ScaleRatio > 1 {
+		return params.Resolution / params.ScaleRatio
+	}
+	return params.Resolution
+}
+
+// applyScaleRatio scales an image by the specified ratio
+// Implements NAPS2's scale transformation (1:1, 1:2, 1:4, 1:8)
+func applyScaleRatio(logger *slog.Logger, imagePath string, scaleRatio int, params models.ScanParams) error {
+	if scaleRatio <= 1 {
+		return nil // No scaling needed
+	}
+
+	// 1. Open and decode image
+	file, err := os.Open(imagePath)
+	if err != nil {
+		return fmt.Errorf("failed to open image: %w", err)
+	}
+	defer file.Close()
+
+	img, format, err := image.Decode(file)
+	if err != nil {
+		return fmt.Errorf("failed to decode image: %w", err)
+	}
+	file.Close() // Close early to allow overwriting
+
+	// 2. Calculate new dimensions (NAPS2: scaleFactor = 1.0 / scaleRatio)
+	bounds := img.Bounds()
+	oldWidth := bounds.Dx()
+	oldHeight := bounds.Dy()
+	newWidth := oldWidth / scaleRatio
+	newHeight := oldHeight / scaleRatio
+
+	if newWidth < 1 {
+		newWidth = 1
+	}
+	if newHeight < 1 {
+		newHeight = 1
+	}
+
+	logger.Debug("Scaling image",
+		"from", fmt.Sprintf("%dx%d", oldWidth, oldHeight),
+		"to", fmt.Sprintf("%dx%d", newWidth, newHeight),
+		"ratio", scaleRatio)
+
+	// 3. Resize using high-quality Lanczos3 interpolation
+	scaled := resize.Resize(
+		uint(newWidth),
+		uint(newHeight),
+		img,
+		resize.Lanczos3, // High-quality interpolation (NAPS2 uses similar)
+	)
+
+	// 4. Save scaled image
+	outFile, err := os.Create(imagePath)
+	if err != nil {
+		return fmt.Errorf("failed to create output file: %w", err)
+	}
+	defer outFile.Close()
+
+	// Use appropriate format
+	if format == "png" || params.MaxQuality {
+		return savePNG(scaled, outFile)
+	}
+
+	quality := params.JpegQuality
+	if quality == 0 {
+		quality = models.DefaultJpegQuality // 75
+	}
+	return saveJPEG(scaled, outFile, quality)
+}
+
+// applyCropToPageSize crops or resizes image to match target page size
+// Implements NAPS2's crop/stretch to page size feature
+func applyCropToPageSize(logger *slog.Logger, imagePath string, params models.ScanParams) error {
+	if !params.CropToPageSize && !params.StretchToPageSize {
+		return nil // No processing needed
+	}
+
+	// 1. Get target page dimensions in millimeters
+	var pageWidthMM, pageHeightMM int
+	if params.PageSize != "" && params.PageSize != "Custom" {
+		if size, ok := models.PaperSizes[params.PageSize]; ok {
+			pageWidthMM = size.Width
+			pageHeightMM = size.Height
+		}
+	} else {
+		pageWidthMM = params.PageWidth
+		pageHeightMM = params.PageHeight
+
+		// Fallback to legacy fields
+		if pageWidthMM == 0 {
+			pageWidthMM = params.Width
+		}
+		if pageHeightMM == 0 {
+			pageHeightMM = params.Height
+		}
+	}
+
+	// Default to A4 if no size specified
+	if pageWidthMM == 0 && pageHeightMM == 0 {
+		pageWidthMM = 210 // A4
+		pageHeightMM = 297
+	}
+
+	// 2. Convert to pixels using scan resolution
+	resolution := params.Resolution
+	if resolution == 0 {
+		resolution = 300
+	}
+
+	targetWidth := int(float64(pageWidthMM) / 25.4 * float64(resolution))
+	targetHeight := int(float64(pageHeightMM) / 25.4 * float64(resolution))
+
+	// 3. Open and decode image
+	img, err := imaging.Open(imagePath)
+	if err != nil {
+		return fmt.Errorf("failed to open image: %w", err)
+	}
+
+	bounds := img.Bounds()
+	currentWidth := bounds.Dx()
+	currentHeight := bounds.Dy()
+
+	// 4. Detect orientation and swap page dimensions if needed (NAPS2 pattern)
+	isImageLandscape := currentWidth > currentHeight
+	isPageLandscape := targetWidth > targetHeight
+
+	if isImageLandscape != isPageLandscape {
+		// Swap target dimensions to match orientation
+		targetWidth, targetHeight = targetHeight, targetWidth
+		logger.Debug("Swapped page dimensions to match orientation", "width", targetWidth, "height", targetHeight)
+	}
+
+	var processed image.Image
+
+	// 5. Apply transformation
+	if params.CropToPageSize {
+		// Crop mode: physically crop image to target size
+		if currentWidth > targetWidth || currentHeight > targetHeight {
+			processed = imaging.CropCenter(img, targetWidth, targetHeight)
+			logger.Debug("Cropped to page size",
+				"from", fmt.Sprintf("%dx%d", currentWidth, currentHeight),
+				"to", fmt.Sprintf("%dx%d", targetWidth, targetHeight))
+		} else {
+			processed = img // Image is already smaller than target
+			logger.Debug("Image smaller than page size, no crop needed")
+		}
+	} else if params.StretchToPageSize {
+		// Stretch mode: resize to fit within target size while preserving aspect ratio
+		processed = imaging.Fit(img, targetWidth, targetHeight, imaging.Lanczos)
+		newBounds := processed.Bounds()
+		logger.Debug("Resized to fit page",
+			"from", fmt.Sprintf("%dx%d", currentWidth, currentHeight),
+			"to", fmt.Sprintf("%dx%d", newBounds.Dx(), newBounds.Dy()))
+	} else {
+		processed = img
+	}
+
+	// 6. Save processed image
+	quality := params.JpegQuality
+	if quality == 0 {
+		quality = models.DefaultJpegQuality
+	}
+
+	// Use PNG for MaxQuality, otherwise JPEG
+	var saveErr error
+	if params.MaxQuality {
+		saveErr = imaging.Save(processed, imagePath, imaging.PNGCompressionLevel(png.BestCompression))
+	} else {
+		saveErr = imaging.Save(processed, imagePath, imaging.JPEGQuality(quality))
+	}
+
+	return saveErr
+}
@@ -0,0 +1,102 @@
+package scanner
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"image/draw"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/disintegration/imaging"
+	"github.com/scanserver/scanner-service/pkg/models"
+)
+
+// testPage saves a white page, with a black square unless blank
+func testPage(t *testing.T, path string, blank bool) models.ScanResult {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, 200, 100))
+	draw.Draw(img, img.Bounds(), image.White, image.Point{}, draw.Src)
+	if !blank {
+		draw.Draw(img, image.Rect(50, 25, 100, 75), &image.Uniform{color.Black}, image.Point{}, draw.Src)
+	}
+	if err := imaging.Save(img, path); err != nil {
+		t.Fatal(err)
+	}
+
+	format := "JPEG"
+	if filepath.Ext(path) == ".png" {
+		format = "PNG"
+	}
+	return models.ScanResult{FilePath: path, Format: format, Resolution: 300}
+}
+
+func TestPostProcess(t *testing.T) {
+	dir := t.TempDir()
+	pages := []models.ScanResult{
+		testPage(t, filepath.Join(dir, "page-0001.png"), true),
+		testPage(t, filepath.Join(dir, "page-0002.jpg"), false),
+		testPage(t, filepath.Join(dir, "page-0003.png"), false),
+		{FilePath: filepath.Join(dir, "page-0004.pdf"), Format: "PDF", Resolution: 300},
+	}
+	for i := range pages {
+		pages[i].PageNumber = i + 1
+	}
+
+	params := models.ScanParams{ExcludeBlankPages: true, ScaleRatio: 2, JpegQuality: 90}
+	results := PostProcess(context.Background(), params, pages)
+
+	if len(results) != 3 {
+		t.Fatalf("%d pages, want 3", len(results))
+	}
+	if _, err := os.Stat(pages[0].FilePath); !os.IsNotExist(err) {
+		t.Errorf("blank page was not deleted: %v", err)
+	}
+
+	for i, result := range results {
+		if result.PageNumber != i+1 {
+			t.Errorf("page %d numbered %d", i+1, result.PageNumber)
+		}
+		if result.FilePath != pages[i+1].FilePath || result.Format != pages[i+1].Format {
+			t.Errorf("page %d = %s %s", i+1, result.Format, result.FilePath)
+		}
+	}
+
+	// Images are scaled and their resolution follows, the PDF is untouched
+	for _, result := range results[:2] {
+		img, err := imaging.Open(result.FilePath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if b := img.Bounds(); b.Dx() != 100 || b.Dy() != 50 {
+			t.Errorf("%s: size %dx%d, want 100x50", result.FilePath, b.Dx(), b.Dy())
+		}
+		if info, _ := os.Stat(result.FilePath); result.FileSize != info.Size() {
+			t.Errorf("%s: file size %d, want %d", result.FilePath, result.FileSize, info.Size())
+		}
+		if result.Resolution != 150 {
+			t.Errorf("%s: resolution %d, want 150", result.FilePath, result.Resolution)
+		}
+	}
+	if results[2].Resolution != 300 {
+		t.Errorf("PDF resolution %d, want 300", results[2].Resolution)
+	}
+}
+
+func TestPostProcessMaxQuality(t *testing.T) {
+	dir := t.TempDir()
+	page := testPage(t, filepath.Join(dir, "page-0001.jpg"), false)
+	page.PageNumber = 1
+
+	results := PostProcess(context.Background(), models.ScanParams{MaxQuality: true}, []models.ScanResult{page})
+	if len(results) != 1 {
+		t.Fatalf("%d pages, want 1", len(results))
+	}
+	if want := filepath.Join(dir, "page-0001.png"); results[0].FilePath != want || results[0].Format != "PNG" {
+		t.Errorf("page = %s %s, want PNG %s", results[0].Format, results[0].FilePath, want)
+	}
+	if _, err := os.Stat(page.FilePath); !os.IsNotExist(err) {
+		t.Errorf("JPEG was not replaced: %v", err)
+	}
+}
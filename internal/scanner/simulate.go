@@ -0,0 +1,82 @@
+//go:build !windows
+
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"strings"
+
+	"github.com/scanserver/scanner-service/internal/storage"
+	"github.com/scanserver/scanner-service/pkg/models"
+)
+
+// Simulated pages are rendered at no more than this resolution to keep them small
+const simulatedMaxDPI = 150
+
+// writeSimulatedPage writes a blank page image into the store for drivers
+// that do not talk to real hardware yet (SANE, ImageCaptureCore)
+func writeSimulatedPage(ctx context.Context, store *storage.FileStore, pageNumber int, params models.ScanParams) (models.ScanResult, error) {
+	format := "JPEG"
+	if strings.EqualFold(params.Format, "PNG") {
+		format = "PNG"
+	}
+
+	filePath, err := store.NextPagePath(ctx, format)
+	if err != nil {
+		return models.ScanResult{}, err
+	}
+
+	widthMM, heightMM := 210, 297 // A4
+	if size, ok := models.PaperSizes[params.PageSize]; ok {
+		widthMM, heightMM = size.Width, size.Height
+	} else if params.PageWidth > 0 && params.PageHeight > 0 {
+		widthMM, heightMM = params.PageWidth, params.PageHeight
+	} else if params.Width > 0 && params.Height > 0 {
+		widthMM, heightMM = params.Width, params.Height
+	}
+
+	dpi := params.Resolution
+	if dpi <= 0 || dpi > simulatedMaxDPI {
+		dpi = simulatedMaxDPI
+	}
+
+	img := image.NewGray(image.Rect(0, 0, widthMM*dpi*10/254, heightMM*dpi*10/254))
+	draw.Draw(img, img.Bounds(), &image.Uniform{C: color.White}, image.Point{}, draw.Src)
+
+	f, err := os.Create(filePath)
+	if err != nil {
+		return models.ScanResult{}, fmt.Errorf("failed to create output file: %w", err)
+	}
+	defer f.Close()
+
+	if format == "PNG" {
+		err = png.Encode(f, img)
+	} else {
+		err = jpeg.Encode(f, img, &jpeg.Options{Quality: models.DefaultJpegQuality})
+	}
+	if err != nil {
+		return models.ScanResult{}, fmt.Errorf("failed to encode page: %w", err)
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		return models.ScanResult{}, err
+	}
+
+	return models.ScanResult{
+		PageNumber: pageNumber,
+		FilePath:   filePath,
+		FileSize:   info.Size(),
+		Format:     format,
+		Width:      params.Width,
+		Height:     params.Height,
+		Resolution: dpi,
+	}, nil
+}
@@ -0,0 +1,345 @@
+package scanner
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/scanserver/scanner-service/pkg/models"
+)
+
+// ValidationMode controls how unsupported scan parameters are handled
+type ValidationMode string
+
+const (
+	ValidationStrict ValidationMode = "strict" // Reject unsupported values
+	ValidationCoerce ValidationMode = "coerce" // Replace unsupported values with the closest supported one
+)
+
+// ParseValidationMode converts a config string to a ValidationMode (default: strict)
+func ParseValidationMode(s string) ValidationMode {
+	if strings.EqualFold(s, string(ValidationCoerce)) {
+		return ValidationCoerce
+	}
+	return ValidationStrict
+}
+
+// FieldError describes a single scan parameter the scanner cannot honour
+type FieldError struct {
+	Field   string      `json:"field"`             // JSON name of the offending ScanParams field
+	Value   interface{} `json:"value"`             // Value that was requested
+	Allowed interface{} `json:"allowed,omitempty"` // Values the scanner supports
+	Message string      `json:"message"`
+}
+
+// ValidationError is returned when scan parameters do not match scanner capabilities
+type ValidationError struct {
+	ScannerID string       `json:"scanner_id"`
+	Fields    []FieldError `json:"fields"`
+}
+
+func (e *ValidationError) Error() string {
+	parts := make([]string, 0, len(e.Fields))
+	for _, f := range e.Fields {
+		parts = append(parts, fmt.Sprintf("%s: %s", f.Field, f.Message))
+	}
+	return fmt.Sprintf("invalid scan parameters for scanner %s: %s", e.ScannerID, strings.Join(parts, "; "))
+}
+
+// Is makes a *ValidationError match ErrUnsupportedSetting
+func (e *ValidationError) Is(target error) bool {
+	return target == ErrUnsupportedSetting
+}
+
+// formatAliases maps common alternative spellings to canonical format names
+var formatAliases = map[string]string{
+	"JPG": "JPEG",
+	"TIF": "TIFF",
+}
+
+// ValidateParams checks params against the scanner's capabilities.
+// In strict mode every unsupported value is reported in a *ValidationError.
+// In coerce mode params is modified in place where a sensible substitute
+// exists; values that cannot be coerced are still reported.
+// Zero values (e.g. Resolution 0, empty ColorMode) mean "driver default" and are accepted.
+func ValidateParams(scanner *models.Scanner, params *models.ScanParams, mode ValidationMode) error {
+	v := &paramValidator{
+		caps:   scanner.Capabilities,
+		params: params,
+		coerce: mode == ValidationCoerce,
+	}
+
+	v.checkResolution()
+	v.checkColorMode()
+	v.checkFormat()
+	v.checkPaperSource()
+	v.checkPageSize()
+	v.checkRanges()
+
+	if len(v.errors) > 0 {
+		return &ValidationError{ScannerID: scanner.ID, Fields: v.errors}
+	}
+	return nil
+}
+
+// paramValidator accumulates field errors while checking a ScanParams value
+type paramValidator struct {
+	caps   models.Capability
+	params *models.ScanParams
+	coerce bool
+	errors []FieldError
+}
+
+func (v *paramValidator) fail(field string, value, allowed interface{}, format string, args ...interface{}) {
+	v.errors = append(v.errors, FieldError{
+		Field:   field,
+		Value:   value,
+		Allowed: allowed,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// checkResolution requires a resolution listed by the scanner (coerce: nearest supported)
+func (v *paramValidator) checkResolution() {
+	res := v.params.Resolution
+	if res == 0 || len(v.caps.Resolutions) == 0 {
+		return
+	}
+	if res < 0 {
+		v.fail("resolution", res, v.caps.Resolutions, "resolution must be positive")
+		return
+	}
+
+	nearest := v.caps.Resolutions[0]
+	for _, r := range v.caps.Resolutions {
+		if r == res {
+			return
+		}
+		if abs(r-res) < abs(nearest-res) {
+			nearest = r
+		}
+	}
+
+	if v.coerce {
+		v.params.Resolution = nearest
+		return
+	}
+	v.fail("resolution", res, v.caps.Resolutions, "resolution %d DPI is not supported", res)
+}
+
+// checkColorMode matches the color mode case-insensitively (coerce: first supported mode)
+func (v *paramValidator) checkColorMode() {
+	mode := v.params.ColorMode
+	if mode == "" || len(v.caps.ColorModes) == 0 {
+		return
+	}
+
+	if canonical, ok := matchFold(v.caps.ColorModes, mode); ok {
+		v.params.ColorMode = canonical
+		return
+	}
+
+	if v.coerce {
+		v.params.ColorMode = v.caps.ColorModes[0]
+		return
+	}
+	v.fail("color_mode", mode, v.caps.ColorModes, "color mode %q is not supported", mode)
+}
+
+// checkFormat matches the output format case-insensitively (coerce: first supported format)
+func (v *paramValidator) checkFormat() {
+	format := v.params.Format
+	if format == "" || len(v.caps.DocumentFormats) == 0 {
+		return
+	}
+
+	lookup := strings.ToUpper(format)
+	if alias, ok := formatAliases[lookup]; ok {
+		lookup = alias
+	}
+	if canonical, ok := matchFold(v.caps.DocumentFormats, lookup); ok {
+		v.params.Format = canonical
+		return
+	}
+
+	if v.coerce {
+		v.params.Format = v.caps.DocumentFormats[0]
+		return
+	}
+	v.fail("format", format, v.caps.DocumentFormats, "format %q is not supported", format)
+}
+
+// checkPaperSource rejects feeder/duplex on scanners without them (coerce: fall back to simplex/flatbed)
+func (v *paramValidator) checkPaperSource() {
+	if v.params.UseFeeder && !v.caps.FeederEnabled {
+		if v.coerce {
+			v.params.UseFeeder = false
+		} else {
+			v.fail("use_feeder", true, []bool{false}, "scanner has no document feeder")
+		}
+	}
+
+	if v.params.UseDuplex && !v.caps.DuplexEnabled {
+		if v.coerce {
+			v.params.UseDuplex = false
+		} else {
+			v.fail("use_duplex", true, []bool{false}, "scanner does not support duplex scanning")
+		}
+	}
+}
+
+// checkPageSize validates named and custom page sizes (in mm) against the scan area
+// (coerce: crop to the scan area)
+func (v *paramValidator) checkPageSize() {
+	p := v.params
+
+	var width, height int
+	switch {
+	case p.PageSize != "" && p.PageSize != "Custom":
+		name, ok := matchFold(paperSizeNames(), p.PageSize)
+		if !ok {
+			v.fail("page_size", p.PageSize, append(paperSizeNames(), "Custom"), "unknown page size %q", p.PageSize)
+			return
+		}
+		p.PageSize = name
+		size := models.PaperSizes[name]
+		width, height = size.Width, size.Height
+	default:
+		width, height = p.PageWidth, p.PageHeight
+		if width == 0 {
+			width = p.Width
+		}
+		if height == 0 {
+			height = p.Height
+		}
+		if width < 0 || height < 0 {
+			v.fail("page_width", width, nil, "page dimensions must not be negative")
+			return
+		}
+	}
+
+	maxWidth, maxHeight := v.scanArea()
+	if (maxWidth > 0 && width > maxWidth) || (maxHeight > 0 && height > maxHeight) {
+		// Coerce to a custom size cropped to the scan area
+		if v.coerce {
+			if maxWidth > 0 && width > maxWidth {
+				width = maxWidth
+			}
+			if maxHeight > 0 && height > maxHeight {
+				height = maxHeight
+			}
+			p.PageSize = "Custom"
+			p.PageWidth, p.PageHeight = width, height
+			return
+		}
+
+		field := "page_width"
+		if p.PageSize != "" && p.PageSize != "Custom" {
+			field = "page_size"
+		}
+		v.fail(field, fmt.Sprintf("%dx%d mm", width, height), v.fittingPaperSizes(),
+			"page size %dx%d mm exceeds scan area %dx%d mm", width, height, maxWidth, maxHeight)
+	}
+}
+
+// scanArea returns the scanner's maximum page size in mm. Capability.MaxWidth
+// and MaxHeight are in 0.1mm; zero means unknown.
+func (v *paramValidator) scanArea() (width, height int) {
+	return v.caps.MaxWidth / 10, v.caps.MaxHeight / 10
+}
+
+// fittingPaperSizes returns named paper sizes that fit inside the scan area
+func (v *paramValidator) fittingPaperSizes() []string {
+	maxWidth, maxHeight := v.scanArea()
+	var fitting []string
+	for _, name := range paperSizeNames() {
+		size := models.PaperSizes[name]
+		if (maxWidth == 0 || size.Width <= maxWidth) && (maxHeight == 0 || size.Height <= maxHeight) {
+			fitting = append(fitting, name)
+		}
+	}
+	return fitting
+}
+
+// checkRanges validates numeric settings with fixed ranges (coerce: clamp)
+func (v *paramValidator) checkRanges() {
+	p := v.params
+
+	v.clamp("brightness", &p.Brightness, -1000, 1000)
+	v.clamp("contrast", &p.Contrast, -1000, 1000)
+	v.clamp("jpeg_quality", &p.JpegQuality, 0, 100)
+	v.clamp("blank_page_white_threshold", &p.BlankPageWhiteThreshold, 0, 100)
+	v.clamp("blank_page_coverage_threshold", &p.BlankPageCoverageThreshold, 0, 100)
+
+	if p.PageCount < 0 {
+		if v.coerce {
+			p.PageCount = 0
+		} else {
+			v.fail("page_count", p.PageCount, nil, "page count must not be negative")
+		}
+	}
+
+	switch p.ScaleRatio {
+	case 0, models.Scale1to1, models.Scale1to2, models.Scale1to4, models.Scale1to8:
+	default:
+		allowed := []int{models.Scale1to1, models.Scale1to2, models.Scale1to4, models.Scale1to8}
+		if v.coerce {
+			p.ScaleRatio = models.Scale1to1
+		} else {
+			v.fail("scale_ratio", p.ScaleRatio, allowed, "unsupported scale ratio %d", p.ScaleRatio)
+		}
+	}
+
+	if p.PageAlign != "" {
+		aligns := []string{models.AlignLeft, models.AlignCenter, models.AlignRight}
+		if canonical, ok := matchFold(aligns, p.PageAlign); ok {
+			p.PageAlign = canonical
+		} else if v.coerce {
+			p.PageAlign = models.AlignRight
+		} else {
+			v.fail("page_align", p.PageAlign, aligns, "unknown page alignment %q", p.PageAlign)
+		}
+	}
+}
+
+func (v *paramValidator) clamp(field string, value *int, min, max int) {
+	if *value >= min && *value <= max {
+		return
+	}
+	if v.coerce {
+		if *value < min {
+			*value = min
+		} else {
+			*value = max
+		}
+		return
+	}
+	v.fail(field, *value, []int{min, max}, "%s must be between %d and %d", field, min, max)
+}
+
+// paperSizeNames returns the names of predefined paper sizes in sorted order
+func paperSizeNames() []string {
+	names := make([]string, 0, len(models.PaperSizes))
+	for name := range models.PaperSizes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// matchFold returns the entry of list equal to s under case folding
+func matchFold(list []string, s string) (string, bool) {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return item, true
+		}
+	}
+	return "", false
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
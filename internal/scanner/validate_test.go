@@ -0,0 +1,147 @@
+package scanner
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/scanserver/scanner-service/pkg/models"
+)
+
+// testScanner has an A4 scan area, like the Linux and macOS drivers report
+func testScanner() *models.Scanner {
+	return &models.Scanner{
+		ID: "scanner-test",
+		Capabilities: models.Capability{
+			MaxWidth:        2100,
+			MaxHeight:       2970,
+			Resolutions:     []int{150, 300, 600},
+			ColorModes:      []string{"Color", "Grayscale", "BlackAndWhite"},
+			DocumentFormats: []string{"PDF", "JPEG", "PNG"},
+		},
+	}
+}
+
+func TestValidateParams(t *testing.T) {
+	tests := []struct {
+		name   string
+		params models.ScanParams
+		mode   ValidationMode
+		field  string // Field expected in the error, "" for success
+		check  func(p models.ScanParams) bool
+	}{
+		{
+			name:   "supported resolution",
+			params: models.ScanParams{Resolution: 300},
+			mode:   ValidationStrict,
+		},
+		{
+			name:   "strict rejects unsupported resolution",
+			params: models.ScanParams{Resolution: 400},
+			mode:   ValidationStrict,
+			field:  "resolution",
+		},
+		{
+			name:   "coerce picks nearest resolution",
+			params: models.ScanParams{Resolution: 500},
+			mode:   ValidationCoerce,
+			check:  func(p models.ScanParams) bool { return p.Resolution == 600 },
+		},
+		{
+			name:   "color mode matches case-insensitively",
+			params: models.ScanParams{ColorMode: "grayscale"},
+			mode:   ValidationStrict,
+			check:  func(p models.ScanParams) bool { return p.ColorMode == "Grayscale" },
+		},
+		{
+			name:   "strict rejects unknown color mode",
+			params: models.ScanParams{ColorMode: "Sepia"},
+			mode:   ValidationStrict,
+			field:  "color_mode",
+		},
+		{
+			name:   "coerce falls back to first color mode",
+			params: models.ScanParams{ColorMode: "Sepia"},
+			mode:   ValidationCoerce,
+			check:  func(p models.ScanParams) bool { return p.ColorMode == "Color" },
+		},
+		{
+			name:   "A4 fits the scan area",
+			params: models.ScanParams{PageSize: "a4"},
+			mode:   ValidationStrict,
+			check:  func(p models.ScanParams) bool { return p.PageSize == "A4" },
+		},
+		{
+			name:   "strict rejects A3 on an A4 scanner",
+			params: models.ScanParams{PageSize: "A3"},
+			mode:   ValidationStrict,
+			field:  "page_size",
+		},
+		{
+			name:   "strict rejects a custom size just over the scan area",
+			params: models.ScanParams{PageWidth: 211, PageHeight: 297},
+			mode:   ValidationStrict,
+			field:  "page_width",
+		},
+		{
+			name:   "coerce crops A3 to the scan area",
+			params: models.ScanParams{PageSize: "A3"},
+			mode:   ValidationCoerce,
+			check: func(p models.ScanParams) bool {
+				return p.PageSize == "Custom" && p.PageWidth == 210 && p.PageHeight == 297
+			},
+		},
+		{
+			name:   "unknown page size is rejected in both modes",
+			params: models.ScanParams{PageSize: "Tabloid"},
+			mode:   ValidationCoerce,
+			field:  "page_size",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := tt.params
+			err := ValidateParams(testScanner(), &params, tt.mode)
+
+			if tt.field == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+			} else {
+				var verr *ValidationError
+				if !errors.As(err, &verr) {
+					t.Fatalf("expected a *ValidationError for %s, got %v", tt.field, err)
+				}
+				if !errors.Is(err, ErrUnsupportedSetting) {
+					t.Errorf("error does not match ErrUnsupportedSetting")
+				}
+				if len(verr.Fields) != 1 || verr.Fields[0].Field != tt.field {
+					t.Errorf("fields = %+v, want one error for %s", verr.Fields, tt.field)
+				}
+			}
+
+			if tt.check != nil && !tt.check(params) {
+				t.Errorf("params not adjusted as expected: %+v", params)
+			}
+		})
+	}
+}
+
+func TestFittingPaperSizes(t *testing.T) {
+	v := &paramValidator{caps: testScanner().Capabilities}
+	fitting := map[string]bool{}
+	for _, name := range v.fittingPaperSizes() {
+		fitting[name] = true
+	}
+
+	for _, name := range []string{"A4", "A5", "A6", "B5"} {
+		if !fitting[name] {
+			t.Errorf("%s should fit an A4 scan area", name)
+		}
+	}
+	for _, name := range []string{"A3", "B4", "Legal", "Letter"} {
+		if fitting[name] {
+			t.Errorf("%s should not fit an A4 scan area", name)
+		}
+	}
+}
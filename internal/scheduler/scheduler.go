@@ -0,0 +1,463 @@
+// Package scheduler runs scans on cron schedules
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/robfig/cron/v3"
+
+	"github.com/scanserver/scanner-service/internal/config"
+	"github.com/scanserver/scanner-service/internal/logging"
+	"github.com/scanserver/scanner-service/pkg/models"
+)
+
+// Schedule sources
+const (
+	SourceConfig = "config"
+	SourceAPI    = "api"
+)
+
+// maxRuns is the number of runs kept per schedule
+const maxRuns = 20
+
+var (
+	// ErrNotFound is returned for an unknown schedule ID
+	ErrNotFound = errors.New("schedule not found")
+
+	// ErrReadOnly is returned when changing a schedule defined in the config file
+	ErrReadOnly = errors.New("schedule is defined in the configuration file")
+
+	// ErrExists is returned when creating a schedule with an ID that is taken
+	ErrExists = errors.New("schedule already exists")
+
+	// ErrRunning is returned when starting a schedule whose previous run is still in progress
+	ErrRunning = errors.New("schedule is already running")
+)
+
+// RunFunc runs a schedule's scan as a job and returns the finished job.
+// ctx carries a logger tagged with the schedule ID. An error means no job
+// could be started.
+type RunFunc func(ctx context.Context, schedule models.Schedule) (*models.ScanJob, error)
+
+// entry is a schedule registered with the cron runner
+type entry struct {
+	schedule models.Schedule
+	cronID   cron.EntryID // 0 while disabled
+	running  bool
+}
+
+// Scheduler runs scans on cron schedules. Schedules come from the config
+// file or are created through the API, which are saved to scheduler.file.
+type Scheduler struct {
+	config *config.SchedulerConfig
+	run    RunFunc
+	cron   *cron.Cron
+	logger *slog.Logger
+
+	mutex   sync.Mutex
+	entries map[string]*entry
+
+	saveMutex sync.Mutex // Serializes writes to scheduler.file
+}
+
+// NewScheduler loads the schedules from the config file and from scheduler.file
+func NewScheduler(cfg *config.SchedulerConfig, run RunFunc, logger *slog.Logger) (*Scheduler, error) {
+	s := &Scheduler{
+		config:  cfg,
+		run:     run,
+		cron:    cron.New(),
+		logger:  logger,
+		entries: make(map[string]*entry),
+	}
+
+	for i, sc := range cfg.Schedules {
+		schedule, err := fromConfig(sc)
+		if err != nil {
+			return nil, fmt.Errorf("schedule %d: %w", i+1, err)
+		}
+		if err := s.add(schedule); err != nil {
+			return nil, fmt.Errorf("schedule %s: %w", schedule.ID, err)
+		}
+	}
+
+	saved, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	for _, schedule := range saved {
+		if err := s.add(schedule); err != nil {
+			s.logger.Warn("Ignoring saved schedule", logging.KeyScheduleID, schedule.ID, logging.Err(err))
+		}
+	}
+
+	return s, nil
+}
+
+// fromConfig converts a schedule from the config file
+func fromConfig(sc config.ScheduleConfig) (models.Schedule, error) {
+	schedule := models.Schedule{
+		ID:        sc.ID,
+		Name:      sc.Name,
+		Cron:      sc.Cron,
+		ScannerID: sc.ScannerID,
+		Enabled:   !sc.Disabled,
+		Source:    SourceConfig,
+		Owner:     sc.Owner,
+	}
+	if schedule.ID == "" {
+		return schedule, fmt.Errorf("id is required")
+	}
+
+	// Parameters use the field names of the scan API
+	if len(sc.Parameters) > 0 {
+		data, err := json.Marshal(sc.Parameters)
+		if err != nil {
+			return schedule, err
+		}
+		if err := json.Unmarshal(data, &schedule.Parameters); err != nil {
+			return schedule, fmt.Errorf("invalid parameters: %w", err)
+		}
+	}
+
+	if sc.Email != "" {
+		schedule.Email = &models.EmailRequest{Profile: sc.Email}
+	}
+	if sc.Export != "" {
+		schedule.Export = &models.ExportRequest{Profile: sc.Export}
+	}
+	return schedule, nil
+}
+
+// Start runs the schedules until Stop is called
+func (s *Scheduler) Start() {
+	s.mutex.Lock()
+	count := len(s.entries)
+	s.mutex.Unlock()
+
+	s.cron.Start()
+	if count > 0 {
+		s.logger.Info("Scheduler started", "schedules", count)
+	}
+}
+
+// Stop stops starting new runs. Runs in progress are not interrupted.
+func (s *Scheduler) Stop() {
+	s.cron.Stop()
+}
+
+// Validate checks a cron expression
+func Validate(expr string) error {
+	if _, err := cron.ParseStandard(expr); err != nil {
+		return fmt.Errorf("invalid cron expression %q: %w", expr, err)
+	}
+	return nil
+}
+
+// add registers a schedule with the cron runner
+func (s *Scheduler) add(schedule models.Schedule) error {
+	if schedule.ScannerID == "" {
+		return fmt.Errorf("scanner_id is required")
+	}
+	if err := Validate(schedule.Cron); err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, ok := s.entries[schedule.ID]; ok {
+		return ErrExists
+	}
+
+	e := &entry{schedule: schedule}
+	if schedule.Enabled {
+		id, err := s.cron.AddFunc(schedule.Cron, func() { s.execute(schedule.ID) })
+		if err != nil {
+			return err
+		}
+		e.cronID = id
+	}
+	s.entries[schedule.ID] = e
+	return nil
+}
+
+// execute runs a schedule once and records the outcome
+func (s *Scheduler) execute(id string) {
+	logger := s.logger.With(logging.KeyScheduleID, id)
+
+	s.mutex.Lock()
+	e, ok := s.entries[id]
+	if !ok {
+		s.mutex.Unlock()
+		return
+	}
+	if e.running {
+		s.mutex.Unlock()
+		logger.Warn("Previous run still in progress, skipping")
+		return
+	}
+	e.running = true
+	schedule := e.schedule
+	s.mutex.Unlock()
+
+	run := models.ScheduleRun{Time: time.Now()}
+	job, err := s.run(logging.WithLogger(context.Background(), logger), schedule)
+	switch {
+	case err != nil:
+		run.Status = "failed"
+		run.Error = err.Error()
+	default:
+		run.JobID = job.ID
+		run.Status = job.Status
+		run.Pages = len(job.Results)
+		run.Error = job.Error
+	}
+
+	switch run.Status {
+	case "completed":
+		logger.Info("Scheduled scan completed", logging.KeyJobID, run.JobID, "pages", run.Pages)
+	case "skipped":
+		logger.Info("Feeder is empty, nothing to scan", logging.KeyJobID, run.JobID)
+	default:
+		logger.Warn("Scheduled scan did not complete", logging.KeyJobID, run.JobID, "status", run.Status, "error", run.Error)
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	e.running = false
+	e.schedule.LastRun = &run
+	e.schedule.Runs = append([]models.ScheduleRun{run}, e.schedule.Runs...)
+	if len(e.schedule.Runs) > maxRuns {
+		e.schedule.Runs = e.schedule.Runs[:maxRuns]
+	}
+}
+
+// RunNow starts a run of a schedule outside its cron times, even if it is disabled
+func (s *Scheduler) RunNow(id string) error {
+	s.mutex.Lock()
+	e, ok := s.entries[id]
+	running := ok && e.running
+	s.mutex.Unlock()
+
+	if !ok {
+		return ErrNotFound
+	}
+	if running {
+		return ErrRunning
+	}
+
+	go s.execute(id)
+	return nil
+}
+
+// List returns all schedules, ordered by ID
+func (s *Scheduler) List() []models.Schedule {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	schedules := make([]models.Schedule, 0, len(s.entries))
+	for _, e := range s.entries {
+		schedules = append(schedules, s.snapshot(e))
+	}
+	sort.Slice(schedules, func(i, j int) bool { return schedules[i].ID < schedules[j].ID })
+	return schedules
+}
+
+// Get returns a schedule
+func (s *Scheduler) Get(id string) (models.Schedule, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	e, ok := s.entries[id]
+	if !ok {
+		return models.Schedule{}, ErrNotFound
+	}
+	return s.snapshot(e), nil
+}
+
+// snapshot returns a copy of a schedule with its next run time. Callers hold the mutex.
+func (s *Scheduler) snapshot(e *entry) models.Schedule {
+	schedule := e.schedule
+	schedule.Runs = append([]models.ScheduleRun(nil), e.schedule.Runs...)
+	if e.cronID != 0 {
+		if next := s.cron.Entry(e.cronID).Next; !next.IsZero() {
+			schedule.NextRun = &next
+		} else if sched, err := cron.ParseStandard(schedule.Cron); err == nil {
+			// Not started yet
+			next := sched.Next(time.Now())
+			schedule.NextRun = &next
+		}
+	}
+	return schedule
+}
+
+// Create adds a schedule and saves it. An empty ID is generated.
+func (s *Scheduler) Create(schedule models.Schedule) (models.Schedule, error) {
+	if schedule.ID == "" {
+		schedule.ID = models.GenerateUUID()
+	}
+	schedule.Source = SourceAPI
+	schedule.NextRun, schedule.LastRun, schedule.Runs = nil, nil, nil
+
+	if err := s.add(schedule); err != nil {
+		return models.Schedule{}, err
+	}
+	if err := s.save(); err != nil {
+		s.remove(schedule.ID)
+		return models.Schedule{}, err
+	}
+	return s.Get(schedule.ID)
+}
+
+// Update replaces a schedule created through the API, keeping its run history
+func (s *Scheduler) Update(id string, schedule models.Schedule) (models.Schedule, error) {
+	if err := Validate(schedule.Cron); err != nil {
+		return models.Schedule{}, err
+	}
+	if schedule.ScannerID == "" {
+		return models.Schedule{}, fmt.Errorf("scanner_id is required")
+	}
+
+	s.mutex.Lock()
+	e, ok := s.entries[id]
+	if !ok {
+		s.mutex.Unlock()
+		return models.Schedule{}, ErrNotFound
+	}
+	if e.schedule.Source == SourceConfig {
+		s.mutex.Unlock()
+		return models.Schedule{}, ErrReadOnly
+	}
+
+	schedule.ID = id
+	schedule.Source = SourceAPI
+	schedule.NextRun = nil
+	schedule.LastRun, schedule.Runs = e.schedule.LastRun, e.schedule.Runs
+	schedule.Owner = e.schedule.Owner // The creator keeps owning the jobs
+
+	if e.cronID != 0 {
+		s.cron.Remove(e.cronID)
+		e.cronID = 0
+	}
+	if schedule.Enabled {
+		cronID, err := s.cron.AddFunc(schedule.Cron, func() { s.execute(id) })
+		if err != nil {
+			s.mutex.Unlock()
+			return models.Schedule{}, err
+		}
+		e.cronID = cronID
+	}
+	e.schedule = schedule
+	s.mutex.Unlock()
+
+	if err := s.save(); err != nil {
+		return models.Schedule{}, err
+	}
+	return s.Get(id)
+}
+
+// Delete removes a schedule created through the API. A run in progress completes.
+func (s *Scheduler) Delete(id string) error {
+	s.mutex.Lock()
+	e, ok := s.entries[id]
+	if ok && e.schedule.Source == SourceConfig {
+		s.mutex.Unlock()
+		return ErrReadOnly
+	}
+	s.mutex.Unlock()
+
+	if !ok {
+		return ErrNotFound
+	}
+	s.remove(id)
+	return s.save()
+}
+
+// remove unregisters a schedule
+func (s *Scheduler) remove(id string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if e, ok := s.entries[id]; ok {
+		if e.cronID != 0 {
+			s.cron.Remove(e.cronID)
+		}
+		delete(s.entries, id)
+	}
+}
+
+// load reads the schedules saved in scheduler.file
+func (s *Scheduler) load() ([]models.Schedule, error) {
+	if s.config.File == "" {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(s.config.File)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schedules: %w", err)
+	}
+
+	var schedules []models.Schedule
+	if err := json.Unmarshal(data, &schedules); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", s.config.File, err)
+	}
+	for i := range schedules {
+		schedules[i].Source = SourceAPI
+	}
+	return schedules, nil
+}
+
+// save writes the schedules created through the API to scheduler.file
+func (s *Scheduler) save() error {
+	if s.config.File == "" {
+		return nil
+	}
+
+	s.saveMutex.Lock()
+	defer s.saveMutex.Unlock()
+
+	s.mutex.Lock()
+	schedules := []models.Schedule{}
+	for _, e := range s.entries {
+		if e.schedule.Source != SourceAPI {
+			continue
+		}
+		schedule := e.schedule
+		schedule.NextRun, schedule.LastRun, schedule.Runs = nil, nil, nil
+		schedules = append(schedules, schedule)
+	}
+	s.mutex.Unlock()
+
+	sort.Slice(schedules, func(i, j int) bool { return schedules[i].ID < schedules[j].ID })
+	data, err := json.MarshalIndent(schedules, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if dir := filepath.Dir(s.config.File); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+	tmp := s.config.File + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to save schedules: %w", err)
+	}
+	if err := os.Rename(tmp, s.config.File); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to save schedules: %w", err)
+	}
+	return nil
+}
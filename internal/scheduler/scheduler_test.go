@@ -0,0 +1,265 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/scanserver/scanner-service/internal/config"
+	"github.com/scanserver/scanner-service/pkg/models"
+)
+
+var testLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+// fakeRun returns a RunFunc that reports each run on started and finishes
+// it with the job or error sent on finish
+type fakeRun struct {
+	started chan models.Schedule
+	finish  chan func() (*models.ScanJob, error)
+}
+
+func newFakeRun() *fakeRun {
+	return &fakeRun{
+		started: make(chan models.Schedule, 1),
+		finish:  make(chan func() (*models.ScanJob, error), 1),
+	}
+}
+
+func (f *fakeRun) run(ctx context.Context, schedule models.Schedule) (*models.ScanJob, error) {
+	f.started <- schedule
+	return (<-f.finish)()
+}
+
+func newTestScheduler(t *testing.T, cfg *config.SchedulerConfig, run RunFunc) *Scheduler {
+	t.Helper()
+	s, err := NewScheduler(cfg, run, testLogger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// waitForRuns waits until the schedule has recorded n runs
+func waitForRuns(t *testing.T, s *Scheduler, id string, n int) models.Schedule {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		schedule, err := s.Get(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(schedule.Runs) >= n {
+			return schedule
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%s: %d runs recorded, want %d", id, len(schedule.Runs), n)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for _, expr := range []string{"0 22 * * 1-5", "*/15 * * * *", "@daily", "@every 2h", "CRON_TZ=Europe/Berlin 0 7 * * *"} {
+		if err := Validate(expr); err != nil {
+			t.Errorf("%q: %v", expr, err)
+		}
+	}
+	for _, expr := range []string{"", "0 22 * *", "0 0 22 * * 1-5", "61 * * * *", "@sometimes", "CRON_TZ=Nowhere/City 0 7 * * *"} {
+		if err := Validate(expr); err == nil {
+			t.Errorf("%q: no error", expr)
+		}
+	}
+
+	s := newTestScheduler(t, &config.SchedulerConfig{}, newFakeRun().run)
+	if _, err := s.Create(models.Schedule{Cron: "every day", ScannerID: "scanner-1"}); err == nil {
+		t.Error("created a schedule with an invalid cron expression")
+	}
+	if _, err := s.Create(models.Schedule{Cron: "@daily"}); err == nil {
+		t.Error("created a schedule without a scanner")
+	}
+}
+
+func TestRunBookkeeping(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "schedules.json")
+	fake := newFakeRun()
+	s := newTestScheduler(t, &config.SchedulerConfig{File: file}, fake.run)
+
+	enabled, err := s.Create(models.Schedule{ID: "nightly", Cron: "0 22 * * *", ScannerID: "scanner-1", Enabled: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if enabled.Source != SourceAPI || enabled.NextRun == nil || enabled.LastRun != nil {
+		t.Fatalf("created schedule = %+v", enabled)
+	}
+	if next := enabled.NextRun; next.Hour() != 22 || next.Minute() != 0 || !next.After(time.Now()) {
+		t.Errorf("next run %v, want the next 22:00", next)
+	}
+	disabled, err := s.Create(models.Schedule{ID: "paused", Cron: "@hourly", ScannerID: "scanner-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if disabled.NextRun != nil {
+		t.Errorf("disabled schedule runs next at %v", disabled.NextRun)
+	}
+
+	// Disabled schedules can be run by hand
+	before := time.Now()
+	if err := s.RunNow("paused"); err != nil {
+		t.Fatal(err)
+	}
+	if got := <-fake.started; got.ID != "paused" || got.ScannerID != "scanner-1" {
+		t.Errorf("ran %+v", got)
+	}
+	fake.finish <- func() (*models.ScanJob, error) {
+		return &models.ScanJob{ID: "job-1", Status: "completed", Results: make([]models.ScanResult, 3)}, nil
+	}
+	schedule := waitForRuns(t, s, "paused", 1)
+	want := models.ScheduleRun{JobID: "job-1", Status: "completed", Pages: 3}
+	if run := schedule.LastRun; run == nil || run.Time.Before(before) || run.JobID != want.JobID || run.Status != want.Status || run.Pages != want.Pages {
+		t.Errorf("last run = %+v, want %+v", run, want)
+	}
+
+	// A run that could not start a job is recorded as failed, most recent first
+	if err := s.RunNow("paused"); err != nil {
+		t.Fatal(err)
+	}
+	<-fake.started
+	fake.finish <- func() (*models.ScanJob, error) { return nil, errors.New("scanner not found") }
+	schedule = waitForRuns(t, s, "paused", 2)
+	if run := schedule.Runs[0]; run.Status != "failed" || run.Error != "scanner not found" || *schedule.LastRun != run {
+		t.Errorf("runs = %+v", schedule.Runs)
+	}
+
+	if err := s.RunNow("missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("RunNow of an unknown schedule: %v", err)
+	}
+
+	// Saved schedules come back without their runs
+	reloaded := newTestScheduler(t, &config.SchedulerConfig{File: file}, fake.run)
+	schedules := reloaded.List()
+	if len(schedules) != 2 || schedules[0].ID != "nightly" || schedules[1].ID != "paused" {
+		t.Fatalf("reloaded %+v", schedules)
+	}
+	if schedules[1].LastRun != nil || len(schedules[1].Runs) != 0 || schedules[0].NextRun == nil {
+		t.Errorf("reloaded %+v", schedules)
+	}
+}
+
+func TestRunNowWhileRunning(t *testing.T) {
+	fake := newFakeRun()
+	s := newTestScheduler(t, &config.SchedulerConfig{}, fake.run)
+	if _, err := s.Create(models.Schedule{ID: "inbox", Cron: "@every 1h", ScannerID: "scanner-1", Enabled: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.RunNow("inbox"); err != nil {
+		t.Fatal(err)
+	}
+	<-fake.started
+	if err := s.RunNow("inbox"); !errors.Is(err, ErrRunning) {
+		t.Errorf("second RunNow: %v, want ErrRunning", err)
+	}
+
+	// A cron tick during the run is skipped as well
+	s.execute("inbox")
+	select {
+	case <-fake.started:
+		t.Error("started a second run")
+	default:
+	}
+
+	fake.finish <- func() (*models.ScanJob, error) { return &models.ScanJob{ID: "job-1", Status: "completed"}, nil }
+	waitForRuns(t, s, "inbox", 1)
+	if err := s.RunNow("inbox"); err != nil {
+		t.Errorf("RunNow after the run finished: %v", err)
+	}
+	<-fake.started
+	fake.finish <- func() (*models.ScanJob, error) { return &models.ScanJob{ID: "job-2", Status: "completed"}, nil }
+	if schedule := waitForRuns(t, s, "inbox", 2); len(schedule.Runs) != 2 {
+		t.Errorf("%d runs, want 2", len(schedule.Runs))
+	}
+}
+
+func TestConfigSchedulesAreReadOnly(t *testing.T) {
+	cfg := &config.SchedulerConfig{
+		File: filepath.Join(t.TempDir(), "schedules.json"),
+		Schedules: []config.ScheduleConfig{{
+			ID:         "frontdesk",
+			Cron:       "0 7 * * 1-5",
+			ScannerID:  "scanner-1",
+			Parameters: map[string]interface{}{"resolution": 300, "use_feeder": true},
+			Export:     "office",
+			Owner:      "key:frontdesk",
+		}},
+	}
+	s := newTestScheduler(t, cfg, newFakeRun().run)
+
+	schedule, err := s.Get("frontdesk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if schedule.Source != SourceConfig || !schedule.Enabled || schedule.Owner != "key:frontdesk" ||
+		schedule.Parameters.Resolution != 300 || !schedule.Parameters.UseFeeder ||
+		schedule.Export == nil || schedule.Export.Profile != "office" {
+		t.Errorf("config schedule = %+v", schedule)
+	}
+
+	update := models.Schedule{Cron: "@daily", ScannerID: "scanner-1"}
+	if _, err := s.Update("frontdesk", update); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("Update: %v, want ErrReadOnly", err)
+	}
+	if err := s.Delete("frontdesk"); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("Delete: %v, want ErrReadOnly", err)
+	}
+	if _, err := s.Create(models.Schedule{ID: "frontdesk", Cron: "@daily", ScannerID: "scanner-1"}); !errors.Is(err, ErrExists) {
+		t.Errorf("Create with a config schedule's ID: %v, want ErrExists", err)
+	}
+
+	// Config schedules are not saved with those created through the API
+	if _, err := s.Create(models.Schedule{ID: "api", Cron: "@daily", ScannerID: "scanner-1"}); err != nil {
+		t.Fatal(err)
+	}
+	reloaded := newTestScheduler(t, &config.SchedulerConfig{File: cfg.File}, newFakeRun().run)
+	if schedules := reloaded.List(); len(schedules) != 1 || schedules[0].ID != "api" {
+		t.Errorf("saved schedules = %+v", schedules)
+	}
+
+	for _, bad := range []config.ScheduleConfig{
+		{Cron: "@daily", ScannerID: "scanner-1"},
+		{ID: "bad-cron", Cron: "daily", ScannerID: "scanner-1"},
+		{ID: "no-scanner", Cron: "@daily"},
+		{ID: "bad-params", Cron: "@daily", ScannerID: "scanner-1", Parameters: map[string]interface{}{"resolution": "high"}},
+	} {
+		if _, err := NewScheduler(&config.SchedulerConfig{Schedules: []config.ScheduleConfig{bad}}, newFakeRun().run, testLogger); err == nil {
+			t.Errorf("%+v: no error", bad)
+		}
+	}
+}
+
+func TestSkippedRun(t *testing.T) {
+	fake := newFakeRun()
+	s := newTestScheduler(t, &config.SchedulerConfig{}, fake.run)
+	if _, err := s.Create(models.Schedule{ID: "adf", Cron: "@hourly", ScannerID: "scanner-1", Enabled: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.RunNow("adf"); err != nil {
+		t.Fatal(err)
+	}
+	<-fake.started
+	fake.finish <- func() (*models.ScanJob, error) {
+		return &models.ScanJob{ID: "job-1", Status: "skipped", ErrorCode: "feeder_empty"}, nil
+	}
+
+	schedule := waitForRuns(t, s, "adf", 1)
+	if run := schedule.LastRun; run.Status != "skipped" || run.JobID != "job-1" || run.Pages != 0 || run.Error != "" {
+		t.Errorf("last run = %+v", run)
+	}
+	if schedule.NextRun == nil {
+		t.Error("a skipped run must not stop the schedule")
+	}
+}
@@ -0,0 +1,138 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/scanserver/scanner-service/pkg/models"
+)
+
+// ManifestName is the file name of the manifest stored alongside a job's pages
+const ManifestName = "job.json"
+
+// Manifest describes a job and its pages
+type Manifest struct {
+	JobID       string         `json:"job_id"`
+	ScannerID   string         `json:"scanner_id"`
+	CreatedAt   time.Time      `json:"created_at"`
+	CompletedAt time.Time      `json:"completed_at"`
+	Pages       []ManifestPage `json:"pages"`
+}
+
+// ManifestPage describes a single page of a job
+type ManifestPage struct {
+	PageNumber int    `json:"page_number"`
+	File       string `json:"file"` // Base name within the job
+	Format     string `json:"format"`
+	Size       int64  `json:"size"`
+	Width      int    `json:"width,omitempty"`
+	Height     int    `json:"height,omitempty"`
+	Resolution int    `json:"resolution,omitempty"`
+	StorageURI string `json:"storage_uri,omitempty"`
+}
+
+// NewManifest builds the manifest of a job from its results
+func NewManifest(job *models.ScanJob, results []models.ScanResult, completedAt time.Time) *Manifest {
+	m := &Manifest{
+		JobID:       job.ID,
+		ScannerID:   job.ScannerID,
+		CreatedAt:   job.CreatedAt,
+		CompletedAt: completedAt,
+		Pages:       make([]ManifestPage, 0, len(results)),
+	}
+	for _, r := range results {
+		m.Pages = append(m.Pages, ManifestPage{
+			PageNumber: r.PageNumber,
+			File:       filepath.Base(r.FilePath),
+			Format:     r.Format,
+			Size:       r.FileSize,
+			Width:      r.Width,
+			Height:     r.Height,
+			Resolution: r.Resolution,
+			StorageURI: r.StorageURI,
+		})
+	}
+	return m
+}
+
+// Archive uploads the pages of a completed job to backend, followed by the
+// job manifest. The storage URI of each page is recorded in results.
+func Archive(ctx context.Context, backend Storage, store *FileStore, job *models.ScanJob, results []models.ScanResult) error {
+	completedAt := time.Now()
+	meta := map[string]string{
+		"job-id":       job.ID,
+		"scanner-id":   job.ScannerID,
+		"created-at":   job.CreatedAt.UTC().Format(time.RFC3339),
+		"completed-at": completedAt.UTC().Format(time.RFC3339),
+	}
+
+	var jobKey string
+	for i := range results {
+		key, err := store.Key(results[i].FilePath)
+		if err != nil {
+			return err
+		}
+		jobKey = path.Dir(key)
+
+		meta["page-number"] = strconv.Itoa(i + 1)
+		uri, err := putFile(ctx, backend, key, results[i].FilePath, meta)
+		if err != nil {
+			return err
+		}
+		results[i].StorageURI = uri
+	}
+
+	if jobKey == "" {
+		return nil // Nothing scanned
+	}
+
+	manifest, err := json.MarshalIndent(NewManifest(job, results, completedAt), "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// Keep a local copy so downloads and later exports can use it
+	dir, err := store.JobDir(&Job{ID: job.ID, CreatedAt: job.CreatedAt})
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, ManifestName), manifest, 0644); err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+
+	delete(meta, "page-number")
+	_, err = putFile(ctx, backend, path.Join(jobKey, ManifestName), filepath.Join(dir, ManifestName), meta)
+	return err
+}
+
+// putFile uploads a local file to backend under key
+func putFile(ctx context.Context, backend Storage, key, filePath string, meta map[string]string) (string, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+
+	contentType := mime.TypeByExtension(path.Ext(key))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	uri, err := backend.Put(ctx, key, f, info.Size(), contentType, meta)
+	if err != nil {
+		return "", fmt.Errorf("failed to store %s: %w", key, err)
+	}
+	return uri, nil
+}
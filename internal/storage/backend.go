@@ -0,0 +1,47 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/scanserver/scanner-service/internal/config"
+)
+
+// Storage is a backend that completed job files are archived to
+type Storage interface {
+	// Name returns the backend type, e.g. "local" or "s3"
+	Name() string
+
+	// Put stores size bytes from body under key and returns the object's URI.
+	// meta is attached to the object where the backend supports it.
+	Put(ctx context.Context, key string, body io.Reader, size int64, contentType string, meta map[string]string) (string, error)
+}
+
+// NewStorage creates the backend selected by storage.backend
+func NewStorage(cfg *config.StorageConfig) (Storage, error) {
+	switch strings.ToLower(cfg.Backend) {
+	case "", "local":
+		path := cfg.Local.Path
+		if path == "" {
+			path = cfg.OutputDir
+		}
+		return NewLocalStorage(path), nil
+
+	case "s3":
+		if cfg.S3.Endpoint == "" || cfg.S3.Bucket == "" {
+			return nil, fmt.Errorf("s3 storage requires endpoint and bucket")
+		}
+		return NewS3Storage(&cfg.S3), nil
+
+	case "webdav":
+		if cfg.WebDAV.URL == "" {
+			return nil, fmt.Errorf("webdav storage requires url")
+		}
+		return NewWebDAVStorage(&cfg.WebDAV), nil
+
+	default:
+		return nil, fmt.Errorf("unknown storage backend: %s", cfg.Backend)
+	}
+}
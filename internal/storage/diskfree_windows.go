@@ -0,0 +1,19 @@
+//go:build windows
+
+package storage
+
+import "golang.org/x/sys/windows"
+
+// diskFree returns the bytes available to the current user on the volume containing path
+func diskFree(path string) (uint64, error) {
+	dir, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return 0, err
+	}
+
+	var freeBytes uint64
+	if err := windows.GetDiskFreeSpaceEx(dir, &freeBytes, nil, nil); err != nil {
+		return 0, err
+	}
+	return freeBytes, nil
+}
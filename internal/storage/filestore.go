@@ -0,0 +1,216 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/scanserver/scanner-service/pkg/models"
+)
+
+// ErrNotInStore is returned when a path does not resolve to a file inside the storage directory
+var ErrNotInStore = errors.New("file is not in the storage directory")
+
+// FileStore allocates output paths for scanned pages under the storage directory.
+// Files are laid out per job: <output_dir>/<yyyy-mm-dd>/<job-id>/page-0001.jpg
+type FileStore struct {
+	root string
+}
+
+// NewFileStore creates a file store rooted at the configured output directory
+func NewFileStore(root string) *FileStore {
+	return &FileStore{root: root}
+}
+
+// Root returns the storage directory
+func (s *FileStore) Root() string {
+	return s.root
+}
+
+// CheckWritable creates and removes a file in dir to verify that files can be written there
+func CheckWritable(dir string) error {
+	f, err := os.CreateTemp(dir, ".write-check-*")
+	if err != nil {
+		return err
+	}
+	name := f.Name()
+	err = f.Close()
+	if removeErr := os.Remove(name); err == nil {
+		err = removeErr
+	}
+	return err
+}
+
+// Job identifies the job a scan writes into. It is carried in the context so
+// that several driver scans (e.g. batch passes) number their pages consecutively.
+type Job struct {
+	ID        string
+	CreatedAt time.Time
+
+	mutex sync.Mutex
+	pages int
+}
+
+type jobKey struct{}
+
+// WithJob returns a context carrying the job that scanned pages belong to
+func WithJob(ctx context.Context, jobID string, createdAt time.Time) context.Context {
+	return context.WithValue(ctx, jobKey{}, &Job{ID: jobID, CreatedAt: createdAt})
+}
+
+// JobFromContext returns the job carried by ctx, if any
+func JobFromContext(ctx context.Context) (*Job, bool) {
+	job, ok := ctx.Value(jobKey{}).(*Job)
+	return job, ok
+}
+
+// Pages returns the number of page paths allocated for the job so far
+func (j *Job) Pages() int {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	return j.pages
+}
+
+// JobDir returns the directory holding a job's files, creating it if needed
+func (s *FileStore) JobDir(job *Job) (string, error) {
+	dir := filepath.Join(s.root, job.CreatedAt.Format("2006-01-02"), job.ID)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create job directory: %w", err)
+	}
+	return dir, nil
+}
+
+// NextPagePath allocates the path for the next page of the job in ctx.
+// format is a document format such as "JPEG" or "PNG".
+func (s *FileStore) NextPagePath(ctx context.Context, format string) (string, error) {
+	job, ok := JobFromContext(ctx)
+	if !ok {
+		return "", fmt.Errorf("no job in context")
+	}
+
+	dir, err := s.JobDir(job)
+	if err != nil {
+		return "", err
+	}
+
+	job.mutex.Lock()
+	job.pages++
+	page := job.pages
+	job.mutex.Unlock()
+
+	return filepath.Join(dir, fmt.Sprintf("page-%04d.%s", page, Extension(format))), nil
+}
+
+// Key returns the slash-separated path of a stored file relative to the storage directory
+func (s *FileStore) Key(path string) (string, error) {
+	root, err := filepath.Abs(s.root)
+	if err != nil {
+		return "", err
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+
+	rel, err := filepath.Rel(root, abs)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%w: %s", ErrNotInStore, path)
+	}
+	return filepath.ToSlash(rel), nil
+}
+
+// Resolve returns the real path of a stored file. The path must resolve,
+// after following symlinks, to a location inside the storage directory.
+func (s *FileStore) Resolve(path string) (string, error) {
+	root, err := filepath.EvalSymlinks(s.root)
+	if err != nil {
+		return "", err
+	}
+	root, err = filepath.Abs(root)
+	if err != nil {
+		return "", err
+	}
+
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return "", err
+	}
+	resolved, err = filepath.Abs(resolved)
+	if err != nil {
+		return "", err
+	}
+
+	rel, err := filepath.Rel(root, resolved)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%w: %s", ErrNotInStore, path)
+	}
+	return resolved, nil
+}
+
+// Open opens a stored file for reading. It must be a regular file inside the storage directory.
+func (s *FileStore) Open(path string) (*os.File, os.FileInfo, error) {
+	resolved, err := s.Resolve(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	file, err := os.Open(resolved)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+	if !info.Mode().IsRegular() {
+		file.Close()
+		return nil, nil, fmt.Errorf("%w: %s", ErrNotInStore, path)
+	}
+
+	return file, info, nil
+}
+
+// RenumberPages renames the page files of results so their names follow the
+// order of results (page-0001, page-0002, ...), and updates FilePath and PageNumber.
+// All pages must be in the same job directory.
+func RenumberPages(results []models.ScanResult) error {
+	// Move everything aside first so renames can't overwrite each other
+	temps := make([]string, len(results))
+	for i, result := range results {
+		temps[i] = result.FilePath + ".renumber"
+		if err := os.Rename(result.FilePath, temps[i]); err != nil {
+			return fmt.Errorf("failed to renumber %s: %w", result.FilePath, err)
+		}
+	}
+
+	for i := range results {
+		dir := filepath.Dir(results[i].FilePath)
+		target := filepath.Join(dir, fmt.Sprintf("page-%04d.%s", i+1, Extension(results[i].Format)))
+		if err := os.Rename(temps[i], target); err != nil {
+			return fmt.Errorf("failed to renumber %s: %w", results[i].FilePath, err)
+		}
+		results[i].FilePath = target
+		results[i].PageNumber = i + 1
+	}
+
+	return nil
+}
+
+// Extension returns the file extension for a document format
+func Extension(format string) string {
+	switch strings.ToUpper(format) {
+	case "JPEG", "JPG", "":
+		return "jpg"
+	case "TIFF", "TIF":
+		return "tif"
+	default:
+		return strings.ToLower(format)
+	}
+}
@@ -0,0 +1,68 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/scanserver/scanner-service/pkg/models"
+)
+
+func TestRenumberPages(t *testing.T) {
+	dir := t.TempDir()
+	page := func(name, format string) models.ScanResult {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return models.ScanResult{FilePath: path, Format: format}
+	}
+
+	// A manual duplex document after blank page removal: page-0002 and
+	// page-0005 are gone and the backs (page-0004, page-0006) were scanned last
+	// page first. Renaming page-0006 to page-0002 must not lose anything.
+	results := []models.ScanResult{
+		page("page-0001.jpg", "JPEG"),
+		page("page-0006.jpg", "JPEG"),
+		page("page-0003.png", "PNG"),
+		page("page-0004.tif", "TIFF"),
+	}
+	if err := RenumberPages(results); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []struct{ name, content string }{
+		{"page-0001.jpg", "page-0001.jpg"},
+		{"page-0002.jpg", "page-0006.jpg"},
+		{"page-0003.png", "page-0003.png"},
+		{"page-0004.tif", "page-0004.tif"},
+	}
+	for i, w := range want {
+		r := results[i]
+		if r.PageNumber != i+1 || r.FilePath != filepath.Join(dir, w.name) {
+			t.Errorf("page %d = %d %s, want %s", i+1, r.PageNumber, r.FilePath, w.name)
+			continue
+		}
+		if data, err := os.ReadFile(r.FilePath); err != nil || string(data) != w.content {
+			t.Errorf("%s holds %q, want %q (%v)", w.name, data, w.content, err)
+		}
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	if got := strings.Join(names, " "); got != "page-0001.jpg page-0002.jpg page-0003.png page-0004.tif" {
+		t.Errorf("files %s", got)
+	}
+
+	missing := []models.ScanResult{page("a.jpg", "JPEG"), {FilePath: filepath.Join(dir, "gone.jpg"), Format: "JPEG"}}
+	if err := RenumberPages(missing); err == nil || !strings.Contains(err.Error(), "gone.jpg") {
+		t.Errorf("error %v", err)
+	}
+}
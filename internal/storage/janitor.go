@@ -0,0 +1,390 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/scanserver/scanner-service/internal/config"
+	"github.com/scanserver/scanner-service/internal/logging"
+)
+
+// ErrStorageFull is returned when there is not enough disk space to accept new scans
+var ErrStorageFull = errors.New("storage is full")
+
+// Usage describes how much space scanned files occupy
+type Usage struct {
+	OutputDir   string     `json:"output_dir"`
+	UsedBytes   int64      `json:"used_bytes"`
+	MaxBytes    int64      `json:"max_bytes"` // Quota (0 = unlimited)
+	FileCount   int        `json:"file_count"`
+	FreeBytes   uint64     `json:"free_bytes"`     // Free space on the volume
+	MinFree     int64      `json:"min_free_bytes"` // New jobs are refused below this
+	LastCleanup *time.Time `json:"last_cleanup,omitempty"`
+	LastRemoved int        `json:"last_removed"` // Files removed by the last cleanup
+}
+
+// Janitor enforces the retention period and storage quota on the output directory
+type Janitor struct {
+	config   *config.StorageConfig
+	onRemove func(paths []string)
+	logger   *slog.Logger
+
+	mutex       sync.Mutex
+	lastCleanup *time.Time
+	lastRemoved int
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewJanitor creates a janitor for the configured output directory
+func NewJanitor(cfg *config.StorageConfig, logger *slog.Logger) *Janitor {
+	return &Janitor{
+		config: cfg,
+		logger: logger,
+	}
+}
+
+// OnRemove registers a callback invoked after every cleanup with the absolute paths of deleted files
+func (j *Janitor) OnRemove(callback func(paths []string)) {
+	j.onRemove = callback
+}
+
+// Start runs cleanup immediately and then periodically until Stop is called
+func (j *Janitor) Start() {
+	if !j.config.CleanupEnabled {
+		j.logger.Info("Storage cleanup is disabled")
+		return
+	}
+
+	interval := time.Duration(j.config.CleanupInterval) * time.Minute
+	if interval <= 0 {
+		interval = time.Hour
+	}
+
+	j.stop = make(chan struct{})
+	j.done = make(chan struct{})
+
+	go func() {
+		defer close(j.done)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			if _, err := j.Cleanup(); err != nil {
+				j.logger.Error("Storage cleanup failed", logging.Err(err))
+			}
+
+			select {
+			case <-ticker.C:
+			case <-j.stop:
+				return
+			}
+		}
+	}()
+
+	j.logger.Info("Storage cleanup enabled",
+		"retention_days", j.config.RetentionDays, "quota_bytes", j.config.MaxStorageSize, "interval", interval)
+}
+
+// Stop stops periodic cleanup
+func (j *Janitor) Stop() {
+	if j.stop == nil {
+		return
+	}
+	close(j.stop)
+	<-j.done
+	j.stop = nil
+}
+
+// storedFile is a file found in the output directory
+type storedFile struct {
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+// storedJob is the unit cleanup removes: a <date>/<job-id> directory with
+// all its files, or a single file outside that layout
+type storedJob struct {
+	dir     string // Empty for a single file
+	files   []storedFile
+	size    int64
+	modTime time.Time // Of the newest file
+}
+
+// groupJobs groups files by the job directory holding them
+func groupJobs(root string, files []storedFile) []*storedJob {
+	byDir := make(map[string]*storedJob)
+	var jobs []*storedJob
+	for _, f := range files {
+		var job *storedJob
+		rel, err := filepath.Rel(root, f.path)
+		parts := strings.Split(filepath.ToSlash(rel), "/")
+		if err == nil && len(parts) > 2 {
+			dir := filepath.Join(root, parts[0], parts[1])
+			job = byDir[dir]
+			if job == nil {
+				job = &storedJob{dir: dir}
+				byDir[dir] = job
+				jobs = append(jobs, job)
+			}
+		} else {
+			job = &storedJob{}
+			jobs = append(jobs, job)
+		}
+
+		job.files = append(job.files, f)
+		job.size += f.size
+		if f.modTime.After(job.modTime) {
+			job.modTime = f.modTime
+		}
+	}
+	return jobs
+}
+
+// remove deletes the files of a stored job and returns the removed paths
+func (s *storedJob) remove() ([]string, error) {
+	var removed []string
+	for _, f := range s.files {
+		if err := os.Remove(f.path); err != nil && !os.IsNotExist(err) {
+			return removed, err
+		}
+		removed = append(removed, f.path)
+	}
+	if s.dir != "" {
+		os.RemoveAll(s.dir)
+	}
+	return removed, nil
+}
+
+// Cleanup deletes jobs whose newest file is older than the retention period,
+// then evicts the oldest jobs until usage is below the quota. Jobs are removed
+// whole, never page by page. It returns the removed paths.
+func (j *Janitor) Cleanup() ([]string, error) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	files, used, err := j.scan()
+	if err != nil {
+		return nil, err
+	}
+	root, err := filepath.Abs(j.config.OutputDir)
+	if err != nil {
+		return nil, err
+	}
+	jobs := groupJobs(root, files)
+
+	// Oldest first
+	sort.Slice(jobs, func(a, b int) bool {
+		return jobs[a].modTime.Before(jobs[b].modTime)
+	})
+
+	var cutoff time.Time
+	if j.config.RetentionDays > 0 {
+		cutoff = time.Now().AddDate(0, 0, -j.config.RetentionDays)
+	}
+
+	var removed []string
+	for _, job := range jobs {
+		expired := !cutoff.IsZero() && job.modTime.Before(cutoff)
+		overQuota := j.config.MaxStorageSize > 0 && used >= j.config.MaxStorageSize
+		if !expired && !overQuota {
+			// Jobs are sorted, so nothing newer is expired either
+			break
+		}
+
+		paths, err := job.remove()
+		removed = append(removed, paths...)
+		if err != nil {
+			j.logger.Warn("Storage cleanup failed to remove job files", "dir", job.dir, logging.Err(err))
+			continue
+		}
+		used -= job.size
+	}
+
+	j.removeEmptyDirs()
+
+	now := time.Now()
+	j.lastCleanup = &now
+	j.lastRemoved = len(removed)
+
+	if len(removed) > 0 {
+		j.logger.Info("Storage cleanup removed files", "files", len(removed), "used_bytes", used)
+	}
+
+	// Always notify so expired records without files are dropped too
+	if j.onRemove != nil {
+		j.onRemove(removed)
+	}
+
+	return removed, nil
+}
+
+// CheckCapacity returns ErrStorageFull if the volume holding the output
+// directory has less free space than the configured minimum, or the output
+// directory has reached its quota. Over quota, a cleanup is run first when
+// cleanup is enabled, evicting the oldest jobs to make room.
+func (j *Janitor) CheckCapacity() error {
+	if err := j.checkQuota(); err != nil {
+		if !j.config.CleanupEnabled {
+			return err
+		}
+		if _, cleanupErr := j.Cleanup(); cleanupErr != nil {
+			j.logger.Error("Storage cleanup failed", logging.Err(cleanupErr))
+		}
+		if err := j.checkQuota(); err != nil {
+			return err
+		}
+	}
+
+	if j.config.MinFreeSpace <= 0 {
+		return nil
+	}
+
+	free, err := diskFree(j.config.OutputDir)
+	if err != nil {
+		// Don't block scanning if free space cannot be determined
+		j.logger.Warn("Could not determine free space", "dir", j.config.OutputDir, logging.Err(err))
+		return nil
+	}
+
+	if free < uint64(j.config.MinFreeSpace) {
+		return fmt.Errorf("%w: %d bytes free on %s, at least %d required",
+			ErrStorageFull, free, j.config.OutputDir, j.config.MinFreeSpace)
+	}
+	return nil
+}
+
+// checkQuota returns ErrStorageFull if usage has reached the quota
+func (j *Janitor) checkQuota() error {
+	if j.config.MaxStorageSize <= 0 {
+		return nil
+	}
+
+	j.mutex.Lock()
+	_, used, err := j.scan()
+	j.mutex.Unlock()
+	if err != nil {
+		// Don't block scanning if usage cannot be determined
+		j.logger.Warn("Could not determine storage usage", "dir", j.config.OutputDir, logging.Err(err))
+		return nil
+	}
+
+	if used >= j.config.MaxStorageSize {
+		return fmt.Errorf("%w: %d bytes used in %s, quota is %d",
+			ErrStorageFull, used, j.config.OutputDir, j.config.MaxStorageSize)
+	}
+	return nil
+}
+
+// FreeSpace returns the free bytes on the volume holding the output
+// directory and the configured minimum
+func (j *Janitor) FreeSpace() (free uint64, min int64, err error) {
+	free, err = diskFree(j.config.OutputDir)
+	return free, j.config.MinFreeSpace, err
+}
+
+// Usage reports current storage usage
+func (j *Janitor) Usage() (*Usage, error) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	files, used, err := j.scan()
+	if err != nil {
+		return nil, err
+	}
+
+	free, err := diskFree(j.config.OutputDir)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Usage{
+		OutputDir:   j.config.OutputDir,
+		UsedBytes:   used,
+		MaxBytes:    j.config.MaxStorageSize,
+		FileCount:   len(files),
+		FreeBytes:   free,
+		MinFree:     j.config.MinFreeSpace,
+		LastCleanup: j.lastCleanup,
+		LastRemoved: j.lastRemoved,
+	}, nil
+}
+
+// scan lists all regular files under the output directory
+func (j *Janitor) scan() ([]storedFile, int64, error) {
+	root, err := filepath.Abs(j.config.OutputDir)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var files []storedFile
+	var total int64
+
+	err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return nil // Removed concurrently
+		}
+
+		files = append(files, storedFile{
+			path:    path,
+			size:    info.Size(),
+			modTime: info.ModTime(),
+		})
+		total += info.Size()
+		return nil
+	})
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to scan storage directory: %w", err)
+	}
+
+	return files, total, nil
+}
+
+// removeEmptyDirs deletes empty subdirectories left behind by cleanup
+func (j *Janitor) removeEmptyDirs() {
+	root, err := filepath.Abs(j.config.OutputDir)
+	if err != nil {
+		return
+	}
+
+	// Leave recently created directories alone, a scan may be about to write into them
+	recent := time.Now().Add(-10 * time.Minute)
+
+	var dirs []string
+	filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.IsDir() || path == root {
+			return nil
+		}
+		if info, err := d.Info(); err == nil && info.ModTime().Before(recent) {
+			dirs = append(dirs, path)
+		}
+		return nil
+	})
+
+	// Deepest first so parents become empty before they are checked
+	for i := len(dirs) - 1; i >= 0; i-- {
+		os.Remove(dirs[i]) // Fails harmlessly if not empty
+	}
+}
@@ -0,0 +1,111 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/scanserver/scanner-service/internal/config"
+)
+
+// testLogger discards the log output of the code under test
+var testLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+// writeJob writes a job directory with pages of size bytes, last modified at modTime
+func writeJob(t *testing.T, root, id string, pages, size int, modTime time.Time) string {
+	t.Helper()
+	dir := filepath.Join(root, modTime.Format("2006-01-02"), id)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= pages; i++ {
+		path := filepath.Join(dir, fmt.Sprintf("page-%04d.jpg", i))
+		if err := os.WriteFile(path, make([]byte, size), 0644); err != nil {
+			t.Fatal(err)
+		}
+		// Pages of one job are written at slightly different times
+		mt := modTime.Add(time.Duration(i) * time.Second)
+		if err := os.Chtimes(path, mt, mt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestCleanupEvictsWholeJobs(t *testing.T) {
+	root := t.TempDir()
+	now := time.Now()
+	oldest := writeJob(t, root, "job-old", 3, 100, now.Add(-3*time.Hour))
+	middle := writeJob(t, root, "job-mid", 3, 100, now.Add(-2*time.Hour))
+	newest := writeJob(t, root, "job-new", 3, 100, now.Add(-time.Hour))
+
+	j := NewJanitor(&config.StorageConfig{OutputDir: root, MaxStorageSize: 650}, testLogger)
+	var notified []string
+	j.OnRemove(func(paths []string) { notified = paths })
+
+	removed, err := j.Cleanup()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 900 bytes over a 650 byte quota: evicting one page would suffice, but
+	// the whole oldest job goes
+	if len(removed) != 3 || len(notified) != 3 {
+		t.Fatalf("removed %d file(s), notified %d, want 3", len(removed), len(notified))
+	}
+	if _, err := os.Stat(oldest); !os.IsNotExist(err) {
+		t.Errorf("oldest job directory still exists")
+	}
+	for _, dir := range []string{middle, newest} {
+		entries, err := os.ReadDir(dir)
+		if err != nil || len(entries) != 3 {
+			t.Errorf("%s: %d page(s) left, want 3 (%v)", dir, len(entries), err)
+		}
+	}
+}
+
+func TestCleanupRetention(t *testing.T) {
+	root := t.TempDir()
+	now := time.Now()
+	expired := writeJob(t, root, "job-expired", 2, 10, now.AddDate(0, 0, -10))
+	kept := writeJob(t, root, "job-kept", 2, 10, now.AddDate(0, 0, -1))
+
+	j := NewJanitor(&config.StorageConfig{OutputDir: root, RetentionDays: 7}, testLogger)
+	if _, err := j.Cleanup(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(expired); !os.IsNotExist(err) {
+		t.Errorf("expired job directory still exists")
+	}
+	if _, err := os.Stat(kept); err != nil {
+		t.Errorf("recent job was removed: %v", err)
+	}
+}
+
+func TestCheckCapacityQuota(t *testing.T) {
+	root := t.TempDir()
+	writeJob(t, root, "job-1", 2, 100, time.Now().Add(-time.Hour))
+
+	j := NewJanitor(&config.StorageConfig{OutputDir: root, MaxStorageSize: 200}, testLogger)
+	if err := j.CheckCapacity(); !errors.Is(err, ErrStorageFull) {
+		t.Fatalf("at quota: got %v, want ErrStorageFull", err)
+	}
+
+	// With cleanup enabled the oldest job is evicted to make room
+	j.config.CleanupEnabled = true
+	if err := j.CheckCapacity(); err != nil {
+		t.Fatalf("after eviction: %v", err)
+	}
+
+	j.config.MaxStorageSize = 1000
+	writeJob(t, root, "job-2", 2, 100, time.Now())
+	if err := j.CheckCapacity(); err != nil {
+		t.Fatalf("under quota: %v", err)
+	}
+}
@@ -0,0 +1,77 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// LocalStorage archives files into a directory on the local filesystem.
+// When it points at the output directory, pages are left in place.
+type LocalStorage struct {
+	root string
+}
+
+// NewLocalStorage creates a local filesystem backend rooted at dir
+func NewLocalStorage(dir string) *LocalStorage {
+	return &LocalStorage{root: dir}
+}
+
+// Name returns the backend type
+func (l *LocalStorage) Name() string {
+	return "local"
+}
+
+// Put writes body to <root>/<key>. Metadata is not stored; the job manifest carries it.
+func (l *LocalStorage) Put(ctx context.Context, key string, body io.Reader, size int64, contentType string, meta map[string]string) (string, error) {
+	dest, err := filepath.Abs(filepath.Join(l.root, filepath.FromSlash(key)))
+	if err != nil {
+		return "", err
+	}
+
+	// The page may already be where it belongs
+	if src, ok := body.(*os.File); ok {
+		srcInfo, err1 := src.Stat()
+		destInfo, err2 := os.Stat(dest)
+		if err1 == nil && err2 == nil && os.SameFile(srcInfo, destInfo) {
+			return fileURI(dest), nil
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return "", fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	// Write to a temporary file first so readers never see a partial file
+	tmp, err := os.CreateTemp(filepath.Dir(dest), ".upload-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, body); err != nil {
+		tmp.Close()
+		return "", fmt.Errorf("failed to write %s: %w", key, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp.Name(), dest); err != nil {
+		return "", err
+	}
+
+	return fileURI(dest), nil
+}
+
+// fileURI returns the file:// URI of an absolute path
+func fileURI(path string) string {
+	p := filepath.ToSlash(path)
+	if !strings.HasPrefix(p, "/") {
+		p = "/" + p // Windows drive letter
+	}
+	return (&url.URL{Scheme: "file", Path: p}).String()
+}
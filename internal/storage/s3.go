@@ -0,0 +1,172 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/scanserver/scanner-service/internal/config"
+)
+
+// unsignedPayload lets uploads stream without hashing the body first
+const unsignedPayload = "UNSIGNED-PAYLOAD"
+
+// S3Storage archives files to an S3-compatible object store (AWS S3, MinIO, ...).
+// Requests are signed with AWS Signature Version 4.
+type S3Storage struct {
+	config *config.S3Config
+	client *http.Client
+}
+
+// NewS3Storage creates an S3-compatible backend
+func NewS3Storage(cfg *config.S3Config) *S3Storage {
+	return &S3Storage{
+		config: cfg,
+		client: &http.Client{Timeout: time.Duration(cfg.Timeout) * time.Second},
+	}
+}
+
+// Name returns the backend type
+func (s *S3Storage) Name() string {
+	return "s3"
+}
+
+// Put uploads body as s3://<bucket>/<prefix><key>. Metadata is stored as x-amz-meta-* headers.
+func (s *S3Storage) Put(ctx context.Context, key string, body io.Reader, size int64, contentType string, meta map[string]string) (string, error) {
+	objectKey := path.Join(s.config.Prefix, key)
+
+	endpoint, err := url.Parse(s.config.Endpoint)
+	if err != nil {
+		return "", fmt.Errorf("invalid s3 endpoint: %w", err)
+	}
+	if endpoint.Scheme == "" {
+		endpoint, err = url.Parse("https://" + s.config.Endpoint)
+		if err != nil {
+			return "", fmt.Errorf("invalid s3 endpoint: %w", err)
+		}
+	}
+
+	// Path-style (endpoint/bucket/key) suits MinIO; virtual-hosted style suits AWS
+	target := *endpoint
+	if s.config.PathStyle {
+		target.Path = "/" + s.config.Bucket + "/" + objectKey
+	} else {
+		target.Host = s.config.Bucket + "." + endpoint.Host
+		target.Path = "/" + objectKey
+	}
+	target.RawPath = s3Escape(target.Path)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, target.String(), body)
+	if err != nil {
+		return "", err
+	}
+	req.ContentLength = size
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	for k, v := range meta {
+		req.Header.Set("X-Amz-Meta-"+k, v)
+	}
+
+	s.sign(req, time.Now().UTC())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("s3 upload failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return "", fmt.Errorf("s3 upload of %s failed: %s: %s", objectKey, resp.Status, strings.TrimSpace(string(msg)))
+	}
+
+	return "s3://" + s.config.Bucket + "/" + objectKey, nil
+}
+
+// sign adds AWS Signature Version 4 headers to req
+func (s *S3Storage) sign(req *http.Request, now time.Time) {
+	region := s.config.Region
+	if region == "" {
+		region = "us-east-1"
+	}
+
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	scope := date + "/" + region + "/s3/aws4_request"
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", unsignedPayload)
+
+	// Sign host and all x-amz-* headers
+	headers := map[string]string{"host": req.URL.Host}
+	for k, v := range req.Header {
+		lk := strings.ToLower(k)
+		if strings.HasPrefix(lk, "x-amz-") {
+			headers[lk] = strings.TrimSpace(strings.Join(v, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, k := range names {
+		canonicalHeaders.WriteString(k + ":" + headers[k] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.Query().Encode(),
+		canonicalHeaders.String(),
+		signedHeaders,
+		unsignedPayload,
+	}, "\n")
+
+	hash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(hash[:])
+
+	key := hmacSHA256([]byte("AWS4"+s.config.SecretKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.config.AccessKey, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// s3Escape percent-encodes a path as S3 expects: everything except
+// unreserved characters and '/'
+func s3Escape(p string) string {
+	var b strings.Builder
+	for i := 0; i < len(p); i++ {
+		c := p[i]
+		if ('A' <= c && c <= 'Z') || ('a' <= c && c <= 'z') || ('0' <= c && c <= '9') ||
+			c == '-' || c == '_' || c == '.' || c == '~' || c == '/' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,241 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/scanserver/scanner-service/internal/config"
+)
+
+const (
+	testAccessKey = "AKIDEXAMPLE"
+	testSecretKey = "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"
+)
+
+// s3Upload is what the fake S3 server received
+type s3Upload struct {
+	host        string
+	path        string
+	body        string
+	contentType string
+	meta        map[string]string
+	sigErr      string // Why the signature did not verify, "" if it did
+}
+
+// newFakeS3 starts a server that verifies SigV4 signatures and records uploads
+func newFakeS3(t *testing.T) (*httptest.Server, chan s3Upload) {
+	t.Helper()
+	uploads := make(chan s3Upload, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		upload := s3Upload{
+			host:        r.Host,
+			path:        r.URL.EscapedPath(),
+			body:        string(body),
+			contentType: r.Header.Get("Content-Type"),
+			meta:        map[string]string{},
+			sigErr:      verifySigV4(r, testSecretKey),
+		}
+		for k, v := range r.Header {
+			if lk := strings.ToLower(k); strings.HasPrefix(lk, "x-amz-meta-") {
+				upload.meta[strings.TrimPrefix(lk, "x-amz-meta-")] = v[0]
+			}
+		}
+		uploads <- upload
+
+		if r.Method != http.MethodPut || upload.sigErr != "" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, uploads
+}
+
+// verifySigV4 recomputes the signature of an S3 request from what arrived
+// on the wire, independently of S3Storage.sign
+func verifySigV4(r *http.Request, secret string) string {
+	if r.Header.Get("X-Amz-Content-Sha256") != "UNSIGNED-PAYLOAD" {
+		return "payload is not UNSIGNED-PAYLOAD"
+	}
+
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 ") {
+		return "not a SigV4 authorization: " + auth
+	}
+	fields := map[string]string{}
+	for _, part := range strings.Split(strings.TrimPrefix(auth, "AWS4-HMAC-SHA256 "), ", ") {
+		k, v, _ := strings.Cut(part, "=")
+		fields[k] = v
+	}
+	credential := strings.Split(fields["Credential"], "/")
+	if len(credential) != 5 || credential[0] != testAccessKey {
+		return "bad credential: " + fields["Credential"]
+	}
+	date, region := credential[1], credential[2]
+
+	// Every x-amz-* header must be signed
+	signed := strings.Split(fields["SignedHeaders"], ";")
+	if !sort.StringsAreSorted(signed) {
+		return "signed headers are not sorted"
+	}
+	for k := range r.Header {
+		lk := strings.ToLower(k)
+		if strings.HasPrefix(lk, "x-amz-") && !contains(signed, lk) {
+			return lk + " is not signed"
+		}
+	}
+
+	var canonicalHeaders strings.Builder
+	for _, name := range signed {
+		value := r.Header.Get(name)
+		if name == "host" {
+			value = r.Host
+		}
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(value) + "\n")
+	}
+	canonicalRequest := r.Method + "\n" + r.URL.EscapedPath() + "\n" + r.URL.RawQuery + "\n" +
+		canonicalHeaders.String() + "\n" + fields["SignedHeaders"] + "\nUNSIGNED-PAYLOAD"
+
+	hash := sha256.Sum256([]byte(canonicalRequest))
+	amzDate := r.Header.Get("X-Amz-Date")
+	if !strings.HasPrefix(amzDate, date) {
+		return "X-Amz-Date does not match the credential scope"
+	}
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + date + "/" + region + "/s3/aws4_request\n" + hex.EncodeToString(hash[:])
+
+	mac := func(key []byte, data string) []byte {
+		h := hmac.New(sha256.New, key)
+		h.Write([]byte(data))
+		return h.Sum(nil)
+	}
+	key := mac(mac(mac(mac([]byte("AWS4"+secret), date), region), "s3"), "aws4_request")
+	if want := hex.EncodeToString(mac(key, stringToSign)); fields["Signature"] != want {
+		return "signature mismatch"
+	}
+	return ""
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+func TestS3PutPathStyle(t *testing.T) {
+	srv, uploads := newFakeS3(t)
+	s3 := NewS3Storage(&config.S3Config{
+		Endpoint:  srv.URL,
+		Region:    "eu-central-1",
+		Bucket:    "scans",
+		Prefix:    "office",
+		AccessKey: testAccessKey,
+		SecretKey: testSecretKey,
+		PathStyle: true,
+	})
+
+	meta := map[string]string{"job-id": "job 1", "page-number": "1"}
+	uri, err := s3.Put(context.Background(), "2024-01-31/job 1/page-0001.jpg", strings.NewReader("page"), 4, "image/jpeg", meta)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	upload := <-uploads
+	if upload.sigErr != "" {
+		t.Fatalf("signature: %s", upload.sigErr)
+	}
+	if want := "/scans/office/2024-01-31/job%201/page-0001.jpg"; upload.path != want {
+		t.Errorf("path = %s, want %s", upload.path, want)
+	}
+	if upload.body != "page" || upload.contentType != "image/jpeg" {
+		t.Errorf("body %q, content type %q", upload.body, upload.contentType)
+	}
+	if upload.meta["job-id"] != "job 1" || upload.meta["page-number"] != "1" {
+		t.Errorf("metadata = %v", upload.meta)
+	}
+	if want := "s3://scans/office/2024-01-31/job 1/page-0001.jpg"; uri != want {
+		t.Errorf("uri = %s, want %s", uri, want)
+	}
+}
+
+func TestS3PutVirtualHosted(t *testing.T) {
+	srv, uploads := newFakeS3(t)
+	s3 := NewS3Storage(&config.S3Config{
+		Endpoint:  "http://s3.example.test",
+		Bucket:    "scans",
+		AccessKey: testAccessKey,
+		SecretKey: testSecretKey,
+	})
+	// Resolve the bucket host name to the fake server
+	s3.client.Transport = &http.Transport{
+		DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, network, srv.Listener.Addr().String())
+		},
+	}
+
+	uri, err := s3.Put(context.Background(), "a/b.pdf", strings.NewReader("%PDF"), 4, "application/pdf", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	upload := <-uploads
+	if upload.sigErr != "" {
+		t.Fatalf("signature: %s", upload.sigErr)
+	}
+	if upload.host != "scans.s3.example.test" || upload.path != "/a/b.pdf" {
+		t.Errorf("request went to %s%s", upload.host, upload.path)
+	}
+	if uri != "s3://scans/a/b.pdf" {
+		t.Errorf("uri = %s", uri)
+	}
+}
+
+func TestS3PutRejected(t *testing.T) {
+	srv, _ := newFakeS3(t)
+	s3 := NewS3Storage(&config.S3Config{
+		Endpoint:  srv.URL,
+		Bucket:    "scans",
+		AccessKey: testAccessKey,
+		SecretKey: "wrong secret",
+		PathStyle: true,
+	})
+
+	_, err := s3.Put(context.Background(), "k", strings.NewReader("x"), 1, "", nil)
+	if err == nil || !strings.Contains(err.Error(), "403") {
+		t.Fatalf("err = %v, want a 403 failure", err)
+	}
+}
+
+func TestS3PutTimeout(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	s3 := NewS3Storage(&config.S3Config{Endpoint: srv.URL, Bucket: "scans", PathStyle: true})
+	s3.client.Timeout = 100 * time.Millisecond
+
+	start := time.Now()
+	if _, err := s3.Put(context.Background(), "k", strings.NewReader("x"), 1, "", nil); err == nil {
+		t.Fatal("expected a timeout error from a stalled server")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("upload took %s despite the timeout", elapsed)
+	}
+}
@@ -0,0 +1,121 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/scanserver/scanner-service/internal/config"
+)
+
+// WebDAVStorage archives files to a WebDAV server (Nextcloud, ownCloud, Apache mod_dav, ...)
+type WebDAVStorage struct {
+	config *config.WebDAVConfig
+	client *http.Client
+
+	mutex   sync.Mutex
+	created map[string]bool // Collections known to exist
+}
+
+// NewWebDAVStorage creates a WebDAV backend
+func NewWebDAVStorage(cfg *config.WebDAVConfig) *WebDAVStorage {
+	return &WebDAVStorage{
+		config:  cfg,
+		client:  &http.Client{Timeout: time.Duration(cfg.Timeout) * time.Second},
+		created: make(map[string]bool),
+	}
+}
+
+// Name returns the backend type
+func (w *WebDAVStorage) Name() string {
+	return "webdav"
+}
+
+// Put uploads body to <url>/<key>, creating parent collections as needed.
+// WebDAV has no per-object metadata on PUT; the job manifest carries it.
+func (w *WebDAVStorage) Put(ctx context.Context, key string, body io.Reader, size int64, contentType string, meta map[string]string) (string, error) {
+	dir := path.Dir(key)
+	if dir != "." {
+		if err := w.mkcolAll(ctx, dir); err != nil {
+			return "", err
+		}
+	}
+
+	target := w.url(key)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, target, body)
+	if err != nil {
+		return "", err
+	}
+	req.ContentLength = size
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := w.do(req)
+	if err != nil {
+		return "", fmt.Errorf("webdav upload failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return "", fmt.Errorf("webdav upload of %s failed: %s", key, resp.Status)
+	}
+
+	return target, nil
+}
+
+// mkcolAll creates dir and its parents on the server
+func (w *WebDAVStorage) mkcolAll(ctx context.Context, dir string) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	current := ""
+	for _, segment := range strings.Split(dir, "/") {
+		current = path.Join(current, segment)
+		if w.created[current] {
+			continue
+		}
+
+		req, err := http.NewRequestWithContext(ctx, "MKCOL", w.url(current)+"/", nil)
+		if err != nil {
+			return err
+		}
+
+		resp, err := w.do(req)
+		if err != nil {
+			return fmt.Errorf("webdav mkcol failed: %w", err)
+		}
+		resp.Body.Close()
+
+		// 405 Method Not Allowed means the collection already exists
+		if resp.StatusCode != http.StatusMethodNotAllowed && (resp.StatusCode < 200 || resp.StatusCode > 299) {
+			return fmt.Errorf("webdav mkcol %s failed: %s", current, resp.Status)
+		}
+		w.created[current] = true
+	}
+
+	return nil
+}
+
+// url returns the URL of key below the configured base URL
+func (w *WebDAVStorage) url(key string) string {
+	segments := strings.Split(key, "/")
+	for i, s := range segments {
+		segments[i] = url.PathEscape(s)
+	}
+	return strings.TrimSuffix(w.config.URL, "/") + "/" + strings.Join(segments, "/")
+}
+
+// do sends req with the configured credentials
+func (w *WebDAVStorage) do(req *http.Request) (*http.Response, error) {
+	if w.config.Username != "" {
+		req.SetBasicAuth(w.config.Username, w.config.Password)
+	}
+	return w.client.Do(req)
+}
@@ -0,0 +1,139 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/scanserver/scanner-service/internal/config"
+)
+
+// fakeDAV is a WebDAV server that, like real ones, answers 409 Conflict to
+// MKCOL and PUT when the parent collection does not exist and 405 to MKCOL
+// of an existing collection
+type fakeDAV struct {
+	mutex       sync.Mutex
+	collections map[string]bool
+	files       map[string]string
+	requests    []string // "METHOD path" in order
+	user, pass  string
+}
+
+func newFakeDAV(t *testing.T, base string) (*fakeDAV, *httptest.Server) {
+	t.Helper()
+	dav := &fakeDAV{
+		collections: map[string]bool{base: true},
+		files:       map[string]string{},
+		user:        "scanner",
+		pass:        "secret",
+	}
+	srv := httptest.NewServer(dav)
+	t.Cleanup(srv.Close)
+	return dav, srv
+}
+
+func (d *fakeDAV) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	p := strings.TrimSuffix(r.URL.Path, "/")
+	d.requests = append(d.requests, r.Method+" "+p)
+
+	if user, pass, ok := r.BasicAuth(); !ok || user != d.user || pass != d.pass {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	switch r.Method {
+	case "MKCOL":
+		if d.collections[p] {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		if !d.collections[path.Dir(p)] {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		d.collections[p] = true
+		w.WriteHeader(http.StatusCreated)
+	case http.MethodPut:
+		if !d.collections[path.Dir(p)] {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		d.files[p] = string(body)
+		w.WriteHeader(http.StatusCreated)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func TestWebDAVPutCreatesCollections(t *testing.T) {
+	dav, srv := newFakeDAV(t, "/dav")
+	// The date collection exists already, e.g. from an earlier server run
+	dav.collections["/dav/2024-01-31"] = true
+
+	w := NewWebDAVStorage(&config.WebDAVConfig{URL: srv.URL + "/dav/", Username: "scanner", Password: "secret"})
+
+	target, err := w.Put(context.Background(), "2024-01-31/job 1/page-0001.jpg", strings.NewReader("page"), 4, "image/jpeg", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := srv.URL + "/dav/2024-01-31/job%201/page-0001.jpg"; target != want {
+		t.Errorf("target = %s, want %s", target, want)
+	}
+	if got := dav.files["/dav/2024-01-31/job 1/page-0001.jpg"]; got != "page" {
+		t.Errorf("stored %q", got)
+	}
+
+	want := []string{
+		"MKCOL /dav/2024-01-31", // 405, already exists
+		"MKCOL /dav/2024-01-31/job 1",
+		"PUT /dav/2024-01-31/job 1/page-0001.jpg",
+	}
+	if strings.Join(dav.requests, "\n") != strings.Join(want, "\n") {
+		t.Errorf("requests:\n%s\nwant:\n%s", strings.Join(dav.requests, "\n"), strings.Join(want, "\n"))
+	}
+
+	// Collections are created once per storage
+	dav.requests = nil
+	if _, err := w.Put(context.Background(), "2024-01-31/job 1/page-0002.jpg", strings.NewReader("page"), 4, "image/jpeg", nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(dav.requests) != 1 || !strings.HasPrefix(dav.requests[0], "PUT ") {
+		t.Errorf("second upload sent %v, want a single PUT", dav.requests)
+	}
+}
+
+func TestWebDAVPutMissingBase(t *testing.T) {
+	_, srv := newFakeDAV(t, "/dav")
+	// The base collection does not exist on the server, so creating the
+	// first collection below it conflicts
+	w := NewWebDAVStorage(&config.WebDAVConfig{URL: srv.URL + "/missing", Username: "scanner", Password: "secret"})
+
+	_, err := w.Put(context.Background(), "2024-01-31/job/page-0001.jpg", strings.NewReader("page"), 4, "", nil)
+	if err == nil || !strings.Contains(err.Error(), "409") {
+		t.Fatalf("err = %v, want a 409 mkcol failure", err)
+	}
+
+	// A failed collection is not cached as created
+	if w.created["2024-01-31"] {
+		t.Error("failed collection was cached")
+	}
+}
+
+func TestWebDAVPutUnauthorized(t *testing.T) {
+	_, srv := newFakeDAV(t, "/dav")
+	w := NewWebDAVStorage(&config.WebDAVConfig{URL: srv.URL + "/dav", Username: "scanner", Password: "wrong"})
+
+	_, err := w.Put(context.Background(), "page.jpg", strings.NewReader("page"), 4, "", nil)
+	if err == nil || !strings.Contains(err.Error(), "401") {
+		t.Fatalf("err = %v, want a 401 upload failure", err)
+	}
+}
@@ -0,0 +1,56 @@
+package tracing
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Middleware traces every request except those to the ignored routes, such
+// as health checks and long-lived WebSocket connections. A trace started by
+// the client (W3C traceparent header) is continued. Handlers find the request
+// span in the request context.
+func Middleware(ignore ...string) gin.HandlerFunc {
+	skip := make(map[string]bool, len(ignore))
+	for _, route := range ignore {
+		skip[route] = true
+	}
+
+	return func(c *gin.Context) {
+		route := c.FullPath()
+		if skip[route] {
+			c.Next()
+			return
+		}
+
+		name := c.Request.Method
+		if route != "" {
+			name += " " + route
+		}
+
+		ctx := otel.GetTextMapPropagator().Extract(c.Request.Context(), propagation.HeaderCarrier(c.Request.Header))
+		ctx, span := otel.Tracer(instrumentationName).Start(ctx, name,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(c.Request.Method),
+				semconv.HTTPRoute(route),
+				semconv.URLPath(c.Request.URL.Path),
+				semconv.ClientAddress(c.ClientIP()),
+			))
+		defer span.End()
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+
+		status := c.Writer.Status()
+		span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+	}
+}
@@ -0,0 +1,122 @@
+// Package tracing exports OpenTelemetry traces of requests and scan jobs to an
+// OTLP collector
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/scanserver/scanner-service/internal/config"
+	"github.com/scanserver/scanner-service/internal/logging"
+)
+
+// instrumentationName identifies the spans of this service's tracer
+const instrumentationName = "github.com/scanserver/scanner-service"
+
+// Attribute keys shared by the spans of a job
+const (
+	KeyJobID     = attribute.Key("scan.job.id")
+	KeyScannerID = attribute.Key("scan.scanner.id")
+	KeyPage      = attribute.Key("scan.page")
+	KeyPages     = attribute.Key("scan.pages")
+)
+
+// Setup installs the global tracer provider, exporting to the configured
+// collector. The returned function exports the spans still buffered and
+// stops the exporter. With tracing disabled spans are dropped and the
+// function does nothing.
+func Setup(ctx context.Context, cfg *config.TracingConfig, version string) (func(context.Context) error, error) {
+	// Continue traces started by callers even when not exporting, so the
+	// IDs in their traceparent headers reach other instrumented services
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{}, propagation.Baggage{}))
+
+	if !cfg.Enabled {
+		return func(context.Context) error { return nil }, nil
+	}
+	if cfg.Endpoint == "" {
+		return nil, errors.New("tracing.endpoint is required")
+	}
+	if cfg.SampleRatio < 0 || cfg.SampleRatio > 1 {
+		return nil, fmt.Errorf("tracing.sample_ratio must be between 0 and 1, got %v", cfg.SampleRatio)
+	}
+
+	opts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(cfg.Endpoint)}
+	if cfg.URLPath != "" {
+		opts = append(opts, otlptracehttp.WithURLPath(cfg.URLPath))
+	}
+	if cfg.Insecure {
+		opts = append(opts, otlptracehttp.WithInsecure())
+	}
+	if len(cfg.Headers) > 0 {
+		opts = append(opts, otlptracehttp.WithHeaders(cfg.Headers))
+	}
+	exporter, err := otlptracehttp.New(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create trace exporter: %w", err)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(semconv.SchemaURL,
+		semconv.ServiceName(cfg.ServiceName),
+		semconv.ServiceVersion(version),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe trace resource: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetErrorHandler(otel.ErrorHandlerFunc(func(err error) {
+		slog.Warn("Trace export failed", logging.Err(err))
+	}))
+
+	return provider.Shutdown, nil
+}
+
+// Start starts a span as a child of the span in ctx, if any
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentationName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End ends span, marking it failed with err unless err is nil
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// Link returns ctx carrying the span of parent, so spans started from the
+// result belong to parent's trace even though ctx is not cancelled with it
+func Link(ctx, parent context.Context) context.Context {
+	return trace.ContextWithSpanContext(ctx, trace.SpanContextFromContext(parent))
+}
+
+// StartJob starts the span of a job, dated from when the job was created.
+// The time until now, which the job spent waiting to start, is recorded as
+// its first child span.
+func StartJob(ctx context.Context, name string, created time.Time, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	tracer := otel.Tracer(instrumentationName)
+	ctx, span := tracer.Start(ctx, name, trace.WithTimestamp(created), trace.WithAttributes(attrs...))
+	_, wait := tracer.Start(ctx, "job.queue_wait", trace.WithTimestamp(created))
+	wait.End()
+	return ctx, span
+}
@@ -0,0 +1,142 @@
+package webhook
+
+import (
+	"sync"
+	"time"
+)
+
+// Delivery states
+const (
+	StatusPending   = "pending" // Queued or waiting for a retry
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+)
+
+// defaultLogSize is the number of deliveries kept when none is configured
+const defaultLogSize = 1000
+
+// Delivery is one event sent to one webhook
+type Delivery struct {
+	ID          string     `json:"id"`
+	URL         string     `json:"url"`
+	Event       Event      `json:"event"`
+	JobID       string     `json:"job_id"`
+	Status      string     `json:"status"`
+	Error       string     `json:"error,omitempty"` // Why the delivery failed
+	Attempts    []Attempt  `json:"attempts"`
+	CreatedAt   time.Time  `json:"created_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+
+	body []byte
+}
+
+// Attempt is one HTTP request of a delivery
+type Attempt struct {
+	Time       time.Time `json:"time"`
+	StatusCode int       `json:"status_code,omitempty"` // 0 if no response was received
+	Error      string    `json:"error,omitempty"`
+	DurationMS int64     `json:"duration_ms"`
+}
+
+// DeliveryFilter selects deliveries from the log. Empty fields match everything.
+type DeliveryFilter struct {
+	JobID  string
+	Event  Event
+	Status string
+	Limit  int // 0 = no limit
+}
+
+// matches reports whether a delivery passes the filter
+func (f DeliveryFilter) matches(d *Delivery) bool {
+	return (f.JobID == "" || d.JobID == f.JobID) &&
+		(f.Event == "" || d.Event == f.Event) &&
+		(f.Status == "" || d.Status == f.Status)
+}
+
+// deliveryLog keeps the most recent deliveries in memory
+type deliveryLog struct {
+	mutex      sync.RWMutex
+	size       int
+	deliveries []*Delivery // Oldest first
+	byID       map[string]*Delivery
+}
+
+func newDeliveryLog(size int) *deliveryLog {
+	if size <= 0 {
+		size = defaultLogSize
+	}
+	return &deliveryLog{
+		size: size,
+		byID: make(map[string]*Delivery),
+	}
+}
+
+// add appends a delivery, evicting the oldest one if the log is full
+func (l *deliveryLog) add(d *Delivery) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if len(l.deliveries) >= l.size {
+		delete(l.byID, l.deliveries[0].ID)
+		l.deliveries = l.deliveries[1:]
+	}
+	l.deliveries = append(l.deliveries, d)
+	l.byID[d.ID] = d
+}
+
+// record adds an attempt to a delivery
+func (l *deliveryLog) record(d *Delivery, attempt Attempt) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	d.Attempts = append(d.Attempts, attempt)
+}
+
+// finish sets the final status of a delivery
+func (l *deliveryLog) finish(d *Delivery, status, reason string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := time.Now()
+	d.Status = status
+	d.Error = reason
+	d.CompletedAt = &now
+	d.body = nil
+}
+
+// list returns copies of the matching deliveries, newest first
+func (l *deliveryLog) list(filter DeliveryFilter) []Delivery {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+
+	result := make([]Delivery, 0)
+	for i := len(l.deliveries) - 1; i >= 0; i-- {
+		if filter.Limit > 0 && len(result) >= filter.Limit {
+			break
+		}
+		if d := l.deliveries[i]; filter.matches(d) {
+			result = append(result, d.snapshot())
+		}
+	}
+	return result
+}
+
+// get returns a copy of a delivery
+func (l *deliveryLog) get(id string) (Delivery, bool) {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+
+	d, ok := l.byID[id]
+	if !ok {
+		return Delivery{}, false
+	}
+	return d.snapshot(), true
+}
+
+// snapshot copies a delivery; the caller holds the log mutex
+func (d *Delivery) snapshot() Delivery {
+	c := *d
+	c.Attempts = append([]Attempt{}, d.Attempts...)
+	c.body = nil
+	return c
+}
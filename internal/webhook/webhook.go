@@ -0,0 +1,320 @@
+// Package webhook notifies external systems of job lifecycle events
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/scanserver/scanner-service/internal/config"
+	"github.com/scanserver/scanner-service/internal/logging"
+	"github.com/scanserver/scanner-service/pkg/models"
+)
+
+// Event is a job lifecycle event
+type Event string
+
+// Job lifecycle events
+const (
+	EventJobCreated     Event = "job.created"
+	EventJobStarted     Event = "job.started"
+	EventJobPageScanned Event = "job.page_scanned"
+	EventJobCompleted   Event = "job.completed"
+	EventJobFailed      Event = "job.failed"
+	EventJobCancelled   Event = "job.cancelled"
+	EventJobSkipped     Event = "job.skipped"
+	EventJobVersioned   Event = "job.version_created"
+)
+
+// Request headers sent with every delivery
+const (
+	HeaderEvent     = "X-Scanner-Event"
+	HeaderDelivery  = "X-Scanner-Delivery"
+	HeaderSignature = "X-Scanner-Signature" // sha256=<hex HMAC of the body>, only if a secret is set
+)
+
+// ErrDeliveryNotFound is returned for a delivery that is not in the log
+var ErrDeliveryNotFound = errors.New("delivery not found")
+
+// queueSize is the number of deliveries an endpoint can have waiting
+const queueSize = 256
+
+// Payload is the JSON body of a delivery
+type Payload struct {
+	ID    string          `json:"id"` // Delivery ID, also in the X-Scanner-Delivery header
+	Event Event           `json:"event"`
+	Time  time.Time       `json:"time"`
+	Page  int             `json:"page,omitempty"` // Page number for job.page_scanned
+	Job   *models.ScanJob `json:"job"`
+}
+
+// Sign returns the X-Scanner-Signature value for a body
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// endpoint is a configured webhook and its delivery queue
+type endpoint struct {
+	config config.WebhookEndpoint
+	events map[Event]bool // nil = all events
+	queue  chan *Delivery
+}
+
+// accepts reports whether the endpoint subscribed to event
+func (e *endpoint) accepts(event Event) bool {
+	return e.events == nil || e.events[event]
+}
+
+// Dispatcher delivers job events to the configured webhooks. Each endpoint
+// receives its events in order; failed deliveries are retried with
+// exponential backoff and every attempt is recorded in the delivery log.
+type Dispatcher struct {
+	config    *config.WebhooksConfig
+	client    *http.Client
+	endpoints []*endpoint
+	log       *deliveryLog
+	backoff   time.Duration // Wait before the first retry, doubled after each
+	logger    *slog.Logger
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewDispatcher creates a dispatcher for the configured webhooks
+func NewDispatcher(cfg *config.WebhooksConfig, logger *slog.Logger) *Dispatcher {
+	timeout := time.Duration(cfg.Timeout) * time.Second
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+
+	backoff := time.Duration(cfg.InitialBackoff) * time.Second
+	if backoff <= 0 {
+		backoff = time.Second
+	}
+
+	d := &Dispatcher{
+		config:  cfg,
+		client:  &http.Client{Timeout: timeout},
+		log:     newDeliveryLog(cfg.LogSize),
+		backoff: backoff,
+		logger:  logger,
+	}
+
+	for _, ep := range cfg.Endpoints {
+		e := &endpoint{config: ep, queue: make(chan *Delivery, queueSize)}
+		if len(ep.Events) > 0 {
+			e.events = make(map[Event]bool, len(ep.Events))
+			for _, event := range ep.Events {
+				e.events[Event(event)] = true
+			}
+		}
+		d.endpoints = append(d.endpoints, e)
+	}
+
+	return d
+}
+
+// Start starts delivering events until Stop is called
+func (d *Dispatcher) Start() {
+	if len(d.endpoints) == 0 || d.stop != nil {
+		return
+	}
+
+	d.stop = make(chan struct{})
+	for _, e := range d.endpoints {
+		d.wg.Add(1)
+		go d.run(e)
+	}
+
+	d.logger.Info("Webhooks enabled", "endpoints", len(d.endpoints))
+}
+
+// Stop stops delivering events. Deliveries still queued or waiting for a retry are marked failed.
+func (d *Dispatcher) Stop() {
+	if d.stop == nil {
+		return
+	}
+	close(d.stop)
+	d.wg.Wait()
+	d.stop = nil
+}
+
+// Send queues event for every webhook subscribed to it. job is a copy
+// the caller took while holding the lock that guards the running job.
+// page is the page number for job.page_scanned and ignored otherwise.
+func (d *Dispatcher) Send(event Event, job models.ScanJob, page int) {
+	if event != EventJobPageScanned {
+		page = 0
+	}
+
+	for _, e := range d.endpoints {
+		if !e.accepts(event) {
+			continue
+		}
+
+		delivery := &Delivery{
+			ID:        models.GenerateUUID(),
+			URL:       e.config.URL,
+			Event:     event,
+			JobID:     job.ID,
+			Status:    StatusPending,
+			CreatedAt: time.Now(),
+		}
+
+		body, err := json.Marshal(Payload{
+			ID:    delivery.ID,
+			Event: event,
+			Time:  delivery.CreatedAt,
+			Page:  page,
+			Job:   &job,
+		})
+		if err != nil {
+			d.logger.Error("Failed to encode webhook payload", logging.KeyJobID, job.ID, logging.Err(err))
+			continue
+		}
+		delivery.body = body
+
+		d.log.add(delivery)
+
+		select {
+		case e.queue <- delivery:
+		default:
+			d.log.finish(delivery, StatusFailed, "delivery queue is full")
+			d.logger.Warn("Webhook queue is full, dropped event",
+				logging.KeyJobID, job.ID, "event", event, "url", e.config.URL)
+		}
+	}
+}
+
+// Deliveries returns the delivery log, newest first
+func (d *Dispatcher) Deliveries(filter DeliveryFilter) []Delivery {
+	return d.log.list(filter)
+}
+
+// Delivery returns a delivery from the log, or ErrDeliveryNotFound
+func (d *Dispatcher) Delivery(id string) (Delivery, error) {
+	delivery, ok := d.log.get(id)
+	if !ok {
+		return Delivery{}, fmt.Errorf("%w: %s", ErrDeliveryNotFound, id)
+	}
+	return delivery, nil
+}
+
+// run delivers the events queued for an endpoint one at a time
+func (d *Dispatcher) run(e *endpoint) {
+	defer d.wg.Done()
+
+	for {
+		select {
+		case delivery := <-e.queue:
+			// select picks randomly when both are ready; never send after Stop
+			select {
+			case <-d.stop:
+				d.log.finish(delivery, StatusFailed, "server shut down")
+			default:
+				d.deliver(e, delivery)
+			}
+		case <-d.stop:
+			// Record what will never be sent
+			for {
+				select {
+				case delivery := <-e.queue:
+					d.log.finish(delivery, StatusFailed, "server shut down")
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// deliver sends a delivery, retrying with exponential backoff
+func (d *Dispatcher) deliver(e *endpoint, delivery *Delivery) {
+	maxAttempts := d.config.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = 1
+	}
+	backoff := d.backoff
+
+	for attempt := 1; ; attempt++ {
+		err := d.attempt(e, delivery)
+		if err == nil {
+			d.log.finish(delivery, StatusSucceeded, "")
+			return
+		}
+
+		if attempt >= maxAttempts {
+			d.log.finish(delivery, StatusFailed, err.Error())
+			d.logger.Warn("Webhook delivery failed",
+				logging.KeyJobID, delivery.JobID, "event", delivery.Event, "url", e.config.URL,
+				"attempts", attempt, logging.Err(err))
+			return
+		}
+
+		select {
+		case <-time.After(backoff):
+			backoff *= 2
+		case <-d.stop:
+			d.log.finish(delivery, StatusFailed, "server shut down")
+			return
+		}
+	}
+}
+
+// attempt makes one POST of a delivery and records the outcome
+func (d *Dispatcher) attempt(e *endpoint, delivery *Delivery) error {
+	start := time.Now()
+	statusCode, err := d.post(e, delivery)
+
+	attempt := Attempt{
+		Time:       start,
+		StatusCode: statusCode,
+		DurationMS: time.Since(start).Milliseconds(),
+	}
+	if err != nil {
+		attempt.Error = err.Error()
+	}
+	d.log.record(delivery, attempt)
+
+	return err
+}
+
+// post sends the delivery body and returns the response status
+func (d *Dispatcher) post(e *endpoint, delivery *Delivery) (int, error) {
+	req, err := http.NewRequest(http.MethodPost, e.config.URL, bytes.NewReader(delivery.body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "scanner-service-webhook")
+	req.Header.Set(HeaderEvent, string(delivery.Event))
+	req.Header.Set(HeaderDelivery, delivery.ID)
+	if e.config.Secret != "" {
+		req.Header.Set(HeaderSignature, Sign(e.config.Secret, delivery.body))
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	// Drain a little of the body so the connection can be reused
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,311 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/scanserver/scanner-service/internal/config"
+	"github.com/scanserver/scanner-service/pkg/models"
+)
+
+// testLogger discards the log output of the code under test
+var testLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+// received is a request the test endpoint got
+type received struct {
+	header http.Header
+	body   []byte
+}
+
+// testEndpoint answers with the statuses in order, then 200
+type testEndpoint struct {
+	*httptest.Server
+	mutex    sync.Mutex
+	statuses []int
+	requests []received
+}
+
+func newTestEndpoint(t *testing.T, statuses ...int) *testEndpoint {
+	t.Helper()
+	e := &testEndpoint{statuses: statuses}
+	e.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		e.mutex.Lock()
+		e.requests = append(e.requests, received{header: r.Header.Clone(), body: body})
+		status := http.StatusOK
+		if len(e.statuses) > 0 {
+			status, e.statuses = e.statuses[0], e.statuses[1:]
+		}
+		e.mutex.Unlock()
+
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(e.Close)
+	return e
+}
+
+func (e *testEndpoint) received() []received {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return append([]received(nil), e.requests...)
+}
+
+// waitFinished waits until the delivery is no longer pending
+func waitFinished(t *testing.T, d *Dispatcher, id string) Delivery {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		delivery, err := d.Delivery(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if delivery.Status != StatusPending {
+			return delivery
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("delivery %s still pending after %d attempt(s)", id, len(delivery.Attempts))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// onlyDelivery returns the single delivery in the log
+func onlyDelivery(t *testing.T, d *Dispatcher) Delivery {
+	t.Helper()
+	deliveries := d.Deliveries(DeliveryFilter{})
+	if len(deliveries) != 1 {
+		t.Fatalf("%d deliveries logged, want 1", len(deliveries))
+	}
+	return deliveries[0]
+}
+
+func TestSignedDelivery(t *testing.T) {
+	e := newTestEndpoint(t)
+	d := NewDispatcher(&config.WebhooksConfig{
+		MaxAttempts: 1,
+		Endpoints:   []config.WebhookEndpoint{{URL: e.URL, Secret: "s3cret"}},
+	}, testLogger)
+	d.Start()
+	defer d.Stop()
+
+	job := models.ScanJob{ID: "job-1", ScannerID: "scanner-1", Status: "processing"}
+	d.Send(EventJobPageScanned, job, 2)
+	delivery := waitFinished(t, d, onlyDelivery(t, d).ID)
+	if delivery.Status != StatusSucceeded {
+		t.Fatalf("status = %s (%s)", delivery.Status, delivery.Error)
+	}
+
+	requests := e.received()
+	if len(requests) != 1 {
+		t.Fatalf("%d requests, want 1", len(requests))
+	}
+	req := requests[0]
+
+	mac := hmac.New(sha256.New, []byte("s3cret"))
+	mac.Write(req.body)
+	want := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	if got := req.header.Get(HeaderSignature); got != want || got != Sign("s3cret", req.body) {
+		t.Errorf("signature = %s, want %s", got, want)
+	}
+	if req.header.Get(HeaderEvent) != string(EventJobPageScanned) || req.header.Get(HeaderDelivery) != delivery.ID {
+		t.Errorf("headers = %v", req.header)
+	}
+
+	var payload Payload
+	if err := json.Unmarshal(req.body, &payload); err != nil {
+		t.Fatal(err)
+	}
+	if payload.ID != delivery.ID || payload.Event != EventJobPageScanned || payload.Page != 2 || payload.Job.ID != "job-1" {
+		t.Errorf("payload = %+v", payload)
+	}
+}
+
+func TestUnsignedDelivery(t *testing.T) {
+	e := newTestEndpoint(t)
+	d := NewDispatcher(&config.WebhooksConfig{Endpoints: []config.WebhookEndpoint{{URL: e.URL}}}, testLogger)
+	d.Start()
+	defer d.Stop()
+
+	d.Send(EventJobCompleted, models.ScanJob{ID: "job-1"}, 3)
+	waitFinished(t, d, onlyDelivery(t, d).ID)
+
+	req := e.received()[0]
+	if sig := req.header.Get(HeaderSignature); sig != "" {
+		t.Errorf("unexpected signature %s without a secret", sig)
+	}
+	// The page number is only sent with job.page_scanned
+	if strings.Contains(string(req.body), `"page"`) {
+		t.Errorf("body has a page number: %s", req.body)
+	}
+}
+
+func TestEventFiltering(t *testing.T) {
+	e := &endpoint{events: map[Event]bool{EventJobCompleted: true, EventJobFailed: true}}
+	for event, want := range map[Event]bool{
+		EventJobCompleted: true,
+		EventJobFailed:    true,
+		EventJobCreated:   false,
+		EventJobVersioned: false,
+	} {
+		if got := e.accepts(event); got != want {
+			t.Errorf("accepts(%s) = %v, want %v", event, got, want)
+		}
+	}
+	if all := (&endpoint{}); !all.accepts(EventJobPageScanned) {
+		t.Error("an endpoint without events must accept all")
+	}
+
+	completed := newTestEndpoint(t)
+	everything := newTestEndpoint(t)
+	d := NewDispatcher(&config.WebhooksConfig{Endpoints: []config.WebhookEndpoint{
+		{URL: completed.URL, Events: []string{"job.completed"}},
+		{URL: everything.URL},
+	}}, testLogger)
+	d.Start()
+	defer d.Stop()
+
+	job := models.ScanJob{ID: "job-1"}
+	d.Send(EventJobCreated, job, 0)
+	d.Send(EventJobCompleted, job, 0)
+	for _, delivery := range d.Deliveries(DeliveryFilter{}) {
+		waitFinished(t, d, delivery.ID)
+	}
+
+	if n := len(d.Deliveries(DeliveryFilter{})); n != 3 {
+		t.Errorf("%d deliveries logged, want 3", n)
+	}
+	got := completed.received()
+	if len(got) != 1 || got[0].header.Get(HeaderEvent) != "job.completed" {
+		t.Errorf("filtered endpoint got %d request(s)", len(got))
+	}
+	// Each endpoint receives its events in order
+	got = everything.received()
+	if len(got) != 2 || got[0].header.Get(HeaderEvent) != "job.created" || got[1].header.Get(HeaderEvent) != "job.completed" {
+		t.Errorf("unfiltered endpoint got %d request(s)", len(got))
+	}
+}
+
+func TestRetryWithBackoff(t *testing.T) {
+	e := newTestEndpoint(t, http.StatusInternalServerError, http.StatusBadGateway)
+	d := NewDispatcher(&config.WebhooksConfig{
+		MaxAttempts: 5,
+		Endpoints:   []config.WebhookEndpoint{{URL: e.URL}},
+	}, testLogger)
+	d.backoff = 20 * time.Millisecond
+	d.Start()
+	defer d.Stop()
+
+	d.Send(EventJobCompleted, models.ScanJob{ID: "job-1"}, 0)
+	delivery := waitFinished(t, d, onlyDelivery(t, d).ID)
+
+	if delivery.Status != StatusSucceeded || delivery.Error != "" || delivery.CompletedAt == nil {
+		t.Fatalf("delivery = %+v", delivery)
+	}
+	attempts := delivery.Attempts
+	if len(attempts) != 3 {
+		t.Fatalf("%d attempts, want 3", len(attempts))
+	}
+	for i, want := range []int{500, 502, 200} {
+		if attempts[i].StatusCode != want {
+			t.Errorf("attempt %d: status %d, want %d", i+1, attempts[i].StatusCode, want)
+		}
+		if (attempts[i].Error == "") != (want == 200) {
+			t.Errorf("attempt %d: error %q", i+1, attempts[i].Error)
+		}
+	}
+	// The wait doubles after each failed attempt
+	if gap := attempts[1].Time.Sub(attempts[0].Time); gap < 20*time.Millisecond {
+		t.Errorf("first retry after %s", gap)
+	}
+	if gap := attempts[2].Time.Sub(attempts[1].Time); gap < 40*time.Millisecond {
+		t.Errorf("second retry after %s", gap)
+	}
+}
+
+func TestGiveUpAfterMaxAttempts(t *testing.T) {
+	e := newTestEndpoint(t, 500, 500, 500, 500)
+	d := NewDispatcher(&config.WebhooksConfig{
+		MaxAttempts: 3,
+		Endpoints:   []config.WebhookEndpoint{{URL: e.URL}},
+	}, testLogger)
+	d.backoff = time.Millisecond
+	d.Start()
+	defer d.Stop()
+
+	d.Send(EventJobFailed, models.ScanJob{ID: "job-1"}, 0)
+	delivery := waitFinished(t, d, onlyDelivery(t, d).ID)
+
+	if delivery.Status != StatusFailed || !strings.Contains(delivery.Error, "500") {
+		t.Errorf("status %s, error %q", delivery.Status, delivery.Error)
+	}
+	if len(delivery.Attempts) != 3 || len(e.received()) != 3 {
+		t.Errorf("%d attempts logged, %d requests sent, want 3", len(delivery.Attempts), len(e.received()))
+	}
+}
+
+func TestStopFailsPendingDeliveries(t *testing.T) {
+	e := newTestEndpoint(t, 503)
+	d := NewDispatcher(&config.WebhooksConfig{
+		MaxAttempts: 5,
+		Endpoints:   []config.WebhookEndpoint{{URL: e.URL}},
+	}, testLogger)
+	d.backoff = time.Hour
+	d.Start()
+
+	job := models.ScanJob{ID: "job-1"}
+	d.Send(EventJobCreated, job, 0)
+	// Wait until the first delivery failed once and waits for its retry;
+	// the second stays queued behind it
+	deadline := time.Now().Add(5 * time.Second)
+	for len(e.received()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	d.Send(EventJobCompleted, job, 0)
+
+	stopped := make(chan struct{})
+	go func() {
+		d.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Stop did not interrupt the retry backoff")
+	}
+
+	deliveries := d.Deliveries(DeliveryFilter{})
+	if len(deliveries) != 2 {
+		t.Fatalf("%d deliveries logged, want 2", len(deliveries))
+	}
+	for _, delivery := range deliveries {
+		if delivery.Status != StatusFailed || delivery.Error != "server shut down" {
+			t.Errorf("%s: status %s, error %q", delivery.Event, delivery.Status, delivery.Error)
+		}
+	}
+	// Newest first: the queued delivery was never attempted
+	if n := len(deliveries[0].Attempts); n != 0 {
+		t.Errorf("queued delivery has %d attempt(s)", n)
+	}
+	if n := len(deliveries[1].Attempts); n != 1 {
+		t.Errorf("retried delivery has %d attempt(s), want 1", n)
+	}
+}
+
+func TestDeliveryNotFound(t *testing.T) {
+	d := NewDispatcher(&config.WebhooksConfig{}, testLogger)
+	if _, err := d.Delivery("missing"); !errors.Is(err, ErrDeliveryNotFound) {
+		t.Errorf("err = %v, want ErrDeliveryNotFound", err)
+	}
+}
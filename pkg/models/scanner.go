@@ -55,8 +55,8 @@ type Scanner struct {
 
 // Capability represents scanner capabilities
 type Capability struct {
-	MaxWidth        int      `json:"max_width"`
-	MaxHeight       int      `json:"max_height"`
+	MaxWidth        int      `json:"max_width"`  // 0.1mm
+	MaxHeight       int      `json:"max_height"` // 0.1mm
 	Resolutions     []int    `json:"resolutions"`
 	ColorModes      []string `json:"color_modes"`      // Color, Grayscale, BlackAndWhite
 	DocumentFormats []string `json:"document_formats"` // PDF, JPEG, PNG, TIFF
@@ -68,13 +68,55 @@ type Capability struct {
 type ScanJob struct {
 	ID          string       `json:"id"`
 	ScannerID   string       `json:"scanner_id"`
-	Status      string       `json:"status"`   // pending, processing, completed, failed
+	Status      string       `json:"status"`   // pending, processing, waiting, completed, failed, cancelled, skipped
 	Progress    int          `json:"progress"` // 0-100
 	Parameters  ScanParams   `json:"parameters"`
 	Results     []ScanResult `json:"results"`
 	CreatedAt   time.Time    `json:"created_at"`
 	CompletedAt *time.Time   `json:"completed_at,omitempty"`
 	Error       string       `json:"error,omitempty"`
+	ErrorCode   string       `json:"error_code,omitempty"` // Stable error code, e.g. feeder_empty, paper_jam
+	Owner       string       `json:"owner,omitempty"`      // Client that started the job or owns its schedule or hot folder, e.g. key:frontdesk or jwt:alice
+	Profile     string       `json:"profile,omitempty"`    // Batch scan profile the job was started with
+
+	Prompt      string       `json:"prompt,omitempty"`     // Set while status is "waiting" for the user
+
+	// Edited documents are new jobs that keep the original pages untouched
+	ParentID   string   `json:"parent_id,omitempty"`   // Job this version was derived from
+	SourceJobs []string `json:"source_jobs,omitempty"` // All jobs whose pages were used
+	Version    int      `json:"version,omitempty"`     // 2 for the first edit of a scan, and so on
+
+	ImportedFrom string `json:"imported_from,omitempty"` // Name of the hot folder file the pages came from
+	ScheduleID   string `json:"schedule_id,omitempty"`   // Schedule that started the job
+
+	// Where the job is sent once it completes
+	Email   *EmailRequest  `json:"email,omitempty"`
+	Export  *ExportRequest `json:"export,omitempty"`
+	Outputs []OutputResult `json:"outputs,omitempty"` // Outcome of each destination
+}
+
+// EmailRequest asks for a completed job to be sent by email
+type EmailRequest struct {
+	Profile string   `json:"profile,omitempty"` // Named profile from email.profiles
+	To      []string `json:"to,omitempty"`      // Replaces the profile's recipients
+	Cc      []string `json:"cc,omitempty"`
+	Attach  string   `json:"attach,omitempty"` // pdf (merged document, default) or images
+}
+
+// ExportRequest selects the remote folders a completed job is exported to
+type ExportRequest struct {
+	Profile      string   `json:"profile,omitempty"`      // Named profile from export.profiles
+	Destinations []string `json:"destinations,omitempty"` // Names from export.destinations, added to the profile's
+}
+
+// OutputResult is the outcome of sending a completed job to a destination
+type OutputResult struct {
+	Destination string    `json:"destination"` // email or an export destination name
+	Status      string    `json:"status"`      // sent, failed
+	Detail      string    `json:"detail,omitempty"`
+	TaskIDs     []string  `json:"task_ids,omitempty"` // Processing tasks started by the destination, e.g. Paperless-ngx
+	Error       string    `json:"error,omitempty"`
+	Time        time.Time `json:"time"`
 }
 
 // ScanParams represents scan parameters (based on NAPS2)
@@ -132,6 +174,8 @@ type ScanResult struct {
 	Format     string `json:"format"`
 	Width      int    `json:"width"`
 	Height     int    `json:"height"`
+	Resolution int    `json:"resolution,omitempty"`  // DPI of the stored image
+	StorageURI string `json:"storage_uri,omitempty"` // Archived copy, e.g. s3://bucket/key
 }
 
 // WebSocketMessage represents a message sent via WebSocket
@@ -139,6 +183,8 @@ type WebSocketMessage struct {
 	Type    string      `json:"type"` // job_status, scanner_status, error
 	Payload interface{} `json:"payload"`
 	Time    time.Time   `json:"time"`
+
+	Job *ScanJob `json:"-"` // Job the message is about; only clients allowed to see it receive the message
 }
 
 // BatchScanType represents the type of batch scanning (NAPS2)
@@ -148,6 +194,7 @@ const (
 	BatchScanSingle            BatchScanType = "single"              // Single scan
 	BatchScanMultipleWithPrompt BatchScanType = "multiple_with_prompt" // Multiple scans with user prompt
 	BatchScanMultipleWithDelay  BatchScanType = "multiple_with_delay"  // Multiple scans with delay
+	BatchScanManualDuplex       BatchScanType = "manual_duplex"        // Fronts, flip the stack, backs
 )
 
 // BatchOutputType represents how batch scan results are output (NAPS2)
@@ -173,7 +220,7 @@ const (
 type BatchSettings struct {
 	ProfileDisplayName string `json:"profile_display_name"` // Scan profile name
 
-	ScanType             BatchScanType   `json:"scan_type"`              // Single, MultipleWithPrompt, MultipleWithDelay
+	ScanType             BatchScanType   `json:"scan_type"`              // Single, MultipleWithPrompt, MultipleWithDelay, ManualDuplex
 	ScanCount            int             `json:"scan_count"`             // Number of scans (for MultipleWithDelay)
 	ScanIntervalSeconds  float64         `json:"scan_interval_seconds"`  // Interval between scans (for MultipleWithDelay)
 
@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+// Schedule is a scan run unattended on a cron schedule
+type Schedule struct {
+	ID         string         `json:"id"`
+	Name       string         `json:"name,omitempty"`
+	Cron       string         `json:"cron"` // e.g. "0 22 * * 1-5", "@daily", "@every 2h"; may start with CRON_TZ=Area/City
+	ScannerID  string         `json:"scanner_id"`
+	Parameters ScanParams     `json:"parameters"`
+	Email      *EmailRequest  `json:"email,omitempty"`
+	Export     *ExportRequest `json:"export,omitempty"`
+	Enabled    bool           `json:"enabled"`
+	Source     string         `json:"source"`          // config or api; schedules from the config file are read-only
+	Owner      string         `json:"owner,omitempty"` // Owner of the schedule's jobs: the client that created it, or from the config file
+
+	NextRun *time.Time    `json:"next_run,omitempty"`
+	LastRun *ScheduleRun  `json:"last_run,omitempty"`
+	Runs    []ScheduleRun `json:"runs,omitempty"` // Most recent first
+}
+
+// ScheduleRun is the outcome of one run of a schedule
+type ScheduleRun struct {
+	Time   time.Time `json:"time"`
+	JobID  string    `json:"job_id,omitempty"`
+	Status string    `json:"status"` // completed, failed, cancelled, skipped (feeder empty)
+	Pages  int       `json:"pages"`
+	Error  string    `json:"error,omitempty"`
+}
@@ -10,6 +10,7 @@ class ScannerClient {
      * @param {Object} options - Configuration options
      * @param {boolean} options.autoReconnect - Auto-reconnect WebSocket on disconnect (default: true)
      * @param {number} options.reconnectInterval - WebSocket reconnect interval in ms (default: 3000)
+     * @param {string} options.token - API key or bearer token, if the service requires authentication
      */
     constructor(baseURL, options = {}) {
         this.baseURL = baseURL.replace(/\/$/, ''); // Remove trailing slash
@@ -18,7 +19,8 @@ class ScannerClient {
 
         this.options = {
             autoReconnect: options.autoReconnect !== false,
-            reconnectInterval: options.reconnectInterval || 3000
+            reconnectInterval: options.reconnectInterval || 3000,
+            token: options.token || ''
         };
 
         this.ws = null;
@@ -41,11 +43,12 @@ class ScannerClient {
 
         try {
             const response = await fetch(url, {
+                ...options,
                 headers: {
                     'Content-Type': 'application/json',
+                    ...this._authHeaders(),
                     ...options.headers
-                },
-                ...options
+                }
             });
 
             if (!response.ok) {
@@ -174,6 +177,49 @@ class ScannerClient {
         });
     }
 
+    /**
+     * Get the URL of a scanned page
+     * @param {string} jobId - Job ID
+     * @param {number} pageNumber - 1-based position in job.results
+     * @returns {string} Page URL
+     */
+    getPageUrl(jobId, pageNumber) {
+        return `${this.apiURL}/jobs/${encodeURIComponent(jobId)}/pages/${pageNumber}`;
+    }
+
+    /**
+     * Get the download URL for a whole job
+     * @param {string} jobId - Job ID
+     * @param {string} format - 'zip' (pages + manifest.json), 'pdf' or 'tiff' (default: 'zip')
+     * @returns {string} Download URL
+     */
+    getDownloadUrl(jobId, format = 'zip') {
+        return `${this.apiURL}/jobs/${encodeURIComponent(jobId)}/download?format=${encodeURIComponent(format)}`;
+    }
+
+    /**
+     * Authorization header for the configured API key or token
+     * @private
+     */
+    _authHeaders() {
+        return this.options.token ? { 'Authorization': `Bearer ${this.options.token}` } : {};
+    }
+
+    /**
+     * Download a whole job as a single file
+     * @param {string} jobId - Job ID
+     * @param {string} format - 'zip', 'pdf' or 'tiff' (default: 'zip')
+     * @returns {Promise<ArrayBuffer>} File contents
+     */
+    async downloadJob(jobId, format = 'zip') {
+        const response = await fetch(this.getDownloadUrl(jobId, format), { headers: this._authHeaders() });
+        if (!response.ok) {
+            const error = await response.json().catch(() => ({}));
+            throw new Error(error.error || `Download failed: ${response.statusText}`);
+        }
+        return await response.arrayBuffer();
+    }
+
     /**
      * Check service health
      * @returns {Promise<Object>} Health status
@@ -194,7 +240,10 @@ class ScannerClient {
             }
 
             try {
-                this.ws = new WebSocket(this.wsURL);
+                // Browsers can't set headers on WebSocket requests, so the token is passed as a subprotocol
+                this.ws = this.options.token
+                    ? new WebSocket(this.wsURL, ['bearer', this.options.token])
+                    : new WebSocket(this.wsURL);
 
                 this.ws.onopen = () => {
                     this.wsConnected = true;
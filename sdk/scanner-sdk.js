@@ -295,14 +295,37 @@ class ScannerClient {
     // ==================== Utility Methods ====================
 
     /**
-     * Get file URL for scanned result
-     * @param {string} filePath - File path from scan result
-     * @returns {string} Full URL to file
+     * Get URL of a scanned page
+     * @param {string} jobId - Job ID
+     * @param {number} pageNumber - 1-based position in job.results
+     * @returns {string} Full URL to the page file
+     */
+    getPageUrl(jobId, pageNumber) {
+        return `${this.apiBase}/jobs/${encodeURIComponent(jobId)}/pages/${pageNumber}`;
+    }
+
+    /**
+     * Get download URL for a whole job
+     * @param {string} jobId - Job ID
+     * @param {string} format - 'zip' (pages + manifest.json), 'pdf' or 'tiff' (default: 'zip')
+     * @returns {string} Full URL to the download
+     */
+    getDownloadUrl(jobId, format = 'zip') {
+        return `${this.apiBase}/jobs/${encodeURIComponent(jobId)}/download?format=${encodeURIComponent(format)}`;
+    }
+
+    /**
+     * Download a whole job in the browser (one click)
+     * @param {string} jobId - Job ID
+     * @param {string} format - 'zip', 'pdf' or 'tiff' (default: 'zip')
      */
-    getFileUrl(filePath) {
-        // Remove leading slash if present
-        const cleanPath = filePath.replace(/^\//, '');
-        return `${this.apiBase}/files/${cleanPath}`;
+    downloadJob(jobId, format = 'zip') {
+        const link = document.createElement('a');
+        link.href = this.getDownloadUrl(jobId, format);
+        link.download = '';
+        document.body.appendChild(link);
+        link.click();
+        link.remove();
     }
 
     /**